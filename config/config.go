@@ -2,10 +2,16 @@ package config
 
 import (
 	"github.com/kelseyhightower/envconfig"
+	"github.com/pkg/errors"
 	"time"
 )
 
+var (
+	ErrInvalidConfig = errors.New("Failed Invalid Config")
+)
+
 type BBFTConfig struct {
+	ChainId                               string `default:"bbft"`
 	Host                                  string `default:"localhost"`
 	Port                                  string `default:"50053"`
 	PublicKey                             []byte
@@ -18,22 +24,98 @@ type BBFTConfig struct {
 	ReceiveVoteVoteMessagePoolLimits      int `default:"5000"`
 	ReceivePreCommitVoteMessagePoolLimits int `default:"500"`
 	PreCommitFinderLimits                 int `default:"500"`
+	EvidencePoolLimits                    int `default:"1000"`
+	EvidenceDetectorLimits                int `default:"5000"`
 
 	// Consensus Parameter
 	NumberOfBlockHasTransactions int           `default:"200"`
+	NumberOfBlockHasEvidences    int           `default:"10"`
 	AllowedConnectDelayTime      time.Duration `default:"500ms"`
 	ProposeMaxCalcTime           time.Duration `default:"500ms"`
 	VoteMaxCalcTime              time.Duration `default:"1s"`
 	PreCommitMaxCalcTime         time.Duration `default:"200ms"`
 	CommitMaxCalcTime            time.Duration `default:"500ms"`
+	// Proposal の CreatedTime と自分の Round の CommitTime のずれの許容値。半分を超えると警告を出す
+	MaxClockSkew time.Duration `default:"1s"`
+	// Block と Transaction の大きさ (byte) の上限。MaxBlockBytes は gRPC が受け取れるメッセージの大きさ (4MB) より小さくする
+	MaxBlockBytes int `default:"3145728"`
+	MaxTxBytes    int `default:"1048576"`
+	// Block に入る Transaction の cost の合計の上限。0 のときは制限しない
+	MaxBlockCost int64 `default:"0"`
+	// BlockValidate で Transaction の署名を並列に検証する goroutine の数。0 のときは GOMAXPROCS
+	VerifyWorkers int `default:"0"`
+	// 署名の検証に成功した Transaction を覚えておく数。0 のときは覚えず毎回検証する
+	VerifiedTxCacheSize int `default:"65536"`
+	// Round が進むごとに各 Phase の MaxCalcTime を伸ばす方法 : none | linear | exponential
+	RoundBackoff        string        `default:"linear"`
+	RoundBackoffMaxTime time.Duration `default:"30s"`
+	// リーダーの選び方 : round_robin | hash | stake
+	LeaderSelector string `default:"hash"`
+	// stake のときの各 Peer の重み。key は hex encode した Pubkey, 指定の無い Peer の重みは voting power
+	LeaderStakes map[string]int64
+	// 起動時の各 Peer の voting power。key は hex encode した Pubkey, 指定の無い Peer の voting power は 1
+	VotingPowers map[string]int64
+	// pbft で CreateEmptyBlocks が false のとき、round 0 の Leader は Transaction が届くまで最大 EmptyBlockInterval 待ってから提案する
+	// 待っても届かないときは空の Block を提案する。Follower はその分だけ Propose Phase を長く待つ
+	CreateEmptyBlocks  bool          `default:"true"`
+	EmptyBlockInterval time.Duration `default:"30s"`
+	// pbft で前の Block の CreatedTime から次の Block の CreatedTime までの目標の間隔。Round が短いときは Round の開始を遅らせる
+	TargetBlockTime time.Duration `default:"0s"`
+	// pbft で AdaptiveTimeout が true のとき、AllowedConnectDelayTime と各 Phase の MaxCalcTime の代わりに
+	// 直近 AdaptiveTimeoutWindow 個の Block に Leader が記録した時間の中央値を AdaptiveTimeoutMarginPercent % に伸ばして使う
+	// 伸ばした時間は AdaptiveMin/Max の範囲に収める。記録の無いものは設定の値をそのまま使う
+	AdaptiveTimeout              bool          `default:"false"`
+	AdaptiveTimeoutWindow        int           `default:"20"`
+	AdaptiveTimeoutMarginPercent int           `default:"200"`
+	AdaptiveMinConnectDelayTime  time.Duration `default:"10ms"`
+	AdaptiveMaxConnectDelayTime  time.Duration `default:"2s"`
+	AdaptiveMinCalcTime          time.Duration `default:"50ms"`
+	AdaptiveMaxCalcTime          time.Duration `default:"5s"`
+	// 各 Peer に Ping を送って RTT を測る間隔
+	PingInterval time.Duration `default:"5s"`
+	// height H で Commit された ValidatorUpdate は H + ValidatorUpdateDelay から有効になる
+	// H の Block を作るときには H の Peer の集合が決まっていなければならないので、1 以上にする
+	ValidatorUpdateDelay int64 `default:"2"`
+
+	// 合意の方法 : pbft | hotstuff
+	// hotstuff は ValidatorUpdate と Evidence を含む Block を提案も投票もしない
+	ConsensusEngine string `default:"pbft"`
+	// hotstuff で覚えておく Proposal の範囲。今の view から HotStuffWindow 個先の view まで、次の Height から HotStuffWindow 個先の Height まで
+	HotStuffWindow int `default:"100"`
+	// pbft で送った・受け取ったメッセージと Round の移り変わり、hotstuff で送った投票を記録する WAL のファイル。空のときはファイルに書かない
+	WALPath string `default:"data/consensus.wal"`
+
+	// Block Sync Parameter
+	BlockSyncBatchSize int `default:"100"`
+
+	// EventBus の購読者ごとに溜めておける Event の数。溢れた購読者は購読を終わらせる
+	EventSubscriptionBufferSize int `default:"1000"`
 
 	Demo Demo
 }
 
 var config BBFTConfig
 
+// Init は環境変数から設定を読み、正しくない設定のときは起動させない
 func Init() {
 	envconfig.MustProcess("bbft", &config)
+	if err := config.Validate(); err != nil {
+		panic(err)
+	}
+}
+
+// Validate は設定の値が合意を壊さないかを確かめる
+func (c *BBFTConfig) Validate() error {
+	if c.ValidatorUpdateDelay < 1 {
+		return errors.Wrapf(ErrInvalidConfig, "ValidatorUpdateDelay must be >= 1: %d", c.ValidatorUpdateDelay)
+	}
+	// voting power の和が 0 以下になると、署名が無くても 2/3 以上の voting power が集まったことになる
+	for pubkey, power := range c.VotingPowers {
+		if power <= 0 {
+			return errors.Wrapf(ErrInvalidConfig, "VotingPowers must be > 0, pubkey: %s, power: %d", pubkey, power)
+		}
+	}
+	return nil
 }
 
 func GetConfig() *BBFTConfig {
@@ -0,0 +1,31 @@
+package config_test
+
+import (
+	"github.com/pkg/errors"
+	. "github.com/satellitex/bbft/config"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBBFTConfig_Validate(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		conf BBFTConfig
+		err  error
+	}{
+		{"success case", BBFTConfig{ValidatorUpdateDelay: 1, VotingPowers: map[string]int64{"aa": 1, "bb": 5}}, nil},
+		{"success no voting powers", BBFTConfig{ValidatorUpdateDelay: 2}, nil},
+		{"failed ValidatorUpdateDelay is 0", BBFTConfig{ValidatorUpdateDelay: 0}, ErrInvalidConfig},
+		{"failed zero voting power", BBFTConfig{ValidatorUpdateDelay: 2, VotingPowers: map[string]int64{"aa": 1, "bb": 0}}, ErrInvalidConfig},
+		{"failed negative voting power", BBFTConfig{ValidatorUpdateDelay: 2, VotingPowers: map[string]int64{"aa": -1}}, ErrInvalidConfig},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.conf.Validate()
+			if c.err == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, errors.Cause(err), c.err.Error())
+		})
+	}
+}
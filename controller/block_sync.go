@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"github.com/pkg/errors"
+	"github.com/satellitex/bbft/convertor"
+	"github.com/satellitex/bbft/proto"
+	"github.com/satellitex/bbft/usecase"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type BlockSyncController struct {
+	receiver usecase.BlockSyncReceiver
+	author   *convertor.Author
+}
+
+func NewBlockSyncController(receiver usecase.BlockSyncReceiver, author *convertor.Author) *BlockSyncController {
+	return &BlockSyncController{
+		receiver: receiver,
+		author:   author,
+	}
+}
+
+func (c *BlockSyncController) GetBlocks(ctx context.Context, req *bbft.BlockSyncRequest) (*bbft.BlockSyncResponse, error) {
+	ctx, err := c.author.ProtoAurhorize(ctx, req)
+	if err != nil { // Unauthenticated ( code = 16 )
+		return nil, err
+	}
+
+	blocks, certs, err := c.receiver.GetBlocks(req.GetFromHeight(), req.GetToHeight())
+	if err != nil {
+		cause := errors.Cause(err)
+		if cause == usecase.ErrBlockSyncInvalidRange {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	res := &bbft.BlockSyncResponse{
+		Blocks:       make([]*bbft.Block, 0, len(blocks)),
+		Certificates: make([]*bbft.CommitCertificate, 0, len(certs)),
+	}
+	for _, block := range blocks {
+		b, ok := block.(*convertor.Block)
+		if !ok {
+			return nil, status.Errorf(codes.Internal, "Can not cast Block model: %#v.", block)
+		}
+		res.Blocks = append(res.Blocks, b.Block)
+	}
+	for _, cert := range certs {
+		// genesis block has no CommitCertificate
+		if cert == nil {
+			res.Certificates = append(res.Certificates, &bbft.CommitCertificate{})
+			continue
+		}
+		ct, ok := cert.(*convertor.CommitCertificate)
+		if !ok {
+			return nil, status.Errorf(codes.Internal, "Can not cast CommitCertificate model: %#v.", cert)
+		}
+		res.Certificates = append(res.Certificates, ct.CommitCertificate)
+	}
+	return res, nil
+}
@@ -0,0 +1,109 @@
+package controller_test
+
+import (
+	"context"
+	"github.com/satellitex/bbft/config"
+	. "github.com/satellitex/bbft/controller"
+	"github.com/satellitex/bbft/convertor"
+	"github.com/satellitex/bbft/dba"
+	"github.com/satellitex/bbft/proto"
+	. "github.com/satellitex/bbft/test_utils"
+	"github.com/satellitex/bbft/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"testing"
+)
+
+func NewTestBlockSyncController(t *testing.T) (*config.BBFTConfig, dba.BlockChain, *BlockSyncController) {
+	testConfig := GetTestConfig()
+	ps := RandomPeerService(t, 3)
+	ps.AddPeer(RandomPeerFromConf(testConfig))
+
+	bc := dba.NewBlockChainOnMemory()
+	bc.Commit(RandomCommitableBlock(t, bc), nil)
+	for i := 1; i < 5; i++ {
+		block := RandomCommitableBlock(t, bc)
+		bc.Commit(block, RandomCommitCertificate(t, block, ps.GetPeers()))
+	}
+
+	receiver := usecase.NewBlockSyncReceiverUsecase(testConfig, bc)
+	author := convertor.NewAuthor(ps)
+	return testConfig, bc, NewBlockSyncController(receiver, author)
+}
+
+func TestBlockSyncController_GetBlocks(t *testing.T) {
+	conf, bc, ctrl := NewTestBlockSyncController(t)
+
+	validReq := &bbft.BlockSyncRequest{FromHeight: 1, ToHeight: 3}
+	overReq := &bbft.BlockSyncRequest{FromHeight: 3, ToHeight: 10}
+	invalidReq := &bbft.BlockSyncRequest{FromHeight: 3, ToHeight: 1}
+
+	evilConf := *conf
+	pk, sk := convertor.NewKeyPair()
+	evilConf.PublicKey = pk
+	evilConf.SecretKey = sk
+
+	for _, c := range []struct {
+		name     string
+		ctx      context.Context
+		req      *bbft.BlockSyncRequest
+		expected []int64
+		code     codes.Code
+	}{
+		{
+			"success case",
+			ValidContext(t, conf, validReq),
+			validReq,
+			[]int64{1, 2, 3},
+			codes.OK,
+		},
+		{
+			"success case, over top height",
+			ValidContext(t, conf, overReq),
+			overReq,
+			[]int64{3, 4},
+			codes.OK,
+		},
+		{
+			"failed case, unauthenticated context",
+			context.TODO(),
+			validReq,
+			nil,
+			codes.Unauthenticated,
+		},
+		{
+			"failed case, authenticated but not peer",
+			ValidContext(t, &evilConf, validReq),
+			validReq,
+			nil,
+			codes.PermissionDenied,
+		},
+		{
+			"failed case, invalid range",
+			ValidContext(t, conf, invalidReq),
+			invalidReq,
+			nil,
+			codes.InvalidArgument,
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			res, err := ctrl.GetBlocks(c.ctx, c.req)
+			if c.code != codes.OK {
+				ValidateStatusCode(t, err, c.code)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, len(c.expected), len(res.Blocks))
+			require.Equal(t, len(c.expected), len(res.Certificates))
+			for id, height := range c.expected {
+				block, ok := bc.GetBlock(height)
+				require.True(t, ok)
+				assert.Equal(t, block.(*convertor.Block).Block, res.Blocks[id])
+				cert, ok := bc.GetCommitCertificate(height)
+				require.True(t, ok)
+				assert.Equal(t, cert.(*convertor.CommitCertificate).CommitCertificate, res.Certificates[id])
+			}
+		})
+	}
+}
@@ -31,6 +31,8 @@ func (c *ClientGateController) Write(ctx context.Context, tx *bbft.Transaction)
 		cause := errors.Cause(err)
 		if cause == model.ErrStatelessTxValidate {
 			return nil, status.Error(codes.InvalidArgument, err.Error())
+		} else if cause == usecase.ErrHotStuffNotSupported {
+			return nil, status.Error(codes.Unimplemented, err.Error())
 		} else {
 			return nil, status.Error(codes.Internal, err.Error())
 		}
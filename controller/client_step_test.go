@@ -17,7 +17,7 @@ func NewTestClientGateController(t *testing.T) *ClientGateController {
 	ps := dba.NewPeerServiceOnMemory()
 	sender := convertor.NewMockConsensusSender()
 	receiver := usecase.NewClientGateReceiverUsecase(
-		convertor.NewStatelessValidator(),
+		convertor.NewStatelessValidator(GetTestConfig()),
 		sender,
 	)
 	author := convertor.NewAuthor(ps)
@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"github.com/satellitex/bbft/convertor"
+	"github.com/satellitex/bbft/dba"
+	"github.com/satellitex/bbft/model"
+	"github.com/satellitex/bbft/proto"
+	"github.com/satellitex/bbft/usecase"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type ConsensusStateController struct {
+	reader usecase.ConsensusStateReader
+	ps     dba.PeerService
+	author *convertor.Author
+}
+
+func NewConsensusStateController(reader usecase.ConsensusStateReader, ps dba.PeerService, author *convertor.Author) *ConsensusStateController {
+	return &ConsensusStateController{
+		reader: reader,
+		ps:     ps,
+		author: author,
+	}
+}
+
+func (c *ConsensusStateController) GetConsensusState(ctx context.Context, req *bbft.ConsensusStateRequest) (*bbft.ConsensusStateResponse, error) {
+	ctx, err := c.author.ProtoAurhorize(ctx, req)
+	if err != nil { // Unauthenticated ( code = 16 )
+		return nil, err
+	}
+
+	state := c.reader.GetConsensusState()
+	res := &bbft.ConsensusStateResponse{
+		Height:           state.Height,
+		Round:            state.Round,
+		Phase:            state.Phase,
+		ProposeTimeOut:   int64(state.ProposeTimeOut),
+		VoteTimeOut:      int64(state.VoteTimeOut),
+		PreCommitTimeOut: int64(state.PreCommitTimeOut),
+		RoundCommitTime:  int64(state.RoundCommitTime),
+		LockedRound:      -1,
+		Votes:            c.validatorVotes(state.Height, state.Votes),
+		PreCommits:       c.validatorVotes(state.Height, state.PreCommits),
+	}
+	if state.Leader != nil {
+		res.LeaderPubkey = state.Leader.GetPubkey()
+		res.LeaderAddress = state.Leader.GetAddress()
+	}
+	if state.LockedProposal != nil {
+		hash, err := state.LockedProposal.GetBlock().GetHash()
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		res.Locked = true
+		res.LockedBlockHash = hash
+		res.LockedRound = state.LockedProposal.GetRound()
+	}
+	return res, nil
+}
+
+func (c *ConsensusStateController) validatorVotes(height int64, votes []model.VoteMessage) []*bbft.ValidatorVote {
+	ret := make([]*bbft.ValidatorVote, 0, len(votes))
+	for _, vote := range votes {
+		v := &bbft.ValidatorVote{
+			Pubkey:        vote.GetSignature().GetPubkey(),
+			BlockHash:     vote.GetBlockHash(),
+			Reject:        vote.IsReject(),
+			RejectMessage: vote.GetRejectMessage(),
+		}
+		if peer, ok := c.ps.AtHeight(height).GetPeer(v.Pubkey); ok {
+			v.Address = peer.GetAddress()
+		}
+		ret = append(ret, v)
+	}
+	return ret
+}
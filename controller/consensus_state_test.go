@@ -0,0 +1,104 @@
+package controller_test
+
+import (
+	"context"
+	. "github.com/satellitex/bbft/controller"
+	"github.com/satellitex/bbft/convertor"
+	"github.com/satellitex/bbft/model"
+	"github.com/satellitex/bbft/proto"
+	. "github.com/satellitex/bbft/test_utils"
+	"github.com/satellitex/bbft/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"testing"
+	"time"
+)
+
+type stubConsensusStateReader struct {
+	state *usecase.ConsensusState
+}
+
+func (r *stubConsensusStateReader) GetConsensusState() *usecase.ConsensusState {
+	return r.state
+}
+
+func TestConsensusStateController_GetConsensusState(t *testing.T) {
+	conf := GetTestConfig()
+	ps := RandomPeerService(t, 3)
+	ps.AddPeer(RandomPeerFromConf(conf))
+	peers := ps.GetPeers()
+
+	proposal := RandomProposalWithHeightRound(t, 3, 1)
+	vote := RandomVoteMessageFromPeerWithBlock(t, peers[0], proposal.GetBlock())
+	reject := convertor.NewModelFactory().NewRejectVoteMessage(conf.ChainId, 3, 1, nil, "Not Found Proposal")
+	ValidSign(t, reject)
+	preCommit := RandomPreCommitFromPeerWithBlock(t, peers[1], proposal.GetBlock())
+
+	reader := &stubConsensusStateReader{
+		&usecase.ConsensusState{
+			Height:           3,
+			Round:            1,
+			Phase:            usecase.PhaseVote,
+			ProposeTimeOut:   time.Duration(10),
+			VoteTimeOut:      time.Duration(20),
+			PreCommitTimeOut: time.Duration(30),
+			RoundCommitTime:  time.Duration(40),
+			Leader:           peers[2],
+			LockedProposal:   proposal,
+			Votes:            []model.VoteMessage{vote, reject},
+			PreCommits:       []model.VoteMessage{preCommit},
+		},
+	}
+	ctrl := NewConsensusStateController(reader, ps, convertor.NewAuthor(ps))
+	req := &bbft.ConsensusStateRequest{}
+
+	evilConf := *conf
+	evilConf.PublicKey, evilConf.SecretKey = convertor.NewKeyPair()
+
+	t.Run("success case", func(t *testing.T) {
+		res, err := ctrl.GetConsensusState(ValidContext(t, conf, req), req)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), res.Height)
+		assert.Equal(t, int32(1), res.Round)
+		assert.Equal(t, usecase.PhaseVote, res.Phase)
+		assert.Equal(t, []int64{10, 20, 30, 40}, []int64{res.ProposeTimeOut, res.VoteTimeOut, res.PreCommitTimeOut, res.RoundCommitTime})
+		assert.Equal(t, peers[2].GetPubkey(), res.LeaderPubkey)
+		assert.Equal(t, peers[2].GetAddress(), res.LeaderAddress)
+		assert.True(t, res.Locked)
+		assert.Equal(t, GetHash(t, proposal.GetBlock()), res.LockedBlockHash)
+		assert.Equal(t, int32(1), res.LockedRound)
+
+		require.Len(t, res.Votes, 2)
+		assert.Equal(t, peers[0].GetPubkey(), res.Votes[0].Pubkey)
+		assert.Equal(t, peers[0].GetAddress(), res.Votes[0].Address)
+		assert.Equal(t, GetHash(t, proposal.GetBlock()), res.Votes[0].BlockHash)
+		assert.False(t, res.Votes[0].Reject)
+		assert.True(t, res.Votes[1].Reject)
+		assert.Equal(t, "Not Found Proposal", res.Votes[1].RejectMessage)
+		assert.Empty(t, res.Votes[1].BlockHash)
+
+		require.Len(t, res.PreCommits, 1)
+		assert.Equal(t, peers[1].GetAddress(), res.PreCommits[0].Address)
+	})
+
+	t.Run("success case, not locked", func(t *testing.T) {
+		reader.state = &usecase.ConsensusState{Round: -1}
+		res, err := ctrl.GetConsensusState(ValidContext(t, conf, req), req)
+		require.NoError(t, err)
+		assert.False(t, res.Locked)
+		assert.Equal(t, int32(-1), res.LockedRound)
+		assert.Empty(t, res.LeaderPubkey)
+		assert.Empty(t, res.Votes)
+	})
+
+	t.Run("failed case, unauthenticated context", func(t *testing.T) {
+		_, err := ctrl.GetConsensusState(context.TODO(), req)
+		ValidateStatusCode(t, err, codes.Unauthenticated)
+	})
+
+	t.Run("failed case, authenticated but not peer", func(t *testing.T) {
+		_, err := ctrl.GetConsensusState(ValidContext(t, &evilConf, req), req)
+		ValidateStatusCode(t, err, codes.PermissionDenied)
+	})
+}
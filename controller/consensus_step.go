@@ -37,6 +37,8 @@ func (c *ConsensusController) Propagate(ctx context.Context, tx *bbft.Transactio
 			return nil, status.Error(codes.InvalidArgument, err.Error())
 		} else if cause == usecase.ErrAlradyReceivedSameObject {
 			return nil, status.Error(codes.AlreadyExists, err.Error())
+		} else if cause == usecase.ErrHotStuffNotSupported {
+			return nil, status.Error(codes.Unimplemented, err.Error())
 		}
 		return nil, err
 	}
@@ -55,8 +57,11 @@ func (c *ConsensusController) Propose(ctx context.Context, p *bbft.Proposal) (*b
 		cause := errors.Cause(err)
 		if cause == model.ErrInvalidProposal ||
 			cause == model.ErrStatelessBlockValidate ||
-			cause == usecase.ErrVerifyOnlyLeader {
+			cause == usecase.ErrVerifyOnlyLeader ||
+			cause == usecase.ErrDetectEquivocation {
 			return nil, status.Error(codes.InvalidArgument, err.Error())
+		} else if cause == usecase.ErrFutureHeightProposal {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
 		} else if cause == usecase.ErrAlradyReceivedSameObject {
 			return nil, status.Error(codes.AlreadyExists, err.Error())
 		}
@@ -99,7 +104,8 @@ func (c *ConsensusController) PreCommit(ctx context.Context, v *bbft.VoteMessage
 		cause := errors.Cause(err)
 		if cause == model.ErrInvalidVoteMessage ||
 			cause == model.ErrVoteMessageVerify ||
-			cause == usecase.ErrPreCommitNotInPeerService {
+			cause == usecase.ErrPreCommitNotInPeerService ||
+			cause == usecase.ErrDetectEquivocation {
 			return nil, status.Error(codes.InvalidArgument, err.Error())
 		} else if cause == usecase.ErrAlradyReceivedSameObject {
 			return nil, status.Error(codes.AlreadyExists, err.Error())
@@ -108,3 +114,34 @@ func (c *ConsensusController) PreCommit(ctx context.Context, v *bbft.VoteMessage
 	}
 	return &bbft.ConsensusResponse{}, nil
 }
+
+func (c *ConsensusController) Evidence(ctx context.Context, e *bbft.Evidence) (*bbft.ConsensusResponse, error) {
+	ctx, err := c.author.ProtoAurhorize(ctx, e)
+	if err != nil { // Unauthenticated ( code = 16 )
+		return nil, err
+	}
+
+	evidence := &convertor.Evidence{e}
+	err = c.receiver.Evidence(evidence)
+	if err != nil {
+		cause := errors.Cause(err)
+		if cause == model.ErrInvalidEvidence ||
+			cause == model.ErrEvidenceValidate {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		} else if cause == usecase.ErrAlradyReceivedSameObject {
+			return nil, status.Error(codes.AlreadyExists, err.Error())
+		} else if cause == usecase.ErrHotStuffNotSupported {
+			return nil, status.Error(codes.Unimplemented, err.Error())
+		}
+		return nil, err
+	}
+	return &bbft.ConsensusResponse{}, nil
+}
+
+func (c *ConsensusController) Ping(ctx context.Context, p *bbft.Ping) (*bbft.Ping, error) {
+	ctx, err := c.author.ProtoAurhorize(ctx, p)
+	if err != nil { // Unauthenticated ( code = 16 )
+		return nil, err
+	}
+	return &bbft.Ping{SentTime: p.GetSentTime()}, nil
+}
@@ -7,6 +7,7 @@ import (
 	. "github.com/satellitex/bbft/controller"
 	"github.com/satellitex/bbft/convertor"
 	"github.com/satellitex/bbft/dba"
+	"github.com/satellitex/bbft/model"
 	"github.com/satellitex/bbft/proto"
 	. "github.com/satellitex/bbft/test_utils"
 	"github.com/satellitex/bbft/usecase"
@@ -16,7 +17,7 @@ import (
 	"testing"
 )
 
-func NewTestConsensusController(t *testing.T) (*config.BBFTConfig, dba.PeerService, *ConsensusController) {
+func NewTestConsensusController(t *testing.T) (*config.BBFTConfig, dba.PeerService, usecase.LeaderSelector, *ConsensusController) {
 
 	testConfig := GetTestConfig()
 	queue := dba.NewProposalTxQueueOnMemory(testConfig)
@@ -26,25 +27,28 @@ func NewTestConsensusController(t *testing.T) (*config.BBFTConfig, dba.PeerServi
 
 	// bc is height = 1
 	bc := dba.NewBlockChainOnMemory()
-	bc.Commit(RandomCommitableBlock(t, bc))
+	bc.Commit(RandomCommitableBlock(t, bc), nil)
 
-	slv := convertor.NewStatelessValidator()
+	slv := convertor.NewStatelessValidator(testConfig)
 	sender := convertor.NewMockConsensusSender()
+	syncer := usecase.NewBlockSyncUsecase(testConfig, bc, ps, slv, convertor.NewStatefulValidator(testConfig, bc, ps, usecase.NewLeaderSelector(testConfig, ps, bc)), convertor.NewCommitCertificateValidator(testConfig, ps), convertor.NewMockBlockSyncSender(bc))
 	receivChan := usecase.NewReceiveChannel(testConfig)
-	receiver := usecase.NewConsensusReceiverUsecase(queue, ps, lock, pool, bc, slv, sender, receivChan)
+	selector := usecase.NewLeaderSelector(testConfig, ps, bc)
+	receiver := usecase.NewConsensusReceiverUsecase(testConfig, queue, ps, selector, lock, pool, dba.NewEvidencePoolOnMemory(testConfig), bc, slv,
+		convertor.NewEvidenceValidator(testConfig, ps), sender, syncer, usecase.NewEvidenceDetector(testConfig, convertor.NewModelFactory()), usecase.NewEventBusOnMemory(testConfig), receivChan)
 
 	author := convertor.NewAuthor(ps)
 
 	// add peer this peer
 	ps.AddPeer(RandomPeerFromConf(testConfig))
 
-	return testConfig, ps, NewConsensusController(receiver, author)
+	return testConfig, ps, selector, NewConsensusController(receiver, author)
 
 }
 
 func TestConsensusController_Propagate(t *testing.T) {
 
-	conf, _, ctrl := NewTestConsensusController(t)
+	conf, _, _, ctrl := NewTestConsensusController(t)
 
 	validTx := RandomValidTx(t).(*convertor.Transaction).Transaction
 	inValidTx := RandomInvalidTx(t).(*convertor.Transaction).Transaction
@@ -111,19 +115,19 @@ func TestConsensusController_Propagate(t *testing.T) {
 
 func TestConsensusController_Propose(t *testing.T) {
 
-	conf, ps, ctrl := NewTestConsensusController(t)
+	conf, ps, selector, ctrl := NewTestConsensusController(t)
 
 	leaderId := func() int32 {
-		for i, p := range ps.GetPermutationPeers(1) {
-			if bytes.Equal(conf.PublicKey, p.GetPubkey()) {
-				return int32(i)
+		for round := int32(0); round < int32(ps.Size()); round++ {
+			if p, ok := selector.GetLeader(1, round); ok && bytes.Equal(conf.PublicKey, p.GetPubkey()) {
+				return round
 			}
 		}
 		return -1
 	}()
 	require.NotEqual(t, -1, leaderId)
 
-	leader := ps.GetPermutationPeers(1)[leaderId]
+	leader, _ := selector.GetLeader(1, leaderId)
 	validProposal := RandomProposalWithPeer(t, 1, leaderId, leader).(*convertor.Proposal).Proposal
 	unLeaderProposal := RandomProposalWithHeightRound(t, 1, leaderId).(*convertor.Proposal).Proposal
 	invalidProposal := RandomInvalidProposalWithRound(t, 1, leaderId).(*convertor.Proposal).Proposal
@@ -196,7 +200,7 @@ func TestConsensusController_Propose(t *testing.T) {
 
 func TestConsensusController_Vote(t *testing.T) {
 
-	conf, ps, ctrl := NewTestConsensusController(t)
+	conf, ps, _, ctrl := NewTestConsensusController(t)
 
 	validVote := RandomVoteMessageFromPeer(t, ps.GetPeers()[0]).(*convertor.VoteMessage).VoteMessage
 	unPeerValidVote := RandomVoteMessage(t).(*convertor.VoteMessage).VoteMessage
@@ -263,10 +267,10 @@ func TestConsensusController_Vote(t *testing.T) {
 
 func TestConsensusController_PreCommit(t *testing.T) {
 
-	conf, ps, ctrl := NewTestConsensusController(t)
+	conf, ps, _, ctrl := NewTestConsensusController(t)
 
-	validVote := RandomVoteMessageFromPeer(t, ps.GetPeers()[0]).(*convertor.VoteMessage).VoteMessage
-	unPeerValidVote := RandomVoteMessage(t).(*convertor.VoteMessage).VoteMessage
+	validVote := RandomPreCommitFromPeer(t, ps.GetPeers()[0]).(*convertor.VoteMessage).VoteMessage
+	unPeerValidVote := RandomPreCommit(t).(*convertor.VoteMessage).VoteMessage
 
 	evilConf := *conf
 	pk, sk := convertor.NewKeyPair()
@@ -327,3 +331,146 @@ func TestConsensusController_PreCommit(t *testing.T) {
 	}
 
 }
+
+func TestConsensusController_Evidence(t *testing.T) {
+
+	conf, ps, _, ctrl := NewTestConsensusController(t)
+
+	reporter := RandomPeerFromConf(conf)
+	validEvidence := DuplicatePreCommitEvidence(t, ps.GetPeers()[0], reporter, 1, 0).(*convertor.Evidence).Evidence
+	unPeerEvidence := DuplicatePreCommitEvidence(t, RandomPeerWithPriv(), reporter, 1, 0).(*convertor.Evidence).Evidence
+
+	evilConf := *conf
+	pk, sk := convertor.NewKeyPair()
+	evilConf.PublicKey = pk
+	evilConf.SecretKey = sk
+
+	for _, c := range []struct {
+		name     string
+		ctx      context.Context
+		evidence *bbft.Evidence
+		code     codes.Code
+	}{
+		{
+			"success case",
+			ValidContext(t, conf, validEvidence),
+			validEvidence,
+			codes.OK,
+		},
+		{
+			"failed case, unauthenticated context",
+			context.TODO(),
+			validEvidence,
+			codes.Unauthenticated,
+		},
+		{
+			"failed case, authenticated but not peer",
+			ValidContext(t, &evilConf, validEvidence),
+			validEvidence,
+			codes.PermissionDenied,
+		},
+		{
+			"failed case, offender is not peer",
+			ValidContext(t, conf, unPeerEvidence),
+			unPeerEvidence,
+			codes.InvalidArgument,
+		},
+		{
+			"failed case, nil",
+			ValidContext(t, conf, validEvidence),
+			nil,
+			codes.Unauthenticated,
+		},
+		{
+			"failed case, duplicate sent",
+			ValidContext(t, conf, validEvidence),
+			validEvidence,
+			codes.AlreadyExists,
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := ctrl.Evidence(c.ctx, c.evidence)
+			if c.code != codes.OK {
+				ValidateStatusCode(t, err, c.code)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+}
+
+func TestConsensusController_HotStuffNotSupported(t *testing.T) {
+	conf := GetTestConfig()
+	ps := RandomPeerService(t, 3)
+	bc := dba.NewBlockChainOnMemory()
+	bc.Commit(RandomCommitableBlock(t, bc), nil)
+	receiver := usecase.NewHotStuffReceiverUsecase(conf, dba.NewProposalTxQueueOnMemory(conf), ps, usecase.NewRoundRobinLeaderSelector(ps),
+		dba.NewReceiverPoolOnMemory(conf), dba.NewEvidencePoolOnMemory(conf), bc, convertor.NewStatelessValidator(conf),
+		convertor.NewEvidenceValidator(conf, ps), convertor.NewMockConsensusSender(), usecase.NewReceiveChannel(conf))
+	ps.AddPeer(RandomPeerFromConf(conf))
+	ctrl := NewConsensusController(receiver, convertor.NewAuthor(ps))
+
+	t.Run("failed case, validator update tx", func(t *testing.T) {
+		added := RandomPeerWithPriv()
+		update := convertor.NewModelFactory().NewValidatorUpdate(model.AddValidator, added.GetAddress(), added.GetPubkey(), nil, 1)
+		tx := ValidatorUpdateTx(t, update, ps.GetPeers()).(*convertor.Transaction).Transaction
+		_, err := ctrl.Propagate(ValidContext(t, conf, tx), tx)
+		ValidateStatusCode(t, err, codes.Unimplemented)
+	})
+
+	t.Run("failed case, evidence", func(t *testing.T) {
+		evidence := DuplicatePreCommitEvidence(t, ps.GetPeers()[0], RandomPeerFromConf(conf), 1, 0).(*convertor.Evidence).Evidence
+		_, err := ctrl.Evidence(ValidContext(t, conf, evidence), evidence)
+		ValidateStatusCode(t, err, codes.Unimplemented)
+	})
+}
+
+func TestConsensusController_Ping(t *testing.T) {
+
+	conf, _, _, ctrl := NewTestConsensusController(t)
+
+	ping := &bbft.Ping{SentTime: 12345}
+
+	evilConf := *conf
+	pk, sk := convertor.NewKeyPair()
+	evilConf.PublicKey = pk
+	evilConf.SecretKey = sk
+
+	for _, c := range []struct {
+		name string
+		ctx  context.Context
+		ping *bbft.Ping
+		code codes.Code
+	}{
+		{
+			"success case",
+			ValidContext(t, conf, ping),
+			ping,
+			codes.OK,
+		},
+		{
+			"failed case, unauthenticated context",
+			context.TODO(),
+			ping,
+			codes.Unauthenticated,
+		},
+		{
+			"failed case, authenticated but not peer",
+			ValidContext(t, &evilConf, ping),
+			ping,
+			codes.PermissionDenied,
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			res, err := ctrl.Ping(c.ctx, c.ping)
+			if c.code != codes.OK {
+				ValidateStatusCode(t, err, c.code)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, ping.GetSentTime(), res.GetSentTime())
+			}
+		})
+	}
+
+}
@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"github.com/pkg/errors"
+	"github.com/satellitex/bbft/convertor"
+	"github.com/satellitex/bbft/proto"
+	"github.com/satellitex/bbft/usecase"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type EventController struct {
+	bus usecase.EventBus
+}
+
+func NewEventController(bus usecase.EventBus) *EventController {
+	return &EventController{
+		bus: bus,
+	}
+}
+
+func (c *EventController) Subscribe(req *bbft.SubscribeRequest, stream bbft.EventGate_SubscribeServer) error {
+	filter := &usecase.EventFilter{
+		Types:      make([]usecase.EventType, 0, len(req.GetTypes())),
+		TxHash:     req.GetTxHash(),
+		FromHeight: req.GetFromHeight(),
+		ToHeight:   req.GetToHeight(),
+	}
+	for _, t := range req.GetTypes() {
+		filter.Types = append(filter.Types, usecase.EventType(t))
+	}
+
+	sub, err := c.bus.Subscribe(filter)
+	if err != nil {
+		cause := errors.Cause(err)
+		if cause == usecase.ErrEventBusInvalidFilter {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		return status.Error(codes.Internal, err.Error())
+	}
+	defer c.bus.Unsubscribe(sub)
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return status.Error(codes.Canceled, stream.Context().Err().Error())
+		case event, ok := <-sub.Events():
+			if !ok {
+				if err := sub.Err(); err != nil {
+					return status.Error(codes.ResourceExhausted, err.Error())
+				}
+				return nil
+			}
+			res, err := toProtoEvent(event)
+			if err != nil {
+				return status.Error(codes.Internal, err.Error())
+			}
+			if err := stream.Send(res); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func toProtoEvent(event *usecase.Event) (*bbft.Event, error) {
+	res := &bbft.Event{
+		Type:   bbft.EventType(event.Type),
+		Height: event.Height,
+		Round:  event.Round,
+		Hash:   event.Hash,
+	}
+	if event.Block != nil {
+		b, ok := event.Block.(*convertor.Block)
+		if !ok {
+			return nil, errors.Errorf("Can not cast Block model: %#v.", event.Block)
+		}
+		res.Block = b.Block
+	}
+	if event.Tx != nil {
+		tx, ok := event.Tx.(*convertor.Transaction)
+		if !ok {
+			return nil, errors.Errorf("Can not cast Transaction model: %#v.", event.Tx)
+		}
+		res.Transaction = tx.Transaction
+	}
+	return res, nil
+}
@@ -0,0 +1,126 @@
+package controller_test
+
+import (
+	"context"
+	. "github.com/satellitex/bbft/controller"
+	"github.com/satellitex/bbft/proto"
+	. "github.com/satellitex/bbft/test_utils"
+	"github.com/satellitex/bbft/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+type fakeEventStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent chan *bbft.Event
+}
+
+func (s *fakeEventStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeEventStream) Send(event *bbft.Event) error {
+	s.sent <- event
+	return nil
+}
+
+// waitUntil は cond が true を返すまで 10ms ごとに呼び、1秒経っても true にならなければ失敗する
+func waitUntil(t *testing.T, cond func() bool) {
+	for i := 0; i < 100; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.FailNow(t, "condition is not satisfied")
+}
+
+func TestEventController_Subscribe(t *testing.T) {
+	conf := GetTestConfig()
+
+	t.Run("success case, stream filtered events until canceled", func(t *testing.T) {
+		bus := usecase.NewEventBusOnMemory(conf)
+		ctrl := NewEventController(bus)
+		ctx, cancel := context.WithCancel(context.Background())
+		stream := &fakeEventStream{ctx: ctx, sent: make(chan *bbft.Event, 10)}
+
+		done := make(chan error)
+		go func() {
+			done <- ctrl.Subscribe(&bbft.SubscribeRequest{Types: []bbft.EventType{bbft.EventType_NEW_BLOCK, bbft.EventType_TX}}, stream)
+		}()
+
+		block := RandomBlock(t)
+		tx := block.GetTransactions()[0]
+		txHash, err := tx.GetHash()
+		require.NoError(t, err)
+		// Subscribe が終わるまで Publish を繰り返す
+		waitUntil(t, func() bool {
+			bus.Publish(&usecase.Event{Type: usecase.EventNewRound, Height: 1})
+			bus.Publish(&usecase.Event{Type: usecase.EventNewBlock, Height: 1, Round: 2, Block: block})
+			return len(stream.sent) > 0
+		})
+		bus.Publish(&usecase.Event{Type: usecase.EventTx, Height: 1, Round: 2, Hash: txHash, Tx: tx})
+
+		event := <-stream.sent
+		assert.Equal(t, bbft.EventType_NEW_BLOCK, event.GetType())
+		assert.Equal(t, int64(1), event.GetHeight())
+		assert.Equal(t, int32(2), event.GetRound())
+		assert.Equal(t, block.GetHeader().GetHeight(), event.GetBlock().GetHeader().GetHeight())
+		for event.GetType() == bbft.EventType_NEW_BLOCK {
+			event = <-stream.sent
+		}
+		assert.Equal(t, bbft.EventType_TX, event.GetType())
+		assert.Equal(t, txHash, event.GetHash())
+		assert.NotNil(t, event.GetTransaction())
+
+		cancel()
+		err = <-done
+		assert.Equal(t, codes.Canceled, status.Code(err))
+	})
+
+	t.Run("failed invalid filter", func(t *testing.T) {
+		ctrl := NewEventController(usecase.NewEventBusOnMemory(conf))
+		stream := &fakeEventStream{ctx: context.Background(), sent: make(chan *bbft.Event, 1)}
+
+		err := ctrl.Subscribe(&bbft.SubscribeRequest{FromHeight: 5, ToHeight: 3}, stream)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("failed slow subscriber", func(t *testing.T) {
+		slowConf := *conf
+		slowConf.EventSubscriptionBufferSize = 1
+		bus := usecase.NewEventBusOnMemory(&slowConf)
+		ctrl := NewEventController(bus)
+		// Send が詰まって Buffer が溢れる
+		stream := &fakeEventStream{ctx: context.Background(), sent: make(chan *bbft.Event)}
+
+		done := make(chan error)
+		go func() {
+			done <- ctrl.Subscribe(&bbft.SubscribeRequest{}, stream)
+		}()
+
+		var err error
+		waitUntil(t, func() bool {
+			for i := 0; i < 3; i++ {
+				bus.Publish(&usecase.Event{Type: usecase.EventNewRound, Height: 1})
+			}
+			select {
+			case <-stream.sent:
+			default:
+			}
+			select {
+			case err = <-done:
+				return true
+			default:
+				return false
+			}
+		})
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	})
+}
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"github.com/pkg/errors"
+	"github.com/satellitex/bbft/convertor"
+	"github.com/satellitex/bbft/model"
+	"github.com/satellitex/bbft/proto"
+	"github.com/satellitex/bbft/usecase"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type LightClientController struct {
+	receiver      usecase.BlockSyncReceiver
+	proofReceiver usecase.TxProofReceiver
+	factory       model.ModelFactory
+}
+
+func NewLightClientController(receiver usecase.BlockSyncReceiver, proofReceiver usecase.TxProofReceiver, factory model.ModelFactory) *LightClientController {
+	return &LightClientController{
+		receiver:      receiver,
+		proofReceiver: proofReceiver,
+		factory:       factory,
+	}
+}
+
+func (c *LightClientController) GetLightBlocks(ctx context.Context, req *bbft.LightBlockRequest) (*bbft.LightBlockResponse, error) {
+	blocks, certs, err := c.receiver.GetBlocks(req.GetFromHeight(), req.GetToHeight())
+	if err != nil {
+		cause := errors.Cause(err)
+		if cause == usecase.ErrBlockSyncInvalidRange {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	res := &bbft.LightBlockResponse{
+		Blocks:       make([]*bbft.LightBlock, 0, len(blocks)),
+		Certificates: make([]*bbft.CommitCertificate, 0, len(certs)),
+	}
+	for _, block := range blocks {
+		light, err := c.factory.NewLightBlock(block)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		b, ok := light.(*convertor.LightBlock)
+		if !ok {
+			return nil, status.Errorf(codes.Internal, "Can not cast LightBlock model: %#v.", light)
+		}
+		res.Blocks = append(res.Blocks, b.LightBlock)
+	}
+	for _, cert := range certs {
+		// genesis block has no CommitCertificate
+		if cert == nil {
+			res.Certificates = append(res.Certificates, &bbft.CommitCertificate{})
+			continue
+		}
+		ct, ok := cert.(*convertor.CommitCertificate)
+		if !ok {
+			return nil, status.Errorf(codes.Internal, "Can not cast CommitCertificate model: %#v.", cert)
+		}
+		res.Certificates = append(res.Certificates, ct.CommitCertificate)
+	}
+	return res, nil
+}
+
+func (c *LightClientController) GetTxProof(ctx context.Context, req *bbft.TxProofRequest) (*bbft.TxProof, error) {
+	proof, err := c.proofReceiver.GetTxProof(req.GetTxHash())
+	if err != nil {
+		cause := errors.Cause(err)
+		if cause == usecase.ErrTxProofNotFound {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	p, ok := proof.(*convertor.TxProof)
+	if !ok {
+		return nil, status.Errorf(codes.Internal, "Can not cast TxProof model: %#v.", proof)
+	}
+	return p.TxProof, nil
+}
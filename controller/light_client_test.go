@@ -0,0 +1,65 @@
+package controller_test
+
+import (
+	"context"
+	. "github.com/satellitex/bbft/controller"
+	"github.com/satellitex/bbft/convertor"
+	"github.com/satellitex/bbft/proto"
+	. "github.com/satellitex/bbft/test_utils"
+	"github.com/satellitex/bbft/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"testing"
+)
+
+func TestLightClientController_GetLightBlocks(t *testing.T) {
+	conf, bc, _ := NewTestBlockSyncController(t)
+	factory := convertor.NewModelFactory()
+	ctrl := NewLightClientController(usecase.NewBlockSyncReceiverUsecase(conf, bc), usecase.NewTxProofReceiverUsecase(bc, factory), factory)
+
+	t.Run("success case, without authorization", func(t *testing.T) {
+		res, err := ctrl.GetLightBlocks(context.Background(), &bbft.LightBlockRequest{FromHeight: 0, ToHeight: 2})
+		require.NoError(t, err)
+		require.Equal(t, 3, len(res.GetBlocks()))
+		require.Equal(t, 3, len(res.GetCertificates()))
+		for i, block := range res.GetBlocks() {
+			expected, ok := bc.GetBlock(int64(i))
+			require.True(t, ok)
+			assert.Equal(t, GetHash(t, expected), GetHash(t, &convertor.LightBlock{block}))
+		}
+		// genesis block has no CommitCertificate
+		assert.Empty(t, res.GetCertificates()[0].GetPreCommits())
+		assert.NotEmpty(t, res.GetCertificates()[1].GetPreCommits())
+	})
+
+	t.Run("failed invalid range", func(t *testing.T) {
+		_, err := ctrl.GetLightBlocks(context.Background(), &bbft.LightBlockRequest{FromHeight: 3, ToHeight: 1})
+		ValidateStatusCode(t, err, codes.InvalidArgument)
+	})
+}
+
+func TestLightClientController_GetTxProof(t *testing.T) {
+	conf, bc, _ := NewTestBlockSyncController(t)
+	factory := convertor.NewModelFactory()
+	ctrl := NewLightClientController(usecase.NewBlockSyncReceiverUsecase(conf, bc), usecase.NewTxProofReceiverUsecase(bc, factory), factory)
+
+	t.Run("success case, without authorization", func(t *testing.T) {
+		block, ok := bc.GetBlock(1)
+		require.True(t, ok)
+		tx := block.GetTransactions()[1]
+		res, err := ctrl.GetTxProof(context.Background(), &bbft.TxProofRequest{TxHash: GetHash(t, tx)})
+		require.NoError(t, err)
+
+		proof := &convertor.TxProof{res}
+		assert.NoError(t, proof.Verify())
+		assert.Equal(t, GetHash(t, block), GetHash(t, proof))
+		assert.Equal(t, GetHash(t, tx), GetHash(t, proof.GetTransaction()))
+		assert.NotEmpty(t, res.GetCertificate().GetPreCommits())
+	})
+
+	t.Run("failed not committed tx", func(t *testing.T) {
+		_, err := ctrl.GetTxProof(context.Background(), &bbft.TxProofRequest{TxHash: RandomByte()})
+		ValidateStatusCode(t, err, codes.NotFound)
+	})
+}
@@ -1,6 +1,8 @@
 package convertor
 
 import (
+	"bytes"
+	"github.com/golang/protobuf/proto"
 	"github.com/pkg/errors"
 	"github.com/satellitex/bbft/model"
 	"github.com/satellitex/bbft/proto"
@@ -14,10 +16,22 @@ type Proposal struct {
 	*bbft.Proposal
 }
 
+type LightBlock struct {
+	*bbft.LightBlock
+}
+
+type TxProof struct {
+	*bbft.TxProof
+}
+
 type BlockHeader struct {
 	*bbft.Block_Header
 }
 
+type PhaseTiming struct {
+	*bbft.PhaseTiming
+}
+
 func (b *Block) GetHeader() model.BlockHeader {
 	if b.Block != nil {
 		return &BlockHeader{b.Header}
@@ -33,6 +47,32 @@ func (b *Block) GetTransactions() []model.Transaction {
 	return ret
 }
 
+func (b *Block) GetEvidences() []model.Evidence {
+	ret := make([]model.Evidence, len(b.Block.GetEvidences()))
+	for id, evidence := range b.Block.GetEvidences() {
+		ret[id] = &Evidence{evidence}
+	}
+	return ret
+}
+
+func (b *Block) GetLastCommit() model.CommitCertificate {
+	if b.Block == nil || b.LastCommit == nil {
+		return nil
+	}
+	return &CommitCertificate{b.LastCommit}
+}
+
+func (b *Block) GetChainState() model.ChainState {
+	header := b.GetHeader()
+	return &ChainState{
+		Proposer:       header.GetProposer(),
+		Round:          header.GetRound(),
+		StateRoot:      header.GetStateRoot(),
+		ValidatorsHash: header.GetValidatorsHash(),
+		LastCommit:     b.GetLastCommit(),
+	}
+}
+
 func (b *Block) GetSignature() model.Signature {
 	if b.Block != nil {
 		return &Signature{b.Signature}
@@ -40,32 +80,103 @@ func (b *Block) GetSignature() model.Signature {
 	return &Signature{nil}
 }
 
+// GetHash は header の Hash に Evidence の Hash を繋げた Hash を返す
+// Transaction は header の txRoot を通して Hash に含まれる
 func (b *Block) GetHash() ([]byte, error) {
+	return blockHash(b.GetHeader(), b.GetEvidences())
+}
+
+func blockHash(header model.BlockHeader, evidences []model.Evidence) ([]byte, error) {
+	hashes, err := evidenceHashes(evidences)
+	if err != nil {
+		return nil, err
+	}
+	return blockHashFromEvidenceHashes(header, hashes)
+}
+
+func evidenceHashes(evidences []model.Evidence) ([][]byte, error) {
+	hashes := make([][]byte, len(evidences))
+	for id, evidence := range evidences {
+		hash, err := evidence.GetHash()
+		if err != nil {
+			return nil, errors.Wrapf(model.ErrEvidenceGetHash, err.Error())
+		}
+		hashes[id] = hash
+	}
+	return hashes, nil
+}
+
+func blockHashFromEvidenceHashes(header model.BlockHeader, evidenceHashes [][]byte) ([]byte, error) {
 	//TODO 毎回 sha256計算したほうが一気にやるよりはやそう？
-	result, err := b.GetHeader().GetHash()
+	result, err := header.GetHash()
 	if err != nil {
 		return nil, errors.Wrapf(model.ErrBlockHeaderGetHash, err.Error())
 	}
-	for _, tx := range b.GetTransactions() {
-		// Unexpeted Can not cast Transaction of GetTransactions()
-		hash, err := CalcHashFromProto(tx.(*Transaction))
+	for _, hash := range evidenceHashes {
+		result = append(result, hash...)
+	}
+	return CalcHash(result), nil
+}
+
+// txHashes は各 Transaction の (署名を含む) Hash を順に返す。txRoot の Merkle Tree の葉になる
+func (b *Block) txHashes() ([][]byte, error) {
+	txHashes := make([][]byte, len(b.Block.GetTransactions()))
+	for id, tx := range b.Block.GetTransactions() {
+		hash, err := CalcHashFromProto(tx)
 		if err != nil {
 			return nil, errors.Wrapf(model.ErrTransactionGetHash, err.Error())
 		}
-		result = append(result, hash...)
+		txHashes[id] = hash
 	}
-	return CalcHash(result), nil
+	return txHashes, nil
+}
+
+func (b *Block) GetSize() int {
+	return proto.Size(b.Block)
 }
 
 func (b *Block) Verify() error {
+	txHashes, err := b.txHashes()
+	if err != nil {
+		return errors.Wrapf(model.ErrBlockGetHash, err.Error())
+	}
+	return b.verifyWithTxHashes(txHashes)
+}
+
+// verifyWithTxHashes は各 Transaction の Hash が txHashes であるとして、header の txRoot とリーダーの署名を検証する
+// BlockValidate で Transaction の検証のために計算した Hash を使い回し、Transaction を何度も Marshal しないようにする
+func (b *Block) verifyWithTxHashes(txHashes [][]byte) error {
 	hash, err := b.GetHash()
 	if err != nil {
 		return errors.Wrapf(model.ErrBlockGetHash, err.Error())
 	}
-	if b.Signature == nil {
+	if root := MerkleRoot(txHashes); !bytes.Equal(root, b.GetHeader().GetTxRoot()) {
+		return errors.Wrapf(model.ErrInvalidTxRoot, "txRoot: %x, expected %x", b.GetHeader().GetTxRoot(), root)
+	}
+	lastCommitHash, err := commitCertificateHash(b.GetLastCommit())
+	if err != nil {
+		return errors.Wrapf(model.ErrCommitCertificateGetHash, err.Error())
+	}
+	if !bytes.Equal(lastCommitHash, b.GetHeader().GetLastCommitHash()) {
+		return errors.Wrapf(model.ErrInvalidLastCommit, "lastCommitHash: %x, expected %x", b.GetHeader().GetLastCommitHash(), lastCommitHash)
+	}
+	return verifySignature(b.Signature, hash)
+}
+
+// commitCertificateHash は cert の Hash を返す。cert が nil のときは空の Hash を返す
+func commitCertificateHash(cert model.CommitCertificate) ([]byte, error) {
+	if cert == nil {
+		return nil, nil
+	}
+	return cert.GetHash()
+}
+
+// verifySignature は signature が hash に対するものかを検証する
+func verifySignature(signature *bbft.Signature, hash []byte) error {
+	if signature == nil {
 		return errors.Wrapf(model.ErrInvalidSignature, "Signature is nil")
 	}
-	if err = Verify(b.Signature.Pubkey, hash, b.Signature.Signature); err != nil {
+	if err := Verify(signature.Pubkey, hash, signature.Signature); err != nil {
 		return errors.Wrapf(ErrCryptoVerify, err.Error())
 	}
 	return nil
@@ -87,6 +198,99 @@ func (b *Block) Sign(pubKey []byte, privKey []byte) error {
 	return nil
 }
 
+func (b *LightBlock) GetHeader() model.BlockHeader {
+	if b.LightBlock != nil {
+		return &BlockHeader{b.Header}
+	}
+	return &BlockHeader{nil}
+}
+
+func (b *LightBlock) GetValidatorUpdateTxs() []model.Transaction {
+	ret := make([]model.Transaction, len(b.ValidatorUpdates))
+	for id, tx := range b.ValidatorUpdates {
+		ret[id] = &Transaction{tx}
+	}
+	return ret
+}
+
+func (b *LightBlock) GetSignature() model.Signature {
+	if b.LightBlock != nil {
+		return &Signature{b.Signature}
+	}
+	return &Signature{nil}
+}
+
+// GetHash は Block の GetHash と同じく header の Hash に Evidence の Hash を繋げた Hash を返す
+func (b *LightBlock) GetHash() ([]byte, error) {
+	return blockHashFromEvidenceHashes(b.GetHeader(), b.GetEvidenceHashes())
+}
+
+func (b *LightBlock) Verify() error {
+	hash, err := b.GetHash()
+	if err != nil {
+		return errors.Wrapf(model.ErrBlockGetHash, err.Error())
+	}
+	if root := MerkleRoot(b.GetTxHashes()); !bytes.Equal(root, b.GetHeader().GetTxRoot()) {
+		return errors.Wrapf(model.ErrInvalidTxRoot, "txRoot: %x, expected %x", b.GetHeader().GetTxRoot(), root)
+	}
+	return verifySignature(b.Signature, hash)
+}
+
+func (p *TxProof) GetHeader() model.BlockHeader {
+	if p.TxProof != nil {
+		return &BlockHeader{p.Header}
+	}
+	return &BlockHeader{nil}
+}
+
+func (p *TxProof) GetTransaction() model.Transaction {
+	if p.TxProof != nil {
+		return &Transaction{p.Transaction}
+	}
+	return &Transaction{nil}
+}
+
+func (p *TxProof) GetMerkleProof() model.MerkleProof {
+	if p.TxProof != nil {
+		return &MerkleProof{p.Proof}
+	}
+	return &MerkleProof{nil}
+}
+
+func (p *TxProof) GetCommitCertificate() model.CommitCertificate {
+	if p.TxProof == nil || p.Certificate == nil {
+		return nil
+	}
+	return &CommitCertificate{p.Certificate}
+}
+
+func (p *TxProof) GetHash() ([]byte, error) {
+	return blockHashFromEvidenceHashes(p.GetHeader(), p.GetEvidenceHashes())
+}
+
+func (p *TxProof) Verify() error {
+	if p.TxProof == nil || p.Transaction == nil {
+		return errors.Wrapf(model.ErrInvalidTxProof, "Transaction is nil")
+	}
+	leaf, err := CalcHashFromProto(p.Transaction)
+	if err != nil {
+		return errors.Wrapf(model.ErrTransactionGetHash, err.Error())
+	}
+	if err := p.GetMerkleProof().Verify(p.GetHeader().GetTxRoot(), leaf); err != nil {
+		return errors.Wrapf(model.ErrTxProofVerify, err.Error())
+	}
+	return nil
+}
+
+func (h *BlockHeader) GetTiming() model.PhaseTiming {
+	return &PhaseTiming{h.Block_Header.GetTiming()}
+}
+
+// GetNextStateRoot は stateRoot に txRoot を繋げた Hash を返す。Transaction を実行した状態ではなく、Transaction の running hash である
+func (h *BlockHeader) GetNextStateRoot() []byte {
+	return CalcHash(append(append([]byte{}, h.GetStateRoot()...), h.GetTxRoot()...))
+}
+
 func (h *BlockHeader) GetHash() ([]byte, error) {
 	return CalcHashFromProto(h)
 }
@@ -94,3 +298,59 @@ func (h *BlockHeader) GetHash() ([]byte, error) {
 func (p *Proposal) GetBlock() model.Block {
 	return &Block{p.Block}
 }
+
+func (p *Proposal) GetJustify() model.CommitCertificate {
+	if p.Proposal == nil || p.Justify == nil {
+		return nil
+	}
+	return &CommitCertificate{p.Justify}
+}
+
+func (p *Proposal) GetPOLRound() int32 {
+	return p.GetPolRound()
+}
+
+func (p *Proposal) GetSignature() model.Signature {
+	if p.Proposal != nil {
+		return &Signature{p.Signature}
+	}
+	return &Signature{nil}
+}
+
+// GetHash は signature 以外の field の Hash を返す。これが署名の対象になる。
+func (p *Proposal) GetHash() ([]byte, error) {
+	if p.Proposal == nil {
+		return nil, errors.Wrapf(model.ErrInvalidProposal, "Proposal is nil")
+	}
+	return CalcHashFromProto(&bbft.Proposal{
+		Block:    p.Block,
+		Round:    p.Round,
+		Justify:  p.Justify,
+		PolRound: p.PolRound,
+	})
+}
+
+func (p *Proposal) Sign(pubKey []byte, privKey []byte) error {
+	hash, err := p.GetHash()
+	if err != nil {
+		return errors.Wrapf(model.ErrProposalGetHash, err.Error())
+	}
+	signature, err := Sign(privKey, hash)
+	if err != nil {
+		return errors.Wrapf(ErrCryptoSign, err.Error())
+	}
+	if err := Verify(pubKey, hash, signature); err != nil {
+		return errors.Wrapf(ErrCryptoVerify, err.Error())
+	}
+	p.Signature = &bbft.Signature{Pubkey: pubKey, Signature: signature}
+	return nil
+}
+
+// Verify は round のリーダーの Proposal への署名を検証する。Block の署名は検証しない
+func (p *Proposal) Verify() error {
+	hash, err := p.GetHash()
+	if err != nil {
+		return errors.Wrapf(model.ErrProposalGetHash, err.Error())
+	}
+	return verifySignature(p.Signature, hash)
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/satellitex/bbft/model"
 	. "github.com/satellitex/bbft/test_utils"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"testing"
 )
 
@@ -37,13 +38,6 @@ func TestBlock_FailedGetHash(t *testing.T) {
 		_, err := block.GetHash()
 		assert.EqualError(t, errors.Cause(err), model.ErrBlockHeaderGetHash.Error())
 	})
-	t.Run("failed nil tx in transactions", func(t *testing.T) {
-		block := ValidSignedBlock(t)
-		block.(*Block).Transactions[0] = nil
-
-		_, err := block.GetHash()
-		assert.EqualError(t, errors.Cause(err), model.ErrTransactionGetHash.Error())
-	})
 	t.Run("failed nil bbft Block", func(t *testing.T) {
 		block := ValidSignedBlock(t)
 		block.(*Block).Block = nil
@@ -110,4 +104,71 @@ func TestBlock_SignAndVerify(t *testing.T) {
 
 		assert.EqualError(t, errors.Cause(block.Verify()), model.ErrBlockGetHash.Error())
 	})
+	t.Run("failed modified transactions after signed", func(t *testing.T) {
+		block := ValidSignedBlock(t)
+		hash := GetHash(t, block)
+		block.(*Block).Transactions = block.(*Block).Transactions[1:]
+
+		assert.Equal(t, hash, GetHash(t, block))
+		assert.EqualError(t, errors.Cause(block.Verify()), model.ErrInvalidTxRoot.Error())
+	})
+}
+
+func TestProposal_SignAndVerify(t *testing.T) {
+	validPub, validPri := NewKeyPair()
+
+	t.Run("success re-proposal signed by round leader", func(t *testing.T) {
+		block := ValidSignedBlock(t)
+		proposal, err := NewModelFactory().NewReProposal(block, 3, 1)
+		require.NoError(t, err)
+		require.NoError(t, proposal.Sign(validPub, validPri))
+
+		assert.NoError(t, proposal.Verify())
+		assert.Equal(t, int32(1), proposal.GetPOLRound())
+		assert.Equal(t, validPub, proposal.GetSignature().GetPubkey())
+		assert.Equal(t, block.GetSignature(), proposal.GetBlock().GetSignature())
+	})
+	t.Run("failed relabeled round after signed", func(t *testing.T) {
+		proposal, err := NewModelFactory().NewReProposal(ValidSignedBlock(t), 3, 1)
+		require.NoError(t, err)
+		require.NoError(t, proposal.Sign(validPub, validPri))
+		proposal.(*Proposal).Round = 4
+
+		assert.EqualError(t, errors.Cause(proposal.Verify()), ErrCryptoVerify.Error())
+	})
+	t.Run("failed modified polRound after signed", func(t *testing.T) {
+		proposal, err := NewModelFactory().NewReProposal(ValidSignedBlock(t), 3, 1)
+		require.NoError(t, err)
+		require.NoError(t, proposal.Sign(validPub, validPri))
+		proposal.(*Proposal).PolRound = 2
+
+		assert.EqualError(t, errors.Cause(proposal.Verify()), ErrCryptoVerify.Error())
+	})
+	t.Run("failed nil signature", func(t *testing.T) {
+		proposal, err := NewModelFactory().NewProposal(ValidSignedBlock(t), 3)
+		require.NoError(t, err)
+
+		assert.EqualError(t, errors.Cause(proposal.Verify()), model.ErrInvalidSignature.Error())
+	})
+}
+
+func TestBlock_Evidences(t *testing.T) {
+	evidences := []model.Evidence{RandomEvidence(t), RandomEvidence(t)}
+	block, err := NewModelFactory().NewBlock(1, RandomByte(), 0, RandomValidTxs(t), evidences, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, evidences, block.GetEvidences())
+
+	validPub, validPri := NewKeyPair()
+	assert.NoError(t, block.Sign(validPub, validPri))
+	assert.NoError(t, block.Verify())
+
+	t.Run("failed modified evidences after signed", func(t *testing.T) {
+		block.(*Block).Evidences = block.(*Block).Evidences[:1]
+		assert.EqualError(t, errors.Cause(block.Verify()), ErrCryptoVerify.Error())
+	})
+	t.Run("failed nil evidence in evidences", func(t *testing.T) {
+		block.(*Block).Evidences[0] = nil
+		_, err := block.GetHash()
+		assert.EqualError(t, errors.Cause(err), model.ErrEvidenceGetHash.Error())
+	})
 }
@@ -0,0 +1,64 @@
+package convertor
+
+import (
+	"container/list"
+	"sync"
+)
+
+// VerifiedTxCache は署名の検証に成功した Transaction の (署名を含む) Hash を最近使われた順に limit 個まで覚える
+// Hash は Transaction 全体から計算するので、検証した後に payload や署名が書き換えられた Transaction は別の Hash になり、再び検証される
+type VerifiedTxCache struct {
+	mutex *sync.Mutex
+	limit int
+	order *list.List
+	elems map[string]*list.Element
+}
+
+// NewVerifiedTxCache は limit 個まで覚える VerifiedTxCache を返す。limit が 0 以下のときは何も覚えない
+func NewVerifiedTxCache(limit int) *VerifiedTxCache {
+	return &VerifiedTxCache{
+		new(sync.Mutex),
+		limit,
+		list.New(),
+		make(map[string]*list.Element),
+	}
+}
+
+// Contains は hash が検証済みかを返し、検証済みなら最近使われたものにする
+func (c *VerifiedTxCache) Contains(hash []byte) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	elem, ok := c.elems[string(hash)]
+	if ok {
+		c.order.MoveToFront(elem)
+	}
+	return ok
+}
+
+// Add は hash を検証済みとして覚え、limit を超えたら最も長く使われていない hash を忘れる
+func (c *VerifiedTxCache) Add(hash []byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.limit <= 0 {
+		return
+	}
+	if elem, ok := c.elems[string(hash)]; ok {
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.elems[string(hash)] = c.order.PushFront(string(hash))
+	for c.order.Len() > c.limit {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.elems, oldest.Value.(string))
+	}
+}
+
+// Len は覚えている hash の数を返す
+func (c *VerifiedTxCache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.order.Len()
+}
@@ -0,0 +1,42 @@
+package convertor_test
+
+import (
+	. "github.com/satellitex/bbft/convertor"
+	. "github.com/satellitex/bbft/test_utils"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestVerifiedTxCache(t *testing.T) {
+	t.Run("success add and contains", func(t *testing.T) {
+		cache := NewVerifiedTxCache(2)
+		hash := RandomByte()
+		assert.False(t, cache.Contains(hash))
+		cache.Add(hash)
+		assert.True(t, cache.Contains(hash))
+		cache.Add(hash)
+		assert.Equal(t, 1, cache.Len())
+	})
+
+	t.Run("success evict least recently used", func(t *testing.T) {
+		cache := NewVerifiedTxCache(2)
+		a, b, c := RandomByte(), RandomByte(), RandomByte()
+		cache.Add(a)
+		cache.Add(b)
+		// a を使ったので b が最も長く使われていない
+		assert.True(t, cache.Contains(a))
+		cache.Add(c)
+		assert.Equal(t, 2, cache.Len())
+		assert.True(t, cache.Contains(a))
+		assert.False(t, cache.Contains(b))
+		assert.True(t, cache.Contains(c))
+	})
+
+	t.Run("success limit 0 does not remember", func(t *testing.T) {
+		cache := NewVerifiedTxCache(0)
+		hash := RandomByte()
+		cache.Add(hash)
+		assert.False(t, cache.Contains(hash))
+		assert.Equal(t, 0, cache.Len())
+	})
+}
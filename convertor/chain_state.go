@@ -0,0 +1,53 @@
+package convertor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/satellitex/bbft/model"
+	"sort"
+)
+
+type ChainState struct {
+	Proposer       []byte
+	Round          int32
+	StateRoot      []byte
+	ValidatorsHash []byte
+	LastCommit     model.CommitCertificate
+}
+
+func (s *ChainState) GetProposer() []byte {
+	return s.Proposer
+}
+
+func (s *ChainState) GetRound() int32 {
+	return s.Round
+}
+
+func (s *ChainState) GetStateRoot() []byte {
+	return s.StateRoot
+}
+
+func (s *ChainState) GetValidatorsHash() []byte {
+	return s.ValidatorsHash
+}
+
+func (s *ChainState) GetLastCommit() model.CommitCertificate {
+	return s.LastCommit
+}
+
+// ValidatorsHash は peers を公開鍵の順に並べ、各 Peer の公開鍵と voting power を繋げた Hash を返す
+// peers の順番によらず同じ集合なら同じ Hash になる
+func ValidatorsHash(peers []model.Peer) []byte {
+	sorted := make([]model.Peer, len(peers))
+	copy(sorted, peers)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].GetPubkey(), sorted[j].GetPubkey()) < 0
+	})
+	buf := make([]byte, 0)
+	power := make([]byte, 8)
+	for _, peer := range sorted {
+		binary.BigEndian.PutUint64(power, uint64(peer.GetPower()))
+		buf = append(append(buf, peer.GetPubkey()...), power...)
+	}
+	return CalcHash(buf)
+}
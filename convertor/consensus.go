@@ -16,12 +16,43 @@ func (v *VoteMessage) GetSignature() model.Signature {
 	return &Signature{v.Signature}
 }
 
+func (v *VoteMessage) GetType() model.VoteType {
+	if v.VoteMessage == nil {
+		return model.UnknownVote
+	}
+	return model.VoteType(v.Type)
+}
+
+func (v *VoteMessage) IsReject() bool {
+	return v.GetReject()
+}
+
+// GetHash は signature 以外の field の Hash を返す。これが署名の対象になる。
+func (v *VoteMessage) GetHash() ([]byte, error) {
+	if v.VoteMessage == nil {
+		return nil, errors.Wrapf(model.ErrInvalidVoteMessage, "VoteMessage is nil")
+	}
+	return CalcHashFromProto(&bbft.VoteMessage{
+		BlockHash:     v.BlockHash,
+		Height:        v.Height,
+		Round:         v.Round,
+		Type:          v.Type,
+		ChainId:       v.ChainId,
+		Reject:        v.Reject,
+		RejectMessage: v.RejectMessage,
+	})
+}
+
 func (v *VoteMessage) Sign(pubKey []byte, privKey []byte) error {
-	signature, err := Sign(privKey, v.GetBlockHash())
+	hash, err := v.GetHash()
+	if err != nil {
+		return errors.Wrapf(model.ErrVoteMessageGetHash, err.Error())
+	}
+	signature, err := Sign(privKey, hash)
 	if err != nil {
 		return errors.Wrapf(ErrCryptoSign, err.Error())
 	}
-	if err := Verify(pubKey, v.GetBlockHash(), signature); err != nil {
+	if err := Verify(pubKey, hash, signature); err != nil {
 		return errors.Wrapf(ErrCryptoVerify, err.Error())
 	}
 	v.Signature = &bbft.Signature{Pubkey: pubKey, Signature: signature}
@@ -29,10 +60,157 @@ func (v *VoteMessage) Sign(pubKey []byte, privKey []byte) error {
 }
 
 func (v *VoteMessage) Verify() error {
+	hash, err := v.GetHash()
+	if err != nil {
+		return errors.Wrapf(model.ErrVoteMessageGetHash, err.Error())
+	}
 	if v.Signature == nil {
 		return errors.Wrapf(model.ErrInvalidSignature, "VoteMessage.Signature is nil")
 	}
-	if err := Verify(v.Signature.Pubkey, v.GetBlockHash(), v.Signature.Signature); err != nil {
+	if err := Verify(v.Signature.Pubkey, hash, v.Signature.Signature); err != nil {
+		return errors.Wrapf(ErrCryptoVerify, err.Error())
+	}
+	return nil
+}
+
+type CommitCertificate struct {
+	*bbft.CommitCertificate
+}
+
+func (c *CommitCertificate) GetPreCommits() []model.VoteMessage {
+	if c.CommitCertificate == nil {
+		return nil
+	}
+	ret := make([]model.VoteMessage, len(c.PreCommits))
+	for id, vote := range c.PreCommits {
+		ret[id] = &VoteMessage{vote}
+	}
+	return ret
+}
+
+func (c *CommitCertificate) GetHash() ([]byte, error) {
+	return CalcHashFromProto(c.CommitCertificate)
+}
+
+type Evidence struct {
+	*bbft.Evidence
+}
+
+func (e *Evidence) GetType() model.EvidenceType {
+	if e.Evidence == nil {
+		return model.UnknownEvidence
+	}
+	return model.EvidenceType(e.Type)
+}
+
+func (e *Evidence) GetProposals() []model.Proposal {
+	if e.Evidence == nil {
+		return nil
+	}
+	ret := make([]model.Proposal, len(e.Proposals))
+	for id, proposal := range e.Proposals {
+		ret[id] = &Proposal{proposal}
+	}
+	return ret
+}
+
+func (e *Evidence) GetVotes() []model.VoteMessage {
+	if e.Evidence == nil {
+		return nil
+	}
+	ret := make([]model.VoteMessage, len(e.Votes))
+	for id, vote := range e.Votes {
+		ret[id] = &VoteMessage{vote}
+	}
+	return ret
+}
+
+func (e *Evidence) GetOffender() []byte {
+	switch e.GetType() {
+	case model.DuplicateProposal:
+		if proposals := e.GetProposals(); len(proposals) > 0 {
+			return model.GetProposer(proposals[0])
+		}
+	case model.DuplicatePreCommit:
+		if votes := e.GetVotes(); len(votes) > 0 {
+			return votes[0].GetSignature().GetPubkey()
+		}
+	}
+	return nil
+}
+
+func (e *Evidence) GetHeight() int64 {
+	switch e.GetType() {
+	case model.DuplicateProposal:
+		if proposals := e.GetProposals(); len(proposals) > 0 {
+			return proposals[0].GetBlock().GetHeader().GetHeight()
+		}
+	case model.DuplicatePreCommit:
+		if votes := e.GetVotes(); len(votes) > 0 {
+			return votes[0].GetHeight()
+		}
+	}
+	return 0
+}
+
+func (e *Evidence) GetRound() int32 {
+	switch e.GetType() {
+	case model.DuplicateProposal:
+		if proposals := e.GetProposals(); len(proposals) > 0 {
+			return proposals[0].GetRound()
+		}
+	case model.DuplicatePreCommit:
+		if votes := e.GetVotes(); len(votes) > 0 {
+			return votes[0].GetRound()
+		}
+	}
+	return 0
+}
+
+func (e *Evidence) GetSignature() model.Signature {
+	if e.Evidence != nil {
+		return &Signature{e.Signature}
+	}
+	return &Signature{nil}
+}
+
+// GetHash は signature 以外の field の Hash を返す。これが署名の対象になる。
+func (e *Evidence) GetHash() ([]byte, error) {
+	if e.Evidence == nil {
+		return nil, errors.Wrapf(model.ErrInvalidEvidence, "Evidence is nil")
+	}
+	return CalcHashFromProto(&bbft.Evidence{
+		Type:      e.Type,
+		Proposals: e.Proposals,
+		Votes:     e.Votes,
+	})
+}
+
+func (e *Evidence) Sign(pubKey []byte, privKey []byte) error {
+	hash, err := e.GetHash()
+	if err != nil {
+		return errors.Wrapf(model.ErrEvidenceGetHash, err.Error())
+	}
+	signature, err := Sign(privKey, hash)
+	if err != nil {
+		return errors.Wrapf(ErrCryptoSign, err.Error())
+	}
+	if err := Verify(pubKey, hash, signature); err != nil {
+		return errors.Wrapf(ErrCryptoVerify, err.Error())
+	}
+	e.Signature = &bbft.Signature{Pubkey: pubKey, Signature: signature}
+	return nil
+}
+
+func (e *Evidence) Verify() error {
+	hash, err := e.GetHash()
+	if err != nil {
+		return errors.Wrapf(model.ErrEvidenceGetHash, err.Error())
+	}
+	if e.Signature == nil {
+		return errors.Wrapf(model.ErrInvalidSignature, "Evidence.Signature is nil")
+	}
+	if err := Verify(e.Signature.Pubkey, hash, e.Signature.Signature); err != nil {
 		return errors.Wrapf(ErrCryptoVerify, err.Error())
 	}
 	return nil
@@ -4,6 +4,7 @@ import (
 	"github.com/pkg/errors"
 	. "github.com/satellitex/bbft/convertor"
 	"github.com/satellitex/bbft/model"
+	"github.com/satellitex/bbft/proto"
 	. "github.com/satellitex/bbft/test_utils"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -13,34 +14,34 @@ import (
 func TestVoteMessage_Sign(t *testing.T) {
 	t.Run("success valid key and exist hash", func(t *testing.T) {
 		validPub, validPri := NewKeyPair()
-		vote := NewModelFactory().NewVoteMessage(RandomByte())
+		vote := NewTestVoteMessage(model.PreVote, 0, 0, RandomByte())
 
 		err := vote.Sign(validPub, validPri)
 		assert.NoError(t, err)
 	})
 	t.Run("success valid key and nil hash", func(t *testing.T) {
 		validPub, validPri := NewKeyPair()
-		vote := NewModelFactory().NewVoteMessage(nil)
+		vote := NewTestVoteMessage(model.PreVote, 0, 0, nil)
 
 		err := vote.Sign(validPub, validPri)
 		assert.NoError(t, err)
 	})
 	t.Run("failed invalid key and exist hash", func(t *testing.T) {
 		invalid, _ := NewKeyPair()
-		vote := NewModelFactory().NewVoteMessage(RandomByte())
+		vote := NewTestVoteMessage(model.PreVote, 0, 0, RandomByte())
 
 		err := vote.Sign(invalid, invalid)
 		assert.EqualError(t, errors.Cause(err), ErrCryptoSign.Error())
 	})
 	t.Run("failed invalid key and nil hash", func(t *testing.T) {
 		invalid, _ := NewKeyPair()
-		vote := NewModelFactory().NewVoteMessage(nil)
+		vote := NewTestVoteMessage(model.PreVote, 0, 0, nil)
 
 		err := vote.Sign(invalid, invalid)
 		assert.Error(t, errors.Cause(err), ErrCryptoVerify.Error())
 	})
 	t.Run("failed invalid signed key", func(t *testing.T) {
-		vote := NewModelFactory().NewVoteMessage(nil)
+		vote := NewTestVoteMessage(model.PreVote, 0, 0, nil)
 
 		err := vote.Sign(nil, nil)
 		assert.Error(t, errors.Cause(err), ErrCryptoSign.Error())
@@ -49,19 +50,85 @@ func TestVoteMessage_Sign(t *testing.T) {
 
 func TestVoteMessage_Verify(t *testing.T) {
 	t.Run("failed nil signature", func(t *testing.T) {
-		vote := NewModelFactory().NewVoteMessage(nil)
+		vote := NewTestVoteMessage(model.PreVote, 0, 0, nil)
 		vote.(*VoteMessage).Signature = nil
 
 		assert.EqualError(t, errors.Cause(vote.Verify()), model.ErrInvalidSignature.Error())
 	})
 	t.Run("failed invalid Sign signature", func(t *testing.T) {
 		invalid, _ := NewKeyPair()
-		vote := NewModelFactory().NewVoteMessage(RandomByte())
+		vote := NewTestVoteMessage(model.PreVote, 0, 0, RandomByte())
 
 		err := vote.Sign(invalid, invalid)
 		require.Error(t, err)
 
 		assert.EqualError(t, errors.Cause(vote.Verify()), ErrCryptoVerify.Error())
 	})
+	t.Run("success valid signed", func(t *testing.T) {
+		vote := RandomVoteMessage(t)
+		assert.NoError(t, vote.Verify())
+	})
+	for _, c := range []struct {
+		name   string
+		modify func(vote *VoteMessage)
+	}{
+		{"height", func(vote *VoteMessage) { vote.Height++ }},
+		{"round", func(vote *VoteMessage) { vote.Round++ }},
+		{"type", func(vote *VoteMessage) { vote.Type = bbft.VoteType_PRECOMMIT }},
+		{"chainId", func(vote *VoteMessage) { vote.ChainId = "other" + vote.ChainId }},
+		{"blockHash", func(vote *VoteMessage) { vote.BlockHash = RandomByte() }},
+	} {
+		t.Run("failed modified "+c.name+" after signed", func(t *testing.T) {
+			vote := RandomVoteMessage(t)
+			c.modify(vote.(*VoteMessage))
+			assert.EqualError(t, errors.Cause(vote.Verify()), ErrCryptoVerify.Error())
+		})
+	}
+}
+
+func TestEvidence_SignAndVerify(t *testing.T) {
+	offender, reporter := RandomPeerWithPriv(), RandomPeerWithPriv()
 
+	t.Run("success valid signed", func(t *testing.T) {
+		for _, evidence := range []model.Evidence{
+			DuplicateProposalEvidence(t, offender, reporter, 3, 2),
+			DuplicatePreCommitEvidence(t, offender, reporter, 3, 2),
+		} {
+			assert.NoError(t, evidence.Verify())
+			assert.Equal(t, reporter.GetPubkey(), evidence.GetSignature().GetPubkey())
+			assert.Equal(t, offender.GetPubkey(), evidence.GetOffender())
+			assert.Equal(t, int64(3), evidence.GetHeight())
+			assert.Equal(t, int32(2), evidence.GetRound())
+		}
+	})
+	t.Run("failed invalid key", func(t *testing.T) {
+		invalid, _ := NewKeyPair()
+		evidence := RandomEvidence(t)
+		assert.Error(t, evidence.Sign(invalid, invalid))
+	})
+	t.Run("failed nil signature", func(t *testing.T) {
+		evidence := RandomEvidence(t)
+		evidence.(*Evidence).Signature = nil
+		assert.EqualError(t, errors.Cause(evidence.Verify()), model.ErrInvalidSignature.Error())
+	})
+	t.Run("failed nil evidence", func(t *testing.T) {
+		evidence := &Evidence{}
+		assert.EqualError(t, errors.Cause(evidence.Verify()), model.ErrEvidenceGetHash.Error())
+		assert.Equal(t, model.UnknownEvidence, evidence.GetType())
+		assert.Nil(t, evidence.GetOffender())
+	})
+	for _, c := range []struct {
+		name   string
+		modify func(evidence *Evidence)
+	}{
+		{"type", func(evidence *Evidence) { evidence.Type = bbft.EvidenceType_DUPLICATE_PROPOSAL }},
+		{"votes", func(evidence *Evidence) { evidence.Votes = evidence.Votes[:1] }},
+		{"vote blockHash", func(evidence *Evidence) { evidence.Votes[0].BlockHash = RandomByte() }},
+	} {
+		t.Run("failed modified "+c.name+" after signed", func(t *testing.T) {
+			evidence := RandomEvidence(t)
+			c.modify(evidence.(*Evidence))
+			assert.EqualError(t, errors.Cause(evidence.Verify()), ErrCryptoVerify.Error())
+		})
+	}
 }
@@ -51,6 +51,12 @@ func NewKeyPair() ([]byte, []byte) {
 	return a, b
 }
 
+// NewKeyPairFromSeed は 32 byte の seed から決定的に鍵ペアを作る
+func NewKeyPairFromSeed(seed []byte) ([]byte, []byte) {
+	pri := ed25519.NewKeyFromSeed(seed)
+	return pri.Public().(ed25519.PublicKey), pri
+}
+
 var (
 	ErrMarshalProtocolBuffer = errors.New("failed to marshal protocol buffer")
 )
@@ -27,6 +27,22 @@ func TestSignAndVerify(t *testing.T) {
 		"pubkey: %x \nhash: %x\nsignature %x", pubkey, hash, signature)
 }
 
+func TestNewKeyPairFromSeed(t *testing.T) {
+	seed := CalcHash([]byte("seed"))
+	pubkey, privkey := NewKeyPairFromSeed(seed)
+	pubkey2, privkey2 := NewKeyPairFromSeed(seed)
+	assert.Equal(t, pubkey, pubkey2)
+	assert.Equal(t, privkey, privkey2)
+
+	hash := CalcHash([]byte("a"))
+	signature, err := Sign(privkey, hash)
+	require.NoError(t, err)
+	assert.NoError(t, Verify(pubkey, hash, signature))
+
+	other, _ := NewKeyPairFromSeed(CalcHash([]byte("other")))
+	assert.NotEqual(t, pubkey, other)
+}
+
 func TestFailedSign(t *testing.T) {
 	hash := CalcHash([]byte("a"))
 	_, err := Sign(nil, hash)
@@ -1,6 +1,7 @@
 package convertor
 
 import (
+	"bytes"
 	"github.com/pkg/errors"
 	"github.com/satellitex/bbft/model"
 	"github.com/satellitex/bbft/proto"
@@ -13,7 +14,11 @@ func NewModelFactory() model.ModelFactory {
 	return &ModelFactory{}
 }
 
-func (_ *ModelFactory) NewBlock(height int64, preBlockHash []byte, createdTime int64, txs []model.Transaction) (model.Block, error) {
+func (f *ModelFactory) NewBlock(height int64, preBlockHash []byte, createdTime int64, txs []model.Transaction, evidences []model.Evidence, state model.ChainState) (model.Block, error) {
+	return f.NewTimedBlock(height, preBlockHash, createdTime, txs, evidences, state, nil)
+}
+
+func (_ *ModelFactory) NewTimedBlock(height int64, preBlockHash []byte, createdTime int64, txs []model.Transaction, evidences []model.Evidence, state model.ChainState, timing model.PhaseTiming) (model.Block, error) {
 	ptxs := make([]*bbft.Transaction, len(txs))
 	for id, tx := range txs {
 		tmp, ok := tx.(*Transaction)
@@ -23,15 +28,161 @@ func (_ *ModelFactory) NewBlock(height int64, preBlockHash []byte, createdTime i
 		}
 		ptxs[id] = tmp.Transaction
 	}
+	pevidences := make([]*bbft.Evidence, len(evidences))
+	for id, evidence := range evidences {
+		tmp, ok := evidence.(*Evidence)
+		if !ok {
+			return nil, errors.Wrapf(model.ErrInvalidEvidence,
+				"Can not cast Evidence model: %#v.", evidence)
+		}
+		pevidences[id] = tmp.Evidence
+	}
+	var ptiming *bbft.PhaseTiming
+	if timing != nil {
+		tmp, ok := timing.(*PhaseTiming)
+		if !ok {
+			return nil, errors.Wrapf(model.ErrInvalidBlockHeader,
+				"Can not cast PhaseTiming model: %#v.", timing)
+		}
+		ptiming = tmp.PhaseTiming
+	}
+	txHashes := make([][]byte, len(ptxs))
+	for id, tx := range ptxs {
+		hash, err := CalcHashFromProto(tx)
+		if err != nil {
+			return nil, errors.Wrapf(model.ErrTransactionGetHash, err.Error())
+		}
+		txHashes[id] = hash
+	}
+	header := &bbft.Block_Header{
+		Height:       height,
+		PreBlockHash: preBlockHash,
+		CreatedTime:  createdTime,
+		Timing:       ptiming,
+		TxRoot:       MerkleRoot(txHashes),
+		Version:      model.BlockHeaderVersion,
+	}
+	var plastCommit *bbft.CommitCertificate
+	if state != nil {
+		header.Proposer = state.GetProposer()
+		header.Round = state.GetRound()
+		header.StateRoot = state.GetStateRoot()
+		header.ValidatorsHash = state.GetValidatorsHash()
+		if lastCommit := state.GetLastCommit(); lastCommit != nil {
+			tmp, ok := lastCommit.(*CommitCertificate)
+			if !ok {
+				return nil, errors.Wrapf(model.ErrInvalidCommitCertificate,
+					"Can not cast CommitCertificate model: %#v.", lastCommit)
+			}
+			hash, err := tmp.GetHash()
+			if err != nil {
+				return nil, errors.Wrapf(model.ErrCommitCertificateGetHash, err.Error())
+			}
+			plastCommit = tmp.CommitCertificate
+			header.LastCommitHash = hash
+		}
+	}
 	return &Block{
 		&bbft.Block{
-			Header: &bbft.Block_Header{
-				Height:       height,
-				PreBlockHash: preBlockHash,
-				CreatedTime:  createdTime,
-			},
+			Header:       header,
 			Transactions: ptxs,
 			Signature:    &bbft.Signature{},
+			Evidences:    pevidences,
+			LastCommit:   plastCommit,
+		},
+	}, nil
+}
+
+func (_ *ModelFactory) NewChainState(proposer []byte, round int32, stateRoot []byte, validators []model.Peer, lastCommit model.CommitCertificate) model.ChainState {
+	return &ChainState{
+		Proposer:       proposer,
+		Round:          round,
+		StateRoot:      stateRoot,
+		ValidatorsHash: ValidatorsHash(validators),
+		LastCommit:     lastCommit,
+	}
+}
+
+func (_ *ModelFactory) NewLightBlock(block model.Block) (model.LightBlock, error) {
+	b, ok := block.(*Block)
+	if !ok {
+		return nil, errors.Wrapf(model.ErrInvalidBlock,
+			"Can not cast Block model: %#v.", block)
+	}
+	txHashes, err := b.txHashes()
+	if err != nil {
+		return nil, err
+	}
+	updates := make([]*bbft.Transaction, 0)
+	for _, tx := range b.GetTransactions() {
+		if _, ok := tx.GetPayload().GetValidatorUpdate(); ok {
+			updates = append(updates, tx.(*Transaction).Transaction)
+		}
+	}
+	evHashes, err := evidenceHashes(b.GetEvidences())
+	if err != nil {
+		return nil, err
+	}
+	return &LightBlock{
+		&bbft.LightBlock{
+			Header:           b.Header,
+			TxHashes:         txHashes,
+			EvidenceHashes:   evHashes,
+			ValidatorUpdates: updates,
+			Signature:        b.Signature,
+		},
+	}, nil
+}
+
+func (_ *ModelFactory) NewTxProof(block model.Block, txHash []byte, cert model.CommitCertificate) (model.TxProof, error) {
+	b, ok := block.(*Block)
+	if !ok {
+		return nil, errors.Wrapf(model.ErrInvalidBlock,
+			"Can not cast Block model: %#v.", block)
+	}
+	index := -1
+	for id, tx := range b.GetTransactions() {
+		hash, err := tx.GetHash()
+		if err != nil {
+			return nil, errors.Wrapf(model.ErrTransactionGetHash, err.Error())
+		}
+		if bytes.Equal(hash, txHash) {
+			index = id
+			break
+		}
+	}
+	if index < 0 {
+		return nil, errors.Wrapf(model.ErrInvalidTxProof,
+			"tx: %x is not included in block of height: %d", txHash, b.GetHeader().GetHeight())
+	}
+	txHashes, err := b.txHashes()
+	if err != nil {
+		return nil, err
+	}
+	proof, err := NewMerkleProof(txHashes, index)
+	if err != nil {
+		return nil, errors.Wrapf(model.ErrInvalidTxProof, err.Error())
+	}
+	evHashes, err := evidenceHashes(b.GetEvidences())
+	if err != nil {
+		return nil, err
+	}
+	var pcert *bbft.CommitCertificate
+	if cert != nil {
+		tmp, ok := cert.(*CommitCertificate)
+		if !ok {
+			return nil, errors.Wrapf(model.ErrInvalidCommitCertificate,
+				"Can not cast CommitCertificate model: %#v.", cert)
+		}
+		pcert = tmp.CommitCertificate
+	}
+	return &TxProof{
+		&bbft.TxProof{
+			Header:         b.Header,
+			EvidenceHashes: evHashes,
+			Transaction:    b.Transactions[index],
+			Proof:          proof.(*MerkleProof).MerkleProof,
+			Certificate:    pcert,
 		},
 	}, nil
 }
@@ -50,11 +201,133 @@ func (_ *ModelFactory) NewProposal(block model.Block, round int32) (model.Propos
 	}, nil
 }
 
-func (_ *ModelFactory) NewVoteMessage(hash []byte) model.VoteMessage {
+func (_ *ModelFactory) NewReProposal(block model.Block, round int32, polRound int32) (model.Proposal, error) {
+	b, ok := block.(*Block)
+	if !ok {
+		return nil, errors.Wrapf(model.ErrInvalidBlock,
+			"Can not cast Block model: %#v.", block)
+	}
+	return &Proposal{
+		&bbft.Proposal{
+			Block:    b.Block,
+			Round:    round,
+			PolRound: polRound,
+		},
+	}, nil
+}
+
+// NewJustifiedProposal は hotstuff の Proposal を作る。justify が nil のときは genesis の子の Proposal である
+func (_ *ModelFactory) NewJustifiedProposal(block model.Block, round int32, justify model.CommitCertificate) (model.Proposal, error) {
+	b, ok := block.(*Block)
+	if !ok {
+		return nil, errors.Wrapf(model.ErrInvalidBlock,
+			"Can not cast Block model: %#v.", block)
+	}
+	var j *bbft.CommitCertificate
+	if justify != nil {
+		c, ok := justify.(*CommitCertificate)
+		if !ok {
+			return nil, errors.Wrapf(model.ErrInvalidCommitCertificate,
+				"Can not cast CommitCertificate model: %#v.", justify)
+		}
+		j = c.CommitCertificate
+	}
+	return &Proposal{
+		&bbft.Proposal{
+			Block:   b.Block,
+			Round:   round,
+			Justify: j,
+		},
+	}, nil
+}
+
+func (_ *ModelFactory) NewVoteMessage(chainId string, height int64, round int32, voteType model.VoteType, hash []byte) model.VoteMessage {
 	return &VoteMessage{
 		&bbft.VoteMessage{
 			BlockHash: hash,
 			Signature: &bbft.Signature{},
+			Height:    height,
+			Round:     round,
+			Type:      bbft.VoteType(voteType),
+			ChainId:   chainId,
+		},
+	}
+}
+
+func (_ *ModelFactory) NewCommitCertificate(height int64, round int32, blockHash []byte, preCommits []model.VoteMessage) (model.CommitCertificate, error) {
+	votes := make([]*bbft.VoteMessage, len(preCommits))
+	for id, preCommit := range preCommits {
+		vote, ok := preCommit.(*VoteMessage)
+		if !ok {
+			return nil, errors.Wrapf(model.ErrInvalidVoteMessage,
+				"Can not cast VoteMessage model: %#v.", preCommit)
+		}
+		votes[id] = vote.VoteMessage
+	}
+	return &CommitCertificate{
+		&bbft.CommitCertificate{
+			Height:     height,
+			Round:      round,
+			BlockHash:  blockHash,
+			PreCommits: votes,
+		},
+	}, nil
+}
+
+// NewEvidence は署名の無い Evidence を作る。Evidence を見つけた Peer が Sign する
+func (_ *ModelFactory) NewEvidence(evidenceType model.EvidenceType, proposals []model.Proposal, votes []model.VoteMessage) (model.Evidence, error) {
+	pproposals := make([]*bbft.Proposal, len(proposals))
+	for id, proposal := range proposals {
+		tmp, ok := proposal.(*Proposal)
+		if !ok {
+			return nil, errors.Wrapf(model.ErrInvalidProposal,
+				"Can not cast Proposal model: %#v.", proposal)
+		}
+		pproposals[id] = tmp.Proposal
+	}
+	pvotes := make([]*bbft.VoteMessage, len(votes))
+	for id, vote := range votes {
+		tmp, ok := vote.(*VoteMessage)
+		if !ok {
+			return nil, errors.Wrapf(model.ErrInvalidVoteMessage,
+				"Can not cast VoteMessage model: %#v.", vote)
+		}
+		pvotes[id] = tmp.VoteMessage
+	}
+	return &Evidence{
+		&bbft.Evidence{
+			Type:      bbft.EvidenceType(evidenceType),
+			Proposals: pproposals,
+			Votes:     pvotes,
+			Signature: &bbft.Signature{},
+		},
+	}, nil
+}
+
+// NewRejectVoteMessage は Vote Phase で hash の Block を reason により Reject する VoteMessage を作る
+// Proposal を受け取れなかった場合は hash = nil とする
+func (_ *ModelFactory) NewRejectVoteMessage(chainId string, height int64, round int32, hash []byte, reason string) model.VoteMessage {
+	return &VoteMessage{
+		&bbft.VoteMessage{
+			BlockHash:     hash,
+			Signature:     &bbft.Signature{},
+			Height:        height,
+			Round:         round,
+			Type:          bbft.VoteType_PREVOTE,
+			ChainId:       chainId,
+			Reject:        true,
+			RejectMessage: reason,
+		},
+	}
+}
+
+func (_ *ModelFactory) NewPhaseTiming(connectDelay int64, propose int64, vote int64, preCommit int64) model.PhaseTiming {
+	return &PhaseTiming{
+		&bbft.PhaseTiming{
+			ConnectDelay: connectDelay,
+			Propose:      propose,
+			Vote:         vote,
+			PreCommit:    preCommit,
 		},
 	}
 }
@@ -68,10 +341,32 @@ func (_ *ModelFactory) NewSignature(pubkey []byte, signature []byte) model.Signa
 	}
 }
 
+// NewPeer は voting power が 1 の Peer を作る
 func (_ *ModelFactory) NewPeer(address string, pubkey []byte) model.Peer {
 	return &Peer{
 		address,
 		pubkey,
+		1,
+	}
+}
+
+func (_ *ModelFactory) NewPeerWithPower(address string, pubkey []byte, power int64) model.Peer {
+	return &Peer{
+		address,
+		pubkey,
+		power,
+	}
+}
+
+func (_ *ModelFactory) NewValidatorUpdate(updateType model.ValidatorUpdateType, address string, pubkey []byte, newPubkey []byte, power int64) model.ValidatorUpdate {
+	return &ValidatorUpdate{
+		&bbft.ValidatorUpdate{
+			Type:      bbft.ValidatorUpdateType(updateType),
+			Address:   address,
+			Pubkey:    pubkey,
+			NewPubkey: newPubkey,
+			Power:     power,
+		},
 	}
 }
 
@@ -129,6 +424,21 @@ func (b *TxModelBuilder) Message(msg string) *TxModelBuilder {
 	return b
 }
 
+func (b *TxModelBuilder) Cost(cost int64) *TxModelBuilder {
+	b.Payload.Cost = cost
+	return b
+}
+
+func (b *TxModelBuilder) ValidatorUpdate(u model.ValidatorUpdate) *TxModelBuilder {
+	update, ok := u.(*ValidatorUpdate)
+	if !ok {
+		b.err = multierr.Append(b.err, errors.Wrapf(model.ErrInvalidValidatorUpdate, "Can not cast ValidatorUpdate model: %#v.", u))
+		return b
+	}
+	b.Payload.ValidatorUpdate = update.ValidatorUpdate
+	return b
+}
+
 func (b *TxModelBuilder) Build() (model.Transaction, error) {
 	if b.err != nil {
 		return nil, b.err
@@ -71,7 +71,7 @@ func TestBlockFactory(t *testing.T) {
 		},
 	} {
 		t.Run(c.name, func(t *testing.T) {
-			block, err := NewModelFactory().NewBlock(c.expectedHeight, c.expectedHash, c.expectedCreatedTime, c.expectedTxs)
+			block, err := NewModelFactory().NewBlock(c.expectedHeight, c.expectedHash, c.expectedCreatedTime, c.expectedTxs, nil, nil)
 			if c.expectedError != nil {
 				assert.EqualError(t, errors.Cause(err), c.expectedError.Error())
 				return
@@ -87,6 +87,168 @@ func TestBlockFactory(t *testing.T) {
 
 }
 
+func TestTimedBlockFactory(t *testing.T) {
+	factory := NewModelFactory()
+	txs := RandomTxs(t)
+
+	t.Run("success, timing is recorded in header", func(t *testing.T) {
+		timing := factory.NewPhaseTiming(1, 2, 3, 4)
+		block, err := factory.NewTimedBlock(10, []byte("preBlockHash"), 5, txs, nil, nil, timing)
+		require.NoError(t, err)
+		assert.Equal(t, timing, block.GetHeader().GetTiming())
+
+		untimed, err := factory.NewBlock(10, []byte("preBlockHash"), 5, txs, nil, nil)
+		require.NoError(t, err)
+		assert.NotEqual(t, GetHash(t, untimed), GetHash(t, block))
+	})
+
+	t.Run("success, nil timing is same as NewBlock", func(t *testing.T) {
+		block, err := factory.NewTimedBlock(10, []byte("preBlockHash"), 5, txs, nil, nil, nil)
+		require.NoError(t, err)
+		untimed, err := factory.NewBlock(10, []byte("preBlockHash"), 5, txs, nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, GetHash(t, untimed), GetHash(t, block))
+		timing := block.GetHeader().GetTiming()
+		assert.Equal(t, []int64{0, 0, 0, 0}, []int64{timing.GetConnectDelay(), timing.GetPropose(), timing.GetVote(), timing.GetPreCommit()})
+	})
+}
+
+func TestChainStateBlockFactory(t *testing.T) {
+	factory := NewModelFactory()
+	txs := RandomTxs(t)
+	proposer := RandomPeerWithPriv()
+	peers := []model.Peer{proposer, RandomPeerWithPriv(), RandomPeerWithPriv()}
+	pre, err := factory.NewBlock(9, []byte("preBlockHash"), 4, nil, nil, nil)
+	require.NoError(t, err)
+	lastCommit := RandomCommitCertificate(t, pre, peers)
+
+	t.Run("success, chain state is recorded in header", func(t *testing.T) {
+		state := factory.NewChainState(proposer.GetPubkey(), 3, []byte("stateRoot"), peers, lastCommit)
+		block, err := factory.NewBlock(10, GetHash(t, pre), 5, txs, nil, state)
+		require.NoError(t, err)
+		header := block.GetHeader()
+		assert.Equal(t, model.BlockHeaderVersion, header.GetVersion())
+		assert.Equal(t, proposer.GetPubkey(), header.GetProposer())
+		assert.Equal(t, int32(3), header.GetRound())
+		assert.Equal(t, []byte("stateRoot"), header.GetStateRoot())
+		assert.Equal(t, ValidatorsHash(peers), header.GetValidatorsHash())
+		assert.Equal(t, GetHash(t, lastCommit), header.GetLastCommitHash())
+		assert.Equal(t, lastCommit, block.GetLastCommit())
+
+		ValidSign(t, block)
+		assert.NoError(t, block.Verify())
+		block.(*Block).LastCommit = RandomCommitCertificate(t, pre, peers[:1]).(*CommitCertificate).CommitCertificate
+		assert.EqualError(t, errors.Cause(block.Verify()), model.ErrInvalidLastCommit.Error())
+	})
+
+	t.Run("success, same chain state makes same header", func(t *testing.T) {
+		block, err := factory.NewBlock(10, GetHash(t, pre), 5, txs, nil,
+			factory.NewChainState(proposer.GetPubkey(), 3, []byte("stateRoot"), peers, lastCommit))
+		require.NoError(t, err)
+		rebuilt, err := factory.NewBlock(10, GetHash(t, pre), 5, txs, nil, block.GetChainState())
+		require.NoError(t, err)
+		assert.Equal(t, GetHash(t, block), GetHash(t, rebuilt))
+	})
+
+	t.Run("success, nil state is genesis", func(t *testing.T) {
+		block, err := factory.NewBlock(0, nil, 0, nil, nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, model.BlockHeaderVersion, block.GetHeader().GetVersion())
+		assert.Empty(t, block.GetHeader().GetProposer())
+		assert.Empty(t, block.GetHeader().GetLastCommitHash())
+		assert.Nil(t, block.GetLastCommit())
+	})
+
+	t.Run("success, next state root depends on txs", func(t *testing.T) {
+		block, err := factory.NewBlock(10, GetHash(t, pre), 5, txs, nil, nil)
+		require.NoError(t, err)
+		empty, err := factory.NewBlock(10, GetHash(t, pre), 5, nil, nil, nil)
+		require.NoError(t, err)
+		assert.NotEqual(t, block.GetHeader().GetNextStateRoot(), empty.GetHeader().GetNextStateRoot())
+	})
+
+	t.Run("success, validators hash does not depend on order", func(t *testing.T) {
+		reversed := []model.Peer{peers[2], peers[1], peers[0]}
+		assert.Equal(t, ValidatorsHash(peers), ValidatorsHash(reversed))
+		assert.NotEqual(t, ValidatorsHash(peers), ValidatorsHash(peers[1:]))
+		assert.NotEqual(t, ValidatorsHash(peers), ValidatorsHash([]model.Peer{RandomPeerWithPower(2), peers[1], peers[2]}))
+	})
+}
+
+func TestLightBlockFactory(t *testing.T) {
+	factory := NewModelFactory()
+	peers := []model.Peer{RandomPeerWithPriv(), RandomPeerWithPriv()}
+	update := ValidatorUpdateTx(t, factory.NewValidatorUpdate(model.AddValidator, RandomStr(), RandomByte(), nil, 1), peers)
+	txs := append(RandomTxs(t), update)
+	evidences := []model.Evidence{RandomEvidence(t)}
+
+	t.Run("success, same hash as block", func(t *testing.T) {
+		block, err := factory.NewBlock(10, []byte("preBlockHash"), 5, txs, evidences, nil)
+		require.NoError(t, err)
+		ValidSign(t, block)
+
+		light, err := factory.NewLightBlock(block)
+		require.NoError(t, err)
+		assert.Equal(t, GetHash(t, block), GetHash(t, light))
+		assert.Equal(t, len(txs), len(light.GetTxHashes()))
+		assert.Equal(t, len(evidences), len(light.GetEvidenceHashes()))
+		assert.Equal(t, []model.Transaction{update}, light.GetValidatorUpdateTxs())
+		assert.NoError(t, light.Verify())
+
+		light.(*LightBlock).TxHashes = light.(*LightBlock).TxHashes[1:]
+		assert.EqualError(t, errors.Cause(light.Verify()), model.ErrInvalidTxRoot.Error())
+	})
+
+	t.Run("failed, nil block", func(t *testing.T) {
+		_, err := factory.NewLightBlock(nil)
+		assert.EqualError(t, errors.Cause(err), model.ErrInvalidBlock.Error())
+	})
+}
+
+func TestTxProofFactory(t *testing.T) {
+	factory := NewModelFactory()
+	txs := RandomValidTxs(t)
+	block, err := factory.NewBlock(10, []byte("preBlockHash"), 5, txs, []model.Evidence{RandomEvidence(t)}, nil)
+	require.NoError(t, err)
+	ValidSign(t, block)
+	cert := RandomCommitCertificate(t, block, []model.Peer{RandomPeerWithPriv()})
+
+	t.Run("success, every tx in block", func(t *testing.T) {
+		for _, tx := range txs {
+			proof, err := factory.NewTxProof(block, GetHash(t, tx), cert)
+			require.NoError(t, err)
+			assert.Equal(t, GetHash(t, block), GetHash(t, proof))
+			assert.Equal(t, GetHash(t, tx), GetHash(t, proof.GetTransaction()))
+			assert.Equal(t, cert, proof.GetCommitCertificate())
+			assert.NoError(t, proof.Verify())
+		}
+	})
+
+	t.Run("success, genesis block without certificate", func(t *testing.T) {
+		proof, err := factory.NewTxProof(block, GetHash(t, txs[0]), nil)
+		require.NoError(t, err)
+		assert.Nil(t, proof.GetCommitCertificate())
+		assert.NoError(t, proof.Verify())
+	})
+
+	t.Run("failed, proof of other tx", func(t *testing.T) {
+		proof, err := factory.NewTxProof(block, GetHash(t, txs[0]), cert)
+		require.NoError(t, err)
+		proof.(*TxProof).Transaction = txs[1].(*Transaction).Transaction
+		assert.EqualError(t, errors.Cause(proof.Verify()), model.ErrTxProofVerify.Error())
+	})
+
+	t.Run("failed, tx not in block", func(t *testing.T) {
+		_, err := factory.NewTxProof(block, GetHash(t, RandomValidTx(t)), cert)
+		assert.EqualError(t, errors.Cause(err), model.ErrInvalidTxProof.Error())
+	})
+
+	t.Run("failed, nil block", func(t *testing.T) {
+		_, err := factory.NewTxProof(nil, GetHash(t, txs[0]), cert)
+		assert.EqualError(t, errors.Cause(err), model.ErrInvalidBlock.Error())
+	})
+}
+
 func TestProposalFactory(t *testing.T) {
 	for _, c := range []struct {
 		name          string
@@ -171,12 +333,68 @@ func TestVoteMessageFactory(t *testing.T) {
 		},
 	} {
 		t.Run(c.name, func(t *testing.T) {
-			vote := NewModelFactory().NewVoteMessage(c.expectedHash)
+			height, round := rand.Int63(), rand.Int31()
+			vote := NewModelFactory().NewVoteMessage("chain", height, round, model.PreCommit, c.expectedHash)
 			assert.Equal(t, c.expectedHash, vote.GetBlockHash())
+			assert.Equal(t, "chain", vote.GetChainId())
+			assert.Equal(t, height, vote.GetHeight())
+			assert.Equal(t, round, vote.GetRound())
+			assert.Equal(t, model.PreCommit, vote.GetType())
 		})
 	}
 }
 
+func TestCommitCertificateFactory(t *testing.T) {
+	block := ValidSignedBlock(t)
+	preCommits := []model.VoteMessage{RandomVoteMessage(t), RandomVoteMessage(t)}
+
+	t.Run("success", func(t *testing.T) {
+		cert, err := NewModelFactory().NewCommitCertificate(1, 2, GetHash(t, block), preCommits)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), cert.GetHeight())
+		assert.Equal(t, int32(2), cert.GetRound())
+		assert.Equal(t, GetHash(t, block), cert.GetBlockHash())
+		assert.Equal(t, preCommits, cert.GetPreCommits())
+	})
+
+	t.Run("failed can not cast VoteMessage", func(t *testing.T) {
+		_, err := NewModelFactory().NewCommitCertificate(1, 2, GetHash(t, block), []model.VoteMessage{nil})
+		assert.EqualError(t, errors.Cause(err), model.ErrInvalidVoteMessage.Error())
+	})
+}
+
+func TestEvidenceFactory(t *testing.T) {
+	proposals := []model.Proposal{RandomProposal(t), RandomProposal(t)}
+	votes := []model.VoteMessage{RandomPreCommit(t), RandomPreCommit(t)}
+
+	t.Run("success proposals", func(t *testing.T) {
+		evidence, err := NewModelFactory().NewEvidence(model.DuplicateProposal, proposals, nil)
+		require.NoError(t, err)
+		assert.Equal(t, model.DuplicateProposal, evidence.GetType())
+		assert.Equal(t, proposals, evidence.GetProposals())
+		assert.Empty(t, evidence.GetVotes())
+	})
+	t.Run("success votes", func(t *testing.T) {
+		evidence, err := NewModelFactory().NewEvidence(model.DuplicatePreCommit, nil, votes)
+		require.NoError(t, err)
+		assert.Equal(t, model.DuplicatePreCommit, evidence.GetType())
+		assert.Equal(t, votes, evidence.GetVotes())
+		assert.Empty(t, evidence.GetProposals())
+	})
+	t.Run("failed nil proposal", func(t *testing.T) {
+		_, err := NewModelFactory().NewEvidence(model.DuplicateProposal, make([]model.Proposal, 2), nil)
+		assert.EqualError(t, errors.Cause(err), model.ErrInvalidProposal.Error())
+	})
+	t.Run("failed nil vote", func(t *testing.T) {
+		_, err := NewModelFactory().NewEvidence(model.DuplicatePreCommit, nil, make([]model.VoteMessage, 2))
+		assert.EqualError(t, errors.Cause(err), model.ErrInvalidVoteMessage.Error())
+	})
+	t.Run("failed block with nil evidence", func(t *testing.T) {
+		_, err := NewModelFactory().NewBlock(0, nil, 0, nil, make([]model.Evidence, 1), nil)
+		assert.EqualError(t, errors.Cause(err), model.ErrInvalidEvidence.Error())
+	})
+}
+
 func TestSignatureFactory(t *testing.T) {
 	for _, c := range []struct {
 		name        string
@@ -0,0 +1,124 @@
+package convertor
+
+import (
+	"bytes"
+	"github.com/pkg/errors"
+	"github.com/satellitex/bbft/model"
+	"github.com/satellitex/bbft/proto"
+)
+
+var (
+	ErrInvalidMerkleProof = errors.Errorf("Failed Invalid MerkleProof")
+)
+
+// Merkle Tree は RFC 6962 と同じく葉と節の Hash に異なる prefix を付け、葉を節と偽れないようにする
+var (
+	merkleLeafPrefix  = []byte{0}
+	merkleInnerPrefix = []byte{1}
+)
+
+type MerkleProof struct {
+	*bbft.MerkleProof
+}
+
+func merkleLeafHash(leaf []byte) []byte {
+	return CalcHash(append(append([]byte{}, merkleLeafPrefix...), leaf...))
+}
+
+func merkleInnerHash(left []byte, right []byte) []byte {
+	buf := make([]byte, 0, len(merkleInnerPrefix)+len(left)+len(right))
+	buf = append(append(append(buf, merkleInnerPrefix...), left...), right...)
+	return CalcHash(buf)
+}
+
+// merkleSplit は n 個の葉を左右に分ける位置 (n より小さい最大の 2 の冪) を返す
+func merkleSplit(n int64) int64 {
+	k := int64(1)
+	for k < n-k {
+		k *= 2
+	}
+	return k
+}
+
+// MerkleRoot は leaves を葉とする Merkle Tree の root を返す。葉が無いときは空の列の Hash を返す
+func MerkleRoot(leaves [][]byte) []byte {
+	switch len(leaves) {
+	case 0:
+		return CalcHash(nil)
+	case 1:
+		return merkleLeafHash(leaves[0])
+	}
+	k := merkleSplit(int64(len(leaves)))
+	return merkleInnerHash(MerkleRoot(leaves[:k]), MerkleRoot(leaves[k:]))
+}
+
+// merkleAunts は leaves[index] から root までの各段の兄弟の Hash を葉に近い順に返す
+func merkleAunts(leaves [][]byte, index int64) [][]byte {
+	if len(leaves) <= 1 {
+		return [][]byte{}
+	}
+	k := merkleSplit(int64(len(leaves)))
+	if index < k {
+		return append(merkleAunts(leaves[:k], index), MerkleRoot(leaves[k:]))
+	}
+	return append(merkleAunts(leaves[k:], index-k), MerkleRoot(leaves[:k]))
+}
+
+// NewMerkleProof は leaves[index] が leaves の Merkle Tree に含まれることの MerkleProof を返す
+func NewMerkleProof(leaves [][]byte, index int) (model.MerkleProof, error) {
+	if index < 0 || index >= len(leaves) {
+		return nil, errors.Wrapf(ErrInvalidMerkleProof, "index: %d, number of leaves: %d", index, len(leaves))
+	}
+	return &MerkleProof{
+		&bbft.MerkleProof{
+			Index: int64(index),
+			Total: int64(len(leaves)),
+			Aunts: merkleAunts(leaves, int64(index)),
+		},
+	}, nil
+}
+
+// merkleRootFromAunts は index 番目の葉が leaf である total 個の葉の Merkle Tree の root を aunts から計算する
+func merkleRootFromAunts(leaf []byte, index int64, total int64, aunts [][]byte) ([]byte, error) {
+	if total == 1 {
+		if len(aunts) != 0 {
+			return nil, errors.Errorf("too many aunts: %d", len(aunts))
+		}
+		return merkleLeafHash(leaf), nil
+	}
+	if len(aunts) == 0 {
+		return nil, errors.Errorf("too few aunts")
+	}
+	k := merkleSplit(total)
+	sibling := aunts[len(aunts)-1]
+	if index < k {
+		left, err := merkleRootFromAunts(leaf, index, k, aunts[:len(aunts)-1])
+		if err != nil {
+			return nil, err
+		}
+		return merkleInnerHash(left, sibling), nil
+	}
+	right, err := merkleRootFromAunts(leaf, index-k, total-k, aunts[:len(aunts)-1])
+	if err != nil {
+		return nil, err
+	}
+	return merkleInnerHash(sibling, right), nil
+}
+
+// Verify は leaf が root の Merkle Tree の index 番目の葉であることを検証する
+func (p *MerkleProof) Verify(root []byte, leaf []byte) error {
+	if p.MerkleProof == nil {
+		return errors.Wrapf(ErrInvalidMerkleProof, "*bbft.MerkleProof is nil")
+	}
+	if p.Total <= 0 || p.Index < 0 || p.Index >= p.Total {
+		return errors.Wrapf(ErrInvalidMerkleProof, "index: %d, total: %d", p.Index, p.Total)
+	}
+	got, err := merkleRootFromAunts(leaf, p.Index, p.Total, p.Aunts)
+	if err != nil {
+		return errors.Wrapf(ErrInvalidMerkleProof, err.Error())
+	}
+	if !bytes.Equal(got, root) {
+		return errors.Wrapf(ErrInvalidMerkleProof, "root: %x, expected %x", got, root)
+	}
+	return nil
+}
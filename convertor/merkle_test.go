@@ -0,0 +1,87 @@
+package convertor_test
+
+import (
+	"github.com/pkg/errors"
+	. "github.com/satellitex/bbft/convertor"
+	. "github.com/satellitex/bbft/test_utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func randomLeaves(n int) [][]byte {
+	leaves := make([][]byte, n)
+	for i := range leaves {
+		leaves[i] = RandomByte()
+	}
+	return leaves
+}
+
+func TestMerkleRoot(t *testing.T) {
+	t.Run("success empty leaves", func(t *testing.T) {
+		assert.Equal(t, CalcHash(nil), MerkleRoot(nil))
+	})
+	t.Run("success leaf is not inner node", func(t *testing.T) {
+		leaves := randomLeaves(2)
+		// 2つの葉の root を葉にしても同じ root にならない
+		assert.NotEqual(t, MerkleRoot(leaves), MerkleRoot([][]byte{append(append([]byte{}, leaves[0]...), leaves[1]...)}))
+	})
+	t.Run("success root depends on order and every leaf", func(t *testing.T) {
+		leaves := randomLeaves(5)
+		root := MerkleRoot(leaves)
+		assert.Equal(t, root, MerkleRoot(leaves))
+		assert.NotEqual(t, root, MerkleRoot([][]byte{leaves[1], leaves[0], leaves[2], leaves[3], leaves[4]}))
+		assert.NotEqual(t, root, MerkleRoot(leaves[:4]))
+		assert.NotEqual(t, root, MerkleRoot(append(leaves, leaves[4])))
+	})
+}
+
+func TestMerkleProof(t *testing.T) {
+	t.Run("success every leaf of every size", func(t *testing.T) {
+		for n := 1; n <= 17; n++ {
+			leaves := randomLeaves(n)
+			root := MerkleRoot(leaves)
+			for i := range leaves {
+				proof, err := NewMerkleProof(leaves, i)
+				require.NoError(t, err)
+				assert.NoError(t, proof.Verify(root, leaves[i]), "n: %d, index: %d", n, i)
+			}
+		}
+	})
+
+	leaves := randomLeaves(7)
+	root := MerkleRoot(leaves)
+	newProof := func(index int) *MerkleProof {
+		proof, err := NewMerkleProof(leaves, index)
+		require.NoError(t, err)
+		return proof.(*MerkleProof)
+	}
+
+	for _, c := range []struct {
+		name   string
+		proof  *MerkleProof
+		modify func(proof *MerkleProof)
+		leaf   []byte
+	}{
+		{"failed other leaf", newProof(3), func(proof *MerkleProof) {}, leaves[4]},
+		{"failed modified aunt", newProof(3), func(proof *MerkleProof) { proof.Aunts[1] = RandomByte() }, leaves[3]},
+		{"failed too few aunts", newProof(3), func(proof *MerkleProof) { proof.Aunts = proof.Aunts[1:] }, leaves[3]},
+		{"failed too many aunts", newProof(3), func(proof *MerkleProof) { proof.Aunts = append(proof.Aunts, RandomByte()) }, leaves[3]},
+		{"failed other index", newProof(3), func(proof *MerkleProof) { proof.Index = 2 }, leaves[3]},
+		{"failed index out of range", newProof(6), func(proof *MerkleProof) { proof.Index = 7 }, leaves[6]},
+		{"failed other total", newProof(5), func(proof *MerkleProof) { proof.Total = 6 }, leaves[5]},
+		{"failed nil proof", &MerkleProof{nil}, func(proof *MerkleProof) {}, leaves[3]},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			c.modify(c.proof)
+			assert.EqualError(t, errors.Cause(c.proof.Verify(root, c.leaf)), ErrInvalidMerkleProof.Error())
+		})
+	}
+
+	t.Run("failed index out of leaves", func(t *testing.T) {
+		_, err := NewMerkleProof(leaves, 7)
+		assert.EqualError(t, errors.Cause(err), ErrInvalidMerkleProof.Error())
+		_, err = NewMerkleProof(nil, 0)
+		assert.EqualError(t, errors.Cause(err), ErrInvalidMerkleProof.Error())
+	})
+}
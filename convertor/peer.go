@@ -1,8 +1,14 @@
 package convertor
 
+import (
+	"github.com/satellitex/bbft/model"
+	"github.com/satellitex/bbft/proto"
+)
+
 type Peer struct {
 	Address string
 	Pubkey  []byte
+	Power   int64
 }
 
 func (p *Peer) GetAddress() string {
@@ -12,3 +18,25 @@ func (p *Peer) GetAddress() string {
 func (p *Peer) GetPubkey() []byte {
 	return p.Pubkey
 }
+
+func (p *Peer) GetPower() int64 {
+	return p.Power
+}
+
+type ValidatorUpdate struct {
+	*bbft.ValidatorUpdate
+}
+
+func (u *ValidatorUpdate) GetType() model.ValidatorUpdateType {
+	if u.ValidatorUpdate == nil {
+		return model.UnknownValidatorUpdate
+	}
+	return model.ValidatorUpdateType(u.Type)
+}
+
+func (u *ValidatorUpdate) GetPeer() model.Peer {
+	if u.GetType() != model.AddValidator {
+		return nil
+	}
+	return &Peer{u.Address, u.Pubkey, u.Power}
+}
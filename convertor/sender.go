@@ -2,7 +2,9 @@ package convertor
 
 import (
 	"github.com/pkg/errors"
+	"github.com/satellitex/bbft/dba"
 	"github.com/satellitex/bbft/model"
+	"time"
 )
 
 type MockConsensusSender struct {
@@ -10,6 +12,11 @@ type MockConsensusSender struct {
 	Proposal         model.Proposal
 	VoteMessage      model.VoteMessage
 	PreCommitMessage model.VoteMessage
+	EvidenceMessage  model.Evidence
+	// PreCommitTo で送った相手
+	PreCommitPeer model.Peer
+	// Ping で返す RTT
+	RoundTripTime time.Duration
 }
 
 func NewMockConsensusSender() model.ConsensusSender {
@@ -47,3 +54,64 @@ func (s *MockConsensusSender) PreCommit(vote model.VoteMessage) error {
 	s.PreCommitMessage = vote
 	return nil
 }
+
+func (s *MockConsensusSender) PreCommitTo(peer model.Peer, vote model.VoteMessage) error {
+	if peer == nil {
+		return errors.Wrapf(model.ErrConsensusSenderPreCommit, "peer is nil")
+	}
+	if _, ok := vote.(*VoteMessage); !ok {
+		return errors.Wrapf(model.ErrInvalidVoteMessage, "vote can not cast to convertor.VoteMessage %#v", vote)
+	}
+	s.PreCommitPeer = peer
+	s.PreCommitMessage = vote
+	return nil
+}
+
+func (s *MockConsensusSender) Evidence(evidence model.Evidence) error {
+	if _, ok := evidence.(*Evidence); !ok {
+		return errors.Wrapf(model.ErrInvalidEvidence, "evidence can not cast to convertor.Evidence %#v", evidence)
+	}
+	s.EvidenceMessage = evidence
+	return nil
+}
+
+func (s *MockConsensusSender) Ping(peer model.Peer) (time.Duration, error) {
+	if peer == nil {
+		return 0, errors.Wrapf(model.ErrConsensusSenderPing, "peer is nil")
+	}
+	return s.RoundTripTime, nil
+}
+
+func (s *MockConsensusSender) Close() error {
+	return nil
+}
+
+type MockBlockSyncSender struct {
+	bc dba.BlockChain
+}
+
+func NewMockBlockSyncSender(bc dba.BlockChain) model.BlockSyncSender {
+	return &MockBlockSyncSender{bc}
+}
+
+func (s *MockBlockSyncSender) GetBlocks(peer model.Peer, from int64, to int64) ([]model.Block, []model.CommitCertificate, error) {
+	if peer == nil {
+		return nil, nil, errors.Wrapf(model.ErrBlockSyncSenderGetBlocks, "peer is nil")
+	}
+	blocks := make([]model.Block, 0, to-from+1)
+	certs := make([]model.CommitCertificate, 0, to-from+1)
+	for height := from; height <= to; height++ {
+		block, ok := s.bc.GetBlock(height)
+		if !ok {
+			break
+		}
+		cert, _ := s.bc.GetCommitCertificate(height)
+		blocks = append(blocks, block)
+		certs = append(certs, cert)
+	}
+	return blocks, certs, nil
+}
+
+func (s *MockBlockSyncSender) Close() error {
+	return nil
+}
@@ -31,6 +31,11 @@ func testConseusSender_InputCheck(t *testing.T, sender model.ConsensusSender) {
 		assert.NoError(t, err)
 	})
 
+	t.Run("success evidence", func(t *testing.T) {
+		err := sender.Evidence(RandomEvidence(t))
+		assert.NoError(t, err)
+	})
+
 	t.Run("failed propagete, input: nil", func(t *testing.T) {
 		err := sender.Propagate(nil)
 		assert.EqualError(t, errors.Cause(err), model.ErrInvalidTransaction.Error())
@@ -51,6 +56,11 @@ func testConseusSender_InputCheck(t *testing.T, sender model.ConsensusSender) {
 		assert.EqualError(t, errors.Cause(err), model.ErrInvalidVoteMessage.Error())
 	})
 
+	t.Run("failed evidence, input: nil", func(t *testing.T) {
+		err := sender.Evidence(nil)
+		assert.EqualError(t, errors.Cause(err), model.ErrInvalidEvidence.Error())
+	})
+
 }
 
 func TestMockConsensusSender(t *testing.T) {
@@ -1,6 +1,7 @@
 package convertor
 
 import (
+	"github.com/golang/protobuf/proto"
 	"github.com/pkg/errors"
 	"github.com/satellitex/bbft/model"
 	"github.com/satellitex/bbft/proto"
@@ -36,6 +37,10 @@ func (t *Transaction) GetHash() ([]byte, error) {
 	return res, nil
 }
 
+func (t *Transaction) GetSize() int {
+	return proto.Size(t.Transaction)
+}
+
 func (t *Transaction) GetSignatures() []model.Signature {
 	ret := make([]model.Signature, len(t.Signatures))
 	for i, sig := range t.Signatures {
@@ -66,3 +71,10 @@ func (t *Transaction) Verify() error {
 func (p *TransactionPayload) GetMessage() string {
 	return p.Todo
 }
+
+func (p *TransactionPayload) GetValidatorUpdate() (model.ValidatorUpdate, bool) {
+	if p.Transaction_Payload == nil || p.ValidatorUpdate == nil {
+		return nil, false
+	}
+	return &ValidatorUpdate{p.ValidatorUpdate}, true
+}
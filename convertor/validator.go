@@ -1,10 +1,16 @@
 package convertor
 
 import (
+	"bytes"
 	"github.com/pkg/errors"
+	"github.com/satellitex/bbft/config"
 	"github.com/satellitex/bbft/dba"
 	"github.com/satellitex/bbft/model"
 	"go.uber.org/multierr"
+	"golang.org/x/crypto/ed25519"
+	"runtime"
+	"sync"
+	"sync/atomic"
 )
 
 var (
@@ -14,10 +20,20 @@ var (
 	ErrStatefulValidateAlreadyExistTx = errors.New("Failed Already Exist Transaction")
 
 	ErrInvalidProposalRound = errors.New("Failed Invalid Proposal Round")
+
+	ErrCommitCertificateNotEnoughPreCommits = errors.New("Failed Not Enough PreCommits in CommitCertificate")
+
+	ErrEvidenceNotConflict = errors.New("Failed Evidence is not Conflict")
+
+	ErrValidatorUpdateNotEnoughSignatures = errors.New("Failed Not Enough Peer Signatures in ValidatorUpdate Transaction")
 )
 
 type StatefulValidator struct {
-	bc dba.BlockChain
+	conf     *config.BBFTConfig
+	bc       dba.BlockChain
+	ps       dba.PeerService
+	selector model.LeaderSelector
+	cv       model.CommitCertificateValidator
 }
 
 func (v *StatefulValidator) Validate(block model.Block) error {
@@ -39,18 +55,136 @@ func (v *StatefulValidator) Validate(block model.Block) error {
 			result = multierr.Append(result, errors.Wrapf(ErrStatefulValidateAlreadyExistTx, "Alrady exist transaction hash : %x", hash))
 		}
 	}
+	if err := v.validateValidatorUpdates(block); err != nil {
+		result = multierr.Append(result, err)
+	}
+	if err := v.validateChainState(block); err != nil {
+		result = multierr.Append(result, err)
+	}
 	return result
 }
 
-func NewStatefulValidator(bc dba.BlockChain) model.StatefulValidator {
-	return &StatefulValidator{bc}
+// validateChainState は header の validatorsHash が block の height の Peer の集合と合っていて、
+// proposer が block に署名した height, round のリーダーであり、
+// lastCommit が前の Block を Commit した正しい CommitCertificate であるかを検証する
+// stateRoot と lastCommit が前の Block と繋がっていることは BlockChain の VerifyCommit で検証する
+func (v *StatefulValidator) validateChainState(block model.Block) error {
+	// genesis block は proposer などを持たない
+	if _, ok := v.bc.Top(); !ok {
+		return nil
+	}
+	header := block.GetHeader()
+	height := header.GetHeight()
+	peers := v.ps.AtHeight(height)
+
+	var result error
+	if expected := ValidatorsHash(peers.GetPeers()); !bytes.Equal(header.GetValidatorsHash(), expected) {
+		result = multierr.Append(result, errors.Wrapf(model.ErrInvalidValidatorsHash,
+			"validatorsHash: %x, expected %x", header.GetValidatorsHash(), expected))
+	}
+	if _, ok := peers.GetPeer(header.GetProposer()); !ok {
+		result = multierr.Append(result, errors.Wrapf(model.ErrInvalidProposer,
+			"proposer is not peer at height %d: %x", height, header.GetProposer()))
+	}
+	if signer := block.GetSignature().GetPubkey(); !bytes.Equal(header.GetProposer(), signer) {
+		result = multierr.Append(result, errors.Wrapf(model.ErrInvalidProposer,
+			"proposer: %x, signer: %x", header.GetProposer(), signer))
+	}
+	if leader, ok := v.selector.GetLeader(height, header.GetRound()); !ok || !bytes.Equal(header.GetProposer(), leader.GetPubkey()) {
+		result = multierr.Append(result, errors.Wrapf(model.ErrInvalidProposer,
+			"proposer is not leader at height %d, round %d: %x", height, header.GetRound(), header.GetProposer()))
+	}
+	if lastCommit := block.GetLastCommit(); lastCommit != nil {
+		if pre, ok := v.bc.GetBlock(height - 1); !ok {
+			result = multierr.Append(result, errors.Wrapf(model.ErrInvalidLastCommit,
+				"block of height %d is not committed", height-1))
+		} else if err := v.cv.Validate(pre, lastCommit); err != nil {
+			result = multierr.Append(result, errors.Wrapf(model.ErrInvalidLastCommit, err.Error()))
+		}
+	}
+	return result
 }
 
+// validateValidatorUpdates は block の ValidatorUpdate を含む Transaction を検証する
+// 各 Transaction は block の height の voting power の 2/3 以上の Peer の署名が必要で、
+// 全ての変更は有効になる height の Peer の集合に順に適用できなければならない
+func (v *StatefulValidator) validateValidatorUpdates(block model.Block) error {
+	height := block.GetHeader().GetHeight()
+	peers := v.ps.AtHeight(height)
+
+	var result error
+	updates := make([]model.ValidatorUpdate, 0)
+	for _, tx := range block.GetTransactions() {
+		update, ok := tx.GetPayload().GetValidatorUpdate()
+		if !ok {
+			continue
+		}
+		signers := make([][]byte, 0, len(tx.GetSignatures()))
+		for _, signature := range tx.GetSignatures() {
+			signers = append(signers, signature.GetPubkey())
+		}
+		if power := peers.GetPower(signers); power < peers.GetRequiredAcceptPower() {
+			result = multierr.Append(result, errors.Wrapf(ErrValidatorUpdateNotEnoughSignatures,
+				"signed power: %d, required: %d", power, peers.GetRequiredAcceptPower()))
+			continue
+		}
+		updates = append(updates, update)
+	}
+	if len(updates) == 0 {
+		return result
+	}
+	if err := dba.VerifyPeerUpdates(v.ps.AtHeight(height+v.conf.ValidatorUpdateDelay), updates); err != nil {
+		result = multierr.Append(result, errors.Wrapf(model.ErrInvalidValidatorUpdate, err.Error()))
+	}
+	return result
+}
+
+func NewStatefulValidator(conf *config.BBFTConfig, bc dba.BlockChain, ps dba.PeerService, selector model.LeaderSelector) model.StatefulValidator {
+	return &StatefulValidator{conf, bc, ps, selector, NewCommitCertificateValidator(conf, ps)}
+}
+
+// StatelessValidator は自分の状態を使わずに Block と Transaction を検証する
+// 署名は ed25519 のバッチ検証 (複数の署名をまとめて1回の multi-scalar 乗算で確かめる) をせず、1つずつ検証する。
+// golang.org/x/crypto/ed25519 はバッチ検証の API を持たず、曲線の演算を公開していないので、
+// BlockValidate は workers 個の goroutine で並列に検証することと verified で検証済みの署名を省くことで速くする
 type StatelessValidator struct {
+	conf    *config.BBFTConfig
+	workers int
+	// verified は TxGate, Propagate, Propose, BlockValidate で同じ Transaction の署名を何度も検証しないために使う
+	verified *VerifiedTxCache
 }
 
-func NewStatelessValidator() model.StatelessValidator {
-	return &StatelessValidator{}
+func NewStatelessValidator(conf *config.BBFTConfig) model.StatelessValidator {
+	workers := conf.VerifyWorkers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	return &StatelessValidator{conf, workers, NewVerifiedTxCache(conf.VerifiedTxCacheSize)}
+}
+
+// parallel は f(0), ..., f(n-1) を workers 個の goroutine に分けて実行し、全て終わるまで待つ
+func parallel(n int, workers int, f func(i int)) {
+	if workers > n {
+		workers = n
+	}
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			f(i)
+		}
+		return
+	}
+	next := int64(-1)
+	wg := new(sync.WaitGroup)
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := int(atomic.AddInt64(&next, 1)); i < n; i = int(atomic.AddInt64(&next, 1)) {
+				f(i)
+			}
+		}()
+	}
+	wg.Wait()
 }
 
 func (v *StatelessValidator) BlockValidate(block model.Block) error {
@@ -58,23 +192,340 @@ func (v *StatelessValidator) BlockValidate(block model.Block) error {
 	if block == nil {
 		return errors.Wrapf(model.ErrInvalidBlock, "Block is nil")
 	}
-	for _, tx := range block.GetTransactions() {
-		if err := v.TxValidate(tx); err != nil {
+	if size := block.GetSize(); size > v.conf.MaxBlockBytes {
+		result = multierr.Append(result, errors.Wrapf(model.ErrBlockTooLarge, "size: %d, max: %d", size, v.conf.MaxBlockBytes))
+	}
+	// 各 Transaction の TxValidate を workers 個の goroutine で並べて行う。署名はバッチ検証せず1つずつ検証する
+	txs := block.GetTransactions()
+	txHashes := make([][]byte, len(txs))
+	errs := make([]error, len(txs))
+	parallel(len(txs), v.workers, func(i int) {
+		txHashes[i] = verifiedTxKey(txs[i])
+		errs[i] = v.txValidate(txs[i], txHashes[i])
+	})
+
+	var cost int64
+	for i, tx := range txs {
+		if err := errs[i]; err != nil {
 			result = multierr.Append(result, errors.Wrapf(model.ErrStatelessTxValidate, err.Error()))
+		} else {
+			cost += tx.GetPayload().GetCost()
 		}
 	}
-	if err := block.Verify(); err != nil {
+	if v.conf.MaxBlockCost > 0 && cost > v.conf.MaxBlockCost {
+		result = multierr.Append(result, errors.Wrapf(model.ErrBlockCostExceeded, "cost: %d, max: %d", cost, v.conf.MaxBlockCost))
+	}
+	if err := v.blockVerify(block, txHashes); err != nil {
 		result = multierr.Append(result, errors.Wrapf(model.ErrBlockVerify, err.Error()))
 	}
+	if timing := block.GetHeader().GetTiming(); timing.GetConnectDelay() < 0 || timing.GetPropose() < 0 ||
+		timing.GetVote() < 0 || timing.GetPreCommit() < 0 {
+		result = multierr.Append(result, errors.Wrapf(model.ErrInvalidBlockHeader, "timing must not be negative: %#v", timing))
+	}
+	if version := block.GetHeader().GetVersion(); version != model.BlockHeaderVersion {
+		result = multierr.Append(result, errors.Wrapf(model.ErrInvalidBlockVersion, "version: %d, expected %d", version, model.BlockHeaderVersion))
+	}
 	return result
 }
 
+// blockVerify は BlockValidate で計算した Transaction の Hash を使って Block の txRoot と署名を検証する
+// Hash を計算できなかった Transaction があるときは Block の Verify に任せる
+func (v *StatelessValidator) blockVerify(block model.Block, txHashes [][]byte) error {
+	b, ok := block.(*Block)
+	if !ok {
+		return block.Verify()
+	}
+	for _, hash := range txHashes {
+		if hash == nil {
+			return block.Verify()
+		}
+	}
+	return b.verifyWithTxHashes(txHashes)
+}
+
+// verifiedTxKey は VerifiedTxCache に使う tx の (署名を含む) Hash を返す。計算できないときは nil を返す
+func verifiedTxKey(tx model.Transaction) []byte {
+	t, ok := tx.(*Transaction)
+	if !ok || t == nil || t.Transaction == nil {
+		return nil
+	}
+	hash, err := CalcHashFromProto(t.Transaction)
+	if err != nil {
+		return nil
+	}
+	return hash
+}
+
 func (v *StatelessValidator) TxValidate(tx model.Transaction) error {
+	return v.txValidate(tx, verifiedTxKey(tx))
+}
+
+// txValidate は key が VerifiedTxCache にあれば tx の署名の検証を省く
+func (v *StatelessValidator) txValidate(tx model.Transaction, key []byte) error {
 	if tx == nil {
 		return errors.Wrapf(model.ErrInvalidTransaction, "tx is nil")
 	}
-	if err := tx.Verify(); err != nil {
-		return errors.Wrapf(model.ErrTransactionVerify, err.Error())
+	if size := tx.GetSize(); size > v.conf.MaxTxBytes {
+		return errors.Wrapf(model.ErrTransactionTooLarge, "size: %d, max: %d", size, v.conf.MaxTxBytes)
+	}
+	if cost := tx.GetPayload().GetCost(); cost < 0 {
+		return errors.Wrapf(model.ErrInvalidTransactionCost, "cost must not be negative: %d", cost)
+	}
+	if key == nil || !v.verified.Contains(key) {
+		if err := tx.Verify(); err != nil {
+			return errors.Wrapf(model.ErrTransactionVerify, err.Error())
+		}
+		if key != nil {
+			v.verified.Add(key)
+		}
+	}
+	if update, ok := tx.GetPayload().GetValidatorUpdate(); ok {
+		return validateValidatorUpdate(update)
+	}
+	return nil
+}
+
+// validateValidatorUpdate は ValidatorUpdate の type に必要な field が揃っているかを検証する
+func validateValidatorUpdate(update model.ValidatorUpdate) error {
+	switch update.GetType() {
+	case model.AddValidator:
+		if update.GetAddress() == "" || len(update.GetPubkey()) != ed25519.PublicKeySize || update.GetPower() <= 0 {
+			return errors.Wrapf(model.ErrInvalidValidatorUpdate, "add validator, address: %s, pubkey: %x, power: %d",
+				update.GetAddress(), update.GetPubkey(), update.GetPower())
+		}
+	case model.RemoveValidator:
+		if len(update.GetPubkey()) != ed25519.PublicKeySize {
+			return errors.Wrapf(model.ErrInvalidValidatorUpdate, "remove validator, pubkey: %x", update.GetPubkey())
+		}
+	case model.RekeyValidator:
+		if update.GetAddress() == "" ||
+			len(update.GetPubkey()) != ed25519.PublicKeySize ||
+			len(update.GetNewPubkey()) != ed25519.PublicKeySize ||
+			bytes.Equal(update.GetPubkey(), update.GetNewPubkey()) {
+			return errors.Wrapf(model.ErrInvalidValidatorUpdate, "rekey validator, address: %s, pubkey: %x, newPubkey: %x",
+				update.GetAddress(), update.GetPubkey(), update.GetNewPubkey())
+		}
+	default:
+		return errors.Wrapf(model.ErrInvalidValidatorUpdate, "unknown validator update type: %d", update.GetType())
+	}
+	return nil
+}
+
+type CommitCertificateValidator struct {
+	conf *config.BBFTConfig
+	ps   dba.PeerService
+}
+
+func NewCommitCertificateValidator(conf *config.BBFTConfig, ps dba.PeerService) model.CommitCertificateValidator {
+	return &CommitCertificateValidator{conf, ps}
+}
+
+// Validate は cert が block を Commit した根拠として正しいかを検証する
+// cert に含まれる PreCommit のうち、署名が正しく、cert と同じ Height, Round の block の Hash に対する、
+// cert の height の Peer からの重複しない PreCommit が voting power の 2/3 以上必要
+func (v *CommitCertificateValidator) Validate(block model.Block, cert model.CommitCertificate) error {
+	if block == nil {
+		return errors.Wrapf(model.ErrInvalidBlock, "Block is nil")
+	}
+	if cert == nil {
+		return errors.Wrapf(model.ErrInvalidCommitCertificate, "CommitCertificate is nil")
+	}
+	hash, err := block.GetHash()
+	if err != nil {
+		return errors.Wrapf(model.ErrBlockGetHash, err.Error())
+	}
+	return v.validate(block.GetHeader().GetHeight(), hash, cert)
+}
+
+func (v *CommitCertificateValidator) ValidateLightBlock(block model.LightBlock, cert model.CommitCertificate) error {
+	if block == nil {
+		return errors.Wrapf(model.ErrInvalidBlock, "LightBlock is nil")
+	}
+	if cert == nil {
+		return errors.Wrapf(model.ErrInvalidCommitCertificate, "CommitCertificate is nil")
+	}
+	hash, err := block.GetHash()
+	if err != nil {
+		return errors.Wrapf(model.ErrBlockGetHash, err.Error())
+	}
+	return v.validate(block.GetHeader().GetHeight(), hash, cert)
+}
+
+// ValidateTxProof は proof の Transaction が header の txRoot に含まれ、その Block が proof の CommitCertificate で Commit されたことを検証する
+func (v *CommitCertificateValidator) ValidateTxProof(proof model.TxProof) error {
+	if proof == nil {
+		return errors.Wrapf(model.ErrInvalidTxProof, "TxProof is nil")
+	}
+	if err := proof.Verify(); err != nil {
+		return errors.Wrapf(model.ErrTxProofVerify, err.Error())
+	}
+	cert := proof.GetCommitCertificate()
+	if cert == nil {
+		return errors.Wrapf(model.ErrInvalidCommitCertificate, "CommitCertificate is nil")
+	}
+	hash, err := proof.GetHash()
+	if err != nil {
+		return errors.Wrapf(model.ErrBlockGetHash, err.Error())
+	}
+	return v.validate(proof.GetHeader().GetHeight(), hash, cert)
+}
+
+// ValidateSignatures は cert の BlockHash の Block に 2/3 以上の voting power が PreCommit したことだけを検証する
+// Block の中身は検証しないので、他の Peer が進んでいることを知るためだけに使う
+func (v *CommitCertificateValidator) ValidateSignatures(cert model.CommitCertificate) error {
+	if cert == nil {
+		return errors.Wrapf(model.ErrInvalidCommitCertificate, "CommitCertificate is nil")
+	}
+	return v.validate(cert.GetHeight(), cert.GetBlockHash(), cert)
+}
+
+// validate は cert が height, hash の Block を Commit した根拠として正しいかを検証する
+func (v *CommitCertificateValidator) validate(height int64, hash []byte, cert model.CommitCertificate) error {
+	if cert.GetHeight() != height {
+		return errors.Wrapf(model.ErrInvalidCommitCertificate, "height: %d, expected %d", cert.GetHeight(), height)
+	}
+	if !bytes.Equal(cert.GetBlockHash(), hash) {
+		return errors.Wrapf(model.ErrInvalidCommitCertificate, "blockHash: %x, expected %x", cert.GetBlockHash(), hash)
+	}
+
+	var result error
+	peers := v.ps.AtHeight(cert.GetHeight())
+	signers := make([][]byte, 0, len(cert.GetPreCommits()))
+	for _, preCommit := range cert.GetPreCommits() {
+		if !bytes.Equal(preCommit.GetBlockHash(), hash) {
+			result = multierr.Append(result, errors.Wrapf(model.ErrInvalidVoteMessage, "preCommit blockHash: %x, expected %x", preCommit.GetBlockHash(), hash))
+			continue
+		}
+		if preCommit.GetType() != model.PreCommit ||
+			preCommit.GetHeight() != cert.GetHeight() ||
+			preCommit.GetRound() != cert.GetRound() ||
+			preCommit.GetChainId() != v.conf.ChainId {
+			result = multierr.Append(result, errors.Wrapf(model.ErrInvalidVoteMessage,
+				"preCommit is not for this certificate, type: %d, height: %d, round: %d, chainId: %s",
+				preCommit.GetType(), preCommit.GetHeight(), preCommit.GetRound(), preCommit.GetChainId()))
+			continue
+		}
+		if err := preCommit.Verify(); err != nil {
+			result = multierr.Append(result, errors.Wrapf(model.ErrVoteMessageVerify, err.Error()))
+			continue
+		}
+		pubkey := preCommit.GetSignature().GetPubkey()
+		if _, ok := peers.GetPeer(pubkey); !ok {
+			result = multierr.Append(result, errors.Wrapf(model.ErrInvalidVoteMessage, "preCommit signer is not peer: %x", pubkey))
+			continue
+		}
+		signers = append(signers, pubkey)
+	}
+	if power := peers.GetPower(signers); power < peers.GetRequiredAcceptPower() {
+		return multierr.Append(errors.Wrapf(ErrCommitCertificateNotEnoughPreCommits,
+			"valid preCommits power: %d, required: %d", power, peers.GetRequiredAcceptPower()), result)
+	}
+	return nil
+}
+
+type EvidenceValidator struct {
+	conf *config.BBFTConfig
+	ps   dba.PeerService
+}
+
+func NewEvidenceValidator(conf *config.BBFTConfig, ps dba.PeerService) model.EvidenceValidator {
+	return &EvidenceValidator{conf, ps}
+}
+
+// Validate は evidence が Peer の不正の証拠として正しいかを検証する
+// Evidence を見つけた Peer と不正をした Peer が共に Peer であり、
+// 2つの Proposal または PreCommit が同じ Peer の署名を持つ、同じ height, round の異なるものである必要がある
+func (v *EvidenceValidator) Validate(evidence model.Evidence) error {
+	if evidence == nil {
+		return errors.Wrapf(model.ErrInvalidEvidence, "Evidence is nil")
+	}
+	if err := evidence.Verify(); err != nil {
+		return errors.Wrapf(model.ErrEvidenceVerify, err.Error())
+	}
+	if pubkey := evidence.GetSignature().GetPubkey(); !v.isPeer(pubkey) {
+		return errors.Wrapf(model.ErrInvalidEvidence, "evidence signer is not peer: %x", pubkey)
+	}
+	if offender := evidence.GetOffender(); !v.isPeerAt(evidence.GetHeight(), offender) {
+		return errors.Wrapf(model.ErrInvalidEvidence, "offender is not peer: %x", offender)
+	}
+
+	switch evidence.GetType() {
+	case model.DuplicateProposal:
+		return v.validateDuplicateProposal(evidence)
+	case model.DuplicatePreCommit:
+		return v.validateDuplicatePreCommit(evidence)
+	}
+	return errors.Wrapf(model.ErrInvalidEvidence, "unknown evidence type: %d", evidence.GetType())
+}
+
+func (v *EvidenceValidator) isPeer(pubkey []byte) bool {
+	_, ok := v.ps.GetPeer(pubkey)
+	return ok
+}
+
+// 不正をした Peer は、その height で合意形成に参加していれば後で取り除かれていてもよい
+func (v *EvidenceValidator) isPeerAt(height int64, pubkey []byte) bool {
+	_, ok := v.ps.AtHeight(height).GetPeer(pubkey)
+	return ok
+}
+
+func (v *EvidenceValidator) validateDuplicateProposal(evidence model.Evidence) error {
+	proposals := evidence.GetProposals()
+	if len(proposals) != 2 || len(evidence.GetVotes()) != 0 {
+		return errors.Wrapf(model.ErrInvalidEvidence, "proposals: %d, votes: %d, expected 2 proposals", len(proposals), len(evidence.GetVotes()))
+	}
+	hashes := make([][]byte, 0, 2)
+	for _, proposal := range proposals {
+		block := proposal.GetBlock()
+		if err := block.Verify(); err != nil {
+			return errors.Wrapf(model.ErrBlockVerify, err.Error())
+		}
+		if block.GetHeader().GetRound() > proposal.GetRound() {
+			return errors.Wrapf(model.ErrInvalidProposal, "block round: %d, is after proposal round: %d", block.GetHeader().GetRound(), proposal.GetRound())
+		}
+		// 提案し直した Proposal は Proposal に署名したリーダーが提案したものとする
+		if block.GetHeader().GetRound() < proposal.GetRound() {
+			if err := proposal.Verify(); err != nil {
+				return errors.Wrapf(model.ErrProposalVerify, err.Error())
+			}
+		}
+		if proposal.GetRound() != evidence.GetRound() ||
+			block.GetHeader().GetHeight() != evidence.GetHeight() ||
+			!bytes.Equal(model.GetProposer(proposal), evidence.GetOffender()) {
+			return errors.Wrapf(ErrEvidenceNotConflict, "proposal is not same height, round, signer")
+		}
+		hash, err := block.GetHash()
+		if err != nil {
+			return errors.Wrapf(model.ErrBlockGetHash, err.Error())
+		}
+		hashes = append(hashes, hash)
+	}
+	if bytes.Equal(hashes[0], hashes[1]) {
+		return errors.Wrapf(ErrEvidenceNotConflict, "proposals are same block: %x", hashes[0])
+	}
+	return nil
+}
+
+func (v *EvidenceValidator) validateDuplicatePreCommit(evidence model.Evidence) error {
+	votes := evidence.GetVotes()
+	if len(votes) != 2 || len(evidence.GetProposals()) != 0 {
+		return errors.Wrapf(model.ErrInvalidEvidence, "votes: %d, proposals: %d, expected 2 votes", len(votes), len(evidence.GetProposals()))
+	}
+	for _, vote := range votes {
+		if err := vote.Verify(); err != nil {
+			return errors.Wrapf(model.ErrVoteMessageVerify, err.Error())
+		}
+		if vote.GetType() != model.PreCommit || vote.GetChainId() != v.conf.ChainId {
+			return errors.Wrapf(model.ErrInvalidVoteMessage, "type: %d, chainId: %s", vote.GetType(), vote.GetChainId())
+		}
+		if vote.GetRound() != evidence.GetRound() ||
+			vote.GetHeight() != evidence.GetHeight() ||
+			!bytes.Equal(vote.GetSignature().GetPubkey(), evidence.GetOffender()) {
+			return errors.Wrapf(ErrEvidenceNotConflict, "preCommit is not same height, round, signer")
+		}
+	}
+	if bytes.Equal(votes[0].GetBlockHash(), votes[1].GetBlockHash()) {
+		return errors.Wrapf(ErrEvidenceNotConflict, "preCommits are same blockHash: %x", votes[0].GetBlockHash())
 	}
 	return nil
 }
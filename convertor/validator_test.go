@@ -1,18 +1,22 @@
 package convertor_test
 
 import (
+	"bytes"
 	"github.com/pkg/errors"
 	. "github.com/satellitex/bbft/convertor"
 	"github.com/satellitex/bbft/dba"
 	"github.com/satellitex/bbft/model"
 	. "github.com/satellitex/bbft/test_utils"
+	"github.com/satellitex/bbft/usecase"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/multierr"
 	"testing"
 )
 
 func TestStatefulValidator_Validate(t *testing.T) {
 	bc := dba.NewBlockChainOnMemory()
-	sfv := NewStatefulValidator(bc)
+	sfv := NewStatefulValidator(GetTestConfig(), bc, dba.NewPeerServiceOnMemory(), usecase.NewLeaderSelector(GetTestConfig(), dba.NewPeerServiceOnMemory(), bc))
 
 	t.Run("success valid commitable Block", func(t *testing.T) {
 		block := RandomCommitableBlock(t, bc)
@@ -41,24 +45,170 @@ func TestStatefulValidator_Validate(t *testing.T) {
 
 	// commit block
 	commitBlock := RandomCommitableBlock(t, bc)
-	bc.Commit(commitBlock)
+	bc.Commit(commitBlock, nil)
+	ps := RandomPeerService(t, 4)
+	sfv = NewStatefulValidator(GetTestConfig(), bc, ps, usecase.NewLeaderSelector(GetTestConfig(), ps, bc))
+	proposer := ps.GetPeers()[0]
 
 	t.Run("succes valid commitable Block, exist bc", func(t *testing.T) {
-		block := RandomCommitableBlock(t, bc)
+		block := RandomCommitableBlockFromPeer(t, bc, ps, proposer)
 		err := sfv.Validate(block)
 		assert.NoError(t, err)
 	})
 
 	t.Run("failed alrady exist Tx including Block", func(t *testing.T) {
-		block := RandomCommitableBlock(t, bc)
+		block := RandomCommitableBlockFromPeer(t, bc, ps, proposer)
 		block.(*Block).Transactions = commitBlock.(*Block).Transactions
 		err := sfv.Validate(block)
 		MultiErrorInCheck(t, err, ErrStatefulValidateAlreadyExistTx)
 	})
+
+	t.Run("failed same evidence is included twice", func(t *testing.T) {
+		block := RandomCommitableBlockFromPeer(t, bc, ps, proposer)
+		evidence := RandomEvidence(t).(*Evidence).Evidence
+		block.(*Block).Evidences = append(block.(*Block).Evidences, evidence, evidence)
+		MultiErrorInCheck(t, sfv.Validate(block), dba.ErrBlockChainVerifyCommit)
+	})
+
+	t.Run("failed validatorsHash of other peers", func(t *testing.T) {
+		block := RandomCommitableBlockFromPeer(t, bc, RandomPeerService(t, 4), proposer)
+		MultiErrorInCheck(t, sfv.Validate(block), model.ErrInvalidValidatorsHash)
+	})
+
+	t.Run("failed proposer is not peer", func(t *testing.T) {
+		block := RandomCommitableBlockFromPeer(t, bc, ps, RandomPeerWithPriv())
+		MultiErrorInCheck(t, sfv.Validate(block), model.ErrInvalidProposer)
+	})
+
+	t.Run("failed proposer is not signer", func(t *testing.T) {
+		block := RandomCommitableBlockFromPeer(t, bc, ps, proposer)
+		other := ps.GetPeers()[1]
+		require.NoError(t, block.Sign(other.GetPubkey(), other.(*PeerWithPriv).PrivKey))
+		MultiErrorInCheck(t, sfv.Validate(block), model.ErrInvalidProposer)
+	})
+
+	t.Run("failed proposer is not leader of round", func(t *testing.T) {
+		block := RandomCommitableBlockFromPeer(t, bc, ps, proposer)
+		height := block.GetHeader().GetHeight()
+		selector := usecase.NewLeaderSelector(GetTestConfig(), ps, bc)
+		for round := int32(0); ; round++ {
+			if leader, ok := selector.GetLeader(height, round); ok && !bytes.Equal(leader.GetPubkey(), proposer.GetPubkey()) {
+				block.(*Block).Header.Round = round
+				break
+			}
+		}
+		require.NoError(t, block.Sign(proposer.GetPubkey(), proposer.(*PeerWithPriv).PrivKey))
+		MultiErrorInCheck(t, sfv.Validate(block), model.ErrInvalidProposer)
+	})
+
+	t.Run("failed stateRoot is not next of top", func(t *testing.T) {
+		block := RandomCommitableBlockFromPeer(t, bc, ps, proposer)
+		block.(*Block).Header.StateRoot = RandomByte()
+		MultiErrorInCheck(t, sfv.Validate(block), dba.ErrBlockChainVerifyCommit)
+	})
+
+	// commit block with CommitCertificate
+	certBlock := RandomCommitableBlockFromPeer(t, bc, ps, proposer)
+	bc.Commit(certBlock, RandomCommitCertificate(t, certBlock, ps.GetPeers()))
+
+	t.Run("success lastCommit commits top", func(t *testing.T) {
+		block := RandomCommitableBlockFromPeer(t, bc, ps, proposer)
+		assert.NoError(t, sfv.Validate(block))
+	})
+
+	t.Run("failed lastCommit is nil", func(t *testing.T) {
+		block := RandomCommitableBlockFromPeer(t, bc, ps, proposer)
+		block.(*Block).LastCommit = nil
+		MultiErrorInCheck(t, sfv.Validate(block), dba.ErrBlockChainVerifyCommit)
+	})
+
+	t.Run("failed lastCommit has not enough preCommits", func(t *testing.T) {
+		block := RandomCommitableBlockFromPeer(t, bc, ps, proposer)
+		block.(*Block).LastCommit = RandomCommitCertificate(t, certBlock, ps.GetPeers()[:1]).(*CommitCertificate).CommitCertificate
+		MultiErrorInCheck(t, sfv.Validate(block), model.ErrInvalidLastCommit)
+	})
+}
+
+func TestStatefulValidator_ValidateValidatorUpdate(t *testing.T) {
+	conf := GetTestConfig()
+	factory := NewModelFactory()
+	bc := dba.NewBlockChainOnMemory()
+	ps := RandomPeerService(t, 4)
+	sfv := NewStatefulValidator(conf, bc, ps, usecase.NewLeaderSelector(conf, ps, bc))
+	bc.Commit(RandomCommitableBlock(t, bc), nil)
+
+	peers := ps.GetPeers()
+	added := RandomPeerWithPriv()
+	addUpdate := factory.NewValidatorUpdate(model.AddValidator, added.GetAddress(), added.GetPubkey(), nil, 1)
+
+	blockWithTx := func(t *testing.T, tx model.Transaction) model.Block {
+		block := RandomCommitableBlockFromPeer(t, bc, ps, peers[0])
+		block.(*Block).Transactions = append(block.(*Block).Transactions, tx.(*Transaction).Transaction)
+		return block
+	}
+
+	t.Run("success signed by 2/3 peers", func(t *testing.T) {
+		block := blockWithTx(t, ValidatorUpdateTx(t, addUpdate, peers[:3]))
+		assert.NoError(t, sfv.Validate(block))
+	})
+
+	t.Run("failed signed by less than 2/3 peers", func(t *testing.T) {
+		block := blockWithTx(t, ValidatorUpdateTx(t, addUpdate, append([]model.Peer{added}, peers[:2]...)))
+		MultiErrorInCheck(t, sfv.Validate(block), ErrValidatorUpdateNotEnoughSignatures)
+	})
+
+	t.Run("failed same peer signed twice", func(t *testing.T) {
+		block := blockWithTx(t, ValidatorUpdateTx(t, addUpdate, []model.Peer{peers[0], peers[0], peers[1]}))
+		MultiErrorInCheck(t, sfv.Validate(block), ErrValidatorUpdateNotEnoughSignatures)
+	})
+
+	t.Run("failed can not apply to validators", func(t *testing.T) {
+		update := factory.NewValidatorUpdate(model.RemoveValidator, "", added.GetPubkey(), nil, 0)
+		block := blockWithTx(t, ValidatorUpdateTx(t, update, peers[:3]))
+		MultiErrorInCheck(t, sfv.Validate(block), model.ErrInvalidValidatorUpdate)
+	})
+
+	t.Run("success validate with validators at activated height", func(t *testing.T) {
+		require.NoError(t, ps.Update(1+conf.ValidatorUpdateDelay, []model.ValidatorUpdate{addUpdate}))
+		update := factory.NewValidatorUpdate(model.RemoveValidator, "", added.GetPubkey(), nil, 0)
+		block := blockWithTx(t, ValidatorUpdateTx(t, update, peers[:3]))
+		assert.NoError(t, sfv.Validate(block))
+	})
+}
+
+func TestStatelessValidator_TxValidateValidatorUpdate(t *testing.T) {
+	slv := NewStatelessValidator(GetTestConfig())
+	factory := NewModelFactory()
+	pub, _ := NewKeyPair()
+	newPub, _ := NewKeyPair()
+
+	for _, c := range []struct {
+		name   string
+		update model.ValidatorUpdate
+		err    error
+	}{
+		{"success add validator", factory.NewValidatorUpdate(model.AddValidator, RandomStr(), pub, nil, 1), nil},
+		{"success remove validator", factory.NewValidatorUpdate(model.RemoveValidator, "", pub, nil, 0), nil},
+		{"success rekey validator", factory.NewValidatorUpdate(model.RekeyValidator, RandomStr(), pub, newPub, 0), nil},
+		{"failed add validator without address", factory.NewValidatorUpdate(model.AddValidator, "", pub, nil, 1), model.ErrInvalidValidatorUpdate},
+		{"failed add validator invalid pubkey", factory.NewValidatorUpdate(model.AddValidator, RandomStr(), []byte("short"), nil, 1), model.ErrInvalidValidatorUpdate},
+		{"failed remove validator invalid pubkey", factory.NewValidatorUpdate(model.RemoveValidator, "", nil, nil, 0), model.ErrInvalidValidatorUpdate},
+		{"failed rekey validator same pubkey", factory.NewValidatorUpdate(model.RekeyValidator, RandomStr(), pub, pub, 0), model.ErrInvalidValidatorUpdate},
+		{"failed unknown type", factory.NewValidatorUpdate(model.UnknownValidatorUpdate, RandomStr(), pub, nil, 0), model.ErrInvalidValidatorUpdate},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			err := slv.TxValidate(ValidatorUpdateTx(t, c.update, []model.Peer{RandomPeerWithPriv()}))
+			if c.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, errors.Cause(err), c.err.Error())
+			}
+		})
+	}
 }
 
 func TestStatelessValidator_Validate(t *testing.T) {
-	slv := NewStatelessValidator()
+	slv := NewStatelessValidator(GetTestConfig())
 	t.Run("success valid key and valid txs", func(t *testing.T) {
 		block := ValidSignedBlock(t)
 		assert.NoError(t, slv.BlockValidate(block))
@@ -78,6 +228,38 @@ func TestStatelessValidator_Validate(t *testing.T) {
 	t.Run("failed nil block", func(t *testing.T) {
 		assert.EqualError(t, errors.Cause(slv.BlockValidate(nil)), model.ErrInvalidBlock.Error())
 	})
+	t.Run("failed negative timing", func(t *testing.T) {
+		block, err := NewModelFactory().NewTimedBlock(1, nil, 0, nil, nil, nil, NewModelFactory().NewPhaseTiming(1, -1, 1, 1))
+		require.NoError(t, err)
+		validPub, validPri := NewKeyPair()
+		require.NoError(t, block.Sign(validPub, validPri))
+		MultiErrorInCheck(t, slv.BlockValidate(block), model.ErrInvalidBlockHeader)
+	})
+	t.Run("failed unknown header version", func(t *testing.T) {
+		block := RandomValidBlock(t)
+		block.(*Block).Header.Version = model.BlockHeaderVersion + 1
+		ValidSign(t, block)
+		MultiErrorInCheck(t, slv.BlockValidate(block), model.ErrInvalidBlockVersion)
+	})
+	t.Run("failed too large block", func(t *testing.T) {
+		block := ValidSignedBlock(t)
+		conf := GetTestConfig()
+		conf.MaxBlockBytes = block.GetSize() - 1
+		MultiErrorInCheck(t, NewStatelessValidator(conf).BlockValidate(block), model.ErrBlockTooLarge)
+	})
+	t.Run("failed block cost exceeded", func(t *testing.T) {
+		txs := []model.Transaction{RandomValidTxWithCost(t, 3), RandomValidTxWithCost(t, 4)}
+		block, err := NewModelFactory().NewBlock(1, nil, 0, txs, nil, nil)
+		require.NoError(t, err)
+		validPub, validPri := NewKeyPair()
+		require.NoError(t, block.Sign(validPub, validPri))
+
+		conf := GetTestConfig()
+		conf.MaxBlockCost = 7
+		assert.NoError(t, NewStatelessValidator(conf).BlockValidate(block))
+		conf.MaxBlockCost = 6
+		MultiErrorInCheck(t, NewStatelessValidator(conf).BlockValidate(block), model.ErrBlockCostExceeded)
+	})
 
 	t.Run("success valid txValidate", func(t *testing.T) {
 		err := slv.TxValidate(RandomValidTx(t))
@@ -91,4 +273,329 @@ func TestStatelessValidator_Validate(t *testing.T) {
 		err := slv.TxValidate(RandomInvalidTx(t))
 		assert.EqualError(t, errors.Cause(err), model.ErrTransactionVerify.Error())
 	})
+	t.Run("failed too large txValidate", func(t *testing.T) {
+		tx := RandomValidTx(t)
+		conf := GetTestConfig()
+		conf.MaxTxBytes = tx.GetSize() - 1
+		err := NewStatelessValidator(conf).TxValidate(tx)
+		assert.EqualError(t, errors.Cause(err), model.ErrTransactionTooLarge.Error())
+	})
+	t.Run("failed negative cost txValidate", func(t *testing.T) {
+		err := slv.TxValidate(RandomValidTxWithCost(t, -1))
+		assert.EqualError(t, errors.Cause(err), model.ErrInvalidTransactionCost.Error())
+	})
+}
+
+// signedBlockWithTxs は n 個の Transaction を持つ署名済みの Block を返す。invalid の位置の Transaction は署名が正しくない
+func signedBlockWithTxs(tb testing.TB, n int, invalid ...int) model.Block {
+	txs := make([]model.Transaction, n)
+	for i := range txs {
+		pub, pri := NewKeyPair()
+		tx, err := NewTxModelBuilder().Message(RandomStr()).Sign(pub, pri).Build()
+		require.NoError(tb, err)
+		txs[i] = tx
+	}
+	for _, i := range invalid {
+		tx, err := NewTxModelBuilder().Message(RandomStr()).Signature(RandomInvalidSig()).Build()
+		require.NoError(tb, err)
+		txs[i] = tx
+	}
+	block, err := NewModelFactory().NewBlock(1, nil, 0, txs, nil, nil)
+	require.NoError(tb, err)
+	pub, pri := NewKeyPair()
+	require.NoError(tb, block.Sign(pub, pri))
+	return block
+}
+
+// 署名はバッチ検証せずに1つずつ検証するので、並列に検証しても不正な署名の Transaction ごとに順にエラーを返す
+func TestStatelessValidator_ParallelBlockValidate(t *testing.T) {
+	sequentialConf := GetTestConfig()
+	sequentialConf.VerifyWorkers = 1
+	parallelConf := GetTestConfig()
+	parallelConf.VerifyWorkers = 4
+
+	t.Run("success valid block", func(t *testing.T) {
+		block := signedBlockWithTxs(t, 50)
+		assert.NoError(t, NewStatelessValidator(parallelConf).BlockValidate(block))
+	})
+	t.Run("failed invalid txs, same errors as sequential", func(t *testing.T) {
+		block := signedBlockWithTxs(t, 50, 3, 17, 49)
+		err := NewStatelessValidator(parallelConf).BlockValidate(block)
+		require.Error(t, err)
+		assert.Equal(t, 3, len(multierr.Errors(err)))
+		assert.Equal(t, NewStatelessValidator(sequentialConf).BlockValidate(block).Error(), err.Error())
+	})
+	t.Run("failed invalid block signature", func(t *testing.T) {
+		block := signedBlockWithTxs(t, 50)
+		block.(*Block).Signature.Signature = RandomByte()
+		MultiErrorInCheck(t, NewStatelessValidator(parallelConf).BlockValidate(block), model.ErrBlockVerify)
+	})
+}
+
+func TestStatelessValidator_VerifiedTxCache(t *testing.T) {
+	slv := NewStatelessValidator(GetTestConfig())
+
+	t.Run("success block of txs already validated", func(t *testing.T) {
+		block := signedBlockWithTxs(t, 10)
+		for _, tx := range block.GetTransactions() {
+			require.NoError(t, slv.TxValidate(tx))
+		}
+		assert.NoError(t, slv.BlockValidate(block))
+	})
+	t.Run("failed mutated signature after validated", func(t *testing.T) {
+		tx := RandomValidTx(t)
+		require.NoError(t, slv.TxValidate(tx))
+		tx.(*Transaction).Signatures[0].Signature = RandomByte()
+		assert.EqualError(t, errors.Cause(slv.TxValidate(tx)), model.ErrTransactionVerify.Error())
+	})
+	t.Run("failed mutated payload after validated", func(t *testing.T) {
+		tx := RandomValidTx(t)
+		require.NoError(t, slv.TxValidate(tx))
+		tx.(*Transaction).Payload.Todo = RandomStr()
+		assert.EqualError(t, errors.Cause(slv.TxValidate(tx)), model.ErrTransactionVerify.Error())
+	})
+	t.Run("failed mutated tx in block after validated", func(t *testing.T) {
+		block := signedBlockWithTxs(t, 10)
+		require.NoError(t, slv.BlockValidate(block))
+		block.(*Block).Transactions[3].Payload.Todo = RandomStr()
+		err := slv.BlockValidate(block)
+		MultiErrorInCheck(t, err, model.ErrStatelessTxValidate)
+		MultiErrorInCheck(t, err, model.ErrBlockVerify)
+	})
+}
+
+// BenchmarkStatelessValidator_BlockValidate は 200 Transaction の Block の検証を、1つずつ、並列、検証済みの cache ありで比べる
+// golang.org/x/crypto/ed25519 にはバッチ検証が無いので、バッチ検証の場合は比べない
+func BenchmarkStatelessValidator_BlockValidate(b *testing.B) {
+	block := signedBlockWithTxs(b, 200)
+	for _, c := range []struct {
+		name      string
+		workers   int
+		cacheSize int
+	}{
+		{"sequential", 1, 0},
+		{"parallel GOMAXPROCS", 0, 0},
+		{"verified tx cache", 0, 65536},
+	} {
+		b.Run(c.name, func(b *testing.B) {
+			conf := GetTestConfig()
+			conf.VerifyWorkers = c.workers
+			conf.VerifiedTxCacheSize = c.cacheSize
+			slv := NewStatelessValidator(conf)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := slv.BlockValidate(block); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestCommitCertificateValidator_Validate(t *testing.T) {
+	ps := RandomPeerService(t, 4)
+	cv := NewCommitCertificateValidator(GetTestConfig(), ps)
+	peers := ps.GetPeers()
+	required := int(ps.GetRequiredAcceptPower())
+
+	block := ValidSignedBlock(t)
+	hash := GetHash(t, block)
+	newCert := func(height int64, hash []byte, preCommits ...model.VoteMessage) model.CommitCertificate {
+		cert, err := NewModelFactory().NewCommitCertificate(height, 0, hash, preCommits)
+		require.NoError(t, err)
+		return cert
+	}
+	preCommitsFrom := func(peers []model.Peer) []model.VoteMessage {
+		ret := make([]model.VoteMessage, 0, len(peers))
+		for _, p := range peers {
+			ret = append(ret, RandomPreCommitFromPeerWithBlock(t, p, block))
+		}
+		return ret
+	}
+	height := block.GetHeader().GetHeight()
+
+	for _, c := range []struct {
+		name  string
+		block model.Block
+		cert  model.CommitCertificate
+		err   error
+	}{
+		{"success all peers", block, RandomCommitCertificate(t, block, peers), nil},
+		{"success required peers", block, RandomCommitCertificate(t, block, peers[:required]), nil},
+		{"failed nil block", nil, RandomCommitCertificate(t, block, peers), model.ErrInvalidBlock},
+		{"failed nil certificate", block, nil, model.ErrInvalidCommitCertificate},
+		{"failed invalid height", block, newCert(height+1, hash, preCommitsFrom(peers)...), model.ErrInvalidCommitCertificate},
+		{"failed invalid blockHash", block, newCert(height, RandomByte(), preCommitsFrom(peers)...), model.ErrInvalidCommitCertificate},
+		{"failed not enough preCommits", block, RandomCommitCertificate(t, block, peers[:required-1]), ErrCommitCertificateNotEnoughPreCommits},
+		{"failed duplicate preCommits", block,
+			newCert(height, hash, append(preCommitsFrom(peers[:required-1]), preCommitsFrom(peers[:1])...)...),
+			ErrCommitCertificateNotEnoughPreCommits},
+		{"failed preCommits from not peer", block,
+			newCert(height, hash, append(preCommitsFrom(peers[:required-1]), preCommitsFrom([]model.Peer{RandomPeerWithPriv()})...)...),
+			ErrCommitCertificateNotEnoughPreCommits},
+		{"failed preCommits for other block", block,
+			newCert(height, hash, append(preCommitsFrom(peers[:required-1]), RandomVoteMessageFromPeer(t, peers[required-1]))...),
+			ErrCommitCertificateNotEnoughPreCommits},
+		{"failed preVotes", block,
+			newCert(height, hash, append(preCommitsFrom(peers[:required-1]), RandomVoteMessageFromPeerWithBlock(t, peers[required-1], block))...),
+			ErrCommitCertificateNotEnoughPreCommits},
+		{"failed preCommits of other round", block,
+			newCert(height, hash, append(preCommitsFrom(peers[:required-1]), VoteMessageFromPeerWithBlockRound(t, model.PreCommit, peers[required-1], block, 1))...),
+			ErrCommitCertificateNotEnoughPreCommits},
+		{"failed unsigned preCommits", block,
+			newCert(height, hash, append(preCommitsFrom(peers[:required-1]), NewTestVoteMessage(model.PreCommit, height, 0, hash))...),
+			ErrCommitCertificateNotEnoughPreCommits},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			err := cv.Validate(c.block, c.cert)
+			if c.err == nil {
+				assert.NoError(t, err)
+				return
+			}
+			MultiErrorInCheck(t, err, c.err)
+		})
+	}
+
+	t.Run("voting power of preCommits", func(t *testing.T) {
+		ps := dba.NewPeerServiceOnMemory()
+		// total 8, required 5
+		heavy := RandomPeerWithPower(5)
+		lights := []model.Peer{RandomPeerWithPower(1), RandomPeerWithPower(1), RandomPeerWithPower(1)}
+		ps.AddPeer(heavy)
+		for _, peer := range lights {
+			ps.AddPeer(peer)
+		}
+		cv := NewCommitCertificateValidator(GetTestConfig(), ps)
+		assert.NoError(t, cv.Validate(block, RandomCommitCertificate(t, block, []model.Peer{heavy})))
+		MultiErrorInCheck(t, cv.Validate(block, RandomCommitCertificate(t, block, lights)), ErrCommitCertificateNotEnoughPreCommits)
+	})
+
+	t.Run("light block of same block", func(t *testing.T) {
+		light, err := NewModelFactory().NewLightBlock(block)
+		require.NoError(t, err)
+		assert.NoError(t, cv.ValidateLightBlock(light, RandomCommitCertificate(t, block, peers[:required])))
+		MultiErrorInCheck(t, cv.ValidateLightBlock(light, RandomCommitCertificate(t, block, peers[:required-1])), ErrCommitCertificateNotEnoughPreCommits)
+		MultiErrorInCheck(t, cv.ValidateLightBlock(nil, RandomCommitCertificate(t, block, peers)), model.ErrInvalidBlock)
+	})
+
+	t.Run("signatures without block", func(t *testing.T) {
+		assert.NoError(t, cv.ValidateSignatures(RandomCommitCertificate(t, block, peers[:required])))
+		MultiErrorInCheck(t, cv.ValidateSignatures(RandomCommitCertificate(t, block, peers[:required-1])), ErrCommitCertificateNotEnoughPreCommits)
+		MultiErrorInCheck(t, cv.ValidateSignatures(nil), model.ErrInvalidCommitCertificate)
+	})
+
+	t.Run("tx proof of same block", func(t *testing.T) {
+		txHash := GetHash(t, block.GetTransactions()[0])
+		proof, err := NewModelFactory().NewTxProof(block, txHash, RandomCommitCertificate(t, block, peers[:required]))
+		require.NoError(t, err)
+		assert.NoError(t, cv.ValidateTxProof(proof))
+
+		proof, err = NewModelFactory().NewTxProof(block, txHash, RandomCommitCertificate(t, block, peers[:required-1]))
+		require.NoError(t, err)
+		MultiErrorInCheck(t, cv.ValidateTxProof(proof), ErrCommitCertificateNotEnoughPreCommits)
+
+		proof, err = NewModelFactory().NewTxProof(block, txHash, nil)
+		require.NoError(t, err)
+		MultiErrorInCheck(t, cv.ValidateTxProof(proof), model.ErrInvalidCommitCertificate)
+		MultiErrorInCheck(t, cv.ValidateTxProof(nil), model.ErrInvalidTxProof)
+	})
+
+	t.Run("success old block after validator removed", func(t *testing.T) {
+		update := NewModelFactory().NewValidatorUpdate(model.RemoveValidator, "", peers[0].GetPubkey(), nil, 0)
+		require.NoError(t, ps.Update(height+1, []model.ValidatorUpdate{update}))
+		ps.SetHeight(height + 1)
+		assert.NoError(t, cv.Validate(block, RandomCommitCertificate(t, block, peers[:required])))
+	})
+}
+
+func TestEvidenceValidator_Validate(t *testing.T) {
+	ps := RandomPeerService(t, 4)
+	ev := NewEvidenceValidator(GetTestConfig(), ps)
+	peers := ps.GetPeers()
+	factory := NewModelFactory()
+
+	signed := func(evidence model.Evidence) model.Evidence {
+		require.NoError(t, evidence.Sign(peers[1].GetPubkey(), peers[1].(*PeerWithPriv).PrivKey))
+		return evidence
+	}
+	newEvidence := func(evidenceType model.EvidenceType, proposals []model.Proposal, votes []model.VoteMessage) model.Evidence {
+		evidence, err := factory.NewEvidence(evidenceType, proposals, votes)
+		require.NoError(t, err)
+		return signed(evidence)
+	}
+	preCommit := func(peer model.Peer, chainId string, height int64, round int32, hash []byte) model.VoteMessage {
+		vote := factory.NewVoteMessage(chainId, height, round, model.PreCommit, hash)
+		require.NoError(t, vote.Sign(peer.GetPubkey(), peer.(*PeerWithPriv).PrivKey))
+		return vote
+	}
+	chainId := GetTestConfig().ChainId
+	proposal := RandomProposalWithPeer(t, 1, 0, peers[0])
+	// peers[0] が round 2 で peers[2] の Block を提案し直した Proposal
+	reproposal := func() model.Proposal {
+		p, err := factory.NewReProposal(RandomProposalWithPeer(t, 1, 0, peers[2]).GetBlock(), 2, 0)
+		require.NoError(t, err)
+		require.NoError(t, p.Sign(peers[0].GetPubkey(), peers[0].(*PeerWithPriv).PrivKey))
+		return p
+	}
+	vote := preCommit(peers[0], chainId, 1, 0, RandomByte())
+
+	for _, c := range []struct {
+		name     string
+		evidence model.Evidence
+		err      error
+	}{
+		{"success duplicate proposal", DuplicateProposalEvidence(t, peers[0], peers[1], 1, 0), nil},
+		{"success duplicate preCommit", DuplicatePreCommitEvidence(t, peers[0], peers[1], 1, 0), nil},
+		{"failed nil evidence", nil, model.ErrInvalidEvidence},
+		{"failed unsigned evidence", func() model.Evidence {
+			evidence, err := factory.NewEvidence(model.DuplicatePreCommit, nil,
+				[]model.VoteMessage{vote, preCommit(peers[0], chainId, 1, 0, RandomByte())})
+			require.NoError(t, err)
+			return evidence
+		}(), model.ErrEvidenceVerify},
+		{"failed reporter is not peer", DuplicatePreCommitEvidence(t, peers[0], RandomPeerWithPriv(), 1, 0), model.ErrInvalidEvidence},
+		{"failed offender is not peer", DuplicatePreCommitEvidence(t, RandomPeerWithPriv(), peers[1], 1, 0), model.ErrInvalidEvidence},
+		{"failed unknown type", newEvidence(model.UnknownEvidence, nil, nil), model.ErrInvalidEvidence},
+		{"success re-proposal and new block in same round", newEvidence(model.DuplicateProposal,
+			[]model.Proposal{reproposal(), RandomProposalWithPeer(t, 1, 2, peers[0])}, nil), nil},
+		{"success two re-proposals in same round", newEvidence(model.DuplicateProposal,
+			[]model.Proposal{reproposal(), reproposal()}, nil), nil},
+		{"failed re-proposal with invalid signature", newEvidence(model.DuplicateProposal,
+			[]model.Proposal{reproposal(), func() model.Proposal {
+				p := reproposal()
+				p.(*Proposal).PolRound++
+				return p
+			}()}, nil), model.ErrProposalVerify},
+		{"failed only one proposal", newEvidence(model.DuplicateProposal, []model.Proposal{proposal}, nil), model.ErrInvalidEvidence},
+		{"failed same proposals", newEvidence(model.DuplicateProposal, []model.Proposal{proposal, proposal}, nil), ErrEvidenceNotConflict},
+		{"failed proposals of other round", newEvidence(model.DuplicateProposal,
+			[]model.Proposal{proposal, RandomProposalWithPeer(t, 1, 1, peers[0])}, nil), ErrEvidenceNotConflict},
+		{"failed proposals of other signer", newEvidence(model.DuplicateProposal,
+			[]model.Proposal{proposal, RandomProposalWithPeer(t, 1, 0, peers[2])}, nil), ErrEvidenceNotConflict},
+		{"failed proposal with invalid block signature", newEvidence(model.DuplicateProposal,
+			[]model.Proposal{proposal, func() model.Proposal {
+				p := RandomProposalWithPeer(t, 1, 0, peers[0])
+				p.(*Proposal).Block.Header.CreatedTime++
+				return p
+			}()}, nil), model.ErrBlockVerify},
+		{"failed only one preCommit", newEvidence(model.DuplicatePreCommit, nil, []model.VoteMessage{vote}), model.ErrInvalidEvidence},
+		{"failed same preCommits", newEvidence(model.DuplicatePreCommit, nil, []model.VoteMessage{vote, vote}), ErrEvidenceNotConflict},
+		{"failed preCommits of other height", newEvidence(model.DuplicatePreCommit, nil,
+			[]model.VoteMessage{vote, preCommit(peers[0], chainId, 2, 0, RandomByte())}), ErrEvidenceNotConflict},
+		{"failed preCommits of other signer", newEvidence(model.DuplicatePreCommit, nil,
+			[]model.VoteMessage{vote, preCommit(peers[2], chainId, 1, 0, RandomByte())}), ErrEvidenceNotConflict},
+		{"failed preCommits of other chain", newEvidence(model.DuplicatePreCommit, nil,
+			[]model.VoteMessage{preCommit(peers[0], "other", 1, 0, RandomByte()), preCommit(peers[0], "other", 1, 0, RandomByte())}), model.ErrInvalidVoteMessage},
+		{"failed preVotes", newEvidence(model.DuplicatePreCommit, nil,
+			[]model.VoteMessage{RandomVoteMessageFromPeer(t, peers[0]), RandomVoteMessageFromPeer(t, peers[0])}), model.ErrInvalidVoteMessage},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			err := ev.Validate(c.evidence)
+			if c.err == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, errors.Cause(err), c.err.Error())
+		})
+	}
 }
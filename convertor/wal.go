@@ -0,0 +1,254 @@
+package convertor
+
+import (
+	"encoding/binary"
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+	"github.com/satellitex/bbft/model"
+	"github.com/satellitex/bbft/proto"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var ErrWALOpen = errors.Errorf("Failed WAL Open")
+
+type WALRecord struct {
+	*bbft.WALRecord
+}
+
+func (r *WALRecord) GetType() model.WALRecordType {
+	if r.WALRecord == nil {
+		return model.WALRound
+	}
+	return model.WALRecordType(r.Type)
+}
+
+func (r *WALRecord) IsSent() bool {
+	return r.GetSent()
+}
+
+func (r *WALRecord) GetProposal() model.Proposal {
+	if r.WALRecord == nil || r.Proposal == nil {
+		return nil
+	}
+	return &Proposal{r.Proposal}
+}
+
+func (r *WALRecord) GetVote() model.VoteMessage {
+	if r.WALRecord == nil || r.Vote == nil {
+		return nil
+	}
+	return &VoteMessage{r.Vote}
+}
+
+// 各記録の前に置く 長さ (4byte) と CRC32 (4byte)
+const walHeaderSize = 8
+
+// WALOnFile は記録を1つのファイルに追記する WAL
+// 記録は 長さ, CRC32, WALRecord の順に書き、書くたびに fsync する
+type WALOnFile struct {
+	path  string
+	file  *os.File
+	mutex *sync.Mutex
+}
+
+// NewWALOnFile は path の WAL を開く。ファイルが無ければ作る
+// 書いている途中で落ちて最後の記録が壊れているときは、壊れた記録を切り詰めてから追記する
+func NewWALOnFile(path string) (model.WAL, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, errors.Wrapf(ErrWALOpen, err.Error())
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, errors.Wrapf(ErrWALOpen, err.Error())
+	}
+	w := &WALOnFile{path, file, new(sync.Mutex)}
+	_, valid, err := w.read()
+	if err != nil {
+		file.Close()
+		return nil, errors.Wrapf(ErrWALOpen, err.Error())
+	}
+	if err := file.Truncate(valid); err != nil {
+		file.Close()
+		return nil, errors.Wrapf(ErrWALOpen, err.Error())
+	}
+	return w, nil
+}
+
+func encodeWALRecord(record *bbft.WALRecord) ([]byte, error) {
+	body, err := proto.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, walHeaderSize+len(body))
+	binary.BigEndian.PutUint32(buf, uint32(len(body)))
+	binary.BigEndian.PutUint32(buf[4:], crc32.ChecksumIEEE(body))
+	copy(buf[walHeaderSize:], body)
+	return buf, nil
+}
+
+// read はファイルの先頭から壊れていない記録を読み、記録と読めた所までの長さを返す
+func (w *WALOnFile) read() ([]*bbft.WALRecord, int64, error) {
+	data, err := ioutil.ReadFile(w.path)
+	if err != nil {
+		return nil, 0, err
+	}
+	records := make([]*bbft.WALRecord, 0)
+	offset := 0
+	for offset+walHeaderSize <= len(data) {
+		size := int(binary.BigEndian.Uint32(data[offset:]))
+		sum := binary.BigEndian.Uint32(data[offset+4:])
+		start := offset + walHeaderSize
+		if size > len(data)-start {
+			break
+		}
+		body := data[start : start+size]
+		if crc32.ChecksumIEEE(body) != sum {
+			break
+		}
+		record := &bbft.WALRecord{}
+		if err := proto.Unmarshal(body, record); err != nil {
+			break
+		}
+		records = append(records, record)
+		offset = start + size
+	}
+	return records, int64(offset), nil
+}
+
+func (w *WALOnFile) write(record *bbft.WALRecord) error {
+	buf, err := encodeWALRecord(record)
+	if err != nil {
+		return errors.Wrapf(model.ErrWALWrite, err.Error())
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if _, err := w.file.Write(buf); err != nil {
+		return errors.Wrapf(model.ErrWALWrite, err.Error())
+	}
+	if err := w.file.Sync(); err != nil {
+		return errors.Wrapf(model.ErrWALWrite, err.Error())
+	}
+	return nil
+}
+
+func (w *WALOnFile) WriteRound(height int64, round int32) error {
+	return w.write(&bbft.WALRecord{
+		Type:   bbft.WALRecordType_WAL_ROUND,
+		Height: height,
+		Round:  round,
+	})
+}
+
+func (w *WALOnFile) WriteProposal(proposal model.Proposal, sent bool) error {
+	p, ok := proposal.(*Proposal)
+	if !ok {
+		return errors.Wrapf(model.ErrInvalidProposal,
+			"Can not cast Proposal model: %#v.", proposal)
+	}
+	return w.write(&bbft.WALRecord{
+		Type:     bbft.WALRecordType_WAL_PROPOSAL,
+		Height:   p.GetBlock().GetHeader().GetHeight(),
+		Round:    p.GetRound(),
+		Sent:     sent,
+		Proposal: p.Proposal,
+	})
+}
+
+func (w *WALOnFile) WriteVote(vote model.VoteMessage, sent bool) error {
+	v, ok := vote.(*VoteMessage)
+	if !ok {
+		return errors.Wrapf(model.ErrInvalidVoteMessage,
+			"Can not cast VoteMessage model: %#v.", vote)
+	}
+	recordType := bbft.WALRecordType_WAL_VOTE
+	if v.GetType() == model.PreCommit {
+		recordType = bbft.WALRecordType_WAL_PRECOMMIT
+	}
+	return w.write(&bbft.WALRecord{
+		Type:   recordType,
+		Height: v.GetHeight(),
+		Round:  v.GetRound(),
+		Sent:   sent,
+		Vote:   v.VoteMessage,
+	})
+}
+
+func (w *WALOnFile) ReadAll() ([]model.WALRecord, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	records, _, err := w.read()
+	if err != nil {
+		return nil, errors.Wrapf(model.ErrWALRead, err.Error())
+	}
+	ret := make([]model.WALRecord, len(records))
+	for id, record := range records {
+		ret[id] = &WALRecord{record}
+	}
+	return ret, nil
+}
+
+// Clean は height 以上の記録だけを別のファイルに書き、元のファイルと置き換える
+func (w *WALOnFile) Clean(height int64) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	records, _, err := w.read()
+	if err != nil {
+		return errors.Wrapf(model.ErrWALClean, err.Error())
+	}
+	buf := make([]byte, 0)
+	kept := 0
+	for _, record := range records {
+		if record.GetHeight() < height {
+			continue
+		}
+		b, err := encodeWALRecord(record)
+		if err != nil {
+			return errors.Wrapf(model.ErrWALClean, err.Error())
+		}
+		buf = append(buf, b...)
+		kept++
+	}
+	if kept == len(records) {
+		return nil
+	}
+
+	tmp := w.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0600)
+	if err != nil {
+		return errors.Wrapf(model.ErrWALClean, err.Error())
+	}
+	if _, err := file.Write(buf); err != nil {
+		file.Close()
+		return errors.Wrapf(model.ErrWALClean, err.Error())
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return errors.Wrapf(model.ErrWALClean, err.Error())
+	}
+	if err := os.Rename(tmp, w.path); err != nil {
+		file.Close()
+		return errors.Wrapf(model.ErrWALClean, err.Error())
+	}
+	w.file.Close()
+	w.file = file
+	// rename を確定させるため、ディレクトリも fsync する
+	dir, err := os.Open(filepath.Dir(w.path))
+	if err != nil {
+		return errors.Wrapf(model.ErrWALClean, err.Error())
+	}
+	defer dir.Close()
+	if err := dir.Sync(); err != nil {
+		return errors.Wrapf(model.ErrWALClean, err.Error())
+	}
+	return nil
+}
+
+func (w *WALOnFile) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.file.Close()
+}
@@ -0,0 +1,86 @@
+package convertor_test
+
+import (
+	"github.com/pkg/errors"
+	. "github.com/satellitex/bbft/convertor"
+	"github.com/satellitex/bbft/model"
+	. "github.com/satellitex/bbft/test_utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWALOnFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wal")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "data", "consensus.wal")
+
+	vote := NewTestVoteMessage(model.PreVote, 1, 0, RandomByte())
+	ValidSign(t, vote)
+
+	t.Run("success reopen and read records written before", func(t *testing.T) {
+		wal, err := NewWALOnFile(path)
+		require.NoError(t, err)
+		require.NoError(t, wal.WriteRound(1, 0))
+		require.NoError(t, wal.WriteVote(vote, true))
+		require.NoError(t, wal.Close())
+
+		wal, err = NewWALOnFile(path)
+		require.NoError(t, err)
+		defer wal.Close()
+		records, err := wal.ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		assert.Equal(t, model.WALRound, records[0].GetType())
+		assert.Equal(t, GetHash(t, vote), GetHash(t, records[1].GetVote()))
+	})
+
+	t.Run("success truncate torn record and append after it", func(t *testing.T) {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+		require.NoError(t, err)
+		_, err = file.Write([]byte{0, 0, 1, 0, 1, 2, 3})
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+
+		wal, err := NewWALOnFile(path)
+		require.NoError(t, err)
+		defer wal.Close()
+		require.NoError(t, wal.WriteRound(1, 1))
+
+		records, err := wal.ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 3)
+		assert.Equal(t, int32(1), records[2].GetRound())
+	})
+
+	t.Run("success stop reading at corrupted record", func(t *testing.T) {
+		data, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		data[len(data)-1] ^= 0xff
+		require.NoError(t, ioutil.WriteFile(path, data, 0600))
+
+		wal, err := NewWALOnFile(path)
+		require.NoError(t, err)
+		defer wal.Close()
+		records, err := wal.ReadAll()
+		require.NoError(t, err)
+		assert.Len(t, records, 2)
+	})
+
+	t.Run("failed write not convertor model", func(t *testing.T) {
+		wal, err := NewWALOnFile(path)
+		require.NoError(t, err)
+		defer wal.Close()
+		err = wal.WriteProposal(nil, true)
+		assert.EqualError(t, errors.Cause(err), model.ErrInvalidProposal.Error())
+	})
+
+	t.Run("failed open directory", func(t *testing.T) {
+		_, err := NewWALOnFile(dir)
+		assert.EqualError(t, errors.Cause(err), ErrWALOpen.Error())
+	})
+}
@@ -9,25 +9,40 @@ import (
 
 type BlockChain interface {
 	Top() (model.Block, bool)
+	GetBlock(height int64) (model.Block, bool)
+	// GetCommitCertificate は height の Block を Commit した根拠となる CommitCertificate を返す
+	GetCommitCertificate(height int64) (model.CommitCertificate, bool)
 	FindTx(hash []byte) (model.Transaction, bool)
+	// FindTxHeight は Hash が hash の Transaction を含む Block の Height を返す
+	FindTxHeight(hash []byte) (int64, bool)
+	// FindEvidenceHeight は evidence と同じ不正の Evidence を含む Block の Height を返す
+	FindEvidenceHeight(evidence model.Evidence) (int64, bool)
 	// Commit is allowed only Commitable Block, ohterwise panic
-	Commit(block model.Block)
+	// cert is nil only genesis block
+	Commit(block model.Block, cert model.CommitCertificate)
 	VerifyCommit(block model.Block) error
 }
 
 type BlockChainOnMemory struct {
 	db        map[int64]model.Block
+	certs     map[int64]model.CommitCertificate
 	tx        map[string]model.Transaction
+	txHeight  map[string]int64
 	hashIndex map[string]int64
-	counter   int64
-	m         *sync.Mutex
+	// evidence は Commit された Evidence の不正 (evidenceKey) から、その Evidence を含む Block の Height を引く
+	evidence map[string]int64
+	counter  int64
+	m        *sync.Mutex
 }
 
 func NewBlockChainOnMemory() BlockChain {
 	return &BlockChainOnMemory{
 		make(map[int64]model.Block),
+		make(map[int64]model.CommitCertificate),
 		make(map[string]model.Transaction),
 		make(map[string]int64),
+		make(map[string]int64),
+		make(map[string]int64),
 		0,
 		new(sync.Mutex),
 	}
@@ -49,11 +64,36 @@ func (b *BlockChainOnMemory) Top() (model.Block, bool) {
 	return res, true
 }
 
+func (b *BlockChainOnMemory) GetBlock(height int64) (model.Block, bool) {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	res, ok := b.db[height]
+	if !ok {
+		return nil, false
+	}
+	return res, true
+}
+
+func (b *BlockChainOnMemory) GetCommitCertificate(height int64) (model.CommitCertificate, bool) {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	res, ok := b.certs[height]
+	if !ok {
+		return nil, false
+	}
+	return res, true
+}
+
 var (
 	ErrBlockChainVerifyCommitInvalidHeight       = errors.New("Failed Invalid Height of Block")
 	ErrBlockChainVerifyCommitInvalidPreBlockHash = errors.New("Failed Invalid PreBlockHash of Block")
 	ErrBlockChainVerifyCommitInvalidCreatedTime  = errors.New("Failed Invalid CreatedTime of Block")
 	ErrBlockChainVerifyCommitAlreadyExist        = errors.New("Failed Alraedy Exist Block")
+	ErrBlockChainVerifyCommitInvalidStateRoot    = errors.New("Failed Invalid StateRoot of Block")
+	ErrBlockChainVerifyCommitInvalidLastCommit   = errors.New("Failed Invalid LastCommit of Block")
+	ErrBlockChainVerifyCommitInvalidEvidence     = errors.New("Failed Invalid Evidence of Block")
 	ErrBlockChainVerifyCommit                    = errors.New("Failed Blockchain Verify Commit")
 )
 
@@ -94,11 +134,63 @@ func (b *BlockChainOnMemory) VerifyCommit(block model.Block) error {
 			return errors.Wrapf(ErrBlockChainVerifyCommitAlreadyExist,
 				"Already exist block %x is %d-th Block", model.MustGetHash(block), id)
 		}
+		// Must stateRoot == top.nextStateRoot
+		if stateRoot := block.GetHeader().GetStateRoot(); !bytes.Equal(stateRoot, top.GetHeader().GetNextStateRoot()) {
+			return errors.Wrapf(ErrBlockChainVerifyCommitInvalidStateRoot,
+				"stateRoot: %x, expected %x", stateRoot, top.GetHeader().GetNextStateRoot())
+		}
+		// Must lastCommit commits top. genesis block has no CommitCertificate
+		if err := b.verifyLastCommit(block.GetLastCommit(), top); err != nil {
+			return errors.Wrapf(ErrBlockChainVerifyCommitInvalidLastCommit, err.Error())
+		}
+		// Must evidences are not duplicated in block and not committed yet
+		if err := b.verifyEvidences(block.GetEvidences()); err != nil {
+			return errors.Wrapf(ErrBlockChainVerifyCommitInvalidEvidence, err.Error())
+		}
 	}
 	return nil
 }
 
-func (b *BlockChainOnMemory) Commit(block model.Block) {
+// verifyEvidences は evidences に同じ不正の Evidence が2つ以上含まれず、前の Block で Commit されていないかを確かめる
+// 同じ不正は EvidencePool と同じく type, height, round, offender で比べるので、同じ Hash の Evidence も重複として扱う
+func (b *BlockChainOnMemory) verifyEvidences(evidences []model.Evidence) error {
+	included := make(map[string]struct{}, len(evidences))
+	for _, evidence := range evidences {
+		key := evidenceKey(evidence)
+		if height, ok := b.evidence[key]; ok {
+			return errors.Errorf("evidence %s is already committed in %d-th Block", key, height)
+		}
+		if _, ok := included[key]; ok {
+			return errors.Errorf("evidence %s is duplicated in block", key)
+		}
+		included[key] = struct{}{}
+	}
+	return nil
+}
+
+// verifyLastCommit は lastCommit が top の Height と Hash に対するものかを確かめる
+// 署名と voting power の検証は StatefulValidator で行う
+func (b *BlockChainOnMemory) verifyLastCommit(lastCommit model.CommitCertificate, top model.Block) error {
+	height := top.GetHeader().GetHeight()
+	if _, ok := b.certs[height]; !ok {
+		if lastCommit != nil {
+			return errors.Errorf("block of height %d has no CommitCertificate, but lastCommit is not nil", height)
+		}
+		return nil
+	}
+	if lastCommit == nil {
+		return errors.Errorf("lastCommit is nil")
+	}
+	if lastCommit.GetHeight() != height {
+		return errors.Errorf("lastCommit height: %d, expected %d", lastCommit.GetHeight(), height)
+	}
+	if hash := model.MustGetHash(top); !bytes.Equal(lastCommit.GetBlockHash(), hash) {
+		return errors.Errorf("lastCommit blockHash: %x, expected %x", lastCommit.GetBlockHash(), hash)
+	}
+	return nil
+}
+
+func (b *BlockChainOnMemory) Commit(block model.Block, cert model.CommitCertificate) {
 	b.m.Lock()
 	defer b.m.Unlock()
 
@@ -107,6 +199,9 @@ func (b *BlockChainOnMemory) Commit(block model.Block) {
 	}
 	b.hashIndex[string(model.MustGetHash(block))] = b.counter
 	b.db[b.counter] = block
+	if cert != nil {
+		b.certs[b.counter] = cert
+	}
 	b.counter += 1
 
 	for _, tx := range block.GetTransactions() {
@@ -114,6 +209,13 @@ func (b *BlockChainOnMemory) Commit(block model.Block) {
 			panic("commit transaction is nil")
 		}
 		b.tx[string(model.MustGetHash(tx))] = tx
+		b.txHeight[string(model.MustGetHash(tx))] = b.counter - 1
+	}
+	for _, evidence := range block.GetEvidences() {
+		if evidence == nil {
+			panic("commit evidence is nil")
+		}
+		b.evidence[evidenceKey(evidence)] = b.counter - 1
 	}
 }
 
@@ -127,3 +229,28 @@ func (b *BlockChainOnMemory) FindTx(hash []byte) (model.Transaction, bool) {
 	}
 	return tx, true
 }
+
+func (b *BlockChainOnMemory) FindTxHeight(hash []byte) (int64, bool) {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	height, ok := b.txHeight[string(hash)]
+	if !ok {
+		return -1, false
+	}
+	return height, true
+}
+
+func (b *BlockChainOnMemory) FindEvidenceHeight(evidence model.Evidence) (int64, bool) {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	if evidence == nil {
+		return -1, false
+	}
+	height, ok := b.evidence[evidenceKey(evidence)]
+	if !ok {
+		return -1, false
+	}
+	return height, true
+}
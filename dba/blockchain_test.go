@@ -18,7 +18,7 @@ func testBlockChain_Top(t *testing.T, bc BlockChain) {
 
 	for i := 0; i < 10; i++ {
 		commitableBlock := RandomCommitableBlock(t, bc)
-		bc.Commit(commitableBlock)
+		bc.Commit(commitableBlock, nil)
 		top, ok := bc.Top()
 		assert.True(t, ok)
 		assert.Equal(t, top, commitableBlock)
@@ -58,7 +58,7 @@ func testBlockChain_VerifyCommit(t *testing.T, bc BlockChain) {
 	})
 
 	// Commit 1 Block
-	bc.Commit(RandomCommitableBlock(t, bc))
+	bc.Commit(RandomCommitableBlock(t, bc), nil)
 
 	t.Run("success exist bc and add comittable block", func(t *testing.T) {
 		block := RandomCommitableBlock(t, bc)
@@ -106,26 +106,167 @@ func testBlockChain_VerifyCommit(t *testing.T, bc BlockChain) {
 
 	t.Run("failed exist bc and add can not GetHash Block", func(t *testing.T) {
 		block := RandomCommitableBlock(t, bc)
-		block.(*convertor.Block).Transactions[0] = nil
+		block.(*convertor.Block).Evidences = append(block.(*convertor.Block).Evidences, nil)
 
 		err := bc.VerifyCommit(block)
 		assert.EqualError(t, errors.Cause(err), model.ErrBlockGetHash.Error())
 	})
 
+	t.Run("failed exist bc and add verified Block, but stateRoot is not next of top", func(t *testing.T) {
+		block := RandomCommitableBlock(t, bc)
+		block.(*convertor.Block).Header.StateRoot = RandomByte()
+		ValidSign(t, block)
+
+		err := bc.VerifyCommit(block)
+		assert.EqualError(t, errors.Cause(err), ErrBlockChainVerifyCommitInvalidStateRoot.Error())
+	})
+
+	t.Run("failed top has no CommitCertificate, but lastCommit is not nil", func(t *testing.T) {
+		top, ok := bc.Top()
+		require.True(t, ok)
+		block := RandomCommitableBlock(t, bc)
+		block.(*convertor.Block).LastCommit = RandomCommitCertificate(t, top, []model.Peer{RandomPeerWithPriv()}).(*convertor.CommitCertificate).CommitCertificate
+
+		err := bc.VerifyCommit(block)
+		assert.EqualError(t, errors.Cause(err), ErrBlockChainVerifyCommitInvalidLastCommit.Error())
+	})
+
+	evidence := RandomEvidence(t)
+	withEvidences := func(t *testing.T, evidences ...model.Evidence) model.Block {
+		block := RandomCommitableBlock(t, bc)
+		for _, evidence := range evidences {
+			block.(*convertor.Block).Evidences = append(block.(*convertor.Block).Evidences, evidence.(*convertor.Evidence).Evidence)
+		}
+		ValidSign(t, block)
+		return block
+	}
+
+	t.Run("success evidence is not committed", func(t *testing.T) {
+		err := bc.VerifyCommit(withEvidences(t, evidence))
+		assert.NoError(t, err)
+	})
+
+	t.Run("failed same evidence is duplicated in block", func(t *testing.T) {
+		err := bc.VerifyCommit(withEvidences(t, evidence, evidence))
+		assert.EqualError(t, errors.Cause(err), ErrBlockChainVerifyCommitInvalidEvidence.Error())
+	})
+
+	t.Run("failed evidences of same offense are duplicated in block", func(t *testing.T) {
+		offender := RandomPeerWithPriv()
+		err := bc.VerifyCommit(withEvidences(t,
+			DuplicatePreCommitEvidence(t, offender, RandomPeerWithPriv(), 1, 0),
+			DuplicatePreCommitEvidence(t, offender, RandomPeerWithPriv(), 1, 0)))
+		assert.EqualError(t, errors.Cause(err), ErrBlockChainVerifyCommitInvalidEvidence.Error())
+	})
+
+	// Commit 1 Block with Evidence
+	bc.Commit(withEvidences(t, evidence), nil)
+
+	t.Run("failed evidence is already committed", func(t *testing.T) {
+		err := bc.VerifyCommit(withEvidences(t, evidence))
+		assert.EqualError(t, errors.Cause(err), ErrBlockChainVerifyCommitInvalidEvidence.Error())
+	})
+
+	// Commit 1 Block with CommitCertificate
+	peers := []model.Peer{RandomPeerWithPriv()}
+	certBlock := RandomCommitableBlock(t, bc)
+	bc.Commit(certBlock, RandomCommitCertificate(t, certBlock, peers))
+
+	t.Run("success lastCommit commits top", func(t *testing.T) {
+		block := RandomCommitableBlock(t, bc)
+		require.NotNil(t, block.GetLastCommit())
+
+		err := bc.VerifyCommit(block)
+		assert.NoError(t, err)
+	})
+
+	t.Run("failed lastCommit is nil", func(t *testing.T) {
+		block := RandomCommitableBlock(t, bc)
+		block.(*convertor.Block).LastCommit = nil
+
+		err := bc.VerifyCommit(block)
+		assert.EqualError(t, errors.Cause(err), ErrBlockChainVerifyCommitInvalidLastCommit.Error())
+	})
+
+	t.Run("failed lastCommit for other block", func(t *testing.T) {
+		block := RandomCommitableBlock(t, bc)
+		block.(*convertor.Block).LastCommit = RandomCommitCertificate(t, RandomValidBlock(t), peers).(*convertor.CommitCertificate).CommitCertificate
+
+		err := bc.VerifyCommit(block)
+		assert.EqualError(t, errors.Cause(err), ErrBlockChainVerifyCommitInvalidLastCommit.Error())
+	})
 }
 
 func testBlockChain_CommitAndFindTx(t *testing.T, bc BlockChain) {
 	block := RandomCommitableBlock(t, bc)
-	bc.Commit(block)
+	bc.Commit(block, nil)
 
 	for _, expectedTx := range block.GetTransactions() {
 		tx, ok := bc.FindTx(GetHash(t, expectedTx))
 		assert.True(t, ok)
 		assert.Equal(t, expectedTx, tx)
+
+		height, ok := bc.FindTxHeight(GetHash(t, expectedTx))
+		assert.True(t, ok)
+		assert.Equal(t, block.GetHeader().GetHeight(), height)
 	}
 	tx, ok := bc.FindTx(RandomByte())
 	assert.False(t, ok)
 	assert.Nil(t, tx)
+	_, ok = bc.FindTxHeight(RandomByte())
+	assert.False(t, ok)
+
+	evidence := RandomEvidence(t)
+	block = RandomCommitableBlock(t, bc)
+	block.(*convertor.Block).Evidences = append(block.(*convertor.Block).Evidences, evidence.(*convertor.Evidence).Evidence)
+	bc.Commit(block, nil)
+
+	height, ok := bc.FindEvidenceHeight(evidence)
+	assert.True(t, ok)
+	assert.Equal(t, block.GetHeader().GetHeight(), height)
+	_, ok = bc.FindEvidenceHeight(RandomEvidence(t))
+	assert.False(t, ok)
+}
+
+func testBlockChain_GetBlock(t *testing.T, bc BlockChain) {
+	_, ok := bc.GetBlock(0)
+	assert.False(t, ok)
+
+	blocks := make([]model.Block, 0, 10)
+	for i := 0; i < 10; i++ {
+		block := RandomCommitableBlock(t, bc)
+		bc.Commit(block, nil)
+		blocks = append(blocks, block)
+	}
+	for height, expectedBlock := range blocks {
+		block, ok := bc.GetBlock(int64(height))
+		assert.True(t, ok)
+		assert.Equal(t, expectedBlock, block)
+	}
+	_, ok = bc.GetBlock(10)
+	assert.False(t, ok)
+	_, ok = bc.GetBlock(-1)
+	assert.False(t, ok)
+}
+
+func testBlockChain_GetCommitCertificate(t *testing.T, bc BlockChain) {
+	ps := RandomPeerService(t, 4)
+
+	// genesis block has no certificate
+	bc.Commit(RandomCommitableBlock(t, bc), nil)
+	_, ok := bc.GetCommitCertificate(0)
+	assert.False(t, ok)
+
+	block := RandomCommitableBlock(t, bc)
+	expectedCert := RandomCommitCertificate(t, block, ps.GetPeers())
+	bc.Commit(block, expectedCert)
+
+	cert, ok := bc.GetCommitCertificate(1)
+	assert.True(t, ok)
+	assert.Equal(t, expectedCert, cert)
+
+	_, ok = bc.GetCommitCertificate(2)
+	assert.False(t, ok)
 }
 
 func TestBlockChainOnMemory_Top(t *testing.T) {
@@ -133,6 +274,11 @@ func TestBlockChainOnMemory_Top(t *testing.T) {
 	testBlockChain_Top(t, bc)
 }
 
+func TestBlockChainOnMemory_GetBlock(t *testing.T) {
+	bc := NewBlockChainOnMemory()
+	testBlockChain_GetBlock(t, bc)
+}
+
 func TestBlockChainOnMemory_VerifyCommit(t *testing.T) {
 	bc := NewBlockChainOnMemory()
 	testBlockChain_VerifyCommit(t, bc)
@@ -142,3 +288,8 @@ func TestBlockChainOnMemory_Commit(t *testing.T) {
 	bc := NewBlockChainOnMemory()
 	testBlockChain_CommitAndFindTx(t, bc)
 }
+
+func TestBlockChainOnMemory_GetCommitCertificate(t *testing.T) {
+	bc := NewBlockChainOnMemory()
+	testBlockChain_GetCommitCertificate(t, bc)
+}
@@ -0,0 +1,123 @@
+package dba
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/satellitex/bbft/config"
+	"github.com/satellitex/bbft/model"
+	"strconv"
+	"sync"
+)
+
+var (
+	ErrEvidencePoolLimits       = errors.Errorf("EvidencePool run limit reached")
+	ErrEvidencePoolAlreadyExist = errors.Errorf("Failed Add Already Exist Evidence")
+	ErrEvidencePoolAdd          = errors.Errorf("Failed EvidencePool Add")
+)
+
+// EvidencePool は Block に含まれるのを待っている Evidence を保持する
+// 同じ不正 (type, height, round, offender が同じ) の Evidence は1つだけ保持する
+type EvidencePool interface {
+	Add(evidence model.Evidence) error
+	IsExist(evidence model.Evidence) bool
+	// GetPendings は Commit されていない Evidence を追加された順に limit 個まで返す
+	GetPendings(limit int) []model.Evidence
+	// Commit は Block に含まれて Commit された evidences を取り除く。以降同じ不正の Evidence は追加できない
+	Commit(evidences []model.Evidence)
+}
+
+type EvidencePoolOnMemory struct {
+	mutex     *sync.Mutex
+	limit     int
+	queue     []string
+	pendings  map[string]model.Evidence
+	committed map[string]struct{}
+}
+
+func NewEvidencePoolOnMemory(conf *config.BBFTConfig) EvidencePool {
+	return &EvidencePoolOnMemory{
+		new(sync.Mutex),
+		conf.EvidencePoolLimits,
+		make([]string, 0, conf.EvidencePoolLimits),
+		make(map[string]model.Evidence),
+		make(map[string]struct{}),
+	}
+}
+
+func evidenceKey(evidence model.Evidence) string {
+	return strconv.FormatInt(int64(evidence.GetType()), 16) + "::" +
+		strconv.FormatInt(evidence.GetHeight(), 16) + "::" +
+		strconv.FormatInt(int64(evidence.GetRound()), 16) + "::" +
+		fmt.Sprintf("%x", evidence.GetOffender())
+}
+
+func (p *EvidencePoolOnMemory) Add(evidence model.Evidence) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if evidence == nil {
+		return errors.Wrapf(model.ErrInvalidEvidence, "add evidence is nil")
+	}
+	key := evidenceKey(evidence)
+	if p.isExist(key) {
+		return errors.Wrapf(ErrEvidencePoolAlreadyExist, "already evidence : %s", key)
+	}
+	if len(p.queue) >= p.limit {
+		return errors.Wrapf(ErrEvidencePoolLimits, "pool's max length: %d", p.limit)
+	}
+	p.pendings[key] = evidence
+	p.queue = append(p.queue, key)
+	return nil
+}
+
+func (p *EvidencePoolOnMemory) isExist(key string) bool {
+	if _, ok := p.pendings[key]; ok {
+		return true
+	}
+	_, ok := p.committed[key]
+	return ok
+}
+
+func (p *EvidencePoolOnMemory) IsExist(evidence model.Evidence) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if evidence == nil {
+		return false
+	}
+	return p.isExist(evidenceKey(evidence))
+}
+
+func (p *EvidencePoolOnMemory) GetPendings(limit int) []model.Evidence {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	ret := make([]model.Evidence, 0, limit)
+	for _, key := range p.queue {
+		if len(ret) >= limit {
+			break
+		}
+		ret = append(ret, p.pendings[key])
+	}
+	return ret
+}
+
+func (p *EvidencePoolOnMemory) Commit(evidences []model.Evidence) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, evidence := range evidences {
+		key := evidenceKey(evidence)
+		p.committed[key] = struct{}{}
+		if _, ok := p.pendings[key]; !ok {
+			continue
+		}
+		delete(p.pendings, key)
+		for id, k := range p.queue {
+			if k == key {
+				p.queue = append(p.queue[:id], p.queue[id+1:]...)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package dba_test
+
+import (
+	"github.com/pkg/errors"
+	. "github.com/satellitex/bbft/dba"
+	"github.com/satellitex/bbft/model"
+	. "github.com/satellitex/bbft/test_utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func testEvidencePool(t *testing.T, pool EvidencePool, limit int) {
+	offender, reporter := RandomPeerWithPriv(), RandomPeerWithPriv()
+	evidences := []model.Evidence{
+		DuplicateProposalEvidence(t, offender, reporter, 1, 0),
+		DuplicatePreCommitEvidence(t, offender, reporter, 1, 0),
+		DuplicatePreCommitEvidence(t, offender, reporter, 1, 1),
+	}
+
+	t.Run("success add and get pendings", func(t *testing.T) {
+		for _, evidence := range evidences {
+			assert.False(t, pool.IsExist(evidence))
+			require.NoError(t, pool.Add(evidence))
+			assert.True(t, pool.IsExist(evidence))
+		}
+		assert.Equal(t, evidences, pool.GetPendings(limit))
+		assert.Equal(t, evidences[:2], pool.GetPendings(2))
+	})
+
+	t.Run("failed add nil", func(t *testing.T) {
+		err := pool.Add(nil)
+		assert.EqualError(t, errors.Cause(err), model.ErrInvalidEvidence.Error())
+		assert.False(t, pool.IsExist(nil))
+	})
+
+	t.Run("failed add same offence reported by other peer", func(t *testing.T) {
+		evidence := DuplicatePreCommitEvidence(t, offender, RandomPeerWithPriv(), 1, 0)
+		assert.True(t, pool.IsExist(evidence))
+		err := pool.Add(evidence)
+		assert.EqualError(t, errors.Cause(err), ErrEvidencePoolAlreadyExist.Error())
+	})
+
+	t.Run("commit evidences, then can not add same offence", func(t *testing.T) {
+		pool.Commit(evidences[:2])
+		assert.Equal(t, evidences[2:], pool.GetPendings(limit))
+
+		for _, evidence := range evidences[:2] {
+			assert.True(t, pool.IsExist(evidence))
+			err := pool.Add(evidence)
+			assert.EqualError(t, errors.Cause(err), ErrEvidencePoolAlreadyExist.Error())
+		}
+
+		// commit not pending evidence
+		other := RandomEvidence(t)
+		pool.Commit([]model.Evidence{other})
+		assert.True(t, pool.IsExist(other))
+		assert.Equal(t, evidences[2:], pool.GetPendings(limit))
+	})
+
+	t.Run("failed add over limits", func(t *testing.T) {
+		for len(pool.GetPendings(limit)) < limit {
+			require.NoError(t, pool.Add(RandomEvidence(t)))
+		}
+		err := pool.Add(RandomEvidence(t))
+		assert.EqualError(t, errors.Cause(err), ErrEvidencePoolLimits.Error())
+	})
+}
+
+func TestEvidencePoolOnMemory(t *testing.T) {
+	conf := GetTestConfig()
+	conf.EvidencePoolLimits = 10
+	testEvidencePool(t, NewEvidencePoolOnMemory(conf), conf.EvidencePoolLimits)
+}
@@ -1,15 +1,22 @@
 package dba
 
 import (
+	"bytes"
 	"github.com/pkg/errors"
 	"github.com/satellitex/bbft/config"
 	"github.com/satellitex/bbft/model"
+	"strconv"
 	"sync"
 )
 
 // Lock は 2/3以上のAcceptedVoteを獲得したProposalを管理する
 //
 // 各 Height について、 2/3以上の AcceptedVote を獲得した Proposal が複数あるとき Round の大きい方の Lock を取る。
+// Lock は より大きい Round で 2/3以上の AcceptedVote を獲得した Proposal が現れたときだけ移り、それ以外では外れない。
+// 自分が PreCommit した Round 以下の Round で 2/3以上の AcceptedVote が集まっても Lock は移らない。
+// Vote は Proposal と Height, Round が一致するものだけを、その Height の Peer の voting power の和で数える。
+// Reject Vote も Height, Round ごとに Peer の voting power の和で数える。
+// Vote と Reject Vote はそれぞれ LockedVotedLimits 個まで覚え、古いものから忘れる。
 type Lock interface {
 	// Proposal を登録する。
 	RegisterProposal(model.Proposal) error
@@ -18,9 +25,20 @@ type Lock interface {
 	// Error Case )
 	// 	1 ) Vote が nil の場合
 	//  2 ) 既にそのVoteが登録されていた場合
+	//  3 ) Vote の type が PreVote でない場合
 	AddVoteMessage(vote model.VoteMessage) error
 	// 高さ height における Lock を取得する。存在しなければ bool = false, otherwise true
 	GetLockedProposal(height int64) (model.Proposal, bool)
+	// 高さ height で Lock を取った Round を取得する。存在しなければ bool = false, otherwise true
+	GetLockedRound(height int64) (int32, bool)
+	// 高さ height の round で PreCommit を送ったことを記録する。以後 round 以下の Round の Vote では Lock を移さない
+	SetPreCommitted(height int64, round int32)
+	// 高さ height, round で voting power の 2/3 以上の Reject Vote が集まっていれば true
+	IsRejected(height int64, round int32) bool
+	// 高さ height, round で集まった Reject Vote を取得する。Reject の理由の診断に使う。
+	GetRejectVotes(height int64, round int32) []model.VoteMessage
+	// 高さ height, round で登録された Reject Vote を含む全ての Vote を取得する。誰がどの Block に投票したかの診断に使う。
+	GetVotes(height int64, round int32) []model.VoteMessage
 	// ある高さ未満の Lock をすべて消す。
 	Clean(height int64)
 }
@@ -28,13 +46,17 @@ type Lock interface {
 type LockOnMemory struct {
 	peerService        PeerService
 	lockedProposal     map[int64]model.Proposal
+	preCommittedRound  map[int64]int32
 	registerdProposals map[string]model.Proposal
 	registeredQueue    []string
 
-	acceptedCounter map[string]int
+	acceptedCounter map[string]int64
 	findedVote      map[string]model.VoteMessage
 	votedQueue      []string
 
+	rejectVotes map[int64]map[int32][]model.VoteMessage
+	rejectQueue []roundKey
+
 	registerdLimits int
 	votedLimits     int
 	mutex           *sync.Mutex
@@ -51,9 +73,11 @@ func NewLockOnMemory(peerService PeerService, cnf *config.BBFTConfig) Lock {
 	return &LockOnMemory{
 		peerService,
 		make(map[int64]model.Proposal),
+		make(map[int64]int32),
 		make(map[string]model.Proposal), make([]string, 0, cnf.LockedRegisteredLimits),
-		make(map[string]int),
+		make(map[string]int64),
 		make(map[string]model.VoteMessage), make([]string, 0, cnf.LockedVotedLimits),
+		make(map[int64]map[int32][]model.VoteMessage), make([]roundKey, 0, cnf.LockedVotedLimits),
 		cnf.LockedRegisteredLimits,
 		cnf.LockedVotedLimits,
 		new(sync.Mutex),
@@ -74,10 +98,24 @@ func validLockedProposal(proposal model.Proposal, lockedProposal model.Proposal)
 	return false
 }
 
-func (lock *LockOnMemory) checkAndLock(hash string) {
-	if proposal, ok := lock.registerdProposals[hash]; ok {
+// rejectQueue で Reject Vote を受け取った順に Height, Round を覚える
+type roundKey struct {
+	height int64
+	round  int32
+}
+
+// Height, Round, BlockHash の組で Proposal と Vote を対応させる
+func lockKey(height int64, round int32, hash []byte) string {
+	return strconv.FormatInt(height, 16) + "::" + strconv.FormatInt(int64(round), 16) + "::" + string(hash)
+}
+
+func (lock *LockOnMemory) checkAndLock(key string) {
+	if proposal, ok := lock.registerdProposals[key]; ok {
 		height := proposal.GetBlock().GetHeader().GetHeight()
-		if lock.peerService.GetNumberOfRequiredAcceptPeers() <= lock.acceptedCounter[hash] {
+		if lock.peerService.AtHeight(height).GetRequiredAcceptPower() <= lock.acceptedCounter[key] {
+			if round, ok := lock.preCommittedRound[height]; ok && proposal.GetRound() <= round {
+				return
+			}
 			if ok := validLockedProposal(proposal, lock.lockedProposal[height]); ok {
 				lock.lockedProposal[height] = proposal
 			}
@@ -94,10 +132,12 @@ func (lock *LockOnMemory) RegisterProposal(proposal model.Proposal) error {
 		return errors.Wrapf(model.ErrBlockGetHash, err.Error())
 	}
 
+	key := lockKey(proposal.GetBlock().GetHeader().GetHeight(), proposal.GetRound(), hash)
+
 	lock.mutex.Lock()
 	defer lock.mutex.Unlock()
 
-	if _, ok := lock.registerdProposals[string(hash)]; ok {
+	if _, ok := lock.registerdProposals[key]; ok {
 		return errors.Wrapf(ErrAlreadyRegisterProposal, "alrady register proposal: %#v", proposal)
 	}
 
@@ -106,10 +146,10 @@ func (lock *LockOnMemory) RegisterProposal(proposal model.Proposal) error {
 		delete(lock.registerdProposals, lock.registeredQueue[0])
 		lock.registeredQueue = lock.registeredQueue[1:]
 	}
-	lock.registerdProposals[string(hash)] = proposal
-	lock.registeredQueue = append(lock.registeredQueue, string(hash))
+	lock.registerdProposals[key] = proposal
+	lock.registeredQueue = append(lock.registeredQueue, key)
 	// =========================
-	lock.checkAndLock(string(hash))
+	lock.checkAndLock(key)
 	return nil
 }
 
@@ -117,32 +157,110 @@ func (lock *LockOnMemory) AddVoteMessage(vote model.VoteMessage) error {
 	if vote == nil {
 		return errors.Wrapf(model.ErrInvalidVoteMessage, "VoteMessage is nil")
 	}
+	if vote.GetType() != model.PreVote {
+		return errors.Wrapf(model.ErrInvalidVoteMessage, "VoteMessage type is not PreVote: %d", vote.GetType())
+	}
 
-	hash := string(vote.GetBlockHash())
+	if vote.IsReject() {
+		return lock.addRejectVote(vote)
+	}
+
+	key := lockKey(vote.GetHeight(), vote.GetRound(), vote.GetBlockHash())
 	pub := string(vote.GetSignature().GetPubkey())
 
 	lock.mutex.Lock()
 	defer lock.mutex.Unlock()
 
-	if _, ok := lock.findedVote[hash+pub]; ok {
+	if _, ok := lock.findedVote[key+pub]; ok {
 		return errors.Wrapf(ErrAlreadyAddVoteMessage, "already add vote: %#v", vote)
 	}
 
 	// === add vote ===
 	if len(lock.votedQueue) >= lock.votedLimits { // shifts Limits
-		delete(lock.acceptedCounter, string(lock.findedVote[lock.votedQueue[0]].GetBlockHash()))
+		old := lock.findedVote[lock.votedQueue[0]]
+		delete(lock.acceptedCounter, lockKey(old.GetHeight(), old.GetRound(), old.GetBlockHash()))
 		delete(lock.findedVote, lock.votedQueue[0])
 		lock.votedQueue = lock.votedQueue[1:]
 	}
-	lock.findedVote[hash+pub] = vote
-	lock.votedQueue = append(lock.votedQueue, hash+pub)
-	lock.acceptedCounter[hash]++
+	lock.findedVote[key+pub] = vote
+	lock.votedQueue = append(lock.votedQueue, key+pub)
+	lock.acceptedCounter[key] += lock.peerService.AtHeight(vote.GetHeight()).GetPower([][]byte{[]byte(pub)})
 	// ================
 
-	lock.checkAndLock(hash)
+	lock.checkAndLock(key)
+	return nil
+}
+
+func (lock *LockOnMemory) addRejectVote(vote model.VoteMessage) error {
+	height, round := vote.GetHeight(), vote.GetRound()
+
+	lock.mutex.Lock()
+	defer lock.mutex.Unlock()
+
+	for _, v := range lock.rejectVotes[height][round] {
+		if bytes.Equal(v.GetSignature().GetPubkey(), vote.GetSignature().GetPubkey()) {
+			return errors.Wrapf(ErrAlreadyAddVoteMessage, "already add reject vote: %#v", vote)
+		}
+	}
+
+	// === add reject vote ===
+	if len(lock.rejectQueue) >= lock.votedLimits { // shifts Limits
+		lock.forgetRejectVote(lock.rejectQueue[0])
+		lock.rejectQueue = lock.rejectQueue[1:]
+	}
+	if _, ok := lock.rejectVotes[height]; !ok {
+		lock.rejectVotes[height] = make(map[int32][]model.VoteMessage)
+	}
+	lock.rejectVotes[height][round] = append(lock.rejectVotes[height][round], vote)
+	lock.rejectQueue = append(lock.rejectQueue, roundKey{height, round})
+	// =======================
 	return nil
 }
 
+// forgetRejectVote は key の Height, Round で最も古い Reject Vote を忘れる
+func (lock *LockOnMemory) forgetRejectVote(key roundKey) {
+	votes := lock.rejectVotes[key.height][key.round]
+	if len(votes) <= 1 {
+		delete(lock.rejectVotes[key.height], key.round)
+		if len(lock.rejectVotes[key.height]) == 0 {
+			delete(lock.rejectVotes, key.height)
+		}
+		return
+	}
+	lock.rejectVotes[key.height][key.round] = votes[1:]
+}
+
+func (lock *LockOnMemory) IsRejected(height int64, round int32) bool {
+	lock.mutex.Lock()
+	defer lock.mutex.Unlock()
+	pubkeys := make([][]byte, 0, len(lock.rejectVotes[height][round]))
+	for _, vote := range lock.rejectVotes[height][round] {
+		pubkeys = append(pubkeys, vote.GetSignature().GetPubkey())
+	}
+	peers := lock.peerService.AtHeight(height)
+	return peers.GetRequiredAcceptPower() <= peers.GetPower(pubkeys)
+}
+
+func (lock *LockOnMemory) GetRejectVotes(height int64, round int32) []model.VoteMessage {
+	lock.mutex.Lock()
+	defer lock.mutex.Unlock()
+	ret := make([]model.VoteMessage, len(lock.rejectVotes[height][round]))
+	copy(ret, lock.rejectVotes[height][round])
+	return ret
+}
+
+func (lock *LockOnMemory) GetVotes(height int64, round int32) []model.VoteMessage {
+	lock.mutex.Lock()
+	defer lock.mutex.Unlock()
+	ret := make([]model.VoteMessage, 0)
+	for _, key := range lock.votedQueue {
+		if vote := lock.findedVote[key]; vote.GetHeight() == height && vote.GetRound() == round {
+			ret = append(ret, vote)
+		}
+	}
+	return append(ret, lock.rejectVotes[height][round]...)
+}
+
 func (lock *LockOnMemory) GetLockedProposal(height int64) (model.Proposal, bool) {
 	lock.mutex.Lock()
 	defer lock.mutex.Unlock()
@@ -152,6 +270,23 @@ func (lock *LockOnMemory) GetLockedProposal(height int64) (model.Proposal, bool)
 	return nil, false
 }
 
+func (lock *LockOnMemory) GetLockedRound(height int64) (int32, bool) {
+	lock.mutex.Lock()
+	defer lock.mutex.Unlock()
+	if ret, ok := lock.lockedProposal[height]; ok {
+		return ret.GetRound(), true
+	}
+	return -1, false
+}
+
+func (lock *LockOnMemory) SetPreCommitted(height int64, round int32) {
+	lock.mutex.Lock()
+	defer lock.mutex.Unlock()
+	if old, ok := lock.preCommittedRound[height]; !ok || old < round {
+		lock.preCommittedRound[height] = round
+	}
+}
+
 func (lock *LockOnMemory) Clean(height int64) {
 	lock.mutex.Lock()
 	defer lock.mutex.Unlock()
@@ -160,4 +295,21 @@ func (lock *LockOnMemory) Clean(height int64) {
 			delete(lock.lockedProposal, k)
 		}
 	}
+	for k := range lock.preCommittedRound {
+		if k < height {
+			delete(lock.preCommittedRound, k)
+		}
+	}
+	for k := range lock.rejectVotes {
+		if k < height {
+			delete(lock.rejectVotes, k)
+		}
+	}
+	queue := make([]roundKey, 0, lock.votedLimits)
+	for _, key := range lock.rejectQueue {
+		if key.height >= height {
+			queue = append(queue, key)
+		}
+	}
+	lock.rejectQueue = queue
 }
@@ -50,22 +50,33 @@ func testLock_RegisterProposal(t *testing.T, lock Lock) {
 func testLock_AddVoteMessageAndGetLocked(t *testing.T, lock Lock, p PeerService) {
 	// 4 peer
 	peers := []model.Peer{
-		RandomPeer(),
-		RandomPeer(),
-		RandomPeer(),
-		RandomPeer(),
+		RandomPeerWithPriv(),
+		RandomPeerWithPriv(),
+		RandomPeerWithPriv(),
+		RandomPeerWithPriv(),
 	}
 	for _, peer := range peers {
 		p.AddPeer(peer)
 	}
+	// Vote は Peer の voting power で数えるので、Peer が順に署名する
+	signed := 0
+	peerSign := func(t *testing.T, vote model.VoteMessage) {
+		peer := peers[signed%len(peers)]
+		signed++
+		require.NoError(t, vote.Sign(peer.GetPubkey(), peer.(*PeerWithPriv).PrivKey))
+	}
 
 	validGetLockedProposal := func(t *testing.T, expectedProposal model.Proposal) {
 		proposal, ok := lock.GetLockedProposal(0)
+		round, rok := lock.GetLockedRound(0)
 		if expectedProposal == nil {
 			assert.False(t, ok)
+			assert.False(t, rok)
 		} else {
 			assert.True(t, ok)
 			assert.Equal(t, expectedProposal, proposal)
+			assert.True(t, rok)
+			assert.Equal(t, expectedProposal.GetRound(), round)
 		}
 	}
 
@@ -94,8 +105,8 @@ func testLock_AddVoteMessageAndGetLocked(t *testing.T, lock Lock, p PeerService)
 	require.True(t, validProposals[0].GetRound() < validProposals[1].GetRound())
 
 	validAddVote := func(t *testing.T, proposal model.Proposal) {
-		vote := convertor.NewModelFactory().NewVoteMessage(GetHash(t, proposal.GetBlock()))
-		ValidSign(t, vote)
+		vote := NewTestVoteMessage(model.PreVote, 0, proposal.GetRound(), GetHash(t, proposal.GetBlock()))
+		peerSign(t, vote)
 		err := lock.AddVoteMessage(vote)
 		require.NoError(t, err)
 	}
@@ -106,15 +117,15 @@ func testLock_AddVoteMessageAndGetLocked(t *testing.T, lock Lock, p PeerService)
 		validAddVote(t, vp)
 		validGetLockedProposal(t, nil)
 
-		vote := convertor.NewModelFactory().NewVoteMessage(GetHash(t, vp.GetBlock()))
-		ValidSign(t, vote)
+		vote := NewTestVoteMessage(model.PreVote, 0, vp.GetRound(), GetHash(t, vp.GetBlock()))
+		peerSign(t, vote)
 		err := lock.AddVoteMessage(vote)
 		assert.NoError(t, err)
 
 		validGetLockedProposal(t, vp)
 
-		vote = convertor.NewModelFactory().NewVoteMessage(GetHash(t, vp.GetBlock()))
-		ValidSign(t, vote)
+		vote = NewTestVoteMessage(model.PreVote, 0, vp.GetRound(), GetHash(t, vp.GetBlock()))
+		peerSign(t, vote)
 		err = lock.AddVoteMessage(vote)
 		assert.NoError(t, err)
 
@@ -127,8 +138,8 @@ func testLock_AddVoteMessageAndGetLocked(t *testing.T, lock Lock, p PeerService)
 		validAddVote(t, vp)
 		validGetLockedProposal(t, validProposals[0])
 
-		vote := convertor.NewModelFactory().NewVoteMessage(GetHash(t, vp.GetBlock()))
-		ValidSign(t, vote)
+		vote := NewTestVoteMessage(model.PreVote, 0, vp.GetRound(), GetHash(t, vp.GetBlock()))
+		peerSign(t, vote)
 		err := lock.AddVoteMessage(vote)
 		assert.NoError(t, err)
 
@@ -141,8 +152,8 @@ func testLock_AddVoteMessageAndGetLocked(t *testing.T, lock Lock, p PeerService)
 		validAddVote(t, vp)
 		validGetLockedProposal(t, validProposals[1])
 
-		vote := convertor.NewModelFactory().NewVoteMessage(GetHash(t, vp.GetBlock()))
-		ValidSign(t, vote)
+		vote := NewTestVoteMessage(model.PreVote, 0, vp.GetRound(), GetHash(t, vp.GetBlock()))
+		peerSign(t, vote)
 		err := lock.AddVoteMessage(vote)
 		assert.NoError(t, err)
 
@@ -154,8 +165,8 @@ func testLock_AddVoteMessageAndGetLocked(t *testing.T, lock Lock, p PeerService)
 	})
 
 	t.Run("failed alrady exist voteMessage", func(t *testing.T) {
-		vote := convertor.NewModelFactory().NewVoteMessage(GetHash(t, RandomBlock(t)))
-		ValidSign(t, vote)
+		vote := NewTestVoteMessage(model.PreVote, 0, 0, GetHash(t, RandomBlock(t)))
+		peerSign(t, vote)
 
 		err := lock.AddVoteMessage(vote)
 		assert.NoError(t, err)
@@ -165,9 +176,59 @@ func testLock_AddVoteMessageAndGetLocked(t *testing.T, lock Lock, p PeerService)
 
 	})
 
-	t.Run("Execute clean lock", func(t *testing.T) {
+	t.Run("failed not PreVote type vote", func(t *testing.T) {
+		vote := NewTestVoteMessage(model.PreCommit, 0, 0, GetHash(t, RandomBlock(t)))
+		peerSign(t, vote)
+
+		err := lock.AddVoteMessage(vote)
+		assert.EqualError(t, errors.Cause(err), model.ErrInvalidVoteMessage.Error())
+	})
+
+	t.Run("success not counted votes of other height or round", func(t *testing.T) {
+		vp := RandomProposalWithHeightRound(t, 0, 3)
+		require.NoError(t, lock.RegisterProposal(vp))
+		for _, peer := range peers[:3] {
+			vote := NewTestVoteMessage(model.PreVote, 0, vp.GetRound()+1, GetHash(t, vp.GetBlock()))
+			require.NoError(t, vote.Sign(peer.GetPubkey(), peer.(*PeerWithPriv).PrivKey))
+			require.NoError(t, lock.AddVoteMessage(vote))
+
+			vote = NewTestVoteMessage(model.PreVote, 1, vp.GetRound(), GetHash(t, vp.GetBlock()))
+			require.NoError(t, vote.Sign(peer.GetPubkey(), peer.(*PeerWithPriv).PrivKey))
+			require.NoError(t, lock.AddVoteMessage(vote))
+		}
 		validGetLockedProposal(t, validProposals[2])
+	})
 
+	t.Run("success not unlocked by polka of older round", func(t *testing.T) {
+		vp := RandomProposalWithHeightRound(t, 0, 1)
+		require.NoError(t, lock.RegisterProposal(vp))
+		for _, peer := range peers[:3] {
+			vote := NewTestVoteMessage(model.PreVote, 0, vp.GetRound(), GetHash(t, vp.GetBlock()))
+			require.NoError(t, vote.Sign(peer.GetPubkey(), peer.(*PeerWithPriv).PrivKey))
+			require.NoError(t, lock.AddVoteMessage(vote))
+		}
+		validGetLockedProposal(t, validProposals[2])
+	})
+
+	t.Run("success not relocked by polka of round not newer than preCommitted round", func(t *testing.T) {
+		lock.SetPreCommitted(0, 4)
+		polka := func(vp model.Proposal) {
+			require.NoError(t, lock.RegisterProposal(vp))
+			for _, peer := range peers[:3] {
+				vote := NewTestVoteMessage(model.PreVote, 0, vp.GetRound(), GetHash(t, vp.GetBlock()))
+				require.NoError(t, vote.Sign(peer.GetPubkey(), peer.(*PeerWithPriv).PrivKey))
+				require.NoError(t, lock.AddVoteMessage(vote))
+			}
+		}
+		polka(RandomProposalWithHeightRound(t, 0, 4))
+		validGetLockedProposal(t, validProposals[2])
+
+		vp := RandomProposalWithHeightRound(t, 0, 5)
+		polka(vp)
+		validGetLockedProposal(t, vp)
+	})
+
+	t.Run("Execute clean lock", func(t *testing.T) {
 		lock.Clean(1)
 
 		validGetLockedProposal(t, nil)
@@ -177,8 +238,8 @@ func testLock_AddVoteMessageAndGetLocked(t *testing.T, lock Lock, p PeerService)
 		t.Skip("This test is too long")
 		for i := 0; i < 1000000; i++ {
 			go func() {
-				vote := convertor.NewModelFactory().NewVoteMessage(GetHash(t, RandomBlock(t)))
-				ValidSign(t, vote)
+				vote := NewTestVoteMessage(model.PreVote, 0, 0, GetHash(t, RandomBlock(t)))
+				peerSign(t, vote)
 
 				err := lock.AddVoteMessage(vote)
 				require.NoError(t, err)
@@ -188,6 +249,133 @@ func testLock_AddVoteMessageAndGetLocked(t *testing.T, lock Lock, p PeerService)
 
 }
 
+func testLock_RejectVotes(t *testing.T, lock Lock, p PeerService) {
+	peers := []model.Peer{
+		RandomPeerWithPriv(),
+		RandomPeerWithPriv(),
+		RandomPeerWithPriv(),
+		RandomPeerWithPriv(),
+	}
+	for _, peer := range peers {
+		p.AddPeer(peer)
+	}
+	newRejectVote := func(peer model.Peer, height int64, round int32, reason string) model.VoteMessage {
+		vote := convertor.NewModelFactory().NewRejectVoteMessage(GetTestConfig().ChainId, height, round, RandomByte(), reason)
+		require.NoError(t, vote.Sign(peer.GetPubkey(), peer.(*PeerWithPriv).PrivKey))
+		return vote
+	}
+
+	t.Run("success not rejected, no reject votes", func(t *testing.T) {
+		assert.False(t, lock.IsRejected(1, 0))
+		assert.Empty(t, lock.GetRejectVotes(1, 0))
+	})
+
+	t.Run("success rejected, 2/3+ reject votes", func(t *testing.T) {
+		expected := make([]model.VoteMessage, 0, 3)
+		for i := 0; i < 3; i++ {
+			assert.False(t, lock.IsRejected(1, 0))
+			vote := newRejectVote(peers[i], 1, 0, "reason")
+			require.NoError(t, lock.AddVoteMessage(vote))
+			expected = append(expected, vote)
+		}
+		assert.True(t, lock.IsRejected(1, 0))
+		assert.Equal(t, expected, lock.GetRejectVotes(1, 0))
+
+		assert.False(t, lock.IsRejected(1, 1))
+		assert.False(t, lock.IsRejected(2, 0))
+		_, ok := lock.GetLockedProposal(1)
+		assert.False(t, ok)
+	})
+
+	t.Run("success get votes with accepted and reject votes", func(t *testing.T) {
+		vote := NewTestVoteMessage(model.PreVote, 1, 0, RandomByte())
+		require.NoError(t, vote.Sign(peers[3].GetPubkey(), peers[3].(*PeerWithPriv).PrivKey))
+		require.NoError(t, lock.AddVoteMessage(vote))
+
+		expected := append([]model.VoteMessage{vote}, lock.GetRejectVotes(1, 0)...)
+		assert.Equal(t, expected, lock.GetVotes(1, 0))
+		assert.Empty(t, lock.GetVotes(1, 1))
+	})
+
+	t.Run("failed already add reject vote from same peer", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			require.NoError(t, lock.AddVoteMessage(newRejectVote(peers[0], 2, 0, "first")))
+			err := lock.AddVoteMessage(newRejectVote(peers[0], 2, 0, "second"))
+			assert.EqualError(t, errors.Cause(err), ErrAlreadyAddVoteMessage.Error())
+			assert.False(t, lock.IsRejected(2, 0))
+			lock.Clean(3)
+		}
+	})
+
+	t.Run("Execute clean reject votes", func(t *testing.T) {
+		lock.Clean(2)
+		assert.False(t, lock.IsRejected(1, 0))
+		assert.Empty(t, lock.GetRejectVotes(1, 0))
+	})
+
+	t.Run("reject votes are bounded by limit", func(t *testing.T) {
+		limit := GetTestConfig().LockedVotedLimits
+		for i := 0; i <= limit; i++ {
+			require.NoError(t, lock.AddVoteMessage(newRejectVote(peers[0], 10, int32(i), "reason")))
+		}
+		assert.Empty(t, lock.GetRejectVotes(10, 0))
+		assert.Len(t, lock.GetRejectVotes(10, int32(limit)), 1)
+
+		lock.Clean(11)
+		assert.Empty(t, lock.GetRejectVotes(10, int32(limit)))
+	})
+}
+
+func TestLockOnMemory_VotingPower(t *testing.T) {
+	ps := NewPeerServiceOnMemory()
+	lock := NewLockOnMemory(ps, GetTestConfig())
+	// total 8, required 5
+	heavy := RandomPeerWithPower(5)
+	peers := []model.Peer{heavy, RandomPeerWithPower(1), RandomPeerWithPower(1), RandomPeerWithPower(1)}
+	for _, peer := range peers {
+		ps.AddPeer(peer)
+	}
+	sign := func(t *testing.T, vote model.VoteMessage, peer model.Peer) model.VoteMessage {
+		require.NoError(t, vote.Sign(peer.GetPubkey(), peer.(*PeerWithPriv).PrivKey))
+		return vote
+	}
+
+	t.Run("not locked by many light peers", func(t *testing.T) {
+		proposal := RandomProposalWithHeightRound(t, 1, 0)
+		require.NoError(t, lock.RegisterProposal(proposal))
+		for _, peer := range peers[1:] {
+			vote := NewTestVoteMessage(model.PreVote, 1, 0, GetHash(t, proposal.GetBlock()))
+			require.NoError(t, lock.AddVoteMessage(sign(t, vote, peer)))
+		}
+		_, ok := lock.GetLockedProposal(1)
+		assert.False(t, ok)
+	})
+
+	t.Run("locked by heavy peer and a light peer", func(t *testing.T) {
+		proposal := RandomProposalWithHeightRound(t, 2, 0)
+		require.NoError(t, lock.RegisterProposal(proposal))
+		for _, peer := range peers[:2] {
+			vote := NewTestVoteMessage(model.PreVote, 2, 0, GetHash(t, proposal.GetBlock()))
+			require.NoError(t, lock.AddVoteMessage(sign(t, vote, peer)))
+		}
+		locked, ok := lock.GetLockedProposal(2)
+		assert.True(t, ok)
+		assert.Equal(t, proposal, locked)
+	})
+
+	t.Run("rejected by voting power", func(t *testing.T) {
+		factory := convertor.NewModelFactory()
+		for _, peer := range peers[1:] {
+			vote := factory.NewRejectVoteMessage(GetTestConfig().ChainId, 3, 0, RandomByte(), "reason")
+			require.NoError(t, lock.AddVoteMessage(sign(t, vote, peer)))
+		}
+		assert.False(t, lock.IsRejected(3, 0))
+		vote := factory.NewRejectVoteMessage(GetTestConfig().ChainId, 3, 0, RandomByte(), "reason")
+		require.NoError(t, lock.AddVoteMessage(sign(t, vote, heavy)))
+		assert.True(t, lock.IsRejected(3, 0))
+	})
+}
+
 func TestLockOnMemory_RegisterProposal(t *testing.T) {
 	lock := NewLockOnMemory(NewPeerServiceOnMemory(), GetTestConfig())
 	testLock_RegisterProposal(t, lock)
@@ -198,3 +386,9 @@ func TestLockOnMemory_AddVoteMessageAndGetLocked(t *testing.T) {
 	lock := NewLockOnMemory(ps, GetTestConfig())
 	testLock_AddVoteMessageAndGetLocked(t, lock, ps)
 }
+
+func TestLockOnMemory_RejectVotes(t *testing.T) {
+	ps := NewPeerServiceOnMemory()
+	lock := NewLockOnMemory(ps, GetTestConfig())
+	testLock_RejectVotes(t, lock, ps)
+}
@@ -1,44 +1,149 @@
 package dba
 
 import (
+	"github.com/pkg/errors"
 	"github.com/satellitex/bbft/model"
-	"math/rand"
 	"sort"
+	"sync"
 )
 
-type PeerService interface {
+var (
+	ErrPeerServiceUpdate      = errors.New("Failed PeerService Update")
+	ErrPeerServiceUpdateOrder = errors.New("Failed PeerService Update, height is older than scheduled updates")
+)
+
+// PeerSet は ある height で合意形成に参加する Peer の集合
+// 合意に必要な数は Peer の数ではなく voting power の和で決まる
+type PeerSet interface {
 	Size() int
-	AddPeer(peer model.Peer)
 	GetPeer(pubkey []byte) (model.Peer, bool)
 	GetPeerFromAddress(address string) (model.Peer, bool)
 	GetPeers() []model.Peer
-	GetNumberOfAllowedFailedPeers() int
-	GetNumberOfRequiredAcceptPeers() int
-	GetPermutationPeers(height int64) []model.Peer
+	// 全ての Peer の voting power の和
+	GetTotalPower() int64
+	// pubkeys のうち重複しない Peer の voting power の和。Peer でない pubkey は数えない
+	GetPower(pubkeys [][]byte) int64
+	// 不正をしても合意が壊れない voting power の上限 f = (total - 1) / 3
+	GetAllowedFailedPower() int64
+	// 合意に必要な voting power 2f + 1
+	GetRequiredAcceptPower() int64
 }
 
-type PeerServiceOnMemory struct {
+// PeerService は height ごとの Peer の集合の履歴を持つ
+// PeerSet のメソッドは SetHeight で設定した現在の height の集合を使う
+type PeerService interface {
+	PeerSet
+	// AddPeer, RemovePeer は起動時の設定に使い、登録済みの全ての height の集合を変える
+	AddPeer(peer model.Peer)
+	RemovePeer(pubkey []byte) bool
+	// Update は updates を height 以降の集合に適用する
+	// Error Case )
+	//  1 ) height が既に登録された変更の height より小さい場合
+	//  2 ) 適用できない変更を含む場合 (どれも適用されない)
+	Update(height int64, updates []model.ValidatorUpdate) error
+	// SetHeight は現在の height を変える
+	SetHeight(height int64)
+	// AtHeight は height で有効な集合を返す。Block の検証は Block の height の集合で行う
+	AtHeight(height int64) PeerSet
+}
+
+type peerSet struct {
+	height      int64
 	peers       map[string]model.Peer
 	fromAddress map[string]model.Peer
+	totalPower  int64
 }
 
-func NewPeerServiceOnMemory() PeerService {
-	return &PeerServiceOnMemory{
+func newPeerSet(height int64) *peerSet {
+	return &peerSet{
+		height,
 		make(map[string]model.Peer),
 		make(map[string]model.Peer),
+		0,
 	}
 }
 
-func (p *PeerServiceOnMemory) Size() int {
-	return len(p.peers)
+// rekeyedPeer は鍵だけを変えた Peer
+type rekeyedPeer struct {
+	model.Peer
+	pubkey []byte
 }
 
-func (p *PeerServiceOnMemory) AddPeer(peer model.Peer) {
+func (p *rekeyedPeer) GetPubkey() []byte {
+	return p.pubkey
+}
+
+func (p *peerSet) copy(height int64) *peerSet {
+	ret := newPeerSet(height)
+	for _, peer := range p.peers {
+		ret.add(peer)
+	}
+	return ret
+}
+
+func (p *peerSet) add(peer model.Peer) {
+	p.remove(peer.GetPubkey())
 	p.peers[string(peer.GetPubkey())] = peer
-	p.fromAddress[string(peer.GetAddress())] = peer
+	p.fromAddress[peer.GetAddress()] = peer
+	p.totalPower += peer.GetPower()
 }
 
-func (p *PeerServiceOnMemory) GetPeer(pubkey []byte) (model.Peer, bool) {
+func (p *peerSet) remove(pubkey []byte) bool {
+	peer, ok := p.peers[string(pubkey)]
+	if !ok {
+		return false
+	}
+	delete(p.peers, string(pubkey))
+	delete(p.fromAddress, peer.GetAddress())
+	p.totalPower -= peer.GetPower()
+	return true
+}
+
+// apply は update を集合に適用する。失敗したときの集合は壊れているので捨てること
+func (p *peerSet) apply(update model.ValidatorUpdate) error {
+	if update == nil {
+		return errors.Wrapf(model.ErrInvalidValidatorUpdate, "ValidatorUpdate is nil")
+	}
+	switch update.GetType() {
+	case model.AddValidator:
+		if _, ok := p.peers[string(update.GetPubkey())]; ok {
+			return errors.Wrapf(ErrPeerServiceUpdate, "already validator: %x", update.GetPubkey())
+		}
+		if _, ok := p.fromAddress[update.GetAddress()]; ok {
+			return errors.Wrapf(ErrPeerServiceUpdate, "already used address: %s", update.GetAddress())
+		}
+		p.add(update.GetPeer())
+	case model.RemoveValidator:
+		if !p.remove(update.GetPubkey()) {
+			return errors.Wrapf(ErrPeerServiceUpdate, "not validator: %x", update.GetPubkey())
+		}
+		if len(p.peers) == 0 {
+			return errors.Wrapf(ErrPeerServiceUpdate, "can not remove the last validator: %x", update.GetPubkey())
+		}
+	case model.RekeyValidator:
+		peer, ok := p.peers[string(update.GetPubkey())]
+		if !ok {
+			return errors.Wrapf(ErrPeerServiceUpdate, "not validator: %x", update.GetPubkey())
+		}
+		if peer.GetAddress() != update.GetAddress() {
+			return errors.Wrapf(ErrPeerServiceUpdate, "address: %s, expected %s", update.GetAddress(), peer.GetAddress())
+		}
+		if _, ok := p.peers[string(update.GetNewPubkey())]; ok {
+			return errors.Wrapf(ErrPeerServiceUpdate, "already validator: %x", update.GetNewPubkey())
+		}
+		p.remove(peer.GetPubkey())
+		p.add(&rekeyedPeer{peer, update.GetNewPubkey()})
+	default:
+		return errors.Wrapf(model.ErrInvalidValidatorUpdate, "unknown validator update type: %d", update.GetType())
+	}
+	return nil
+}
+
+func (p *peerSet) Size() int {
+	return len(p.peers)
+}
+
+func (p *peerSet) GetPeer(pubkey []byte) (model.Peer, bool) {
 	peer, ok := p.peers[string(pubkey)]
 	if !ok {
 		return nil, false
@@ -46,7 +151,7 @@ func (p *PeerServiceOnMemory) GetPeer(pubkey []byte) (model.Peer, bool) {
 	return peer, true
 }
 
-func (p *PeerServiceOnMemory) GetPeerFromAddress(address string) (model.Peer, bool) {
+func (p *peerSet) GetPeerFromAddress(address string) (model.Peer, bool) {
 	peer, ok := p.fromAddress[address]
 	if !ok {
 		return nil, false
@@ -54,7 +159,7 @@ func (p *PeerServiceOnMemory) GetPeerFromAddress(address string) (model.Peer, bo
 	return peer, true
 }
 
-func (p *PeerServiceOnMemory) GetPeers() []model.Peer {
+func (p *peerSet) GetPeers() []model.Peer {
 	keys := make([]string, 0, p.Size())
 	for key, _ := range p.fromAddress {
 		keys = append(keys, key)
@@ -68,19 +173,166 @@ func (p *PeerServiceOnMemory) GetPeers() []model.Peer {
 	return ret
 }
 
-func (p *PeerServiceOnMemory) GetNumberOfAllowedFailedPeers() int {
-	return (p.Size() - 1) / 3
+func (p *peerSet) GetTotalPower() int64 {
+	return p.totalPower
 }
 
-func (p *PeerServiceOnMemory) GetNumberOfRequiredAcceptPeers() int {
-	return p.GetNumberOfAllowedFailedPeers()*2 + 1
+func (p *peerSet) GetPower(pubkeys [][]byte) int64 {
+	counted := make(map[string]struct{}, len(pubkeys))
+	var ret int64
+	for _, pubkey := range pubkeys {
+		if _, ok := counted[string(pubkey)]; ok {
+			continue
+		}
+		if peer, ok := p.peers[string(pubkey)]; ok {
+			counted[string(pubkey)] = struct{}{}
+			ret += peer.GetPower()
+		}
+	}
+	return ret
+}
+
+func (p *peerSet) GetAllowedFailedPower() int64 {
+	return (p.totalPower - 1) / 3
 }
 
-func (p *PeerServiceOnMemory) GetPermutationPeers(height int64) []model.Peer {
-	rnd := rand.New(rand.NewSource(height))
-	peers := p.GetPeers()
-	rnd.Shuffle(len(peers), func(i, j int) {
-		peers[i], peers[j] = peers[j], peers[i]
+func (p *peerSet) GetRequiredAcceptPower() int64 {
+	return p.GetAllowedFailedPower()*2 + 1
+}
+
+// VerifyPeerUpdates は updates を set に順に適用できるかを set を変えずに確かめる
+func VerifyPeerUpdates(set PeerSet, updates []model.ValidatorUpdate) error {
+	tmp := newPeerSet(0)
+	for _, peer := range set.GetPeers() {
+		tmp.add(peer)
+	}
+	for _, update := range updates {
+		if err := tmp.apply(update); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PeerServiceOnMemory は有効になる height の昇順に並べた集合を持つ
+// 登録した集合は変えずに、AddPeer, RemovePeer, Update は変更した複製に置き換えるので、AtHeight で返した集合は lock 無しで読める
+type PeerServiceOnMemory struct {
+	height int64
+	sets   []*peerSet
+	mutex  *sync.Mutex
+}
+
+func NewPeerServiceOnMemory() PeerService {
+	return &PeerServiceOnMemory{
+		0,
+		[]*peerSet{newPeerSet(0)},
+		new(sync.Mutex),
+	}
+}
+
+// at は height で有効な集合を返す。mutex を取ってから呼ぶこと
+func (p *PeerServiceOnMemory) at(height int64) *peerSet {
+	i := sort.Search(len(p.sets), func(i int) bool {
+		return p.sets[i].height > height
 	})
-	return peers
+	if i == 0 {
+		return p.sets[0]
+	}
+	return p.sets[i-1]
+}
+
+func (p *PeerServiceOnMemory) current() *peerSet {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.at(p.height)
+}
+
+func (p *PeerServiceOnMemory) Size() int {
+	return p.current().Size()
+}
+
+func (p *PeerServiceOnMemory) AddPeer(peer model.Peer) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for i, set := range p.sets {
+		next := set.copy(set.height)
+		next.add(peer)
+		p.sets[i] = next
+	}
+}
+
+func (p *PeerServiceOnMemory) RemovePeer(pubkey []byte) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	ok := false
+	for i, set := range p.sets {
+		next := set.copy(set.height)
+		if next.remove(pubkey) {
+			p.sets[i] = next
+			ok = true
+		}
+	}
+	return ok
+}
+
+func (p *PeerServiceOnMemory) GetPeer(pubkey []byte) (model.Peer, bool) {
+	return p.current().GetPeer(pubkey)
+}
+
+func (p *PeerServiceOnMemory) GetPeerFromAddress(address string) (model.Peer, bool) {
+	return p.current().GetPeerFromAddress(address)
+}
+
+func (p *PeerServiceOnMemory) GetPeers() []model.Peer {
+	return p.current().GetPeers()
+}
+
+func (p *PeerServiceOnMemory) GetTotalPower() int64 {
+	return p.current().GetTotalPower()
+}
+
+func (p *PeerServiceOnMemory) GetPower(pubkeys [][]byte) int64 {
+	return p.current().GetPower(pubkeys)
+}
+
+func (p *PeerServiceOnMemory) GetAllowedFailedPower() int64 {
+	return p.current().GetAllowedFailedPower()
+}
+
+func (p *PeerServiceOnMemory) GetRequiredAcceptPower() int64 {
+	return p.current().GetRequiredAcceptPower()
+}
+
+func (p *PeerServiceOnMemory) Update(height int64, updates []model.ValidatorUpdate) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	last := p.sets[len(p.sets)-1]
+	if height < last.height {
+		return errors.Wrapf(ErrPeerServiceUpdateOrder, "height: %d, last scheduled: %d", height, last.height)
+	}
+	next := last.copy(height)
+	for _, update := range updates {
+		if err := next.apply(update); err != nil {
+			return err
+		}
+	}
+	if height == last.height {
+		p.sets[len(p.sets)-1] = next
+	} else {
+		p.sets = append(p.sets, next)
+	}
+	return nil
+}
+
+func (p *PeerServiceOnMemory) SetHeight(height int64) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.height = height
+}
+
+func (p *PeerServiceOnMemory) AtHeight(height int64) PeerSet {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.at(height)
 }
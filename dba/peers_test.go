@@ -1,12 +1,14 @@
 package dba_test
 
 import (
+	"github.com/pkg/errors"
+	"github.com/satellitex/bbft/convertor"
 	. "github.com/satellitex/bbft/dba"
 	"github.com/satellitex/bbft/model"
 	. "github.com/satellitex/bbft/test_utils"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"math/rand"
+	"sync"
 	"testing"
 )
 
@@ -21,9 +23,6 @@ func testPeerService(t *testing.T, p PeerService) {
 	t.Run("empty peerService, test", func(t *testing.T) {
 		peers := p.GetPeers()
 		assert.Empty(t, peers)
-
-		peers = p.GetPermutationPeers(0)
-		assert.Empty(t, peers)
 	})
 
 	t.Run("test Add And Get", func(t *testing.T) {
@@ -52,60 +51,43 @@ func testPeerService(t *testing.T, p PeerService) {
 
 	t.Run("Get Numer", func(t *testing.T) {
 		// 4 peers (Minimum peers is 4)
-		assert.Equal(t, 3, p.GetNumberOfRequiredAcceptPeers())
-		assert.Equal(t, 1, p.GetNumberOfAllowedFailedPeers())
+		assert.Equal(t, int64(3), p.GetRequiredAcceptPower())
+		assert.Equal(t, int64(1), p.GetAllowedFailedPower())
 
 		// 5 peers
 		p.AddPeer(RandomPeer())
-		assert.Equal(t, 3, p.GetNumberOfRequiredAcceptPeers())
-		assert.Equal(t, 1, p.GetNumberOfAllowedFailedPeers())
+		assert.Equal(t, int64(3), p.GetRequiredAcceptPower())
+		assert.Equal(t, int64(1), p.GetAllowedFailedPower())
 
 		// 6 peers
 		p.AddPeer(RandomPeer())
-		assert.Equal(t, 3, p.GetNumberOfRequiredAcceptPeers())
-		assert.Equal(t, 1, p.GetNumberOfAllowedFailedPeers())
+		assert.Equal(t, int64(3), p.GetRequiredAcceptPower())
+		assert.Equal(t, int64(1), p.GetAllowedFailedPower())
 
 		// 7 peers
 		p.AddPeer(RandomPeer())
-		assert.Equal(t, 5, p.GetNumberOfRequiredAcceptPeers())
-		assert.Equal(t, 2, p.GetNumberOfAllowedFailedPeers())
+		assert.Equal(t, int64(5), p.GetRequiredAcceptPower())
+		assert.Equal(t, int64(2), p.GetAllowedFailedPower())
 
 		// 8 peers
 		p.AddPeer(RandomPeer())
-		assert.Equal(t, 5, p.GetNumberOfRequiredAcceptPeers())
-		assert.Equal(t, 2, p.GetNumberOfAllowedFailedPeers())
+		assert.Equal(t, int64(5), p.GetRequiredAcceptPower())
+		assert.Equal(t, int64(2), p.GetAllowedFailedPower())
 
 		// 9 peers
 		p.AddPeer(RandomPeer())
-		assert.Equal(t, 5, p.GetNumberOfRequiredAcceptPeers())
-		assert.Equal(t, 2, p.GetNumberOfAllowedFailedPeers())
+		assert.Equal(t, int64(5), p.GetRequiredAcceptPower())
+		assert.Equal(t, int64(2), p.GetAllowedFailedPower())
 
 		// 10 peers
 		p.AddPeer(RandomPeer())
-		assert.Equal(t, 7, p.GetNumberOfRequiredAcceptPeers())
-		assert.Equal(t, 3, p.GetNumberOfAllowedFailedPeers())
+		assert.Equal(t, int64(7), p.GetRequiredAcceptPower())
+		assert.Equal(t, int64(3), p.GetAllowedFailedPower())
 
 		// 11 peers
 		p.AddPeer(RandomPeer())
-		assert.Equal(t, 7, p.GetNumberOfRequiredAcceptPeers())
-		assert.Equal(t, 3, p.GetNumberOfAllowedFailedPeers())
-	})
-
-	t.Run("Get Permutation", func(t *testing.T) {
-		peers := p.GetPeers()
-		peers2 := p.GetPeers()
-		assert.Equal(t, peers, peers2)
-		for i := 0; i < 20; i++ {
-			x := rand.Int63()
-			peers_x := p.GetPermutationPeers(x)
-			peers_x2 := p.GetPermutationPeers(x)
-			assert.Equal(t, peers_x, peers_x2)
-
-			y := rand.Int63()
-			require.NotEqual(t, x, y)
-			peers_y := p.GetPermutationPeers(y)
-			assert.NotEqual(t, peers_x, peers_y)
-		}
+		assert.Equal(t, int64(7), p.GetRequiredAcceptPower())
+		assert.Equal(t, int64(3), p.GetAllowedFailedPower())
 	})
 
 }
@@ -114,3 +96,180 @@ func TestPeerServiceOnMemory(t *testing.T) {
 	peerService := NewPeerServiceOnMemory()
 	testPeerService(t, peerService)
 }
+
+func TestPeerServiceOnMemory_Update(t *testing.T) {
+	factory := convertor.NewModelFactory()
+	p := NewPeerServiceOnMemory()
+	peers := []model.Peer{RandomPeer(), RandomPeer(), RandomPeer(), RandomPeer()}
+	for _, peer := range peers {
+		p.AddPeer(peer)
+	}
+	added := RandomPeer()
+	newPub, _ := convertor.NewKeyPair()
+
+	t.Run("success add validator from height 3", func(t *testing.T) {
+		err := p.Update(3, []model.ValidatorUpdate{
+			factory.NewValidatorUpdate(model.AddValidator, added.GetAddress(), added.GetPubkey(), nil, 1),
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, 4, p.Size())
+		assert.Equal(t, 4, p.AtHeight(2).Size())
+		assert.Equal(t, 5, p.AtHeight(3).Size())
+		assert.Equal(t, 5, p.AtHeight(100).Size())
+		_, ok := p.AtHeight(3).GetPeer(added.GetPubkey())
+		assert.True(t, ok)
+	})
+
+	t.Run("success remove and rekey validator from height 5", func(t *testing.T) {
+		err := p.Update(5, []model.ValidatorUpdate{
+			factory.NewValidatorUpdate(model.RemoveValidator, "", peers[0].GetPubkey(), nil, 0),
+			factory.NewValidatorUpdate(model.RekeyValidator, peers[1].GetAddress(), peers[1].GetPubkey(), newPub, 0),
+		})
+		require.NoError(t, err)
+
+		set := p.AtHeight(5)
+		assert.Equal(t, 4, set.Size())
+		_, ok := set.GetPeer(peers[0].GetPubkey())
+		assert.False(t, ok)
+		_, ok = set.GetPeer(peers[1].GetPubkey())
+		assert.False(t, ok)
+		peer, ok := set.GetPeerFromAddress(peers[1].GetAddress())
+		require.True(t, ok)
+		assert.Equal(t, newPub, peer.GetPubkey())
+
+		// 古い height の集合は変わらない
+		assert.Equal(t, 5, p.AtHeight(4).Size())
+		_, ok = p.AtHeight(4).GetPeer(peers[1].GetPubkey())
+		assert.True(t, ok)
+	})
+
+	t.Run("success SetHeight switches current set", func(t *testing.T) {
+		p.SetHeight(3)
+		assert.Equal(t, 5, p.Size())
+		assert.Equal(t, int64(3), p.GetRequiredAcceptPower())
+		p.SetHeight(5)
+		_, ok := p.GetPeer(peers[0].GetPubkey())
+		assert.False(t, ok)
+	})
+
+	t.Run("failed older height than scheduled", func(t *testing.T) {
+		err := p.Update(4, []model.ValidatorUpdate{
+			factory.NewValidatorUpdate(model.RemoveValidator, "", peers[2].GetPubkey(), nil, 0),
+		})
+		assert.EqualError(t, errors.Cause(err), ErrPeerServiceUpdateOrder.Error())
+	})
+
+	for _, c := range []struct {
+		name   string
+		update model.ValidatorUpdate
+	}{
+		{"add already validator", factory.NewValidatorUpdate(model.AddValidator, RandomStr(), peers[2].GetPubkey(), nil, 1)},
+		{"add already used address", factory.NewValidatorUpdate(model.AddValidator, peers[2].GetAddress(), RandomPeer().GetPubkey(), nil, 1)},
+		{"remove not validator", factory.NewValidatorUpdate(model.RemoveValidator, "", peers[0].GetPubkey(), nil, 0)},
+		{"rekey different address", factory.NewValidatorUpdate(model.RekeyValidator, RandomStr(), peers[2].GetPubkey(), RandomPeer().GetPubkey(), 0)},
+		{"rekey to already validator", factory.NewValidatorUpdate(model.RekeyValidator, peers[2].GetAddress(), peers[2].GetPubkey(), newPub, 0)},
+	} {
+		t.Run("failed "+c.name, func(t *testing.T) {
+			err := p.Update(7, []model.ValidatorUpdate{
+				factory.NewValidatorUpdate(model.RemoveValidator, "", peers[3].GetPubkey(), nil, 0),
+				c.update,
+			})
+			assert.EqualError(t, errors.Cause(err), ErrPeerServiceUpdate.Error())
+			// どの変更も適用されない
+			assert.Equal(t, 4, p.AtHeight(7).Size())
+		})
+	}
+
+	t.Run("failed remove all validators", func(t *testing.T) {
+		set := NewPeerServiceOnMemory()
+		set.AddPeer(peers[0])
+		err := VerifyPeerUpdates(set, []model.ValidatorUpdate{
+			factory.NewValidatorUpdate(model.RemoveValidator, "", peers[0].GetPubkey(), nil, 0),
+		})
+		assert.EqualError(t, errors.Cause(err), ErrPeerServiceUpdate.Error())
+		assert.Equal(t, 1, set.Size())
+	})
+}
+
+func TestPeerServiceOnMemory_Power(t *testing.T) {
+	factory := convertor.NewModelFactory()
+	p := NewPeerServiceOnMemory()
+	peers := []model.Peer{RandomPeerWithPower(1), RandomPeerWithPower(2), RandomPeerWithPower(3), RandomPeerWithPower(4)}
+	for _, peer := range peers {
+		p.AddPeer(peer)
+	}
+
+	t.Run("quorum by total power", func(t *testing.T) {
+		assert.Equal(t, int64(10), p.GetTotalPower())
+		assert.Equal(t, int64(3), p.GetAllowedFailedPower())
+		assert.Equal(t, int64(7), p.GetRequiredAcceptPower())
+	})
+
+	t.Run("sum power of distinct peers", func(t *testing.T) {
+		pubkeys := [][]byte{
+			peers[3].GetPubkey(),
+			peers[2].GetPubkey(),
+			peers[3].GetPubkey(),
+			RandomPeer().GetPubkey(),
+		}
+		assert.Equal(t, int64(7), p.GetPower(pubkeys))
+		assert.Equal(t, int64(0), p.GetPower(nil))
+	})
+
+	t.Run("validator updates change total power", func(t *testing.T) {
+		added := RandomPeer()
+		newPub, _ := convertor.NewKeyPair()
+		require.NoError(t, p.Update(2, []model.ValidatorUpdate{
+			factory.NewValidatorUpdate(model.AddValidator, added.GetAddress(), added.GetPubkey(), nil, 5),
+			factory.NewValidatorUpdate(model.RemoveValidator, "", peers[0].GetPubkey(), nil, 0),
+			factory.NewValidatorUpdate(model.RekeyValidator, peers[3].GetAddress(), peers[3].GetPubkey(), newPub, 0),
+		}))
+		set := p.AtHeight(2)
+		assert.Equal(t, int64(14), set.GetTotalPower())
+		assert.Equal(t, int64(9), set.GetRequiredAcceptPower())
+		// 鍵を変えても voting power は変わらない
+		assert.Equal(t, int64(4), set.GetPower([][]byte{newPub}))
+		assert.Equal(t, int64(10), p.AtHeight(1).GetTotalPower())
+	})
+}
+
+// go test -race で、AtHeight で返した集合を読んでいる間に AddPeer, RemovePeer しても競合しないことを確かめる
+func TestPeerServiceOnMemory_Concurrent(t *testing.T) {
+	p := NewPeerServiceOnMemory()
+	p.AddPeer(RandomPeer())
+	require.NoError(t, p.Update(5, nil))
+
+	done := make(chan struct{})
+	waiter := &sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		waiter.Add(1)
+		go func(height int64) {
+			defer waiter.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				set := p.AtHeight(height)
+				pubkeys := make([][]byte, 0, set.Size())
+				for _, peer := range set.GetPeers() {
+					pubkeys = append(pubkeys, peer.GetPubkey())
+				}
+				assert.Equal(t, set.GetTotalPower(), set.GetPower(pubkeys))
+			}
+		}(int64(i * 2))
+	}
+	for i := 0; i < 100; i++ {
+		peer := RandomPeer()
+		p.AddPeer(peer)
+		if i%2 == 0 {
+			assert.True(t, p.RemovePeer(peer.GetPubkey()))
+		}
+	}
+	close(done)
+	waiter.Wait()
+	assert.Equal(t, 51, p.AtHeight(0).Size())
+	assert.Equal(t, 51, p.AtHeight(6).Size())
+}
@@ -77,30 +77,40 @@ type ReceiverPool interface {
 	IsExistPreCommit(preCommit model.VoteMessage) bool
 }
 
+// Proposal は height, round, Block の Hash で区別する
+// 同じ height, round の異なる Proposal は重複ではなく、不正の証拠として扱う
 type proposalHasher struct {
-	hash []byte
+	proposal model.Proposal
 }
 
 func newProposalHasher(proposal model.Proposal) model.Hasher {
-	return &proposalHasher{
-		[]byte(strconv.FormatInt(proposal.GetBlock().GetHeader().GetHeight(), 16) + "::" + strconv.FormatInt(int64(proposal.GetRound()), 16)),
-	}
+	return &proposalHasher{proposal}
 }
 
 func (p *proposalHasher) GetHash() ([]byte, error) {
-	return p.hash, nil
+	hash, err := p.proposal.GetBlock().GetHash()
+	if err != nil {
+		return nil, errors.Wrapf(model.ErrBlockGetHash, err.Error())
+	}
+	return append([]byte(strconv.FormatInt(p.proposal.GetBlock().GetHeader().GetHeight(), 16)+"::"+
+		strconv.FormatInt(int64(p.proposal.GetRound()), 16)+"::"), hash...), nil
 }
 
+// VoteMessage は chainId, height, round, type, blockHash, 署名者 で区別する
 type voteHasher struct {
-	hash []byte
+	vote model.VoteMessage
 }
 
 func newVoteHasher(vote model.VoteMessage) model.Hasher {
-	return &voteHasher{append(vote.GetBlockHash(), vote.GetSignature().GetPubkey()...)}
+	return &voteHasher{vote}
 }
 
 func (v *voteHasher) GetHash() ([]byte, error) {
-	return v.hash, nil
+	hash, err := v.vote.GetHash()
+	if err != nil {
+		return nil, errors.Wrapf(model.ErrVoteMessageGetHash, err.Error())
+	}
+	return append(hash, v.vote.GetSignature().GetPubkey()...), nil
 }
 
 type ReceiverPoolOnMemory struct {
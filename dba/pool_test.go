@@ -37,6 +37,14 @@ func testReceiverPoolOnMemory(t *testing.T, pool ReceiverPool) {
 		assert.False(t, pool.IsExistPropose(RandomProposal(t)))
 	})
 
+	t.Run("other proposal in same height and round is not exist", func(t *testing.T) {
+		peer := RandomPeerWithPriv()
+		obj := RandomProposalWithPeer(t, 10, 1, peer)
+		assert.NoError(t, pool.SetPropose(obj))
+		assert.True(t, pool.IsExistPropose(obj))
+		assert.False(t, pool.IsExistPropose(RandomProposalWithPeer(t, 10, 1, peer)))
+	})
+
 	t.Run("failed set propagete nil", func(t *testing.T) {
 		err := pool.SetPropose(nil)
 		assert.EqualError(t, errors.Cause(err), model.ErrInvalidProposal.Error())
@@ -17,6 +17,10 @@ var (
 type ProposalTxQueue interface {
 	Push(tx model.Transaction) error
 	Pop() (model.Transaction, bool)
+	// Peek は次に Pop される Transaction を取り出さずに返す
+	Peek() (model.Transaction, bool)
+	// Len は Pop されていない Transaction の数を返す
+	Len() int
 }
 
 type ProposalTxQueueOnMemory struct {
@@ -72,3 +76,19 @@ func (q *ProposalTxQueueOnMemory) Pop() (model.Transaction, bool) {
 	q.queue = q.queue[1:]
 	return front, true
 }
+
+func (q *ProposalTxQueueOnMemory) Peek() (model.Transaction, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if len(q.queue) == 0 {
+		return nil, false
+	}
+	return q.queue[0], true
+}
+
+func (q *ProposalTxQueueOnMemory) Len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return len(q.queue)
+}
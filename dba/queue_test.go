@@ -27,11 +27,18 @@ func testProposalTxQueue(t *testing.T, queue ProposalTxQueue) {
 			assert.NoError(t, err)
 		}
 
+		assert.Equal(t, len(txs), queue.Len())
+
 		for _, tx := range txs {
+			peeked, ok := queue.Peek()
+			assert.True(t, ok)
+			assert.Equal(t, tx, peeked)
+
 			front, ok := queue.Pop()
 			assert.True(t, ok)
 			assert.Equal(t, tx, front)
 		}
+		assert.Equal(t, 0, queue.Len())
 	})
 
 	t.Run("Failed, nil tx push", func(t *testing.T) {
@@ -43,6 +50,10 @@ func testProposalTxQueue(t *testing.T, queue ProposalTxQueue) {
 		front, ok := queue.Pop()
 		assert.False(t, ok)
 		assert.Equal(t, nil, front)
+
+		front, ok = queue.Peek()
+		assert.False(t, ok)
+		assert.Equal(t, nil, front)
 	})
 
 	t.Run("Failed, over limits random valid tx push", func(t *testing.T) {
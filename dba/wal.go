@@ -0,0 +1,107 @@
+package dba
+
+import (
+	"github.com/pkg/errors"
+	"github.com/satellitex/bbft/model"
+	"sync"
+)
+
+// WALOnMemory は記録をメモリ上に持つ WAL。再起動すると記録は消えるので、テストと Simulator で使う
+type WALOnMemory struct {
+	records []model.WALRecord
+	mutex   *sync.Mutex
+}
+
+type walRecordOnMemory struct {
+	recordType model.WALRecordType
+	height     int64
+	round      int32
+	sent       bool
+	proposal   model.Proposal
+	vote       model.VoteMessage
+}
+
+func NewWALOnMemory() model.WAL {
+	return &WALOnMemory{
+		make([]model.WALRecord, 0),
+		new(sync.Mutex),
+	}
+}
+
+func (w *WALOnMemory) write(record *walRecordOnMemory) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.records = append(w.records, record)
+	return nil
+}
+
+func (w *WALOnMemory) WriteRound(height int64, round int32) error {
+	return w.write(&walRecordOnMemory{model.WALRound, height, round, false, nil, nil})
+}
+
+func (w *WALOnMemory) WriteProposal(proposal model.Proposal, sent bool) error {
+	if proposal == nil {
+		return errors.Wrapf(model.ErrWALWrite, "proposal is nil")
+	}
+	return w.write(&walRecordOnMemory{model.WALProposal, proposal.GetBlock().GetHeader().GetHeight(), proposal.GetRound(), sent, proposal, nil})
+}
+
+func (w *WALOnMemory) WriteVote(vote model.VoteMessage, sent bool) error {
+	if vote == nil {
+		return errors.Wrapf(model.ErrWALWrite, "vote is nil")
+	}
+	recordType := model.WALVote
+	if vote.GetType() == model.PreCommit {
+		recordType = model.WALPreCommit
+	}
+	return w.write(&walRecordOnMemory{recordType, vote.GetHeight(), vote.GetRound(), sent, nil, vote})
+}
+
+func (w *WALOnMemory) ReadAll() ([]model.WALRecord, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	ret := make([]model.WALRecord, len(w.records))
+	copy(ret, w.records)
+	return ret, nil
+}
+
+func (w *WALOnMemory) Clean(height int64) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	records := make([]model.WALRecord, 0, len(w.records))
+	for _, record := range w.records {
+		if record.GetHeight() >= height {
+			records = append(records, record)
+		}
+	}
+	w.records = records
+	return nil
+}
+
+func (w *WALOnMemory) Close() error {
+	return nil
+}
+
+func (r *walRecordOnMemory) GetType() model.WALRecordType {
+	return r.recordType
+}
+
+func (r *walRecordOnMemory) GetHeight() int64 {
+	return r.height
+}
+
+func (r *walRecordOnMemory) GetRound() int32 {
+	return r.round
+}
+
+func (r *walRecordOnMemory) IsSent() bool {
+	return r.sent
+}
+
+func (r *walRecordOnMemory) GetProposal() model.Proposal {
+	return r.proposal
+}
+
+func (r *walRecordOnMemory) GetVote() model.VoteMessage {
+	return r.vote
+}
@@ -0,0 +1,93 @@
+package dba_test
+
+import (
+	"github.com/satellitex/bbft/convertor"
+	. "github.com/satellitex/bbft/dba"
+	"github.com/satellitex/bbft/model"
+	. "github.com/satellitex/bbft/test_utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testWAL(t *testing.T, wal model.WAL) {
+	proposal := RandomProposalWithHeightRound(t, 2, 1)
+	vote := NewTestVoteMessage(model.PreVote, 2, 1, GetHash(t, proposal.GetBlock()))
+	ValidSign(t, vote)
+	preCommit := NewTestVoteMessage(model.PreCommit, 3, 0, RandomByte())
+	ValidSign(t, preCommit)
+
+	t.Run("success write and read all in order", func(t *testing.T) {
+		require.NoError(t, wal.WriteRound(2, 1))
+		require.NoError(t, wal.WriteProposal(proposal, false))
+		require.NoError(t, wal.WriteVote(vote, true))
+		require.NoError(t, wal.WriteVote(preCommit, false))
+
+		records, err := wal.ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 4)
+
+		assert.Equal(t, model.WALRound, records[0].GetType())
+		assert.Equal(t, int64(2), records[0].GetHeight())
+		assert.Equal(t, int32(1), records[0].GetRound())
+		assert.Nil(t, records[0].GetProposal())
+		assert.Nil(t, records[0].GetVote())
+
+		assert.Equal(t, model.WALProposal, records[1].GetType())
+		assert.False(t, records[1].IsSent())
+		assert.Equal(t, GetHash(t, proposal.GetBlock()), GetHash(t, records[1].GetProposal().GetBlock()))
+		assert.Equal(t, proposal.GetRound(), records[1].GetProposal().GetRound())
+
+		assert.Equal(t, model.WALVote, records[2].GetType())
+		assert.True(t, records[2].IsSent())
+		assert.Equal(t, GetHash(t, vote), GetHash(t, records[2].GetVote()))
+		assert.NoError(t, records[2].GetVote().Verify())
+
+		assert.Equal(t, model.WALPreCommit, records[3].GetType())
+		assert.Equal(t, int64(3), records[3].GetHeight())
+		assert.Equal(t, GetHash(t, preCommit), GetHash(t, records[3].GetVote()))
+	})
+
+	t.Run("failed write nil", func(t *testing.T) {
+		assert.Error(t, wal.WriteProposal(nil, true))
+		assert.Error(t, wal.WriteVote(nil, true))
+	})
+
+	t.Run("success clean lower height records", func(t *testing.T) {
+		require.NoError(t, wal.Clean(3))
+		records, err := wal.ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		assert.Equal(t, model.WALPreCommit, records[0].GetType())
+
+		require.NoError(t, wal.WriteRound(3, 0))
+		records, err = wal.ReadAll()
+		require.NoError(t, err)
+		assert.Len(t, records, 2)
+	})
+
+	t.Run("success clean all records", func(t *testing.T) {
+		require.NoError(t, wal.Clean(4))
+		records, err := wal.ReadAll()
+		require.NoError(t, err)
+		assert.Empty(t, records)
+	})
+}
+
+func TestWALOnMemory(t *testing.T) {
+	testWAL(t, NewWALOnMemory())
+}
+
+func TestWALOnFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wal")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	wal, err := convertor.NewWALOnFile(filepath.Join(dir, "consensus.wal"))
+	require.NoError(t, err)
+	defer wal.Close()
+	testWAL(t, wal)
+}
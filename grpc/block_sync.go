@@ -0,0 +1,50 @@
+package grpc
+
+import (
+	"github.com/pkg/errors"
+	"github.com/satellitex/bbft/config"
+	. "github.com/satellitex/bbft/convertor"
+	"github.com/satellitex/bbft/model"
+	"github.com/satellitex/bbft/proto"
+)
+
+type GrpcBlockSyncSender struct {
+	conf    *config.BBFTConfig
+	manager *GrpcConnectionManager
+}
+
+func NewGrpcBlockSyncSender(conf *config.BBFTConfig) model.BlockSyncSender {
+	return &GrpcBlockSyncSender{conf: conf, manager: NewGrpcConnectManager()}
+}
+
+func (s *GrpcBlockSyncSender) Close() error {
+	return s.manager.Close()
+}
+
+func (s *GrpcBlockSyncSender) GetBlocks(peer model.Peer, from int64, to int64) ([]model.Block, []model.CommitCertificate, error) {
+	if peer == nil {
+		return nil, nil, errors.Wrapf(model.ErrBlockSyncSenderGetBlocks, "peer is nil")
+	}
+	req := &bbft.BlockSyncRequest{FromHeight: from, ToHeight: to}
+	ctx, err := NewContextByProtobuf(s.conf, req)
+	if err != nil {
+		return nil, nil, errors.Wrapf(model.ErrBlockSyncSenderGetBlocks, err.Error())
+	}
+	client, err := s.manager.GetBlockSyncClient(peer)
+	if err != nil {
+		return nil, nil, errors.Wrapf(model.ErrBlockSyncSenderGetBlocks, err.Error())
+	}
+	res, err := client.GetBlocks(ctx, req)
+	if err != nil {
+		return nil, nil, errors.Wrapf(model.ErrBlockSyncSenderGetBlocks, err.Error())
+	}
+	blocks := make([]model.Block, len(res.Blocks))
+	for i, block := range res.Blocks {
+		blocks[i] = &Block{block}
+	}
+	certs := make([]model.CommitCertificate, len(res.Certificates))
+	for i, cert := range res.Certificates {
+		certs[i] = &CommitCertificate{cert}
+	}
+	return blocks, certs, nil
+}
@@ -0,0 +1,60 @@
+package grpc
+
+import (
+	"github.com/pkg/errors"
+	. "github.com/satellitex/bbft/convertor"
+	"github.com/satellitex/bbft/model"
+	"github.com/satellitex/bbft/proto"
+	"golang.org/x/net/context"
+)
+
+type GrpcLightBlockSender struct {
+	manager *GrpcConnectionManager
+}
+
+// NewGrpcLightBlockSender は Light Client が使う Sender を返す。LightClientGate は署名が要らないので鍵を持たない
+func NewGrpcLightBlockSender() model.LightBlockSender {
+	return &GrpcLightBlockSender{manager: NewGrpcConnectManager()}
+}
+
+func (s *GrpcLightBlockSender) Close() error {
+	return s.manager.Close()
+}
+
+func (s *GrpcLightBlockSender) GetLightBlocks(peer model.Peer, from int64, to int64) ([]model.LightBlock, []model.CommitCertificate, error) {
+	if peer == nil {
+		return nil, nil, errors.Wrapf(model.ErrLightBlockSenderGetLightBlocks, "peer is nil")
+	}
+	client, err := s.manager.GetLightClientClient(peer)
+	if err != nil {
+		return nil, nil, errors.Wrapf(model.ErrLightBlockSenderGetLightBlocks, err.Error())
+	}
+	res, err := client.GetLightBlocks(context.Background(), &bbft.LightBlockRequest{FromHeight: from, ToHeight: to})
+	if err != nil {
+		return nil, nil, errors.Wrapf(model.ErrLightBlockSenderGetLightBlocks, err.Error())
+	}
+	blocks := make([]model.LightBlock, len(res.Blocks))
+	for i, block := range res.Blocks {
+		blocks[i] = &LightBlock{block}
+	}
+	certs := make([]model.CommitCertificate, len(res.Certificates))
+	for i, cert := range res.Certificates {
+		certs[i] = &CommitCertificate{cert}
+	}
+	return blocks, certs, nil
+}
+
+func (s *GrpcLightBlockSender) GetTxProof(peer model.Peer, txHash []byte) (model.TxProof, error) {
+	if peer == nil {
+		return nil, errors.Wrapf(model.ErrLightBlockSenderGetTxProof, "peer is nil")
+	}
+	client, err := s.manager.GetLightClientClient(peer)
+	if err != nil {
+		return nil, errors.Wrapf(model.ErrLightBlockSenderGetTxProof, err.Error())
+	}
+	res, err := client.GetTxProof(context.Background(), &bbft.TxProofRequest{TxHash: txHash})
+	if err != nil {
+		return nil, errors.Wrapf(model.ErrLightBlockSenderGetTxProof, err.Error())
+	}
+	return &TxProof{res}, nil
+}
@@ -8,46 +8,109 @@ import (
 	"github.com/satellitex/bbft/model"
 	"github.com/satellitex/bbft/proto"
 	"go.uber.org/multierr"
+	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	"log"
 	"sync"
+	"time"
 )
 
 type GrpcConnectionManager struct {
+	conns   map[string]*grpc.ClientConn
 	clients map[string]bbft.ConsensusGateClient
+	mutex   *sync.Mutex
 }
 
 func NewGrpcConnectManager() *GrpcConnectionManager {
 	return &GrpcConnectionManager{
+		make(map[string]*grpc.ClientConn),
 		make(map[string]bbft.ConsensusGateClient),
+		new(sync.Mutex),
 	}
 }
 
 func (m *GrpcConnectionManager) CreateConn(peer model.Peer) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.createConn(peer)
+}
+
+func (m *GrpcConnectionManager) createConn(peer model.Peer) error {
 	// TODO now Insecure...?
 	gc, err := grpc.Dial(peer.GetAddress(), grpc.WithInsecure())
 	if err != nil {
 		return err
 	}
+	m.conns[peer.GetAddress()] = gc
 	m.clients[peer.GetAddress()] = bbft.NewConsensusGateClient(gc)
 	return nil
 }
 
+func (m *GrpcConnectionManager) getConn(peer model.Peer) (*grpc.ClientConn, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if conn, ok := m.conns[peer.GetAddress()]; ok {
+		return conn, nil
+	}
+	if err := m.createConn(peer); err != nil {
+		return nil, err
+	}
+	return m.conns[peer.GetAddress()], nil
+}
+
 func (m *GrpcConnectionManager) GetConnectsToChannel(peers []model.Peer, ret chan bbft.ConsensusGateClient) {
 	for _, p := range peers {
-		if client, ok := m.clients[p.GetAddress()]; ok {
-			ret <- client
-		} else {
-			if err := m.CreateConn(p); err != nil {
-				log.Println("Error Connection to peer: ", p)
-				return
-			}
-			ret <- m.clients[p.GetAddress()]
+		if _, err := m.getConn(p); err != nil {
+			log.Println("Error Connection to peer: ", p)
+			return
 		}
+		m.mutex.Lock()
+		client := m.clients[p.GetAddress()]
+		m.mutex.Unlock()
+		ret <- client
 	}
 	close(ret)
 }
 
+// Close は作成した全ての接続を閉じる。閉じた後に使うと新しく接続し直す
+func (m *GrpcConnectionManager) Close() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var result error
+	for address, conn := range m.conns {
+		result = multierr.Append(result, conn.Close())
+		delete(m.conns, address)
+		delete(m.clients, address)
+	}
+	return result
+}
+
+func (m *GrpcConnectionManager) GetConsensusClient(peer model.Peer) (bbft.ConsensusGateClient, error) {
+	if _, err := m.getConn(peer); err != nil {
+		return nil, err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.clients[peer.GetAddress()], nil
+}
+
+func (m *GrpcConnectionManager) GetBlockSyncClient(peer model.Peer) (bbft.BlockSyncGateClient, error) {
+	conn, err := m.getConn(peer)
+	if err != nil {
+		return nil, err
+	}
+	return bbft.NewBlockSyncGateClient(conn), nil
+}
+
+func (m *GrpcConnectionManager) GetLightClientClient(peer model.Peer) (bbft.LightClientGateClient, error) {
+	conn, err := m.getConn(peer)
+	if err != nil {
+		return nil, err
+	}
+	return bbft.NewLightClientGateClient(conn), nil
+}
+
 type GrpcConsensusSender struct {
 	conf    *config.BBFTConfig
 	manager *GrpcConnectionManager
@@ -59,6 +122,10 @@ func NewGrpcConsensusSender(conf *config.BBFTConfig, ps dba.PeerService) model.C
 	return sender
 }
 
+func (s *GrpcConsensusSender) Close() error {
+	return s.manager.Close()
+}
+
 func (s *GrpcConsensusSender) broadCast(send func(bbft.ConsensusGateClient, chan error, *sync.WaitGroup)) error {
 	// BroadCast to All Peer in PeerService
 	clientChan := make(chan bbft.ConsensusGateClient)
@@ -170,3 +237,66 @@ func (s *GrpcConsensusSender) PreCommit(vote model.VoteMessage) error {
 	}
 	return nil
 }
+
+func (s *GrpcConsensusSender) PreCommitTo(peer model.Peer, vote model.VoteMessage) error {
+	if peer == nil {
+		return errors.Wrapf(model.ErrConsensusSenderPreCommit, "peer is nil")
+	}
+	if proto, ok := vote.(*VoteMessage); ok {
+		ctx, err := NewContextByProtobuf(s.conf, proto)
+		if err != nil {
+			return err
+		}
+		client, err := s.manager.GetConsensusClient(peer)
+		if err != nil {
+			return errors.Wrapf(model.ErrConsensusSenderPreCommit, err.Error())
+		}
+		_, err = client.PreCommit(ctx, proto.VoteMessage)
+		return err
+	} else {
+		return errors.Wrapf(model.ErrInvalidVoteMessage, "vote can not cast to convertor.VoteMessage %#v", vote)
+	}
+}
+
+func (s *GrpcConsensusSender) Ping(peer model.Peer) (time.Duration, error) {
+	if peer == nil {
+		return 0, errors.Wrapf(model.ErrConsensusSenderPing, "peer is nil")
+	}
+	ping := &bbft.Ping{SentTime: time.Now().UnixNano()}
+	ctx, err := NewContextByProtobuf(s.conf, ping)
+	if err != nil {
+		return 0, err
+	}
+	client, err := s.manager.GetConsensusClient(peer)
+	if err != nil {
+		return 0, errors.Wrapf(model.ErrConsensusSenderPing, err.Error())
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.conf.PingInterval)
+	defer cancel()
+	start := time.Now()
+	if _, err := client.Ping(ctx, ping); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+func (s *GrpcConsensusSender) Evidence(evidence model.Evidence) error {
+	if proto, ok := evidence.(*Evidence); ok {
+		ctx, err := NewContextByProtobuf(s.conf, proto)
+		if err != nil {
+			return err
+		}
+
+		// BroadCast to All Peer in PeerService
+		return s.broadCast(
+			func(c bbft.ConsensusGateClient, errChan chan error, waiter *sync.WaitGroup) {
+				if _, err := c.Evidence(ctx, proto.Evidence); err != nil {
+					errChan <- err
+				}
+				waiter.Done()
+			})
+	} else {
+		return errors.Wrapf(model.ErrInvalidEvidence, "evidence can not cast to convertor.Evidence %#v", evidence)
+	}
+	return nil
+}
@@ -45,16 +45,20 @@ func SetUpTestServer(t *testing.T, conf *config.BBFTConfig, ps dba.PeerService,
 
 	bc := dba.NewBlockChainOnMemory()
 
-	slv := convertor.NewStatelessValidator()
+	slv := convertor.NewStatelessValidator(conf)
 	sender := convertor.NewMockConsensusSender() // WIP
+	syncer := usecase.NewBlockSyncUsecase(conf, bc, ps, slv, convertor.NewStatefulValidator(conf, bc, ps, usecase.NewLeaderSelector(conf, ps, bc)), convertor.NewCommitCertificateValidator(conf, ps), convertor.NewMockBlockSyncSender(bc))
 	receivChan := usecase.NewReceiveChannel(conf)
 
-	consensusReceiver := usecase.NewConsensusReceiverUsecase(queue, ps, lock, pool, bc, slv, sender, receivChan)
+	consensusReceiver := usecase.NewConsensusReceiverUsecase(conf, queue, ps, usecase.NewLeaderSelector(conf, ps, bc), lock, pool, dba.NewEvidencePoolOnMemory(conf), bc, slv,
+		convertor.NewEvidenceValidator(conf, ps), sender, syncer, usecase.NewEvidenceDetector(conf, convertor.NewModelFactory()), usecase.NewEventBusOnMemory(conf), receivChan)
 	clientRceiver := usecase.NewClientGateReceiverUsecase(slv, sender)
+	blockSyncReceiver := usecase.NewBlockSyncReceiverUsecase(conf, bc)
 	fmt.Println("Success New Receivers")
 
 	bbft.RegisterConsensusGateServer(s, controller.NewConsensusController(consensusReceiver, author))
 	bbft.RegisterTxGateServer(s, controller.NewClientGateController(clientRceiver, author))
+	bbft.RegisterBlockSyncGateServer(s, controller.NewBlockSyncController(blockSyncReceiver, author))
 	fmt.Println("Success New Register Endpoint")
 
 	if err := s.Serve(l); err != nil {
@@ -210,20 +214,22 @@ func TestGrpcConsensusSender_Propose(t *testing.T) {
 		}(conf, servers[i])
 	}
 
+	// each server has empty BlockChain
+	selector := usecase.NewLeaderSelector(confs[0], ps, dba.NewBlockChainOnMemory())
 	leaderId := func() int32 {
-		for i, p := range ps.GetPermutationPeers(1) {
-			if bytes.Equal(confs[0].PublicKey, p.GetPubkey()) {
-				return int32(i)
+		for round := int32(0); round < int32(ps.Size()); round++ {
+			if p, ok := selector.GetLeader(0, round); ok && bytes.Equal(confs[0].PublicKey, p.GetPubkey()) {
+				return round
 			}
 		}
 		return -1
 	}()
 	require.NotEqual(t, -1, leaderId)
 
-	leader := ps.GetPermutationPeers(1)[leaderId]
-	validProposal := RandomProposalWithPeer(t, 1, leaderId, leader)
-	unLeaderSignedProposal := RandomProposalWithHeightRound(t, 1, leaderId)
-	invalidProposal := RandomInvalidProposalWithRound(t, 1, leaderId)
+	leader, _ := selector.GetLeader(0, leaderId)
+	validProposal := RandomProposalWithPeer(t, 0, leaderId, leader)
+	unLeaderSignedProposal := RandomProposalWithHeightRound(t, 0, leaderId)
+	invalidProposal := RandomInvalidProposalWithRound(t, 0, leaderId)
 
 	evilConf := *confs[0]
 	pk, sk := convertor.NewKeyPair()
@@ -366,7 +372,7 @@ func TestGrpcConsensusSender_Vote(t *testing.T) {
 		},
 		{
 			"failed case, unsigned vote",
-			convertor.NewModelFactory().NewVoteMessage(RandomByte()),
+			NewTestVoteMessage(model.PreVote, 0, 0, RandomByte()),
 			sender,
 			codes.InvalidArgument,
 			nil,
@@ -428,8 +434,8 @@ func TestGrpcConsensusSender_PreCommit(t *testing.T) {
 		}(conf, servers[i])
 	}
 
-	validVote := RandomVoteMessageFromPeer(t, ps.GetPeers()[0])
-	unPeerValidVote := RandomVoteMessage(t)
+	validVote := RandomPreCommitFromPeer(t, ps.GetPeers()[0])
+	unPeerValidVote := RandomPreCommit(t)
 
 	evilConf := *confs[0]
 	pk, sk := convertor.NewKeyPair()
@@ -469,7 +475,7 @@ func TestGrpcConsensusSender_PreCommit(t *testing.T) {
 		},
 		{
 			"failed case, unsigned vote",
-			convertor.NewModelFactory().NewVoteMessage(RandomByte()),
+			NewTestVoteMessage(model.PreCommit, 0, 0, RandomByte()),
 			sender,
 			codes.InvalidArgument,
 			nil,
@@ -505,3 +511,160 @@ func TestGrpcConsensusSender_PreCommit(t *testing.T) {
 		s.GracefulStop()
 	}
 }
+
+func TestGrpcConsensusSender_PreCommitTo(t *testing.T) {
+	confs := []*config.BBFTConfig{
+		GetTestConfig(),
+		GetTestConfig(),
+	}
+	confs[0].Port = "50053"
+	confs[1].Port = "50054"
+
+	ps := dba.NewPeerServiceOnMemory()
+	for _, conf := range confs {
+		ps.AddPeer(RandomPeerFromConf(conf))
+	}
+
+	servers := make([]*grpc.Server, 0, 2)
+	for i, conf := range confs {
+		servers = append(servers, NewTestGrpcServer())
+		go func(conf *config.BBFTConfig, server *grpc.Server) {
+			SetUpTestServer(t, conf, ps, server)
+		}(conf, servers[i])
+	}
+
+	to := RandomPeerFromConf(confs[1])
+	validVote := RandomPreCommitFromPeer(t, ps.GetPeers()[0])
+	sender := NewGrpcConsensusSender(confs[0], ps)
+
+	for _, c := range []struct {
+		name string
+		peer model.Peer
+		vote model.VoteMessage
+		code codes.Code
+		err  error
+	}{
+		{
+			"success case",
+			to,
+			validVote,
+			codes.OK,
+			nil,
+		},
+		{
+			"failed case, unsigned vote",
+			to,
+			NewTestVoteMessage(model.PreCommit, 0, 0, RandomByte()),
+			codes.InvalidArgument,
+			nil,
+		},
+		{
+			"failed case, duplicate sent",
+			to,
+			validVote,
+			codes.AlreadyExists,
+			nil,
+		},
+		{
+			"failed case, nil vote",
+			to,
+			nil,
+			codes.OK,
+			model.ErrInvalidVoteMessage,
+		},
+		{
+			"failed case, nil peer",
+			nil,
+			validVote,
+			codes.OK,
+			model.ErrConsensusSenderPreCommit,
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			err := sender.PreCommitTo(c.peer, c.vote)
+			if c.err != nil {
+				assert.EqualError(t, errors.Cause(err), c.err.Error())
+			} else if c.code != codes.OK {
+				ValidateStatusCode(t, err, c.code)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	for _, s := range servers {
+		s.GracefulStop()
+	}
+}
+
+func TestGrpcConsensusSender_Ping(t *testing.T) {
+	conf := GetTestConfig()
+	conf.Port = "50055"
+
+	ps := dba.NewPeerServiceOnMemory()
+	ps.AddPeer(RandomPeerFromConf(conf))
+
+	server := NewTestGrpcServer()
+	go SetUpTestServer(t, conf, ps, server)
+
+	to := RandomPeerFromConf(conf)
+	evilConf := *conf
+	evilConf.PublicKey, evilConf.SecretKey = convertor.NewKeyPair()
+
+	for _, c := range []struct {
+		name   string
+		sender model.ConsensusSender
+		peer   model.Peer
+		code   codes.Code
+		err    error
+	}{
+		{
+			"success case",
+			NewGrpcConsensusSender(conf, ps),
+			to,
+			codes.OK,
+			nil,
+		},
+		{
+			"failed case, sender is not peer",
+			NewGrpcConsensusSender(&evilConf, ps),
+			to,
+			codes.PermissionDenied,
+			nil,
+		},
+		{
+			"failed case, nil peer",
+			NewGrpcConsensusSender(conf, ps),
+			nil,
+			codes.OK,
+			model.ErrConsensusSenderPing,
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			rtt, err := c.sender.Ping(c.peer)
+			if c.err != nil {
+				assert.EqualError(t, errors.Cause(err), c.err.Error())
+			} else if c.code != codes.OK {
+				ValidateStatusCode(t, err, c.code)
+			} else {
+				assert.NoError(t, err)
+				assert.True(t, rtt > 0)
+			}
+		})
+	}
+
+	server.GracefulStop()
+}
+
+func TestGrpcConnectionManager_Close(t *testing.T) {
+	manager := NewGrpcConnectManager()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, manager.CreateConn(RandomPeer()))
+	}
+	assert.NoError(t, manager.Close())
+
+	// reconnect after close
+	_, err := manager.GetBlockSyncClient(RandomPeer())
+	assert.NoError(t, err)
+	assert.NoError(t, manager.Close())
+}
@@ -0,0 +1,304 @@
+package lightclient
+
+import (
+	"bytes"
+	"github.com/pkg/errors"
+	"github.com/satellitex/bbft/config"
+	"github.com/satellitex/bbft/convertor"
+	"github.com/satellitex/bbft/dba"
+	"github.com/satellitex/bbft/model"
+	"sync"
+)
+
+var (
+	ErrLightClientTrust        = errors.Errorf("Failed LightClient Trust")
+	ErrLightClientVerifyHeader = errors.Errorf("Failed LightClient VerifyHeader")
+	ErrLightClientVerifyTx     = errors.Errorf("Failed LightClient VerifyTx")
+	ErrLightClientFindTx       = errors.Errorf("Failed LightClient FindTx")
+)
+
+// TrustOptions は Light Client が検証を始める信頼できる Block
+type TrustOptions struct {
+	Height int64
+	Hash   []byte
+	// Validators は Height の Block に PreCommit した Peer の集合
+	Validators []model.Peer
+}
+
+// LightClient は信頼できる Block から順に Full Node から取得した LightBlock を検証し、Full Node を動かさずに Chain を信頼する
+// 各 Block は前の Block の Hash を持ち、その Height の Peer の 2/3 以上の PreCommit で Commit されていることを確かめる
+// Block の validatorsHash が読み込んだ Peer の集合と一致することも確かめ、ValidatorUpdate を隠す Full Node を信頼しない
+// Commit された ValidatorUpdate は Full Node と同じく ValidatorUpdateDelay 後の Height から Peer の集合に反映する
+type LightClient interface {
+	// VerifyHeader は height までの Block を検証し、height の Block の Header を返す
+	VerifyHeader(height int64) (model.BlockHeader, error)
+	// VerifyTx は height の Block を検証し、tx が その Block に含まれることを確かめる
+	VerifyTx(height int64, tx model.Transaction) error
+	// FindTx は Hash が txHash の Transaction とそれを含む Block の Height を Full Node から TxProof で取得し、
+	// その Height までの Block を検証して TxProof が検証済みの Block のものであることを確かめる
+	FindTx(txHash []byte) (model.Transaction, int64, error)
+	// LatestHeight は検証済みの最も新しい Block の Height を返す
+	LatestHeight() int64
+}
+
+type Client struct {
+	conf   *config.BBFTConfig
+	sender model.LightBlockSender
+	// peer は LightBlock を取得する Full Node
+	peer  model.Peer
+	ps    dba.PeerService
+	cv    model.CommitCertificateValidator
+	trust int64
+	// blocks は trust 以降の検証済みの LightBlock
+	blocks map[int64]model.LightBlock
+	latest model.LightBlock
+	mutex  *sync.Mutex
+}
+
+// NewLightClient は trust の Block を peer から取得して信頼し、その Block までに Commit された ValidatorUpdate を読み込んだ LightClient を返す
+// trust の Block から遡って ValidatorUpdateDelay 個の Block の Hash が繋がっていることを確かめる
+func NewLightClient(conf *config.BBFTConfig, sender model.LightBlockSender, peer model.Peer, trust TrustOptions) (LightClient, error) {
+	if len(trust.Validators) == 0 {
+		return nil, errors.Wrapf(ErrLightClientTrust, "validators is empty")
+	}
+	ps := dba.NewPeerServiceOnMemory()
+	for _, validator := range trust.Validators {
+		ps.AddPeer(validator)
+	}
+	c := &Client{
+		conf:   conf,
+		sender: sender,
+		peer:   peer,
+		ps:     ps,
+		cv:     convertor.NewCommitCertificateValidator(conf, ps),
+		trust:  trust.Height,
+		blocks: make(map[int64]model.LightBlock),
+		mutex:  new(sync.Mutex),
+	}
+
+	// trust より前に Commit され、trust より後で有効になる ValidatorUpdate を読み込む
+	from := trust.Height - conf.ValidatorUpdateDelay + 1
+	if from > trust.Height {
+		from = trust.Height
+	}
+	if from < 0 {
+		from = 0
+	}
+	blocks, _, err := c.fetch(from, trust.Height)
+	if err != nil {
+		return nil, errors.Wrapf(ErrLightClientTrust, err.Error())
+	}
+	hash := trust.Hash
+	for i := len(blocks) - 1; i >= 0; i-- {
+		got, err := blocks[i].GetHash()
+		if err != nil {
+			return nil, errors.Wrapf(ErrLightClientTrust, err.Error())
+		}
+		if !bytes.Equal(got, hash) {
+			return nil, errors.Wrapf(ErrLightClientTrust, "height: %d, hash: %x, expected %x", blocks[i].GetHeader().GetHeight(), got, hash)
+		}
+		// Hash は txHashes を txRoot を通してしか含まないので、ValidatorUpdate を読む前に txRoot と一致することを確かめる
+		if root := convertor.MerkleRoot(blocks[i].GetTxHashes()); !bytes.Equal(root, blocks[i].GetHeader().GetTxRoot()) {
+			return nil, errors.Wrapf(ErrLightClientTrust, "height: %d, txRoot: %x, expected %x", blocks[i].GetHeader().GetHeight(), blocks[i].GetHeader().GetTxRoot(), root)
+		}
+		hash = blocks[i].GetHeader().GetPreBlockHash()
+	}
+	for _, block := range blocks {
+		if err := c.applyValidatorUpdates(block); err != nil {
+			return nil, errors.Wrapf(ErrLightClientTrust, err.Error())
+		}
+	}
+	c.latest = blocks[len(blocks)-1]
+	c.blocks[trust.Height] = c.latest
+	return c, nil
+}
+
+// fetch は [from, to] の LightBlock を全て取得する。足りないときは Error を返す
+func (c *Client) fetch(from int64, to int64) ([]model.LightBlock, []model.CommitCertificate, error) {
+	blocks := make([]model.LightBlock, 0, to-from+1)
+	certs := make([]model.CommitCertificate, 0, to-from+1)
+	for height := from; height <= to; {
+		bs, cs, err := c.sender.GetLightBlocks(c.peer, height, to)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(bs) == 0 || len(bs) != len(cs) {
+			return nil, nil, errors.Errorf("peer does not have blocks from height: %d", height)
+		}
+		for i, block := range bs {
+			if h := block.GetHeader().GetHeight(); h != height {
+				return nil, nil, errors.Errorf("height: %d, expected %d", h, height)
+			}
+			blocks = append(blocks, block)
+			certs = append(certs, cs[i])
+			height++
+		}
+	}
+	return blocks, certs, nil
+}
+
+// applyValidatorUpdates は block に含まれる ValidatorUpdate を height + ValidatorUpdateDelay からの Peer の集合に反映する
+// ValidatorUpdate を含む Transaction が block に含まれていることを確かめる
+func (c *Client) applyValidatorUpdates(block model.LightBlock) error {
+	txs := block.GetValidatorUpdateTxs()
+	if len(txs) == 0 {
+		return nil
+	}
+	for _, tx := range txs {
+		if err := includes(block, tx); err != nil {
+			return err
+		}
+	}
+	height := block.GetHeader().GetHeight()
+	if err := c.ps.Update(height+c.conf.ValidatorUpdateDelay, model.GetValidatorUpdates(txs)); err != nil {
+		return errors.Wrapf(dba.ErrPeerServiceUpdate, err.Error())
+	}
+	return nil
+}
+
+// includes は tx が block の Transaction であるかを確かめる
+func includes(block model.LightBlock, tx model.Transaction) error {
+	t, ok := tx.(*convertor.Transaction)
+	if !ok {
+		return errors.Wrapf(model.ErrInvalidTransaction, "Can not cast Transaction model: %#v.", tx)
+	}
+	hash, err := convertor.CalcHashFromProto(t)
+	if err != nil {
+		return errors.Wrapf(model.ErrTransactionGetHash, err.Error())
+	}
+	for _, h := range block.GetTxHashes() {
+		if bytes.Equal(h, hash) {
+			return nil
+		}
+	}
+	return errors.Errorf("tx: %x is not included in block of height: %d", hash, block.GetHeader().GetHeight())
+}
+
+// verify は block が latest の次の Block として正しいかを検証する
+func (c *Client) verify(block model.LightBlock, cert model.CommitCertificate) error {
+	height := block.GetHeader().GetHeight()
+	if expected := c.latest.GetHeader().GetHeight() + 1; height != expected {
+		return errors.Errorf("height: %d, expected %d", height, expected)
+	}
+	latestHash, err := c.latest.GetHash()
+	if err != nil {
+		return errors.Wrapf(model.ErrBlockGetHash, err.Error())
+	}
+	if !bytes.Equal(block.GetHeader().GetPreBlockHash(), latestHash) {
+		return errors.Errorf("preBlockHash: %x, expected %x", block.GetHeader().GetPreBlockHash(), latestHash)
+	}
+	if err := block.Verify(); err != nil {
+		return errors.Wrapf(model.ErrBlockVerify, err.Error())
+	}
+	// Full Node が ValidatorUpdate を返さなかったときは、読み込んだ Peer の集合が Block の validatorsHash と一致しない
+	if expected := convertor.ValidatorsHash(c.ps.AtHeight(height).GetPeers()); !bytes.Equal(block.GetHeader().GetValidatorsHash(), expected) {
+		return errors.Wrapf(model.ErrInvalidValidatorsHash, "validatorsHash: %x, expected %x", block.GetHeader().GetValidatorsHash(), expected)
+	}
+	if pubkey := block.GetSignature().GetPubkey(); !c.isPeerAt(height, pubkey) {
+		return errors.Errorf("block signer is not peer: %x", pubkey)
+	}
+	if err := c.cv.ValidateLightBlock(block, cert); err != nil {
+		return errors.Wrapf(model.ErrCommitCertificateValidate, err.Error())
+	}
+	return nil
+}
+
+func (c *Client) isPeerAt(height int64, pubkey []byte) bool {
+	_, ok := c.ps.AtHeight(height).GetPeer(pubkey)
+	return ok
+}
+
+// verifyTo は height までの Block を順に検証し、height の LightBlock を返す
+func (c *Client) verifyTo(height int64) (model.LightBlock, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if height < c.trust {
+		return nil, errors.Errorf("height: %d is older than trusted height: %d", height, c.trust)
+	}
+	if block, ok := c.blocks[height]; ok {
+		return block, nil
+	}
+	blocks, certs, err := c.fetch(c.latest.GetHeader().GetHeight()+1, height)
+	if err != nil {
+		return nil, err
+	}
+	for i, block := range blocks {
+		if err := c.verify(block, certs[i]); err != nil {
+			return nil, err
+		}
+		if err := c.applyValidatorUpdates(block); err != nil {
+			return nil, err
+		}
+		c.blocks[block.GetHeader().GetHeight()] = block
+		c.latest = block
+	}
+	return c.latest, nil
+}
+
+func (c *Client) VerifyHeader(height int64) (model.BlockHeader, error) {
+	block, err := c.verifyTo(height)
+	if err != nil {
+		return nil, errors.Wrapf(ErrLightClientVerifyHeader, err.Error())
+	}
+	return block.GetHeader(), nil
+}
+
+func (c *Client) VerifyTx(height int64, tx model.Transaction) error {
+	block, err := c.verifyTo(height)
+	if err != nil {
+		return errors.Wrapf(ErrLightClientVerifyTx, err.Error())
+	}
+	if err := includes(block, tx); err != nil {
+		return errors.Wrapf(ErrLightClientVerifyTx, err.Error())
+	}
+	return nil
+}
+
+func (c *Client) FindTx(txHash []byte) (model.Transaction, int64, error) {
+	proof, err := c.sender.GetTxProof(c.peer, txHash)
+	if err != nil {
+		return nil, 0, errors.Wrapf(ErrLightClientFindTx, err.Error())
+	}
+	height := proof.GetHeader().GetHeight()
+	block, err := c.verifyTo(height)
+	if err != nil {
+		return nil, 0, errors.Wrapf(ErrLightClientFindTx, err.Error())
+	}
+	if err := verifyTxProof(block, proof, txHash); err != nil {
+		return nil, 0, errors.Wrapf(ErrLightClientFindTx, err.Error())
+	}
+	return proof.GetTransaction(), height, nil
+}
+
+// verifyTxProof は proof が検証済みの block のものであり、Hash が txHash の Transaction を block の txRoot に含むことを確かめる
+func verifyTxProof(block model.LightBlock, proof model.TxProof, txHash []byte) error {
+	blockHash, err := block.GetHash()
+	if err != nil {
+		return errors.Wrapf(model.ErrBlockGetHash, err.Error())
+	}
+	proofHash, err := proof.GetHash()
+	if err != nil {
+		return errors.Wrapf(model.ErrBlockGetHash, err.Error())
+	}
+	if !bytes.Equal(proofHash, blockHash) {
+		return errors.Errorf("block hash of proof: %x, expected %x", proofHash, blockHash)
+	}
+	hash, err := proof.GetTransaction().GetHash()
+	if err != nil {
+		return errors.Wrapf(model.ErrTransactionGetHash, err.Error())
+	}
+	if !bytes.Equal(hash, txHash) {
+		return errors.Errorf("tx hash of proof: %x, expected %x", hash, txHash)
+	}
+	if err := proof.Verify(); err != nil {
+		return errors.Wrapf(model.ErrTxProofVerify, err.Error())
+	}
+	return nil
+}
+
+func (c *Client) LatestHeight() int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.latest.GetHeader().GetHeight()
+}
@@ -0,0 +1,216 @@
+package lightclient_test
+
+import (
+	"github.com/pkg/errors"
+	"github.com/satellitex/bbft/config"
+	"github.com/satellitex/bbft/controller"
+	"github.com/satellitex/bbft/convertor"
+	"github.com/satellitex/bbft/dba"
+	. "github.com/satellitex/bbft/grpc"
+	. "github.com/satellitex/bbft/lightclient"
+	"github.com/satellitex/bbft/model"
+	"github.com/satellitex/bbft/proto"
+	. "github.com/satellitex/bbft/test_utils"
+	"github.com/satellitex/bbft/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"net"
+	"testing"
+)
+
+// SetUpFullNode は bc の Block を LightClientGate で返す Full Node を起動し、その Peer を返す
+func SetUpFullNode(t *testing.T, conf *config.BBFTConfig, bc dba.BlockChain) (model.Peer, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := grpc.NewServer()
+	factory := convertor.NewModelFactory()
+	bbft.RegisterLightClientGateServer(s, controller.NewLightClientController(usecase.NewBlockSyncReceiverUsecase(conf, bc),
+		usecase.NewTxProofReceiverUsecase(bc, factory), factory))
+	go s.Serve(l)
+	return factory.NewPeer(l.Addr().String(), nil), s.Stop
+}
+
+// commitBlock は txs を入れた Block を validators の signers[0] が提案し、signers が PreCommit して Commit する
+func commitBlock(t *testing.T, bc dba.BlockChain, txs []model.Transaction, validators []model.Peer, signers []model.Peer) model.Block {
+	top, ok := bc.Top()
+	require.True(t, ok)
+	factory := convertor.NewModelFactory()
+	lastCommit, _ := bc.GetCommitCertificate(top.GetHeader().GetHeight())
+	state := factory.NewChainState(signers[0].GetPubkey(), 0, top.GetHeader().GetNextStateRoot(), validators, lastCommit)
+	block, err := factory.NewBlock(top.GetHeader().GetHeight()+1, GetHash(t, top),
+		top.GetHeader().GetCreatedTime()+10, txs, nil, state)
+	require.NoError(t, err)
+	require.NoError(t, block.Sign(signers[0].GetPubkey(), signers[0].(*PeerWithPriv).PrivKey))
+	bc.Commit(block, RandomCommitCertificate(t, block, signers))
+	return block
+}
+
+// omitValidatorUpdatesSender は LightBlock から ValidatorUpdate を除いて返す Full Node の代わり
+type omitValidatorUpdatesSender struct {
+	model.LightBlockSender
+}
+
+func (s *omitValidatorUpdatesSender) GetLightBlocks(peer model.Peer, from int64, to int64) ([]model.LightBlock, []model.CommitCertificate, error) {
+	blocks, certs, err := s.LightBlockSender.GetLightBlocks(peer, from, to)
+	for _, block := range blocks {
+		block.(*convertor.LightBlock).ValidatorUpdates = nil
+	}
+	return blocks, certs, err
+}
+
+func TestLightClient(t *testing.T) {
+	conf := GetTestConfig()
+	conf.ValidatorUpdateDelay = 2
+	// 一度に返す Block を少なくして何度も取得させる
+	conf.BlockSyncBatchSize = 2
+	factory := convertor.NewModelFactory()
+
+	validators := []model.Peer{RandomPeerWithPriv(), RandomPeerWithPriv(), RandomPeerWithPriv(), RandomPeerWithPriv()}
+	added := RandomPeerWithPriv()
+	// added が加わった後の 5 Peer の 2/3 以上
+	withAdded := []model.Peer{added, validators[0], validators[1]}
+	after := append([]model.Peer{added}, validators...)
+
+	bc := dba.NewBlockChainOnMemory()
+	genesis := RandomCommitableBlock(t, bc)
+	bc.Commit(genesis, nil)
+	commitBlock(t, bc, RandomValidTxs(t), validators, validators)
+	// height 2 で Commit された added は height 4 から有効になる
+	update := ValidatorUpdateTx(t, factory.NewValidatorUpdate(model.AddValidator, added.GetAddress(), added.GetPubkey(), nil, 1), validators)
+	commitBlock(t, bc, append(RandomValidTxs(t), update), validators, validators)
+	txBlock := commitBlock(t, bc, RandomValidTxs(t), validators, validators)
+	commitBlock(t, bc, RandomValidTxs(t), after, withAdded)
+	commitBlock(t, bc, RandomValidTxs(t), after, withAdded)
+
+	peer, stop := SetUpFullNode(t, conf, bc)
+	defer stop()
+	sender := NewGrpcLightBlockSender()
+	defer sender.Close()
+
+	t.Run("success verify headers through validator update", func(t *testing.T) {
+		client, err := NewLightClient(conf, sender, peer, TrustOptions{0, GetHash(t, genesis), validators})
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), client.LatestHeight())
+
+		header, err := client.VerifyHeader(5)
+		require.NoError(t, err)
+		assert.Equal(t, int64(5), header.GetHeight())
+		assert.Equal(t, int64(5), client.LatestHeight())
+
+		header, err = client.VerifyHeader(3)
+		require.NoError(t, err)
+		assert.Equal(t, GetHash(t, txBlock.GetHeader()), GetHash(t, header))
+	})
+
+	t.Run("success trust from middle of validator update delay", func(t *testing.T) {
+		block, ok := bc.GetBlock(3)
+		require.True(t, ok)
+		client, err := NewLightClient(conf, sender, peer, TrustOptions{3, GetHash(t, block), validators})
+		require.NoError(t, err)
+
+		_, err = client.VerifyHeader(5)
+		assert.NoError(t, err)
+	})
+
+	t.Run("success and failed verify tx", func(t *testing.T) {
+		client, err := NewLightClient(conf, sender, peer, TrustOptions{0, GetHash(t, genesis), validators})
+		require.NoError(t, err)
+
+		assert.NoError(t, client.VerifyTx(3, txBlock.GetTransactions()[0]))
+		assert.EqualError(t, errors.Cause(client.VerifyTx(3, RandomValidTx(t))), ErrLightClientVerifyTx.Error())
+		assert.EqualError(t, errors.Cause(client.VerifyTx(4, txBlock.GetTransactions()[0])), ErrLightClientVerifyTx.Error())
+	})
+
+	t.Run("success and failed find tx", func(t *testing.T) {
+		client, err := NewLightClient(conf, sender, peer, TrustOptions{0, GetHash(t, genesis), validators})
+		require.NoError(t, err)
+
+		expected := txBlock.GetTransactions()[1]
+		tx, height, err := client.FindTx(GetHash(t, expected))
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), height)
+		assert.Equal(t, GetHash(t, expected), GetHash(t, tx))
+		assert.Equal(t, int64(3), client.LatestHeight())
+
+		_, _, err = client.FindTx(GetHash(t, RandomValidTx(t)))
+		assert.EqualError(t, errors.Cause(err), ErrLightClientFindTx.Error())
+	})
+
+	t.Run("failed find tx from peer with forged block", func(t *testing.T) {
+		// height 3 に validators が Commit していない Block を持つ Full Node の TxProof は受け入れない
+		forged := dba.NewBlockChainOnMemory()
+		for height := int64(0); height < 3; height++ {
+			block, ok := bc.GetBlock(height)
+			require.True(t, ok)
+			cert, _ := bc.GetCommitCertificate(height)
+			forged.Commit(block, cert)
+		}
+		forgedTx := RandomValidTx(t)
+		commitBlock(t, forged, []model.Transaction{forgedTx}, validators, []model.Peer{RandomPeerWithPriv()})
+		forgedPeer, stop := SetUpFullNode(t, conf, forged)
+		defer stop()
+
+		client, err := NewLightClient(conf, sender, forgedPeer, TrustOptions{0, GetHash(t, genesis), validators})
+		require.NoError(t, err)
+		_, _, err = client.FindTx(GetHash(t, forgedTx))
+		assert.EqualError(t, errors.Cause(err), ErrLightClientFindTx.Error())
+		assert.Equal(t, int64(2), client.LatestHeight())
+	})
+
+	t.Run("failed untrusted hash", func(t *testing.T) {
+		_, err := NewLightClient(conf, sender, peer, TrustOptions{0, RandomByte(), validators})
+		assert.EqualError(t, errors.Cause(err), ErrLightClientTrust.Error())
+	})
+
+	t.Run("failed height older than trust or not committed", func(t *testing.T) {
+		block, ok := bc.GetBlock(3)
+		require.True(t, ok)
+		client, err := NewLightClient(conf, sender, peer, TrustOptions{3, GetHash(t, block), validators})
+		require.NoError(t, err)
+
+		_, err = client.VerifyHeader(2)
+		assert.EqualError(t, errors.Cause(err), ErrLightClientVerifyHeader.Error())
+		_, err = client.VerifyHeader(10)
+		assert.EqualError(t, errors.Cause(err), ErrLightClientVerifyHeader.Error())
+	})
+
+	t.Run("failed without earlier validator update", func(t *testing.T) {
+		// height 3 の Block だけを読むと height 2 の ValidatorUpdate を知らず、added の PreCommit が数えられない
+		shortConf := *conf
+		shortConf.ValidatorUpdateDelay = 1
+		block, ok := bc.GetBlock(3)
+		require.True(t, ok)
+		client, err := NewLightClient(&shortConf, sender, peer, TrustOptions{3, GetHash(t, block), validators})
+		require.NoError(t, err)
+
+		_, err = client.VerifyHeader(4)
+		assert.EqualError(t, errors.Cause(err), ErrLightClientVerifyHeader.Error())
+	})
+
+	t.Run("failed peer omits validator update", func(t *testing.T) {
+		// added を知らないまま height 4 の Block を読むと validatorsHash が一致しない
+		client, err := NewLightClient(conf, &omitValidatorUpdatesSender{sender}, peer, TrustOptions{0, GetHash(t, genesis), validators})
+		require.NoError(t, err)
+
+		_, err = client.VerifyHeader(4)
+		assert.EqualError(t, errors.Cause(err), ErrLightClientVerifyHeader.Error())
+		assert.Contains(t, err.Error(), model.ErrInvalidValidatorsHash.Error())
+		assert.Equal(t, int64(3), client.LatestHeight())
+	})
+
+	t.Run("failed block committed by unknown peers", func(t *testing.T) {
+		evil := dba.NewBlockChainOnMemory()
+		evil.Commit(genesis, nil)
+		evils := []model.Peer{RandomPeerWithPriv(), RandomPeerWithPriv(), RandomPeerWithPriv()}
+		commitBlock(t, evil, RandomValidTxs(t), evils, evils)
+		evilPeer, stop := SetUpFullNode(t, conf, evil)
+		defer stop()
+
+		client, err := NewLightClient(conf, sender, evilPeer, TrustOptions{0, GetHash(t, genesis), validators})
+		require.NoError(t, err)
+		_, err = client.VerifyHeader(1)
+		assert.EqualError(t, errors.Cause(err), ErrLightClientVerifyHeader.Error())
+		assert.Equal(t, int64(0), client.LatestHeight())
+	})
+}
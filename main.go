@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"encoding/base64"
+	"encoding/hex"
 	"github.com/grpc-ecosystem/go-grpc-middleware"
 	"github.com/grpc-ecosystem/go-grpc-middleware/recovery"
 	"github.com/grpc-ecosystem/go-grpc-middleware/tags"
@@ -12,6 +14,7 @@ import (
 	"github.com/satellitex/bbft/dba"
 	. "github.com/satellitex/bbft/grpc"
 	"github.com/satellitex/bbft/model"
+	"github.com/satellitex/bbft/node"
 	"github.com/satellitex/bbft/proto"
 	"github.com/satellitex/bbft/usecase"
 	"google.golang.org/grpc"
@@ -29,37 +32,46 @@ func DecodeString64(s string) []byte {
 	return ret
 }
 
+// NewGenesisPeer は conf.VotingPowers の voting power を持つ Peer を作る。指定の無い Peer の voting power は 1
+func NewGenesisPeer(conf *config.BBFTConfig, factory model.ModelFactory, address string, pubkey []byte) model.Peer {
+	power, ok := conf.VotingPowers[hex.EncodeToString(pubkey)]
+	if !ok {
+		power = 1
+	}
+	return factory.NewPeerWithPower(address, pubkey, power)
+}
+
 func DemoGenesisCommit(conf *config.BBFTConfig, factory model.ModelFactory, bc dba.BlockChain, ps dba.PeerService) {
 
 	// myself addPeer
 	conf.PublicKey = DecodeString64(conf.Demo.PublicKey)
 	conf.SecretKey = DecodeString64(conf.Demo.SecretKey)
 
-	ps.AddPeer(factory.NewPeer(conf.Demo.Host1+":"+conf.Demo.Port1, DecodeString64(conf.Demo.Pubkey1)))
-	ps.AddPeer(factory.NewPeer(conf.Demo.Host2+":"+conf.Demo.Port2, DecodeString64(conf.Demo.Pubkey2)))
-	ps.AddPeer(factory.NewPeer(conf.Demo.Host3+":"+conf.Demo.Port3, DecodeString64(conf.Demo.Pubkey3)))
-	ps.AddPeer(factory.NewPeer(conf.Demo.Host4+":"+conf.Demo.Port4, DecodeString64(conf.Demo.Pubkey4)))
+	ps.AddPeer(NewGenesisPeer(conf, factory, conf.Demo.Host1+":"+conf.Demo.Port1, DecodeString64(conf.Demo.Pubkey1)))
+	ps.AddPeer(NewGenesisPeer(conf, factory, conf.Demo.Host2+":"+conf.Demo.Port2, DecodeString64(conf.Demo.Pubkey2)))
+	ps.AddPeer(NewGenesisPeer(conf, factory, conf.Demo.Host3+":"+conf.Demo.Port3, DecodeString64(conf.Demo.Pubkey3)))
+	ps.AddPeer(NewGenesisPeer(conf, factory, conf.Demo.Host4+":"+conf.Demo.Port4, DecodeString64(conf.Demo.Pubkey4)))
 
-	genesisBlock, err := factory.NewBlock(0, nil, 0, nil)
+	genesisBlock, err := factory.NewBlock(0, nil, 0, nil, nil, nil)
 	if err != nil {
 		panic("DemoGenesisCommit: " + err.Error())
 	}
 
-	bc.Commit(genesisBlock)
+	bc.Commit(genesisBlock, nil)
 }
 
 func OnceNodeGenesis(conf *config.BBFTConfig, factory model.ModelFactory, bc dba.BlockChain, ps dba.PeerService) {
 
 	// myself addPeer
 	conf.PublicKey, conf.SecretKey = convertor.NewKeyPair()
-	ps.AddPeer(factory.NewPeer(conf.Host+":"+conf.Port, conf.PublicKey))
+	ps.AddPeer(NewGenesisPeer(conf, factory, conf.Host+":"+conf.Port, conf.PublicKey))
 
-	genesisBlock, err := factory.NewBlock(0, nil, 0, nil)
+	genesisBlock, err := factory.NewBlock(0, nil, 0, nil, nil, nil)
 	if err != nil {
 		panic("DemoGenesisCommit: " + err.Error())
 	}
 
-	bc.Commit(genesisBlock)
+	bc.Commit(genesisBlock, nil)
 }
 
 func main() {
@@ -81,13 +93,39 @@ func main() {
 	queue := dba.NewProposalTxQueueOnMemory(conf)
 	lock := dba.NewLockOnMemory(ps, conf)
 	pool := dba.NewReceiverPoolOnMemory(conf)
+	evidences := dba.NewEvidencePoolOnMemory(conf)
 	bc := dba.NewBlockChainOnMemory()
-	slv := convertor.NewStatelessValidator()
+	slv := convertor.NewStatelessValidator(conf)
+	selector := usecase.NewLeaderSelector(conf, ps, bc)
+	sfv := convertor.NewStatefulValidator(conf, bc, ps, selector)
+	cv := convertor.NewCommitCertificateValidator(conf, ps)
+	ev := convertor.NewEvidenceValidator(conf, ps)
+	factory := convertor.NewModelFactory()
+	wal := dba.NewWALOnMemory()
+	if conf.WALPath != "" {
+		if wal, err = convertor.NewWALOnFile(conf.WALPath); err != nil {
+			panic(err.Error())
+		}
+	}
 	sender := NewGrpcConsensusSender(conf, ps)
+	syncSender := NewGrpcBlockSyncSender(conf)
+	syncer := usecase.NewBlockSyncUsecase(conf, bc, ps, slv, sfv, cv, syncSender)
 	receivChan := usecase.NewReceiveChannel(conf)
-
-	consensusReceiver := usecase.NewConsensusReceiverUsecase(queue, ps, lock, pool, bc, slv, sender, receivChan)
-	clientRceiver := usecase.NewClientGateReceiverUsecase(slv, sender)
+	detector := usecase.NewEvidenceDetector(conf, factory)
+	bus := usecase.NewEventBusOnMemory(conf)
+	monitor := usecase.NewLatencyMonitorUsecase(conf, ps, sender, factory)
+
+	var consensusReceiver usecase.ConsensusReceiver
+	var clientRceiver usecase.ClientGateReceiver
+	if conf.ConsensusEngine == usecase.ConsensusEngineHotStuff {
+		consensusReceiver = usecase.NewHotStuffReceiverUsecase(conf, queue, ps, selector, pool, evidences, bc, slv, ev, sender, receivChan)
+		clientRceiver = usecase.NewHotStuffClientGateReceiverUsecase(slv, sender)
+	} else {
+		consensusReceiver = usecase.NewConsensusReceiverUsecase(conf, queue, ps, selector, lock, pool, evidences, bc, slv, ev, sender, syncer, detector, bus, receivChan)
+		clientRceiver = usecase.NewClientGateReceiverUsecase(slv, sender)
+	}
+	blockSyncReceiver := usecase.NewBlockSyncReceiverUsecase(conf, bc)
+	txProofReceiver := usecase.NewTxProofReceiverUsecase(bc, factory)
 	log.Println("Success New Receivers")
 
 	s := grpc.NewServer([]grpc.ServerOption{
@@ -101,14 +139,23 @@ func main() {
 
 	bbft.RegisterConsensusGateServer(s, controller.NewConsensusController(consensusReceiver, author))
 	bbft.RegisterTxGateServer(s, controller.NewClientGateController(clientRceiver, author))
+	bbft.RegisterBlockSyncGateServer(s, controller.NewBlockSyncController(blockSyncReceiver, author))
+	bbft.RegisterEventGateServer(s, controller.NewEventController(bus))
+	bbft.RegisterLightClientGateServer(s, controller.NewLightClientController(blockSyncReceiver, txProofReceiver, factory))
 	log.Println("Success New Register Endpoint")
 
 	log.Println("Set Up!!")
 
-	sfv := convertor.NewStatefulValidator(bc)
-	factory := convertor.NewModelFactory()
-
-	consensus := usecase.NewConsensusStepUsecase(conf, bc, ps, lock, queue, sender, slv, sfv, factory, receivChan)
+	var consensus usecase.ConsensusStep
+	if conf.ConsensusEngine == usecase.ConsensusEngineHotStuff {
+		consensus = usecase.NewHotStuffUsecase(conf, bc, ps, selector, queue, sender, slv, sfv, cv, factory, syncer, wal, usecase.NewRealClock(), receivChan)
+	} else {
+		consensus = usecase.NewConsensusStepUsecase(conf, bc, ps, selector, lock, queue, evidences, sender, slv, sfv, ev, factory, syncer, wal, bus, monitor, usecase.NewRealClock(), receivChan)
+	}
+	// hotstuff は合意の状態を返さない
+	if reader, ok := consensus.(usecase.ConsensusStateReader); ok {
+		bbft.RegisterConsensusStateGateServer(s, controller.NewConsensusStateController(reader, ps, author))
+	}
 
 	if os.Getenv("DEMO") != "" {
 		time.Sleep(time.Second * 2)
@@ -117,13 +164,18 @@ func main() {
 		OnceNodeGenesis(conf, factory, bc, ps)
 	}
 
-	// Consensus Run!!
-	go func() {
-		consensus.Run()
-	}()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// pbft で TimeOut を決めるために Leader が記録する RTT を測る
+	if conf.AdaptiveTimeout && conf.ConsensusEngine != usecase.ConsensusEngineHotStuff {
+		go monitor.Run(ctx)
+	}
 
-	if err := s.Serve(l); err != nil {
-		log.Println("Failed to server grpc: ", err.Error())
+	// Consensus Run!! until SIGTERM
+	n := node.NewNode(s, l, consensus, receivChan, sender, syncSender, wal)
+	if err := n.RunUntilSignal(ctx); err != nil {
+		log.Println("Failed to stop node: ", err.Error())
 	}
+	log.Println("=========================== stop bbft ===========================")
 
 }
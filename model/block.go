@@ -8,30 +8,150 @@ var (
 	ErrBlockVerify  = errors.Errorf("Failed Block Verify")
 	ErrBlockSign    = errors.Errorf("Failed Block Sign")
 
-	ErrInvalidBlockHeader = errors.Errorf("Failed Invalid BlockHeader")
-	ErrBlockHeaderGetHash = errors.Errorf("Failed BlockHeader GetHash")
+	ErrBlockCreatedTimeSkew = errors.Errorf("Failed Block CreatedTime is too far from local commit time")
+	ErrBlockTooLarge        = errors.Errorf("Failed Block size exceeds MaxBlockBytes")
+	ErrBlockCostExceeded    = errors.Errorf("Failed Block total cost exceeds MaxBlockCost")
+
+	ErrInvalidBlockHeader    = errors.Errorf("Failed Invalid BlockHeader")
+	ErrInvalidTxRoot         = errors.Errorf("Failed Invalid TxRoot of BlockHeader")
+	ErrInvalidBlockVersion   = errors.Errorf("Failed Invalid Version of BlockHeader")
+	ErrInvalidProposer       = errors.Errorf("Failed Invalid Proposer of BlockHeader")
+	ErrInvalidValidatorsHash = errors.Errorf("Failed Invalid ValidatorsHash of BlockHeader")
+	ErrInvalidLastCommit     = errors.Errorf("Failed Invalid LastCommit of Block")
+	ErrBlockHeaderGetHash    = errors.Errorf("Failed BlockHeader GetHash")
 
 	ErrInvalidProposal = errors.Errorf("Failed Invalid Proposal")
+	ErrProposalGetHash = errors.Errorf("Failed Proposal GetHash")
+	ErrProposalVerify  = errors.Errorf("Failed Proposal Verify")
+
+	ErrInvalidTxProof = errors.Errorf("Failed Invalid TxProof")
+	ErrTxProofVerify  = errors.Errorf("Failed TxProof Verify")
 )
 
+// BlockHeaderVersion は今の BlockHeader の形式の version
+const BlockHeaderVersion uint32 = 1
+
 type Block interface {
 	GetHeader() BlockHeader
 	GetTransactions() []Transaction
+	GetEvidences() []Evidence
+	// GetLastCommit は前の Block の CommitCertificate を返す。前の Block が genesis block のときは nil を返す
+	GetLastCommit() CommitCertificate
+	// GetChainState は header に記録された ChainState を返す。同じ ChainState で作り直した Block は同じ header になる
+	GetChainState() ChainState
 	GetSignature() Signature
 	GetHash() ([]byte, error)
+	// GetSize は Block を encode したときの大きさ (byte) を返す
+	GetSize() int
 	Verify() error
 	Sign(pubKey []byte, privKey []byte) error
 }
 
+// LightBlock は Light Client が検証に使う Block の要約。Transaction と Evidence は Hash だけを持つ
+type LightBlock interface {
+	GetHeader() BlockHeader
+	// GetTxHashes は Block の Transaction の署名を含む Hash を順に返す
+	GetTxHashes() [][]byte
+	GetEvidenceHashes() [][]byte
+	// GetValidatorUpdateTxs は Block の Transaction のうち ValidatorUpdate を含むものを順に返す
+	GetValidatorUpdateTxs() []Transaction
+	GetSignature() Signature
+	// GetHash は元の Block と同じ Hash を返す
+	GetHash() ([]byte, error)
+	// Verify は元の Block の Verify と同じくリーダーの署名を検証する
+	Verify() error
+}
+
 type BlockHeader interface {
 	GetHeight() int64
 	GetPreBlockHash() []byte
 	GetCreatedTime() int64
 	GetCommitTime() int64
+	// GetTiming は Leader が観測した時間を返す。記録されていないときは全て 0 を返す
+	GetTiming() PhaseTiming
+	// GetTxRoot は Block の Transaction の Merkle Tree の root を返す
+	GetTxRoot() []byte
+	GetVersion() uint32
+	// GetProposer は Block を作って署名したリーダーの公開鍵を返す
+	GetProposer() []byte
+	// GetRound は proposer がリーダーとして Block を作った Round を返す
+	GetRound() int32
+	// GetStateRoot は前の Block までの Transaction の running hash を返す
+	GetStateRoot() []byte
+	// GetNextStateRoot はこの Block の Transaction までの running hash を返す。次の Block の stateRoot になる
+	GetNextStateRoot() []byte
+	// GetValidatorsHash は height で有効な Peer の集合の Hash を返す
+	GetValidatorsHash() []byte
+	// GetLastCommitHash は Block の lastCommit の Hash を返す。lastCommit が無いときは空
+	GetLastCommitHash() []byte
+	GetHash() ([]byte, error)
+}
+
+// ChainState は Block を作るときに header に記録する Chain の状態
+type ChainState interface {
+	GetProposer() []byte
+	GetRound() int32
+	GetStateRoot() []byte
+	GetValidatorsHash() []byte
+	// GetLastCommit は前の Block の CommitCertificate を返す。前の Block が genesis block のときは nil
+	GetLastCommit() CommitCertificate
+}
+
+// MerkleProof は葉が Merkle Tree に含まれることの証明
+type MerkleProof interface {
+	GetIndex() int64
+	GetTotal() int64
+	// GetAunts は葉から root までの各段の兄弟の Hash を葉に近い順に返す
+	GetAunts() [][]byte
+	// Verify は leaf が root の Merkle Tree の index 番目の葉であることを検証する
+	Verify(root []byte, leaf []byte) error
+}
+
+// TxProof は Transaction が Commit された Block に含まれることの証明
+type TxProof interface {
+	GetHeader() BlockHeader
+	GetEvidenceHashes() [][]byte
+	GetTransaction() Transaction
+	GetMerkleProof() MerkleProof
+	// GetCommitCertificate は Block を Commit した CommitCertificate を返す。genesis block のときは nil を返す
+	GetCommitCertificate() CommitCertificate
+	// GetHash は Transaction を含む Block の Hash を返す
 	GetHash() ([]byte, error)
+	// Verify は Transaction の Hash から MerkleProof で計算した root が header の txRoot と一致するかを検証する
+	Verify() error
+}
+
+// PhaseTiming は Leader が観測した通信と各 Phase にかかった時間 (ns)。0 は観測していないことを表す
+type PhaseTiming interface {
+	GetConnectDelay() int64
+	GetPropose() int64
+	GetVote() int64
+	GetPreCommit() int64
 }
 
+// Proposal の Block は作ったリーダーの署名を持つ。
+// pbft で Lock した Block を後の Round で提案し直すときは、Block を変えずに round のリーダーが Proposal に署名する
 type Proposal interface {
 	GetBlock() Block
 	GetRound() int32
+	// GetJustify は hotstuff で Block の親に対する QC を返す。無いときは nil
+	GetJustify() CommitCertificate
+	// GetPOLRound は提案し直した Proposal のとき、Block が 2/3 以上の PreVote を集めて Lock された Round を返す
+	GetPOLRound() int32
+	// GetSignature は提案し直した Proposal の round のリーダーの署名を返す。提案し直していないときは空
+	GetSignature() Signature
+	// GetHash は signature 以外の field の Hash を返す。これが署名の対象になる
+	GetHash() ([]byte, error)
+	Sign(pubKey []byte, privKey []byte) error
+	Verify() error
+}
+
+// GetProposer は proposal を GetRound() の Round で提案したリーダーの Pubkey を返す
+// 提案し直した Proposal では Proposal に署名した Peer、そうでなければ Block に署名した Peer になる
+func GetProposer(proposal Proposal) []byte {
+	block := proposal.GetBlock()
+	if block.GetHeader().GetRound() < proposal.GetRound() {
+		return proposal.GetSignature().GetPubkey()
+	}
+	return block.GetSignature().GetPubkey()
 }
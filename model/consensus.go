@@ -7,11 +7,72 @@ var (
 	ErrVoteMessageVerify       = errors.Errorf("Failed VoteMessage Verify")
 	ErrVoteMessageSign         = errors.Errorf("Failed VoteMessage Sign")
 	ErrInvalidVoteMessage      = errors.Errorf("Failed Invalid VoteMessage")
+	ErrVoteMessageGetHash      = errors.Errorf("Failed VoteMessage GetHash")
+
+	ErrInvalidCommitCertificate = errors.Errorf("Failed Invalid CommitCertificate")
+	ErrCommitCertificateGetHash = errors.Errorf("Failed CommitCertificate GetHash")
+
+	ErrInvalidEvidence = errors.Errorf("Failed Invalid Evidence")
+	ErrEvidenceGetHash = errors.Errorf("Failed Evidence GetHash")
+	ErrEvidenceVerify  = errors.Errorf("Failed Evidence Verify")
+	ErrEvidenceSign    = errors.Errorf("Failed Evidence Sign")
+)
+
+type VoteType int32
+
+const (
+	UnknownVote VoteType = iota
+	PreVote
+	PreCommit
 )
 
+// VoteMessage は signature 以外の全ての field を署名する
 type VoteMessage interface {
+	GetChainId() string
+	GetHeight() int64
+	GetRound() int32
+	GetType() VoteType
+	GetBlockHash() []byte
+	// Reject Vote であるか。Reject Vote の GetRejectMessage は Reject の理由を返す
+	IsReject() bool
+	GetRejectMessage() string
+	GetSignature() Signature
+	GetHash() ([]byte, error)
+	Sign(pubKey []byte, privKey []byte) error
+	Verify() error
+}
+
+type CommitCertificate interface {
+	GetHeight() int64
+	GetRound() int32
 	GetBlockHash() []byte
+	GetPreCommits() []VoteMessage
+	GetHash() ([]byte, error)
+}
+
+type EvidenceType int32
+
+const (
+	UnknownEvidence EvidenceType = iota
+	DuplicateProposal
+	DuplicatePreCommit
+)
+
+// Evidence は同じ height, round で異なる2つの Proposal または PreCommit に署名した Peer の不正の証拠である
+// signature は Evidence を見つけた Peer の署名で、signature 以外の全ての field を署名する
+type Evidence interface {
+	GetType() EvidenceType
+	// DuplicateProposal のとき、同じリーダーが同じ Round で提案した異なる Block の2つの Proposal
+	// 提案し直した Proposal では Proposal に署名した Peer をリーダー、Proposal の round を Round とする
+	GetProposals() []Proposal
+	// DuplicatePreCommit のとき、同じ Peer が署名した異なる2つの PreCommit
+	GetVotes() []VoteMessage
+	// 不正をした Peer の Pubkey
+	GetOffender() []byte
+	GetHeight() int64
+	GetRound() int32
 	GetSignature() Signature
+	GetHash() ([]byte, error)
 	Sign(pubKey []byte, privKey []byte) error
 	Verify() error
 }
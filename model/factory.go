@@ -5,14 +5,35 @@ import "github.com/pkg/errors"
 var (
 	ErrNewBlock    = errors.Errorf("Failed Factory NewBlock")
 	ErrNewProposal = errors.Errorf("Failed Factory NewProposal")
+
+	ErrNewCommitCertificate = errors.Errorf("Failed Factory NewCommitCertificate")
+	ErrNewEvidence          = errors.Errorf("Failed Factory NewEvidence")
 )
 
 type ModelFactory interface {
-	NewBlock(height int64, preBlockHash []byte, createdTime int64, txs []Transaction) (Block, error)
+	// NewBlock は state を header に記録した Block を作る。state が nil のときは proposer などを空にする (genesis block)
+	NewBlock(height int64, preBlockHash []byte, createdTime int64, txs []Transaction, evidences []Evidence, state ChainState) (Block, error)
+	// NewTimedBlock は Leader が観測した timing を記録した Block を作る。timing が nil のときは NewBlock と同じ
+	NewTimedBlock(height int64, preBlockHash []byte, createdTime int64, txs []Transaction, evidences []Evidence, state ChainState, timing PhaseTiming) (Block, error)
+	// NewChainState は round のリーダーの proposer が作る Block の ChainState を返す。validatorsHash は validators から計算する
+	NewChainState(proposer []byte, round int32, stateRoot []byte, validators []Peer, lastCommit CommitCertificate) ChainState
+	// NewLightBlock は block から Light Client に渡す LightBlock を作る
+	NewLightBlock(block Block) (LightBlock, error)
+	// NewTxProof は Hash が txHash の Transaction が block に含まれることの TxProof を作る。cert は block の CommitCertificate
+	NewTxProof(block Block, txHash []byte, cert CommitCertificate) (TxProof, error)
 	NewProposal(block Block, round int32) (Proposal, error)
-	NewVoteMessage(hash []byte) VoteMessage
+	// NewReProposal は polRound で Lock した block を round で提案し直す Proposal を作る。round のリーダーが Sign する
+	NewReProposal(block Block, round int32, polRound int32) (Proposal, error)
+	NewJustifiedProposal(block Block, round int32, justify CommitCertificate) (Proposal, error)
+	NewVoteMessage(chainId string, height int64, round int32, voteType VoteType, hash []byte) VoteMessage
+	NewRejectVoteMessage(chainId string, height int64, round int32, hash []byte, reason string) VoteMessage
+	NewCommitCertificate(height int64, round int32, blockHash []byte, preCommits []VoteMessage) (CommitCertificate, error)
+	NewEvidence(evidenceType EvidenceType, proposals []Proposal, votes []VoteMessage) (Evidence, error)
 	NewSignature(pubkey []byte, signature []byte) Signature
+	NewPhaseTiming(connectDelay int64, propose int64, vote int64, preCommit int64) PhaseTiming
 	NewPeer(address string, pubkey []byte) Peer
+	NewPeerWithPower(address string, pubkey []byte, power int64) Peer
+	NewValidatorUpdate(updateType ValidatorUpdateType, address string, pubkey []byte, newPubkey []byte, power int64) ValidatorUpdate
 }
 
 type Hasher interface {
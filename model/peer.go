@@ -1,6 +1,50 @@
 package model
 
+import "github.com/pkg/errors"
+
+var (
+	ErrInvalidValidatorUpdate = errors.Errorf("Failed Invalid ValidatorUpdate")
+)
+
+// Peer は合意形成に参加する Peer。Vote は Peer の数ではなく voting power の和で数える
 type Peer interface {
 	GetAddress() string
 	GetPubkey() []byte
+	GetPower() int64
+}
+
+type ValidatorUpdateType int32
+
+const (
+	UnknownValidatorUpdate ValidatorUpdateType = iota
+	AddValidator
+	RemoveValidator
+	RekeyValidator
+)
+
+// ValidatorUpdate は合意形成に参加する Peer の集合の変更である
+// Commit された height から ValidatorUpdateDelay 後の height で有効になる
+type ValidatorUpdate interface {
+	GetType() ValidatorUpdateType
+	// AddValidator, RekeyValidator のときの Peer の Address
+	GetAddress() string
+	// 追加, 削除, 鍵を変える Peer の Pubkey
+	GetPubkey() []byte
+	// RekeyValidator のときの新しい Pubkey
+	GetNewPubkey() []byte
+	// AddValidator のときの Peer の voting power
+	GetPower() int64
+	// AddValidator のときに追加する Peer。それ以外のときは nil
+	GetPeer() Peer
+}
+
+// GetValidatorUpdates は txs に含まれる ValidatorUpdate を順に返す
+func GetValidatorUpdates(txs []Transaction) []ValidatorUpdate {
+	ret := make([]ValidatorUpdate, 0)
+	for _, tx := range txs {
+		if update, ok := tx.GetPayload().GetValidatorUpdate(); ok {
+			ret = append(ret, update)
+		}
+	}
+	return ret
 }
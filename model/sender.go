@@ -1,12 +1,22 @@
 package model
 
-import "github.com/pkg/errors"
+import (
+	"github.com/pkg/errors"
+	"time"
+)
 
 var (
 	ErrConsensusSenderPropagate = errors.Errorf("Failed ConsensusSender Propagate")
 	ErrConsensusSenderPropose   = errors.Errorf("Failed ConsensusSender Propose")
 	ErrConsensusSenderVote      = errors.Errorf("Failed ConsensusSender Vote")
 	ErrConsensusSenderPreCommit = errors.Errorf("Failed ConsensusSender PreCommit")
+	ErrConsensusSenderEvidence  = errors.Errorf("Failed ConsensusSender Evidence")
+	ErrConsensusSenderPing      = errors.Errorf("Failed ConsensusSender Ping")
+
+	ErrBlockSyncSenderGetBlocks = errors.Errorf("Failed BlockSyncSender GetBlocks")
+
+	ErrLightBlockSenderGetLightBlocks = errors.Errorf("Failed LightBlockSender GetLightBlocks")
+	ErrLightBlockSenderGetTxProof     = errors.Errorf("Failed LightBlockSender GetTxProof")
 )
 
 type ConsensusSender interface {
@@ -14,4 +24,26 @@ type ConsensusSender interface {
 	Propose(proposal Proposal) error
 	Vote(vote VoteMessage) error
 	PreCommit(vote VoteMessage) error
+	// PreCommitTo は vote を peer だけに送る。hotstuff で次の view のリーダーに投票するときに使う
+	PreCommitTo(peer Peer, vote VoteMessage) error
+	Evidence(evidence Evidence) error
+	// Ping は peer に Ping を送り、返ってくるまでの時間 (RTT) を返す
+	Ping(peer Peer) (time.Duration, error)
+	// Close は Peer との接続を全て閉じる
+	Close() error
+}
+
+type BlockSyncSender interface {
+	GetBlocks(peer Peer, from int64, to int64) ([]Block, []CommitCertificate, error)
+	// Close は Peer との接続を全て閉じる
+	Close() error
+}
+
+// LightBlockSender は Light Client が Full Node から LightBlock を取得する
+type LightBlockSender interface {
+	GetLightBlocks(peer Peer, from int64, to int64) ([]LightBlock, []CommitCertificate, error)
+	// GetTxProof は Hash が txHash の Transaction が Commit された Block に含まれることの TxProof を取得する
+	GetTxProof(peer Peer, txHash []byte) (TxProof, error)
+	// Close は Peer との接続を全て閉じる
+	Close() error
 }
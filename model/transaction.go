@@ -6,15 +6,24 @@ var (
 	ErrInvalidTransaction = errors.Errorf("Failed Invalid Transaction")
 	ErrTransactionGetHash = errors.Errorf("Failed Transaction GetHash")
 	ErrTransactionVerify  = errors.Errorf("Failed Transaction Verify")
+
+	ErrTransactionTooLarge    = errors.Errorf("Failed Transaction size exceeds MaxTxBytes")
+	ErrInvalidTransactionCost = errors.Errorf("Failed Invalid Transaction cost")
 )
 
 type Transaction interface {
 	GetPayload() TransactionPayload
 	GetSignatures() []Signature
 	GetHash() ([]byte, error)
+	// GetSize は Transaction を encode したときの大きさ (byte) を返す
+	GetSize() int
 	Verify() error
 }
 
 type TransactionPayload interface {
 	GetMessage() string
+	// Peer の集合を変える Transaction のとき ValidatorUpdate と true を返す
+	GetValidatorUpdate() (ValidatorUpdate, bool)
+	// GetCost は Application が見積もった処理の重さを返す
+	GetCost() int64
 }
@@ -6,13 +6,35 @@ var (
 	ErrStatefulValidate       = errors.Errorf("Failed StatefulValidator Validate")
 	ErrStatelessBlockValidate = errors.Errorf("Failed StatelessBlockValidator Validate")
 	ErrStatelessTxValidate    = errors.Errorf("Failed StatelessTxValidator Validate")
+
+	ErrCommitCertificateValidate = errors.Errorf("Failed CommitCertificateValidator Validate")
+	ErrEvidenceValidate          = errors.Errorf("Failed EvidenceValidator Validate")
 )
 
 type StatefulValidator interface {
 	Validate(block Block) error
 }
 
+// LeaderSelector は height, round のリーダーを返す。StatefulValidator が Block の proposer を確かめるのに使う
+type LeaderSelector interface {
+	GetLeader(height int64, round int32) (Peer, bool)
+}
+
 type StatelessValidator interface {
 	BlockValidate(block Block) error
 	TxValidate(tx Transaction) error
 }
+
+type CommitCertificateValidator interface {
+	Validate(block Block, cert CommitCertificate) error
+	// ValidateLightBlock は block の元の Block に対して Validate と同じ検証をする
+	ValidateLightBlock(block LightBlock, cert CommitCertificate) error
+	// ValidateTxProof は proof の Transaction を含む Block に対して Validate と同じ検証をし、Transaction が Block に含まれることを確かめる
+	ValidateTxProof(proof TxProof) error
+	// ValidateSignatures は元の Block を持っていないときに、cert 自身の Height と BlockHash に対して Validate と同じ署名の検証をする
+	ValidateSignatures(cert CommitCertificate) error
+}
+
+type EvidenceValidator interface {
+	Validate(evidence Evidence) error
+}
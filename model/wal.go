@@ -0,0 +1,48 @@
+package model
+
+import "github.com/pkg/errors"
+
+var (
+	ErrWALWrite         = errors.Errorf("Failed WAL Write")
+	ErrWALRead          = errors.Errorf("Failed WAL Read")
+	ErrWALClean         = errors.Errorf("Failed WAL Clean")
+	ErrInvalidWALRecord = errors.Errorf("Failed Invalid WALRecord")
+)
+
+type WALRecordType int32
+
+const (
+	WALRound WALRecordType = iota
+	WALProposal
+	WALVote
+	WALPreCommit
+)
+
+// WALRecord は WAL に書いた1つの記録
+type WALRecord interface {
+	GetType() WALRecordType
+	GetHeight() int64
+	GetRound() int32
+	// 自分が送ったメッセージであるか
+	IsSent() bool
+	// WALProposal のときの Proposal。それ以外は nil
+	GetProposal() Proposal
+	// WALVote, WALPreCommit のときの VoteMessage。それ以外は nil
+	GetVote() VoteMessage
+}
+
+// WAL (Write Ahead Log) は合意の途中で送った・受け取ったメッセージと Round の移り変わりを記録する
+// 再起動した Node は記録を読み直して height, round, Lock と投票を元に戻し、前と矛盾する署名をしないようにする
+type WAL interface {
+	// WriteRound は height, round の Round を始めたことを記録する
+	WriteRound(height int64, round int32) error
+	// WriteProposal は Proposal を記録する。sent は自分が送った Proposal であるか
+	WriteProposal(proposal Proposal, sent bool) error
+	// WriteVote は PreVote または PreCommit を記録する。sent は自分が送った VoteMessage であるか
+	WriteVote(vote VoteMessage, sent bool) error
+	// ReadAll は記録を書いた順に返す
+	ReadAll() ([]WALRecord, error)
+	// Clean は height 未満の記録をすべて消す
+	Clean(height int64) error
+	Close() error
+}
@@ -0,0 +1,135 @@
+package node
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/satellitex/bbft/usecase"
+	"go.uber.org/multierr"
+	"google.golang.org/grpc"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+var (
+	ErrNodeAlreadyStarted = errors.Errorf("Failed Node is already started")
+	ErrNodeNotStarted     = errors.Errorf("Failed Node is not started")
+	ErrNodeStop           = errors.Errorf("Failed Node Stop")
+)
+
+// Node は gRPC Server と Consensus の loop の起動と停止を管理する
+type Node struct {
+	server    *grpc.Server
+	listener  net.Listener
+	consensus usecase.ConsensusStep
+	channel   *usecase.ReceiveChannel
+	// closers は停止時に閉じる Sender や Storage
+	closers []io.Closer
+
+	mutex   *sync.Mutex
+	cancel  context.CancelFunc
+	done    chan struct{}
+	stopped bool
+}
+
+func NewNode(server *grpc.Server, listener net.Listener, consensus usecase.ConsensusStep, channel *usecase.ReceiveChannel, closers ...io.Closer) *Node {
+	return &Node{
+		server:    server,
+		listener:  listener,
+		consensus: consensus,
+		channel:   channel,
+		closers:   closers,
+		mutex:     new(sync.Mutex),
+	}
+}
+
+// Start は gRPC Server と Consensus の loop を起動してすぐに返る
+// ctx が終わると Consensus の loop は Phase の間で止まる
+func (n *Node) Start(ctx context.Context) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if n.cancel != nil || n.stopped {
+		return ErrNodeAlreadyStarted
+	}
+	ctx, n.cancel = context.WithCancel(ctx)
+	n.done = make(chan struct{})
+
+	go func() {
+		defer close(n.done)
+		if err := n.consensus.Run(ctx); err != nil && err != context.Canceled {
+			log.Println("Consensus Stopped: ", err)
+		}
+	}()
+
+	go func() {
+		if err := n.server.Serve(n.listener); err != nil {
+			log.Println("Failed to server grpc: ", err.Error())
+		}
+	}()
+	return nil
+}
+
+// Stop は Consensus の loop を Phase の間で止め、受け取り済みのメッセージを捨てながら gRPC Server を GracefulStop し、
+// closers を全て閉じる。2回目以降の呼び出しは何もしない
+func (n *Node) Stop() error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if n.cancel == nil {
+		return ErrNodeNotStarted
+	}
+	if n.stopped {
+		return nil
+	}
+	n.stopped = true
+
+	n.cancel()
+	// GracefulStop は処理中の Handler を待つが、Handler は誰も読まなくなった Channel への送信で止まりうるので、
+	// GracefulStop が終わるまで Channel を空にし続ける
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		n.server.GracefulStop()
+	}()
+	n.channel.DrainUntil(stopped)
+	<-n.done
+	n.channel.Drain()
+
+	var result error
+	for _, closer := range n.closers {
+		result = multierr.Append(result, closer.Close())
+	}
+	if result != nil {
+		return errors.Wrapf(ErrNodeStop, result.Error())
+	}
+	return nil
+}
+
+// Done は Consensus の loop が止まったときに閉じる channel を返す
+func (n *Node) Done() <-chan struct{} {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.done
+}
+
+// RunUntilSignal は Node を起動し、SIGTERM か SIGINT を受け取ると Stop する
+func (n *Node) RunUntilSignal(ctx context.Context) error {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(sig)
+
+	if err := n.Start(ctx); err != nil {
+		return err
+	}
+	select {
+	case s := <-sig:
+		log.Println("Receive Signal: ", s)
+	case <-n.Done():
+	}
+	return n.Stop()
+}
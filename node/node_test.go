@@ -0,0 +1,157 @@
+package node_test
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/satellitex/bbft/convertor"
+	. "github.com/satellitex/bbft/node"
+	"github.com/satellitex/bbft/proto"
+	. "github.com/satellitex/bbft/test_utils"
+	"github.com/satellitex/bbft/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+type blockingConsensusStep struct {
+	usecase.ConsensusStep
+	running chan struct{}
+}
+
+func (c *blockingConsensusStep) Run(ctx context.Context) error {
+	close(c.running)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+type testCloser struct {
+	closed bool
+	err    error
+}
+
+func (c *testCloser) Close() error {
+	c.closed = true
+	return c.err
+}
+
+func NewTestNode(t *testing.T, closers ...*testCloser) (*blockingConsensusStep, *usecase.ReceiveChannel, *Node) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	consensus := &blockingConsensusStep{running: make(chan struct{})}
+	channel := usecase.NewReceiveChannel(GetTestConfig())
+	cs := make([]io.Closer, 0, len(closers))
+	for _, c := range closers {
+		cs = append(cs, c)
+	}
+	return consensus, channel, NewNode(grpc.NewServer(), l, consensus, channel, cs...)
+}
+
+func TestNode_StartStop(t *testing.T) {
+	closer := &testCloser{}
+	consensus, channel, node := NewTestNode(t, closer)
+
+	assert.EqualError(t, errors.Cause(node.Stop()), ErrNodeNotStarted.Error())
+
+	require.NoError(t, node.Start(context.Background()))
+	<-consensus.running
+	assert.EqualError(t, errors.Cause(node.Start(context.Background())), ErrNodeAlreadyStarted.Error())
+
+	channel.Propose <- RandomProposal(t)
+	channel.Vote <- RandomVoteMessage(t)
+
+	require.NoError(t, node.Stop())
+	<-node.Done()
+	assert.True(t, closer.closed)
+	assert.Empty(t, channel.Propose)
+	assert.Empty(t, channel.Vote)
+
+	// Stop twice is nothing to do
+	assert.NoError(t, node.Stop())
+	assert.EqualError(t, errors.Cause(node.Start(context.Background())), ErrNodeAlreadyStarted.Error())
+}
+
+func TestNode_StopWithCloseError(t *testing.T) {
+	closers := []*testCloser{{err: errors.New("close error")}, {}}
+	consensus, _, node := NewTestNode(t, closers...)
+
+	require.NoError(t, node.Start(context.Background()))
+	<-consensus.running
+
+	err := node.Stop()
+	assert.EqualError(t, errors.Cause(err), ErrNodeStop.Error())
+	for _, c := range closers {
+		assert.True(t, c.closed)
+	}
+}
+
+func TestNode_StopByContext(t *testing.T) {
+	consensus, _, node := NewTestNode(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, node.Start(ctx))
+	<-consensus.running
+	cancel()
+	<-node.Done()
+	assert.NoError(t, node.Stop())
+}
+
+// registerBlockingService は受け取った Proposal を Channel に送る Handler を登録する
+// Consensus の loop が止まって Channel を読まなくなった後の Receiver と同じく、Channel が一杯なら送信で止まる
+func registerBlockingService(server *grpc.Server, channel *usecase.ReceiveChannel, entered chan struct{}) {
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "test.Blocking",
+		HandlerType: (*interface{})(nil),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "Propose",
+			Handler: func(_ interface{}, _ context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := &bbft.Proposal{}
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				close(entered)
+				channel.Propose <- &convertor.Proposal{in}
+				return &bbft.ConsensusResponse{}, nil
+			},
+		}},
+	}, struct{}{})
+}
+
+func TestNode_StopWithBlockedHandler(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	consensus := &blockingConsensusStep{running: make(chan struct{})}
+	channel := usecase.NewReceiveChannel(GetTestConfig())
+	server := grpc.NewServer()
+	entered := make(chan struct{})
+	registerBlockingService(server, channel, entered)
+	node := NewNode(server, l, consensus, channel)
+
+	require.NoError(t, node.Start(context.Background()))
+	<-consensus.running
+	for i := 0; i < cap(channel.Propose); i++ {
+		channel.Propose <- RandomProposal(t)
+	}
+
+	conn, err := grpc.Dial(l.Addr().String(), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	go conn.Invoke(context.Background(), "/test.Blocking/Propose",
+		RandomProposal(t).(*convertor.Proposal).Proposal, &bbft.ConsensusResponse{})
+	<-entered
+
+	stopped := make(chan error)
+	go func() {
+		stopped <- node.Stop()
+	}()
+	select {
+	case err := <-stopped:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop is blocked by the handler sending to the full channel")
+	}
+	assert.Empty(t, channel.Propose)
+}
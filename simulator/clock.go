@@ -0,0 +1,176 @@
+package simulator
+
+import (
+	"container/heap"
+	"github.com/satellitex/bbft/usecase"
+	"sync"
+	"time"
+)
+
+// event は仮想時刻 at に起こる Timer の発火かメッセージの配送
+// 同じ時刻のものは登録された順 (seq) に起こる
+type event struct {
+	at      int64
+	seq     uint64
+	timer   *virtualTimer
+	deliver func()
+}
+
+type eventQueue []*event
+
+func (q eventQueue) Len() int { return len(q) }
+func (q eventQueue) Less(i, j int) bool {
+	if q[i].at != q[j].at {
+		return q[i].at < q[j].at
+	}
+	return q[i].seq < q[j].seq
+}
+func (q eventQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *eventQueue) Push(x interface{}) { *q = append(*q, x.(*event)) }
+func (q *eventQueue) Pop() interface{} {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+// scheduler は全ての Node で共有する仮想時刻と event の queue を持つ
+// Node は Timer を待つ直前に idle になり、Simulator は全ての Node が idle のときだけ次の event を起こす
+// そのため同時に動く Node は高々1つで、同じ seed なら同じ順序で実行される
+type scheduler struct {
+	mutex *sync.Mutex
+	cond  *sync.Cond
+	now   int64
+	seq   uint64
+	queue eventQueue
+	busy  []bool
+}
+
+func newScheduler(start int64, nodes int) *scheduler {
+	mutex := new(sync.Mutex)
+	busy := make([]bool, nodes)
+	for i := range busy {
+		busy[i] = true // 最初の Timer を待つまでは busy
+	}
+	return &scheduler{
+		mutex: mutex,
+		cond:  sync.NewCond(mutex),
+		now:   start,
+		busy:  busy,
+	}
+}
+
+func (s *scheduler) Now() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.now
+}
+
+func (s *scheduler) schedule(at int64, timer *virtualTimer, deliver func()) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.seq++
+	heap.Push(&s.queue, &event{at, s.seq, timer, deliver})
+}
+
+// next は次の event を取り出し、仮想時刻をその時刻まで進める。止められた Timer は飛ばす
+func (s *scheduler) next() (*event, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for s.queue.Len() > 0 {
+		e := heap.Pop(&s.queue).(*event)
+		if e.timer != nil && e.timer.stopped {
+			continue
+		}
+		if e.at > s.now {
+			s.now = e.at
+		}
+		return e, true
+	}
+	return nil, false
+}
+
+// fire は Timer を待っている Node を起こす
+func (s *scheduler) fire(timer *virtualTimer) {
+	s.mutex.Lock()
+	timer.stopped = true
+	s.busy[timer.node] = true
+	now := s.now
+	s.mutex.Unlock()
+	timer.c <- time.Unix(0, now)
+}
+
+func (s *scheduler) setBusy(node int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.busy[node] = true
+}
+
+func (s *scheduler) setIdle(node int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.busy[node] = false
+	s.cond.Broadcast()
+}
+
+// waitIdle は全ての Node が idle になるまで待つ
+func (s *scheduler) waitIdle() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for s.isBusy() {
+		s.cond.Wait()
+	}
+}
+
+func (s *scheduler) isBusy() bool {
+	for _, busy := range s.busy {
+		if busy {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeClock は Node ごとの usecase.Clock。Now は仮想時刻から skew だけずれる
+type nodeClock struct {
+	s    *scheduler
+	node int
+	skew time.Duration
+}
+
+func (c *nodeClock) Now() int64 {
+	return c.s.Now() + int64(c.skew)
+}
+
+func (c *nodeClock) NewTimer(d time.Duration) usecase.Timer {
+	timer := &virtualTimer{
+		s:    c.s,
+		node: c.node,
+		c:    make(chan time.Time, 1),
+	}
+	c.s.schedule(c.s.Now()+int64(d), timer, nil)
+	return timer
+}
+
+type virtualTimer struct {
+	s       *scheduler
+	node    int
+	c       chan time.Time
+	stopped bool
+}
+
+// C を呼んだ Node はこれから select で待つので idle になる
+func (t *virtualTimer) C() <-chan time.Time {
+	t.s.setIdle(t.node)
+	return t.c
+}
+
+func (t *virtualTimer) Stop() bool {
+	t.s.mutex.Lock()
+	defer t.s.mutex.Unlock()
+	if t.stopped {
+		return false
+	}
+	t.stopped = true
+	return true
+}
@@ -0,0 +1,111 @@
+package simulator
+
+import (
+	"github.com/pkg/errors"
+	"github.com/satellitex/bbft/model"
+	"github.com/satellitex/bbft/usecase"
+	"time"
+)
+
+// transport は Node の ConsensusSender で、メッセージを全ての Node (自分を含む) への配送 event にする
+// PreCommitTo だけは宛先の Node への配送 event にする
+type transport struct {
+	sim  *Simulator
+	from int
+}
+
+func (t *transport) Propagate(tx model.Transaction) error {
+	if tx == nil {
+		return errors.Wrapf(model.ErrConsensusSenderPropagate, "tx is nil")
+	}
+	t.sim.broadcast(t.from, func(receiver usecase.ConsensusReceiver) error {
+		return receiver.Propagate(tx)
+	})
+	return nil
+}
+
+func (t *transport) Propose(proposal model.Proposal) error {
+	if proposal == nil {
+		return errors.Wrapf(model.ErrConsensusSenderPropose, "proposal is nil")
+	}
+	t.sim.broadcast(t.from, func(receiver usecase.ConsensusReceiver) error {
+		return receiver.Propose(proposal)
+	})
+	return nil
+}
+
+func (t *transport) Vote(vote model.VoteMessage) error {
+	if vote == nil {
+		return errors.Wrapf(model.ErrConsensusSenderVote, "vote is nil")
+	}
+	t.sim.broadcast(t.from, func(receiver usecase.ConsensusReceiver) error {
+		return receiver.Vote(vote)
+	})
+	return nil
+}
+
+func (t *transport) PreCommit(vote model.VoteMessage) error {
+	if vote == nil {
+		return errors.Wrapf(model.ErrConsensusSenderPreCommit, "vote is nil")
+	}
+	t.sim.broadcast(t.from, func(receiver usecase.ConsensusReceiver) error {
+		return receiver.PreCommit(vote)
+	})
+	return nil
+}
+
+func (t *transport) PreCommitTo(peer model.Peer, vote model.VoteMessage) error {
+	if peer == nil || vote == nil {
+		return errors.Wrapf(model.ErrConsensusSenderPreCommit, "peer or vote is nil")
+	}
+	target, ok := t.sim.findNode(peer.GetAddress())
+	if !ok {
+		return errors.Wrapf(model.ErrConsensusSenderPreCommit, "unknown peer: %s", peer.GetAddress())
+	}
+	t.sim.send(t.from, target, func(receiver usecase.ConsensusReceiver) error {
+		return receiver.PreCommit(vote)
+	})
+	return nil
+}
+
+func (t *transport) Evidence(evidence model.Evidence) error {
+	if evidence == nil {
+		return errors.Wrapf(model.ErrConsensusSenderEvidence, "evidence is nil")
+	}
+	t.sim.broadcast(t.from, func(receiver usecase.ConsensusReceiver) error {
+		return receiver.Evidence(evidence)
+	})
+	return nil
+}
+
+// Ping は乱数を引かずに、配送の遅れの平均の往復を返す
+func (t *transport) Ping(peer model.Peer) (time.Duration, error) {
+	if peer == nil {
+		return 0, errors.Wrapf(model.ErrConsensusSenderPing, "peer is nil")
+	}
+	return t.sim.sim.MinDelay + t.sim.sim.MaxDelay, nil
+}
+
+func (t *transport) Close() error {
+	return nil
+}
+
+// blockSyncTransport は Node の BlockSyncSender で、相手の Node の BlockChain から直接読む
+type blockSyncTransport struct {
+	sim *Simulator
+}
+
+func (t *blockSyncTransport) GetBlocks(peer model.Peer, from int64, to int64) ([]model.Block, []model.CommitCertificate, error) {
+	if peer == nil {
+		return nil, nil, errors.Wrapf(model.ErrBlockSyncSenderGetBlocks, "peer is nil")
+	}
+	target, ok := t.sim.findNode(peer.GetAddress())
+	if !ok {
+		return nil, nil, errors.Wrapf(model.ErrBlockSyncSenderGetBlocks, "unknown peer: %s", peer.GetAddress())
+	}
+	return target.syncReceiver.GetBlocks(from, to)
+}
+
+func (t *blockSyncTransport) Close() error {
+	return nil
+}
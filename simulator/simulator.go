@@ -0,0 +1,339 @@
+package simulator
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/satellitex/bbft/config"
+	"github.com/satellitex/bbft/convertor"
+	"github.com/satellitex/bbft/dba"
+	"github.com/satellitex/bbft/model"
+	"github.com/satellitex/bbft/usecase"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var (
+	ErrSimulatorAgreement = errors.Errorf("Failed Agreement, different blocks are committed at same height")
+	ErrSimulatorLiveness  = errors.Errorf("Failed Liveness, not reached target height after GST")
+	ErrSimulatorDeadlock  = errors.Errorf("Failed Simulator has no event")
+)
+
+// 仮想時刻の開始時刻
+var simulationStart = time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano()
+
+// Config は Simulation の設定
+type Config struct {
+	// Seed が同じなら同じ実行結果になる
+	Seed int64
+	// Node の数
+	Nodes int
+	// 全ての Node がこの height まで Commit すると終わる
+	Heights int64
+	// GST (Global Stabilization Time) より前に送られたメッセージは DropRate の確率で届かない
+	GST      time.Duration
+	DropRate float64
+	// メッセージは MinDelay から MaxDelay の間で一様に遅れて届く
+	MinDelay time.Duration
+	MaxDelay time.Duration
+	// 各 Node の時計は -ClockSkew から ClockSkew の間で一様にずれる
+	ClockSkew time.Duration
+	// GST 以降、全ての Node の height がこの仮想時間進まないときは Liveness 違反とする
+	Timeout time.Duration
+	// i 番目の Node の voting power。指定の無い Node の voting power は 1
+	Powers []int64
+}
+
+// Result は Simulation の結果。同じ Seed なら同じ値になる
+type Result struct {
+	// Hashes は height 1 から順に合意した Block の Hash
+	Hashes [][]byte
+	// Elapsed は経過した仮想時間
+	Elapsed time.Duration
+	// Delivered と Dropped は届いたメッセージと落ちたメッセージの数
+	Delivered int
+	Dropped   int
+}
+
+type simNode struct {
+	id           int
+	address      string
+	bc           dba.BlockChain
+	receiver     usecase.ConsensusReceiver
+	syncReceiver usecase.BlockSyncReceiver
+	step         usecase.ConsensusStep
+	// receiver が受け取ったメッセージは recvChan に入り、Simulator が1つずつ stepChan に渡す
+	recvChan *usecase.ReceiveChannel
+	stepChan *usecase.ReceiveChannel
+	// checked まで Agreement を確認した
+	checked int64
+}
+
+// Simulator は N 個の Node をメモリ上のネットワークと仮想時刻で動かし、Agreement と Liveness を確認する
+type Simulator struct {
+	conf      *config.BBFTConfig
+	sim       Config
+	rand      *rand.Rand
+	scheduler *scheduler
+	nodes     []*simNode
+	result    *Result
+}
+
+// NewSimulator は conf を元に sim.Nodes 個の Node を作る。鍵と Address は Seed から決まる
+func NewSimulator(conf *config.BBFTConfig, sim Config) *Simulator {
+	s := &Simulator{
+		conf:      conf,
+		sim:       sim,
+		rand:      rand.New(rand.NewSource(sim.Seed)),
+		scheduler: newScheduler(simulationStart, sim.Nodes),
+		nodes:     make([]*simNode, sim.Nodes),
+		result:    &Result{Hashes: make([][]byte, 0, sim.Heights)},
+	}
+	factory := convertor.NewModelFactory()
+
+	confs := make([]*config.BBFTConfig, sim.Nodes)
+	peers := make([]model.Peer, sim.Nodes)
+	for i := range confs {
+		c := *conf
+		c.PublicKey, c.SecretKey = convertor.NewKeyPairFromSeed(nodeSeed(sim.Seed, i))
+		c.Host, c.Port = fmt.Sprintf("node%d", i), "0"
+		confs[i] = &c
+		power := int64(1)
+		if i < len(sim.Powers) {
+			power = sim.Powers[i]
+		}
+		peers[i] = factory.NewPeerWithPower(c.Host+":"+c.Port, c.PublicKey, power)
+	}
+	for i, c := range confs {
+		var skew time.Duration
+		if sim.ClockSkew > 0 {
+			skew = time.Duration(s.rand.Int63n(int64(2*sim.ClockSkew))) - sim.ClockSkew
+		}
+		s.nodes[i] = s.newNode(i, c, peers, factory, &nodeClock{s.scheduler, i, skew})
+	}
+	return s
+}
+
+func nodeSeed(seed int64, i int) []byte {
+	b := make([]byte, 16)
+	binary.BigEndian.PutUint64(b, uint64(seed))
+	binary.BigEndian.PutUint64(b[8:], uint64(i))
+	hash := sha256.Sum256(b)
+	return hash[:]
+}
+
+func (s *Simulator) newNode(id int, conf *config.BBFTConfig, peers []model.Peer, factory model.ModelFactory, clock usecase.Clock) *simNode {
+	ps := dba.NewPeerServiceOnMemory()
+	for _, peer := range peers {
+		ps.AddPeer(peer)
+	}
+	queue := dba.NewProposalTxQueueOnMemory(conf)
+	lock := dba.NewLockOnMemory(ps, conf)
+	pool := dba.NewReceiverPoolOnMemory(conf)
+	evidences := dba.NewEvidencePoolOnMemory(conf)
+	bc := dba.NewBlockChainOnMemory()
+	slv := convertor.NewStatelessValidator(conf)
+	selector := usecase.NewLeaderSelector(conf, ps, bc)
+	sfv := convertor.NewStatefulValidator(conf, bc, ps, selector)
+	cv := convertor.NewCommitCertificateValidator(conf, ps)
+	ev := convertor.NewEvidenceValidator(conf, ps)
+	sender := &transport{s, id}
+	syncer := usecase.NewBlockSyncUsecase(conf, bc, ps, slv, sfv, cv, &blockSyncTransport{s})
+	detector := usecase.NewEvidenceDetector(conf, factory)
+	bus := usecase.NewEventBusOnMemory(conf)
+	recvChan := usecase.NewReceiveChannel(conf)
+	stepChan := usecase.NewReceiveChannel(conf)
+
+	genesisBlock, err := factory.NewBlock(0, nil, 0, nil, nil, nil)
+	if err != nil {
+		panic("Simulator genesis: " + err.Error())
+	}
+	bc.Commit(genesisBlock, nil)
+
+	node := &simNode{
+		id:           id,
+		address:      peers[id].GetAddress(),
+		bc:           bc,
+		syncReceiver: usecase.NewBlockSyncReceiverUsecase(conf, bc),
+		recvChan:     recvChan,
+		stepChan:     stepChan,
+	}
+	if conf.ConsensusEngine == usecase.ConsensusEngineHotStuff {
+		node.receiver = usecase.NewHotStuffReceiverUsecase(conf, queue, ps, selector, pool, evidences, bc, slv, ev, sender, recvChan)
+		node.step = usecase.NewHotStuffUsecase(conf, bc, ps, selector, queue, sender, slv, sfv, cv, factory, syncer, dba.NewWALOnMemory(), clock, stepChan)
+	} else {
+		node.receiver = usecase.NewConsensusReceiverUsecase(conf, queue, ps, selector, lock, pool, evidences, bc, slv, ev, sender, syncer, detector, bus, recvChan)
+		// 配送の遅れは分布が変わらないので、Ping の loop を回さずに最初に1度だけ RTT を測る
+		monitor := usecase.NewLatencyMonitorUsecase(conf, ps, sender, factory)
+		for i, peer := range peers {
+			if i == id {
+				continue
+			}
+			if rtt, err := sender.Ping(peer); err == nil {
+				monitor.ObserveRoundTrip(peer, rtt)
+			}
+		}
+		node.step = usecase.NewConsensusStepUsecase(conf, bc, ps, selector, lock, queue, evidences, sender, slv, sfv, ev, factory, syncer,
+			dba.NewWALOnMemory(), bus, monitor, clock, stepChan)
+	}
+	return node
+}
+
+func (s *Simulator) findNode(address string) (*simNode, bool) {
+	for _, node := range s.nodes {
+		if node.address == address {
+			return node, true
+		}
+	}
+	return nil, false
+}
+
+// broadcast は from から全ての Node へのメッセージを配送 event にする
+func (s *Simulator) broadcast(from int, send func(receiver usecase.ConsensusReceiver) error) {
+	for _, node := range s.nodes {
+		s.send(from, node, send)
+	}
+}
+
+// send は from から target へのメッセージを配送 event にする
+// 呼ばれるのは動いている唯一の Node か Simulator 自身なので、乱数を引く順序は決定的である
+func (s *Simulator) send(from int, target *simNode, send func(receiver usecase.ConsensusReceiver) error) {
+	now := s.scheduler.Now()
+	if time.Duration(now-simulationStart) < s.sim.GST && s.rand.Float64() < s.sim.DropRate {
+		s.result.Dropped++
+		return
+	}
+	delay := s.sim.MinDelay
+	if s.sim.MaxDelay > s.sim.MinDelay {
+		delay += time.Duration(s.rand.Int63n(int64(s.sim.MaxDelay - s.sim.MinDelay)))
+	}
+	s.scheduler.schedule(now+int64(delay), nil, func() {
+		s.result.Delivered++
+		send(target.receiver)
+		s.forward(target)
+	})
+}
+
+// forward は receiver が受け取ったメッセージを1つずつ Node に渡し、Node が処理し終わるまで待つ
+func (s *Simulator) forward(node *simNode) {
+	for {
+		s.scheduler.setBusy(node.id)
+		select {
+		case proposal := <-node.recvChan.Propose:
+			node.stepChan.Propose <- proposal
+		case vote := <-node.recvChan.Vote:
+			node.stepChan.Vote <- vote
+		case preCommit := <-node.recvChan.PreCommit:
+			node.stepChan.PreCommit <- preCommit
+		default:
+			s.scheduler.setIdle(node.id)
+			return
+		}
+		s.scheduler.waitIdle()
+	}
+}
+
+// Run は全ての Node が sim.Heights まで Commit するか、不変条件が破れるまで Simulation を進める
+func (s *Simulator) Run() (*Result, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := new(sync.WaitGroup)
+	for _, node := range s.nodes {
+		wg.Add(1)
+		go func(node *simNode) {
+			defer wg.Done()
+			node.step.Run(ctx)
+		}(node)
+	}
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	progress, lowest := simulationStart+int64(s.sim.GST), int64(0)
+	for {
+		s.scheduler.waitIdle()
+		now := s.scheduler.Now()
+		s.result.Elapsed = time.Duration(now - simulationStart)
+		if err := s.checkAgreement(); err != nil {
+			return s.result, err
+		}
+		if s.reached() {
+			return s.result, nil
+		}
+		if h := s.lowest(); h > lowest {
+			lowest = h
+			if now > progress {
+				progress = now
+			}
+		}
+		e, ok := s.scheduler.next()
+		if !ok {
+			return s.result, ErrSimulatorDeadlock
+		}
+		if e.at > progress+int64(s.sim.Timeout) {
+			return s.result, errors.Wrapf(ErrSimulatorLiveness, "heights: %v", s.heights())
+		}
+		if e.timer != nil {
+			s.scheduler.fire(e.timer)
+		} else {
+			e.deliver()
+		}
+	}
+}
+
+// checkAgreement は各 Node の新しく Commit された Block が、他の Node が同じ height に Commit した Block と同じか確認する
+func (s *Simulator) checkAgreement() error {
+	for _, node := range s.nodes {
+		top, ok := node.bc.Top()
+		if !ok {
+			continue
+		}
+		for height := node.checked + 1; height <= top.GetHeader().GetHeight(); height++ {
+			block, ok := node.bc.GetBlock(height)
+			if !ok {
+				break
+			}
+			hash := model.MustGetHash(block)
+			if height <= int64(len(s.result.Hashes)) {
+				if expected := s.result.Hashes[height-1]; !bytes.Equal(expected, hash) {
+					return errors.Wrapf(ErrSimulatorAgreement, "height: %d, node%d: %x, others: %x", height, node.id, hash, expected)
+				}
+			} else {
+				s.result.Hashes = append(s.result.Hashes, hash)
+			}
+			node.checked = height
+		}
+	}
+	return nil
+}
+
+func (s *Simulator) reached() bool {
+	for _, node := range s.nodes {
+		if node.checked < s.sim.Heights {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Simulator) lowest() int64 {
+	ret := s.nodes[0].checked
+	for _, node := range s.nodes {
+		if node.checked < ret {
+			ret = node.checked
+		}
+	}
+	return ret
+}
+
+func (s *Simulator) heights() []int64 {
+	ret := make([]int64, len(s.nodes))
+	for i, node := range s.nodes {
+		ret[i] = node.checked
+	}
+	return ret
+}
@@ -0,0 +1,148 @@
+package simulator_test
+
+import (
+	"github.com/pkg/errors"
+	. "github.com/satellitex/bbft/simulator"
+	. "github.com/satellitex/bbft/test_utils"
+	"github.com/satellitex/bbft/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"log"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(ioutil.Discard)
+	os.Exit(m.Run())
+}
+
+func testSimulatorConfig(seed int64) Config {
+	return Config{
+		Seed:     seed,
+		Nodes:    4,
+		Heights:  20,
+		GST:      10 * time.Second,
+		DropRate: 0.3,
+		MinDelay: 10 * time.Millisecond,
+		MaxDelay: 200 * time.Millisecond,
+		Timeout:  time.Minute,
+	}
+}
+
+func TestSimulator_Run(t *testing.T) {
+	for _, seed := range []int64{1, 2, 3} {
+		result, err := NewSimulator(GetTestConfig(), testSimulatorConfig(seed)).Run()
+		require.NoError(t, err, "seed: %d", seed)
+		assert.True(t, len(result.Hashes) >= 20)
+		assert.True(t, result.Dropped > 0)
+	}
+}
+
+func TestSimulator_ClockSkew(t *testing.T) {
+	conf := GetTestConfig()
+	sim := testSimulatorConfig(1)
+	sim.ClockSkew = 300 * time.Millisecond
+	result, err := NewSimulator(conf, sim).Run()
+	require.NoError(t, err)
+	assert.True(t, len(result.Hashes) >= 20)
+}
+
+func TestSimulator_VotingPower(t *testing.T) {
+	sim := testSimulatorConfig(1)
+	sim.Powers = []int64{5, 1, 1, 1}
+	result, err := NewSimulator(GetTestConfig(), sim).Run()
+	require.NoError(t, err)
+	assert.True(t, len(result.Hashes) >= 20)
+}
+
+func TestSimulator_Deterministic(t *testing.T) {
+	expected, err := NewSimulator(GetTestConfig(), testSimulatorConfig(42)).Run()
+	require.NoError(t, err)
+	actual, err := NewSimulator(GetTestConfig(), testSimulatorConfig(42)).Run()
+	require.NoError(t, err)
+	assert.Equal(t, expected, actual)
+}
+
+func TestSimulator_Liveness(t *testing.T) {
+	sim := testSimulatorConfig(1)
+	sim.DropRate = 1
+	sim.GST = time.Hour
+	sim.Timeout = time.Minute
+	_, err := NewSimulator(GetTestConfig(), sim).Run()
+	assert.EqualError(t, errors.Cause(err), ErrSimulatorLiveness.Error())
+}
+
+func TestSimulator_EmptyBlocks(t *testing.T) {
+	sim := testSimulatorConfig(1)
+	sim.DropRate = 0
+
+	t.Run("idle chain waits interval for each empty block", func(t *testing.T) {
+		conf := GetTestConfig()
+		conf.CreateEmptyBlocks = false
+		conf.EmptyBlockInterval = 10 * time.Second
+		result, err := NewSimulator(conf, sim).Run()
+		require.NoError(t, err)
+		assert.True(t, len(result.Hashes) >= 20)
+		assert.True(t, result.Elapsed >= 20*conf.EmptyBlockInterval, "elapsed: %v", result.Elapsed)
+	})
+
+	t.Run("target block time", func(t *testing.T) {
+		conf := GetTestConfig()
+		conf.TargetBlockTime = 10 * time.Second
+		result, err := NewSimulator(conf, sim).Run()
+		require.NoError(t, err)
+		assert.True(t, len(result.Hashes) >= 20)
+		assert.True(t, result.Elapsed >= 19*conf.TargetBlockTime, "elapsed: %v", result.Elapsed)
+	})
+}
+
+func TestSimulator_AdaptiveTimeout(t *testing.T) {
+	sim := testSimulatorConfig(1)
+	sim.DropRate = 0
+
+	static, err := NewSimulator(GetTestConfig(), sim).Run()
+	require.NoError(t, err)
+
+	conf := GetTestConfig()
+	conf.AdaptiveTimeout = true
+	adaptive, err := NewSimulator(conf, sim).Run()
+	require.NoError(t, err)
+	assert.True(t, len(adaptive.Hashes) >= 20)
+	assert.True(t, adaptive.Elapsed < static.Elapsed, "adaptive: %v, static: %v", adaptive.Elapsed, static.Elapsed)
+
+	again, err := NewSimulator(conf, sim).Run()
+	require.NoError(t, err)
+	assert.Equal(t, adaptive, again)
+}
+
+func TestSimulator_HotStuff(t *testing.T) {
+	conf := GetTestConfig()
+	conf.ConsensusEngine = usecase.ConsensusEngineHotStuff
+	for _, seed := range []int64{1, 2, 3} {
+		result, err := NewSimulator(conf, testSimulatorConfig(seed)).Run()
+		require.NoError(t, err, "seed: %d", seed)
+		assert.True(t, len(result.Hashes) >= 20)
+		assert.True(t, result.Dropped > 0)
+	}
+}
+
+func TestSimulator_HotStuffMessages(t *testing.T) {
+	sim := testSimulatorConfig(1)
+	sim.DropRate = 0
+	sim.Nodes = 7
+
+	pbft, err := NewSimulator(GetTestConfig(), sim).Run()
+	require.NoError(t, err)
+
+	conf := GetTestConfig()
+	conf.ConsensusEngine = usecase.ConsensusEngineHotStuff
+	hotstuff, err := NewSimulator(conf, sim).Run()
+	require.NoError(t, err)
+
+	// 投票をリーダーだけに送るので、Block あたりのメッセージは pbft より少ない
+	assert.True(t, hotstuff.Delivered/len(hotstuff.Hashes) < pbft.Delivered/len(pbft.Hashes),
+		"hotstuff: %d / %d, pbft: %d / %d", hotstuff.Delivered, len(hotstuff.Hashes), pbft.Delivered, len(pbft.Hashes))
+}
@@ -23,6 +23,17 @@ func RandomValidTx(t *testing.T) model.Transaction {
 	return tx
 }
 
+func RandomValidTxWithCost(t *testing.T, cost int64) model.Transaction {
+	validPub, validPriv := convertor.NewKeyPair()
+	tx, err := convertor.NewTxModelBuilder().
+		Message(RandomStr()).
+		Cost(cost).
+		Sign(validPub, validPriv).
+		Build()
+	require.NoError(t, err)
+	return tx
+}
+
 func RandomInvalidTx(t *testing.T) model.Transaction {
 	tx, err := convertor.NewTxModelBuilder().
 		Message(RandomStr()).
@@ -69,13 +80,13 @@ func GetHash(t *testing.T, hasher model.Hasher) []byte {
 }
 
 func RandomValidBlock(t *testing.T) model.Block {
-	block, err := convertor.NewModelFactory().NewBlock(rand.Int63(), RandomByte(), rand.Int63(), RandomValidTxs(t))
+	block, err := convertor.NewModelFactory().NewBlock(rand.Int63(), RandomByte(), rand.Int63(), RandomValidTxs(t), nil, nil)
 	require.NoError(t, err)
 	return block
 }
 
 func RandomInvalidBlock(t *testing.T) model.Block {
-	block, err := convertor.NewModelFactory().NewBlock(rand.Int63(), RandomByte(), rand.Int63(), RandomInvalidTxs(t))
+	block, err := convertor.NewModelFactory().NewBlock(rand.Int63(), RandomByte(), rand.Int63(), RandomInvalidTxs(t), nil, nil)
 	require.NoError(t, err)
 	return block
 }
@@ -11,17 +11,9 @@ import (
 )
 
 func RandomCommitableBlock(t *testing.T, bc dba.BlockChain) model.Block {
-	if pre, ok := bc.Top(); ok {
-		block, err := convertor.NewModelFactory().NewBlock(
-			pre.GetHeader().GetHeight()+1,
-			GetHash(t, pre),
-			pre.GetHeader().GetCreatedTime()+10,
-			RandomValidTxs(t),
-		)
-		require.NoError(t, err)
+	if _, ok := bc.Top(); ok {
 		validPub, validPri := convertor.NewKeyPair()
-		block.Sign(validPub, validPri)
-		return block
+		return commitableBlock(t, bc, nil, validPub, validPri)
 	}
 	block := RandomValidBlock(t)
 	block.(*convertor.Block).Header.Height = 0
@@ -29,6 +21,44 @@ func RandomCommitableBlock(t *testing.T, bc dba.BlockChain) model.Block {
 	return block
 }
 
+// RandomCommitableBlockFromPeer は peer が ps の Peer の集合で作った bc の Top の次の Block を返す
+func RandomCommitableBlockFromPeer(t *testing.T, bc dba.BlockChain, ps dba.PeerService, peer model.Peer) model.Block {
+	return commitableBlock(t, bc, ps, peer.GetPubkey(), peer.(*PeerWithPriv).PrivKey)
+}
+
+func commitableBlock(t *testing.T, bc dba.BlockChain, ps dba.PeerService, pub []byte, pri []byte) model.Block {
+	pre, ok := bc.Top()
+	require.True(t, ok)
+	block, err := convertor.NewModelFactory().NewBlock(
+		pre.GetHeader().GetHeight()+1,
+		GetHash(t, pre),
+		pre.GetHeader().GetCreatedTime()+10,
+		RandomValidTxs(t),
+		nil,
+		CommitableChainState(t, bc, ps, pub),
+	)
+	require.NoError(t, err)
+	require.NoError(t, block.Sign(pub, pri))
+	return block
+}
+
+// CommitableChainState は proposer が ps の Peer の集合で bc の Top の次の Block を作るときの ChainState を返す
+// round は proposer がリーダーになる最初の round とする
+// ps が nil のときは Peer の集合を空とする
+func CommitableChainState(t *testing.T, bc dba.BlockChain, ps dba.PeerService, proposer []byte) model.ChainState {
+	top, ok := bc.Top()
+	require.True(t, ok)
+	height := top.GetHeader().GetHeight()
+	var validators []model.Peer
+	var round int32
+	if ps != nil {
+		validators = ps.AtHeight(height + 1).GetPeers()
+		round = LeaderRound(t, bc, ps, height+1, proposer)
+	}
+	lastCommit, _ := bc.GetCommitCertificate(height)
+	return convertor.NewModelFactory().NewChainState(proposer, round, top.GetHeader().GetNextStateRoot(), validators, lastCommit)
+}
+
 func RandomProposal(t *testing.T) model.Proposal {
 	proposal, err := convertor.NewModelFactory().NewProposal(ValidSignedBlock(t), rand.Int31())
 	require.NoError(t, err)
@@ -42,7 +72,7 @@ func RandomInvalidProposal(t *testing.T) model.Proposal {
 }
 
 func RandomInvalidProposalWithRound(t *testing.T, height int64, round int32) model.Proposal {
-	block, err := convertor.NewModelFactory().NewBlock(height, RandomByte(), rand.Int63(), RandomInvalidTxs(t))
+	block, err := convertor.NewModelFactory().NewBlock(height, RandomByte(), rand.Int63(), RandomInvalidTxs(t), nil, nil)
 	require.NoError(t, err)
 	ValidSign(t, block)
 	proposal, err := convertor.NewModelFactory().NewProposal(block, round)
@@ -50,29 +80,99 @@ func RandomInvalidProposalWithRound(t *testing.T, height int64, round int32) mod
 	return proposal
 }
 
+func NewTestVoteMessage(voteType model.VoteType, height int64, round int32, hash []byte) model.VoteMessage {
+	return convertor.NewModelFactory().NewVoteMessage(GetTestConfig().ChainId, height, round, voteType, hash)
+}
+
 func RandomVoteMessage(t *testing.T) model.VoteMessage {
-	vote := convertor.NewModelFactory().NewVoteMessage(RandomByte())
+	vote := NewTestVoteMessage(model.PreVote, 0, 0, RandomByte())
+	ValidSign(t, vote)
+	return vote
+}
+
+func RandomPreCommit(t *testing.T) model.VoteMessage {
+	vote := NewTestVoteMessage(model.PreCommit, 0, 0, RandomByte())
 	ValidSign(t, vote)
 	return vote
 }
 
 func RandomUnSignedVoteMessage(t *testing.T) model.VoteMessage {
-	vote := convertor.NewModelFactory().NewVoteMessage(RandomByte())
+	vote := NewTestVoteMessage(model.PreVote, 0, 0, RandomByte())
 	return vote
 }
 
 func RandomVoteMessageFromPeer(t *testing.T, peer model.Peer) model.VoteMessage {
-	vote := convertor.NewModelFactory().NewVoteMessage(RandomByte())
+	vote := NewTestVoteMessage(model.PreVote, 0, 0, RandomByte())
 	vote.Sign(peer.GetPubkey(), peer.(*PeerWithPriv).PrivKey)
 	return vote
 }
 
-func RandomVoteMessageFromPeerWithBlock(t *testing.T, peer model.Peer, block model.Block) model.VoteMessage {
-	vote := convertor.NewModelFactory().NewVoteMessage(GetHash(t, block))
+func RandomPreCommitFromPeer(t *testing.T, peer model.Peer) model.VoteMessage {
+	vote := NewTestVoteMessage(model.PreCommit, 0, 0, RandomByte())
+	vote.Sign(peer.GetPubkey(), peer.(*PeerWithPriv).PrivKey)
+	return vote
+}
+
+func VoteMessageFromPeerWithBlockRound(t *testing.T, voteType model.VoteType, peer model.Peer, block model.Block, round int32) model.VoteMessage {
+	vote := NewTestVoteMessage(voteType, block.GetHeader().GetHeight(), round, GetHash(t, block))
 	vote.Sign(peer.GetPubkey(), peer.(*PeerWithPriv).PrivKey)
 	return vote
 }
 
+func RandomVoteMessageFromPeerWithBlock(t *testing.T, peer model.Peer, block model.Block) model.VoteMessage {
+	return VoteMessageFromPeerWithBlockRound(t, model.PreVote, peer, block, 0)
+}
+
+func RandomPreCommitFromPeerWithBlock(t *testing.T, peer model.Peer, block model.Block) model.VoteMessage {
+	return VoteMessageFromPeerWithBlockRound(t, model.PreCommit, peer, block, 0)
+}
+
+func RandomCommitCertificate(t *testing.T, block model.Block, peers []model.Peer) model.CommitCertificate {
+	return CommitCertificateWithRound(t, block, 0, peers)
+}
+
+// CommitCertificateWithRound は peers が block に round で PreCommit した CommitCertificate を返す
+func CommitCertificateWithRound(t *testing.T, block model.Block, round int32, peers []model.Peer) model.CommitCertificate {
+	preCommits := make([]model.VoteMessage, 0, len(peers))
+	for _, peer := range peers {
+		preCommits = append(preCommits, VoteMessageFromPeerWithBlockRound(t, model.PreCommit, peer, block, round))
+	}
+	cert, err := convertor.NewModelFactory().NewCommitCertificate(block.GetHeader().GetHeight(), round, GetHash(t, block), preCommits)
+	require.NoError(t, err)
+	return cert
+}
+
+// DuplicateProposalEvidence は offender が height, round で署名した異なる2つの Proposal の reporter が署名した Evidence を返す
+func DuplicateProposalEvidence(t *testing.T, offender model.Peer, reporter model.Peer, height int64, round int32) model.Evidence {
+	evidence, err := convertor.NewModelFactory().NewEvidence(model.DuplicateProposal,
+		[]model.Proposal{
+			RandomProposalWithPeer(t, height, round, offender),
+			RandomProposalWithPeer(t, height, round, offender),
+		}, nil)
+	require.NoError(t, err)
+	require.NoError(t, evidence.Sign(reporter.GetPubkey(), reporter.(*PeerWithPriv).PrivKey))
+	return evidence
+}
+
+// DuplicatePreCommitEvidence は offender が height, round で署名した異なる blockHash の2つの PreCommit の reporter が署名した Evidence を返す
+func DuplicatePreCommitEvidence(t *testing.T, offender model.Peer, reporter model.Peer, height int64, round int32) model.Evidence {
+	votes := []model.VoteMessage{
+		NewTestVoteMessage(model.PreCommit, height, round, RandomByte()),
+		NewTestVoteMessage(model.PreCommit, height, round, RandomByte()),
+	}
+	for _, vote := range votes {
+		require.NoError(t, vote.Sign(offender.GetPubkey(), offender.(*PeerWithPriv).PrivKey))
+	}
+	evidence, err := convertor.NewModelFactory().NewEvidence(model.DuplicatePreCommit, nil, votes)
+	require.NoError(t, err)
+	require.NoError(t, evidence.Sign(reporter.GetPubkey(), reporter.(*PeerWithPriv).PrivKey))
+	return evidence
+}
+
+func RandomEvidence(t *testing.T) model.Evidence {
+	return DuplicatePreCommitEvidence(t, RandomPeerWithPriv(), RandomPeerWithPriv(), rand.Int63(), rand.Int31())
+}
+
 func RandomPeerService(t *testing.T, n int) dba.PeerService {
 	ps := dba.NewPeerServiceOnMemory()
 	for i := 0; i < n; i++ {
@@ -81,6 +181,18 @@ func RandomPeerService(t *testing.T, n int) dba.PeerService {
 	return ps
 }
 
+func ValidatorUpdateTx(t *testing.T, update model.ValidatorUpdate, signers []model.Peer) model.Transaction {
+	builder := convertor.NewTxModelBuilder().
+		Message(RandomStr()).
+		ValidatorUpdate(update)
+	for _, signer := range signers {
+		builder.Sign(signer.GetPubkey(), signer.(*PeerWithPriv).PrivKey)
+	}
+	tx, err := builder.Build()
+	require.NoError(t, err)
+	return tx
+}
+
 type PeerWithPriv struct {
 	*convertor.Peer
 	PrivKey []byte
@@ -89,14 +201,20 @@ type PeerWithPriv struct {
 func RandomPeerWithPriv() model.Peer {
 	validPub, validPri := convertor.NewKeyPair()
 	return &PeerWithPriv{
-		&convertor.Peer{RandomStr(), validPub},
+		&convertor.Peer{RandomStr(), validPub, 1},
 		validPri,
 	}
 }
 
+func RandomPeerWithPower(power int64) model.Peer {
+	peer := RandomPeerWithPriv()
+	peer.(*PeerWithPriv).Power = power
+	return peer
+}
+
 func RandomPeerFromConf(conf *config.BBFTConfig) model.Peer {
 	return &PeerWithPriv{
-		&convertor.Peer{conf.Host + ":" + conf.Port, conf.PublicKey},
+		&convertor.Peer{conf.Host + ":" + conf.Port, conf.PublicKey, 1},
 		conf.SecretKey,
 	}
 }
@@ -1,8 +1,11 @@
 package test_utils
 
 import (
+	"bytes"
 	"github.com/satellitex/bbft/convertor"
+	"github.com/satellitex/bbft/dba"
 	"github.com/satellitex/bbft/model"
+	"github.com/satellitex/bbft/usecase"
 	"github.com/stretchr/testify/require"
 	"math/rand"
 	"testing"
@@ -10,23 +13,49 @@ import (
 )
 
 func RandomProposalWithHeightRound(t *testing.T, height int64, round int32) model.Proposal {
-	block, err := convertor.NewModelFactory().NewBlock(height, RandomByte(), rand.Int63(), RandomValidTxs(t))
+	return RandomProposalWithPeer(t, height, round, RandomPeerWithPriv())
+}
+
+// RandomProposalWithPeer は peer が height, round のリーダーとして作って署名した Block の Proposal を返す
+func RandomProposalWithPeer(t *testing.T, height int64, round int32, peer model.Peer) model.Proposal {
+	state := convertor.NewModelFactory().NewChainState(peer.GetPubkey(), round, nil, nil, nil)
+	block, err := convertor.NewModelFactory().NewBlock(height, RandomByte(), rand.Int63(), RandomValidTxs(t), nil, state)
 	require.NoError(t, err)
-	ValidSign(t, block)
+	block.Sign(peer.(*PeerWithPriv).Pubkey, peer.(*PeerWithPriv).PrivKey)
 	proposal, err := convertor.NewModelFactory().NewProposal(block, round)
 	require.NoError(t, err)
 	return proposal
 }
 
-func RandomProposalWithPeer(t *testing.T, height int64, round int32, peer model.Peer) model.Proposal {
-	block, err := convertor.NewModelFactory().NewBlock(height, RandomByte(), rand.Int63(), RandomValidTxs(t))
+// HotStuffProposal は leader が ps の Peer の集合で parent の子を view で提案した、justify を持つ Proposal を返す
+func HotStuffProposal(t *testing.T, ps dba.PeerService, parent model.Block, view int32, justify model.CommitCertificate, leader model.Peer) model.Proposal {
+	createdTime := time.Now().UnixNano()
+	if createdTime <= parent.GetHeader().GetCreatedTime() {
+		createdTime = parent.GetHeader().GetCreatedTime() + 1
+	}
+	height := parent.GetHeader().GetHeight() + 1
+	state := convertor.NewModelFactory().NewChainState(leader.GetPubkey(), view, parent.GetHeader().GetNextStateRoot(),
+		ps.AtHeight(height).GetPeers(), justify)
+	block, err := convertor.NewModelFactory().NewBlock(height, GetHash(t, parent), createdTime, RandomValidTxs(t), nil, state)
 	require.NoError(t, err)
-	block.Sign(peer.(*PeerWithPriv).Pubkey, peer.(*PeerWithPriv).PrivKey)
-	proposal, err := convertor.NewModelFactory().NewProposal(block, round)
+	require.NoError(t, block.Sign(leader.GetPubkey(), leader.(*PeerWithPriv).PrivKey))
+	proposal, err := convertor.NewModelFactory().NewJustifiedProposal(block, view, justify)
 	require.NoError(t, err)
 	return proposal
 }
 
+// LeaderRound は GetTestConfig の LeaderSelector で proposer が height のリーダーになる最初の round を返す
+// proposer がリーダーになる round が見つからないときは 0 を返す
+func LeaderRound(t *testing.T, bc dba.BlockChain, ps dba.PeerService, height int64, proposer []byte) int32 {
+	selector := usecase.NewLeaderSelector(GetTestConfig(), ps, bc)
+	for round := int32(0); round < 1000; round++ {
+		if leader, ok := selector.GetLeader(height, round); ok && bytes.Equal(leader.GetPubkey(), proposer) {
+			return round
+		}
+	}
+	return 0
+}
+
 func TimeParseDuration(t *testing.T, s string) time.Duration {
 	d, err := time.ParseDuration(s)
 	require.NoError(t, err)
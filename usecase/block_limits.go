@@ -0,0 +1,47 @@
+package usecase
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/satellitex/bbft/config"
+	"github.com/satellitex/bbft/dba"
+	"github.com/satellitex/bbft/model"
+)
+
+// collectTxs は Leader が Block に入れる Transaction を queue の先頭から取り出す
+// base は Transaction を入れる前の署名済み Block で、MaxBlockBytes と MaxBlockCost を超えない所まで詰める
+// 入りきらない Transaction は次の Block のために queue に残し、空の Block にも入らない Transaction と skip が true を返す Transaction は捨てる
+func collectTxs(conf *config.BBFTConfig, queue dba.ProposalTxQueue, base model.Block, skip func(tx model.Transaction) bool) []model.Transaction {
+	maxBytes := conf.MaxBlockBytes - base.GetSize()
+	restBytes, restCost := maxBytes, conf.MaxBlockCost
+
+	txs := make([]model.Transaction, 0, conf.NumberOfBlockHasTransactions)
+	for len(txs) < conf.NumberOfBlockHasTransactions {
+		tx, ok := queue.Peek()
+		if !ok { // ProposalTx is empty
+			break
+		}
+		if skip(tx) {
+			queue.Pop()
+			continue
+		}
+		size, cost := encodedTxSize(tx), tx.GetPayload().GetCost()
+		if size > maxBytes || (conf.MaxBlockCost > 0 && cost > conf.MaxBlockCost) {
+			queue.Pop()
+			continue // Never fit in a Block
+		}
+		if size > restBytes || (conf.MaxBlockCost > 0 && cost > restCost) {
+			break // Left for the next Block
+		}
+		queue.Pop()
+		restBytes -= size
+		restCost -= cost
+		txs = append(txs, tx)
+	}
+	return txs
+}
+
+// encodedTxSize は Block の Transactions に tx を 1つ足したときに増える大きさ (tag, length, 本体) を返す
+func encodedTxSize(tx model.Transaction) int {
+	size := tx.GetSize()
+	return 1 + proto.SizeVarint(uint64(size)) + size
+}
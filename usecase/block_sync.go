@@ -0,0 +1,271 @@
+package usecase
+
+import (
+	"bytes"
+	"github.com/pkg/errors"
+	"github.com/satellitex/bbft/config"
+	"github.com/satellitex/bbft/dba"
+	"github.com/satellitex/bbft/model"
+	"go.uber.org/multierr"
+	"log"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrBlockSyncInvalidRange = errors.New("Failed Invalid Block Sync Range")
+	ErrBlockSyncInvalidBlock = errors.New("Failed Invalid Synced Block")
+	ErrBlockSync             = errors.New("Failed Block Sync")
+)
+
+type BlockSyncReceiver interface {
+	GetBlocks(from int64, to int64) ([]model.Block, []model.CommitCertificate, error)
+}
+
+type BlockSyncReceiverUsecase struct {
+	conf *config.BBFTConfig
+	bc   dba.BlockChain
+}
+
+func NewBlockSyncReceiverUsecase(conf *config.BBFTConfig, bc dba.BlockChain) BlockSyncReceiver {
+	return &BlockSyncReceiverUsecase{
+		conf: conf,
+		bc:   bc,
+	}
+}
+
+// GetBlocks は [from, to] の Commit 済みの Block とその CommitCertificate を返す。一度に返すのは BlockSyncBatchSize 個まで。
+// CommitCertificate を持たない Block (genesis block) の CommitCertificate は nil になる。
+func (b *BlockSyncReceiverUsecase) GetBlocks(from int64, to int64) ([]model.Block, []model.CommitCertificate, error) {
+	if from < 0 || from > to { // InvalidArgument (code = 3)
+		return nil, nil, errors.Wrapf(ErrBlockSyncInvalidRange, "from: %d, to: %d", from, to)
+	}
+	if limit := from + int64(b.conf.BlockSyncBatchSize) - 1; to > limit {
+		to = limit
+	}
+	blocks := make([]model.Block, 0, to-from+1)
+	certs := make([]model.CommitCertificate, 0, to-from+1)
+	for height := from; height <= to; height++ {
+		block, ok := b.bc.GetBlock(height)
+		if !ok {
+			break
+		}
+		cert, _ := b.bc.GetCommitCertificate(height)
+		blocks = append(blocks, block)
+		certs = append(certs, cert)
+	}
+	return blocks, certs, nil
+}
+
+// BlockSync は自分が他の Peer より遅れていることを検知し、足りない Block を取得して Commit する
+type BlockSync interface {
+	// pubkey の Peer が署名した Height の Proposal, Vote, PreCommit を受け取ったことを記録する
+	// 1つの Peer の署名では遅れていると判断せず、Height の Peer の voting power の 2/3 以上が揃ったときにその Height を観測したとみなす
+	// Height の Peer でない pubkey と、観測済みまたは Commit 済みの Height の署名は記録しない
+	Observe(height int64, pubkey []byte)
+	// cert の署名が正しいときだけ、cert の Height の次の Height を観測したとみなす
+	// 観測する Height は cert から決めるので、cert を送った Peer が好きな Height を観測させることはできない
+	ObserveCertificate(cert model.CommitCertificate)
+	// WAL に残した自分の記録から、以前 height まで進んでいたことを観測したとみなす
+	Resume(height int64)
+	// 記録した Height に対して自分の BlockChain が遅れているかどうか
+	IsBehind() bool
+	// 足りない Block を他の Peer から取得し、検証して Commit する
+	Sync() error
+}
+
+type BlockSyncUsecase struct {
+	conf   *config.BBFTConfig
+	bc     dba.BlockChain
+	ps     dba.PeerService
+	slv    model.StatelessValidator
+	sfv    model.StatefulValidator
+	cv     model.CommitCertificateValidator
+	sender model.BlockSyncSender
+
+	observedHeight int64
+	signedHeights  map[string]int64
+	mutex          *sync.Mutex
+}
+
+func NewBlockSyncUsecase(conf *config.BBFTConfig, bc dba.BlockChain, ps dba.PeerService,
+	slv model.StatelessValidator, sfv model.StatefulValidator, cv model.CommitCertificateValidator,
+	sender model.BlockSyncSender) BlockSync {
+	return &BlockSyncUsecase{
+		conf:          conf,
+		bc:            bc,
+		ps:            ps,
+		slv:           slv,
+		sfv:           sfv,
+		cv:            cv,
+		sender:        sender,
+		signedHeights: make(map[string]int64),
+		mutex:         new(sync.Mutex),
+	}
+}
+
+// Observe は Peer ごとに署名した最も高い Height だけを覚え、観測済みまたは Commit 済みの Height の記録は消す
+// Peer でない鍵の署名は記録しないので、記録は Peer の数までしか増えない
+func (b *BlockSyncUsecase) Observe(height int64, pubkey []byte) {
+	if _, ok := b.ps.AtHeight(height).GetPeer(pubkey); !ok {
+		return
+	}
+	top := b.topHeight()
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if height <= b.observedHeight || height <= top {
+		return
+	}
+	if signed, ok := b.signedHeights[string(pubkey)]; ok && signed >= height {
+		return
+	}
+	b.signedHeights[string(pubkey)] = height
+	defer b.prune(top)
+
+	// 高い Height から順に、その Height 以上に署名した Peer の voting power が足りているかを確かめる
+	heights := make([]int64, 0, len(b.signedHeights))
+	for _, signed := range b.signedHeights {
+		if signed > b.observedHeight {
+			heights = append(heights, signed)
+		}
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] > heights[j] })
+	for _, candidate := range heights {
+		signers := make([][]byte, 0, len(b.signedHeights))
+		for key, signed := range b.signedHeights {
+			if signed >= candidate {
+				signers = append(signers, []byte(key))
+			}
+		}
+		peers := b.ps.AtHeight(candidate)
+		if peers.GetPower(signers) >= peers.GetRequiredAcceptPower() {
+			b.observedHeight = candidate
+			return
+		}
+	}
+}
+
+func (b *BlockSyncUsecase) ObserveCertificate(cert model.CommitCertificate) {
+	if err := b.cv.ValidateSignatures(cert); err != nil {
+		log.Println("BlockSync : ignore invalid certificate,", err)
+		return
+	}
+	b.Resume(cert.GetHeight() + 1)
+}
+
+func (b *BlockSyncUsecase) Resume(height int64) {
+	top := b.topHeight()
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if height > b.observedHeight {
+		b.observedHeight = height
+	}
+	b.prune(top)
+}
+
+// prune は観測済みまたは top まで Commit 済みの Height 以下の署名の記録を消す。mutex を取ってから呼ぶ
+func (b *BlockSyncUsecase) prune(top int64) {
+	for key, signed := range b.signedHeights {
+		if signed <= b.observedHeight || signed <= top {
+			delete(b.signedHeights, key)
+		}
+	}
+}
+
+func (b *BlockSyncUsecase) topHeight() int64 {
+	top, ok := b.bc.Top()
+	if !ok {
+		return -1
+	}
+	return top.GetHeader().GetHeight()
+}
+
+// Height H の Proposal が来ているならば、H-1 までの Block は既に Commit されている
+func (b *BlockSyncUsecase) targetHeight() int64 {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.observedHeight - 1
+}
+
+func (b *BlockSyncUsecase) IsBehind() bool {
+	return b.topHeight() < b.targetHeight()
+}
+
+func (b *BlockSyncUsecase) Sync() error {
+	for b.IsBehind() {
+		from, to := b.topHeight()+1, b.targetHeight()
+		if limit := from + int64(b.conf.BlockSyncBatchSize) - 1; to > limit {
+			to = limit
+		}
+		log.Printf("BlockSync : height %d -> %d\n", from, to)
+		if err := b.syncFromAnyPeer(from, to); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *BlockSyncUsecase) syncFromAnyPeer(from int64, to int64) error {
+	var result error
+	for _, peer := range b.ps.GetPeers() {
+		if bytes.Equal(peer.GetPubkey(), b.conf.PublicKey) {
+			continue
+		}
+		blocks, certs, err := b.sender.GetBlocks(peer, from, to)
+		if err != nil {
+			result = multierr.Append(result, errors.Wrapf(model.ErrBlockSyncSenderGetBlocks, err.Error()))
+			continue
+		}
+		if len(blocks) != len(certs) {
+			result = multierr.Append(result, errors.Wrapf(ErrBlockSyncInvalidBlock,
+				"number of blocks: %d, number of certificates: %d", len(blocks), len(certs)))
+			continue
+		}
+		committed, err := b.commitBlocks(from, blocks, certs)
+		if err != nil {
+			result = multierr.Append(result, err)
+		}
+		if committed > 0 {
+			return nil
+		}
+	}
+	if result == nil {
+		return errors.Wrapf(ErrBlockSync, "no peer has blocks, height: [%d, %d]", from, to)
+	}
+	return errors.Wrapf(ErrBlockSync, result.Error())
+}
+
+func (b *BlockSyncUsecase) commitBlocks(from int64, blocks []model.Block, certs []model.CommitCertificate) (int, error) {
+	for id, block := range blocks {
+		if err := b.verify(from+int64(id), block, certs[id]); err != nil {
+			return id, errors.Wrapf(ErrBlockSyncInvalidBlock, err.Error())
+		}
+		b.bc.Commit(block, certs[id])
+		if err := commitValidatorUpdates(b.conf, b.ps, block); err != nil {
+			log.Println(err)
+		}
+	}
+	return len(blocks), nil
+}
+
+func (b *BlockSyncUsecase) verify(height int64, block model.Block, cert model.CommitCertificate) error {
+	if block == nil {
+		return errors.Wrapf(model.ErrInvalidBlock, "block is nil")
+	}
+	if h := block.GetHeader().GetHeight(); h != height {
+		return errors.Wrapf(model.ErrInvalidBlock, "height: %d, expected %d", h, height)
+	}
+	if err := b.slv.BlockValidate(block); err != nil {
+		return errors.Wrapf(model.ErrStatelessBlockValidate, err.Error())
+	}
+	if _, ok := b.ps.AtHeight(height).GetPeer(block.GetSignature().GetPubkey()); !ok {
+		return errors.Wrapf(model.ErrInvalidBlock, "block signer is not peer: %x", block.GetSignature().GetPubkey())
+	}
+	if err := b.cv.Validate(block, cert); err != nil {
+		return errors.Wrapf(model.ErrCommitCertificateValidate, err.Error())
+	}
+	if err := b.sfv.Validate(block); err != nil {
+		return errors.Wrapf(model.ErrStatefulValidate, err.Error())
+	}
+	return nil
+}
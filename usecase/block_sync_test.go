@@ -0,0 +1,287 @@
+package usecase_test
+
+import (
+	"github.com/pkg/errors"
+	"github.com/satellitex/bbft/config"
+	"github.com/satellitex/bbft/convertor"
+	"github.com/satellitex/bbft/dba"
+	"github.com/satellitex/bbft/model"
+	. "github.com/satellitex/bbft/test_utils"
+	. "github.com/satellitex/bbft/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestBlockSyncReceiverUsecase_GetBlocks(t *testing.T) {
+	conf := GetTestConfig()
+	conf.BlockSyncBatchSize = 5
+	ps := RandomPeerService(t, 4)
+	bc := dba.NewBlockChainOnMemory()
+	bc.Commit(RandomCommitableBlock(t, bc), nil)
+	for i := 1; i < 10; i++ {
+		block := RandomCommitableBlock(t, bc)
+		bc.Commit(block, RandomCommitCertificate(t, block, ps.GetPeers()))
+	}
+	receiver := NewBlockSyncReceiverUsecase(conf, bc)
+
+	for _, c := range []struct {
+		name     string
+		from     int64
+		to       int64
+		expected []int64
+		err      error
+	}{
+		{"success case", 2, 4, []int64{2, 3, 4}, nil},
+		{"success case, only one block", 7, 7, []int64{7}, nil},
+		{"success case, over batch size", 0, 9, []int64{0, 1, 2, 3, 4}, nil},
+		{"success case, over top height", 8, 12, []int64{8, 9}, nil},
+		{"success case, not exist blocks", 10, 12, []int64{}, nil},
+		{"failed case, from > to", 4, 2, nil, ErrBlockSyncInvalidRange},
+		{"failed case, from < 0", -1, 2, nil, ErrBlockSyncInvalidRange},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			blocks, certs, err := receiver.GetBlocks(c.from, c.to)
+			if c.err != nil {
+				assert.EqualError(t, errors.Cause(err), c.err.Error())
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, len(c.expected), len(blocks))
+			require.Equal(t, len(c.expected), len(certs))
+			for id, height := range c.expected {
+				expectedBlock, ok := bc.GetBlock(height)
+				require.True(t, ok)
+				assert.Equal(t, expectedBlock, blocks[id])
+				expectedCert, _ := bc.GetCommitCertificate(height)
+				assert.Equal(t, expectedCert, certs[id])
+			}
+		})
+	}
+}
+
+func NewTestBlockSyncUsecase(t *testing.T, conf *config.BBFTConfig, src dba.BlockChain) (dba.PeerService, dba.BlockChain, BlockSync) {
+	ps := dba.NewPeerServiceOnMemory()
+	ps.AddPeer(RandomPeerFromConf(conf))
+	for i := 0; i < 3; i++ {
+		ps.AddPeer(RandomPeerWithPriv())
+	}
+
+	// same genesis block
+	bc := dba.NewBlockChainOnMemory()
+	genesis, ok := src.GetBlock(0)
+	require.True(t, ok)
+	bc.Commit(genesis, nil)
+
+	slv := convertor.NewStatelessValidator(conf)
+	sfv := convertor.NewStatefulValidator(conf, bc, ps, NewLeaderSelector(conf, ps, bc))
+	cv := convertor.NewCommitCertificateValidator(conf, ps)
+	return ps, bc, NewBlockSyncUsecase(conf, bc, ps, slv, sfv, cv, convertor.NewMockBlockSyncSender(src))
+}
+
+func commitWithCertificate(t *testing.T, bc dba.BlockChain, block model.Block, ps dba.PeerService) {
+	bc.Commit(block, RandomCommitCertificate(t, block, ps.GetPeers()))
+}
+
+func TestBlockSyncUsecase_Observe(t *testing.T) {
+	conf := GetTestConfig()
+	src := dba.NewBlockChainOnMemory()
+	src.Commit(RandomCommitableBlock(t, src), nil)
+
+	t.Run("behind only when 2/3 voting power signed higher height", func(t *testing.T) {
+		ps, _, syncer := NewTestBlockSyncUsecase(t, conf, src)
+		peers := ps.GetPeers()
+
+		syncer.Observe(5, peers[1].GetPubkey())
+		syncer.Observe(9, peers[1].GetPubkey())
+		assert.False(t, syncer.IsBehind())
+		syncer.Observe(9, RandomPeer().GetPubkey())
+		assert.False(t, syncer.IsBehind())
+		syncer.Observe(5, peers[2].GetPubkey())
+		assert.False(t, syncer.IsBehind())
+
+		// peers[1]: 9, peers[2]: 5, peers[3]: 4 なので Height 4 まで揃う
+		syncer.Observe(4, peers[3].GetPubkey())
+		assert.True(t, syncer.IsBehind())
+	})
+
+	t.Run("ignore signers that are not peers", func(t *testing.T) {
+		ps, _, syncer := NewTestBlockSyncUsecase(t, conf, src)
+		peers := ps.GetPeers()
+
+		for i := 0; i < 100; i++ {
+			syncer.Observe(5, RandomPeer().GetPubkey())
+		}
+		syncer.Observe(5, peers[1].GetPubkey())
+		syncer.Observe(5, peers[2].GetPubkey())
+		assert.False(t, syncer.IsBehind())
+		syncer.Observe(5, peers[3].GetPubkey())
+		assert.True(t, syncer.IsBehind())
+	})
+
+	t.Run("behind only when certificate is valid", func(t *testing.T) {
+		ps, bc, syncer := NewTestBlockSyncUsecase(t, conf, src)
+		peers := ps.GetPeers()
+		block := RandomCommitableBlockFromPeer(t, src, ps, peers[0])
+
+		syncer.ObserveCertificate(nil)
+		syncer.ObserveCertificate(RandomCommitCertificate(t, block, peers[:2]))
+		assert.False(t, syncer.IsBehind())
+		cert := RandomCommitCertificate(t, block, peers[:3])
+		syncer.ObserveCertificate(cert)
+		assert.True(t, syncer.IsBehind())
+
+		// 観測するのは cert の Height までなので、その Block を Commit すると遅れていない
+		bc.Commit(block, cert)
+		assert.False(t, syncer.IsBehind())
+	})
+}
+
+func TestBlockSyncUsecase_Sync(t *testing.T) {
+	conf := GetTestConfig()
+	conf.BlockSyncBatchSize = 3
+
+	src := dba.NewBlockChainOnMemory()
+	src.Commit(RandomCommitableBlock(t, src), nil)
+	ps, bc, syncer := NewTestBlockSyncUsecase(t, conf, src)
+
+	peers := ps.GetPeers()
+	for i := 0; i < 10; i++ {
+		commitWithCertificate(t, src, RandomCommitableBlockFromPeer(t, src, ps, peers[i%len(peers)]), ps)
+	}
+
+	t.Run("not behind, no observe", func(t *testing.T) {
+		assert.False(t, syncer.IsBehind())
+		assert.NoError(t, syncer.Sync())
+	})
+
+	t.Run("not behind, observe next height", func(t *testing.T) {
+		syncer.Resume(1)
+		assert.False(t, syncer.IsBehind())
+	})
+
+	t.Run("success sync, observe higher height", func(t *testing.T) {
+		syncer.Resume(11)
+		require.True(t, syncer.IsBehind())
+
+		assert.NoError(t, syncer.Sync())
+		assert.False(t, syncer.IsBehind())
+
+		expectedTop, ok := src.Top()
+		require.True(t, ok)
+		top, ok := bc.Top()
+		require.True(t, ok)
+		assert.Equal(t, expectedTop, top)
+	})
+
+	t.Run("failed sync, no peer has blocks", func(t *testing.T) {
+		syncer.Resume(13)
+		require.True(t, syncer.IsBehind())
+
+		assert.EqualError(t, errors.Cause(syncer.Sync()), ErrBlockSync.Error())
+		assert.True(t, syncer.IsBehind())
+	})
+}
+
+func TestBlockSyncUsecase_Sync_InvalidBlock(t *testing.T) {
+	conf := GetTestConfig()
+
+	src := dba.NewBlockChainOnMemory()
+	src.Commit(RandomCommitableBlock(t, src), nil)
+	ps, bc, syncer := NewTestBlockSyncUsecase(t, conf, src)
+
+	// signed by not peer
+	commitWithCertificate(t, src, RandomCommitableBlock(t, src), ps)
+	commitWithCertificate(t, src, RandomCommitableBlockFromPeer(t, src, ps, ps.GetPeers()[0]), ps)
+
+	syncer.Resume(3)
+	require.True(t, syncer.IsBehind())
+
+	assert.EqualError(t, errors.Cause(syncer.Sync()), ErrBlockSync.Error())
+
+	top, ok := bc.Top()
+	require.True(t, ok)
+	assert.Equal(t, int64(0), top.GetHeader().GetHeight())
+	_, ok = bc.GetBlock(1)
+	assert.False(t, ok)
+}
+
+func TestBlockSyncUsecase_Sync_InvalidCommitCertificate(t *testing.T) {
+	conf := GetTestConfig()
+
+	src := dba.NewBlockChainOnMemory()
+	src.Commit(RandomCommitableBlock(t, src), nil)
+	ps, bc, syncer := NewTestBlockSyncUsecase(t, conf, src)
+	peers := ps.GetPeers()
+
+	// height 1 : valid certificate
+	commitWithCertificate(t, src, RandomCommitableBlockFromPeer(t, src, ps, peers[0]), ps)
+	// height 2 : not enough preCommits
+	block := RandomCommitableBlockFromPeer(t, src, ps, peers[0])
+	src.Commit(block, RandomCommitCertificate(t, block, peers[:ps.GetRequiredAcceptPower()-1]))
+	// height 3 : preCommits for other block
+	block = RandomCommitableBlockFromPeer(t, src, ps, peers[0])
+	src.Commit(block, RandomCommitCertificate(t, RandomCommitableBlock(t, src), peers))
+
+	syncer.Resume(4)
+	require.True(t, syncer.IsBehind())
+
+	assert.EqualError(t, errors.Cause(syncer.Sync()), ErrBlockSync.Error())
+
+	top, ok := bc.Top()
+	require.True(t, ok)
+	assert.Equal(t, int64(1), top.GetHeader().GetHeight())
+	cert, ok := bc.GetCommitCertificate(1)
+	require.True(t, ok)
+	expectedCert, ok := src.GetCommitCertificate(1)
+	require.True(t, ok)
+	assert.Equal(t, expectedCert, cert)
+}
+
+func TestBlockSyncUsecase_Sync_ValidatorUpdate(t *testing.T) {
+	conf := GetTestConfig()
+
+	src := dba.NewBlockChainOnMemory()
+	src.Commit(RandomCommitableBlock(t, src), nil)
+	ps, _, syncer := NewTestBlockSyncUsecase(t, conf, src)
+	peers := ps.GetPeers()
+	added := RandomPeerWithPriv()
+
+	// height 1 : add validator, activated at height 1 + ValidatorUpdateDelay
+	update := convertor.NewModelFactory().NewValidatorUpdate(model.AddValidator, added.GetAddress(), added.GetPubkey(), nil, 1)
+	top, ok := src.Top()
+	require.True(t, ok)
+	block, err := convertor.NewModelFactory().NewBlock(1, GetHash(t, top), top.GetHeader().GetCreatedTime()+10,
+		append(RandomValidTxs(t), ValidatorUpdateTx(t, update, peers[:ps.GetRequiredAcceptPower()])), nil,
+		CommitableChainState(t, src, ps, peers[0].GetPubkey()))
+	require.NoError(t, err)
+	require.NoError(t, block.Sign(peers[0].GetPubkey(), peers[0].(*PeerWithPriv).PrivKey))
+	commitWithCertificate(t, src, block, ps)
+	// height 2 : old validators
+	commitWithCertificate(t, src, RandomCommitableBlockFromPeer(t, src, ps, peers[1]), ps)
+	// height 3 : proposed and committed by new validators
+	after := append([]model.Peer{added}, peers[1:]...)
+	validators := append([]model.Peer{added}, peers...)
+	activated := dba.NewPeerServiceOnMemory()
+	for _, peer := range validators {
+		activated.AddPeer(peer)
+	}
+	top, ok = src.Top()
+	require.True(t, ok)
+	lastCommit, ok := src.GetCommitCertificate(2)
+	require.True(t, ok)
+	block, err = convertor.NewModelFactory().NewBlock(3, GetHash(t, top), top.GetHeader().GetCreatedTime()+10, RandomValidTxs(t), nil,
+		convertor.NewModelFactory().NewChainState(added.GetPubkey(), LeaderRound(t, src, activated, 3, added.GetPubkey()), top.GetHeader().GetNextStateRoot(), validators, lastCommit))
+	require.NoError(t, err)
+	require.NoError(t, block.Sign(added.GetPubkey(), added.(*PeerWithPriv).PrivKey))
+	src.Commit(block, RandomCommitCertificate(t, block, after))
+
+	syncer.Resume(4)
+	require.True(t, syncer.IsBehind())
+	require.NoError(t, syncer.Sync())
+
+	assert.Equal(t, 4, ps.AtHeight(2).Size())
+	assert.Equal(t, 5, ps.AtHeight(1+conf.ValidatorUpdateDelay).Size())
+	_, ok = ps.GetPeer(added.GetPubkey())
+	assert.True(t, ok)
+}
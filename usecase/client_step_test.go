@@ -11,7 +11,7 @@ import (
 )
 
 func TestClientGateReceiverUsecase_Gate(t *testing.T) {
-	validator := convertor.NewStatelessValidator()
+	validator := convertor.NewStatelessValidator(GetTestConfig())
 	sender := convertor.NewMockConsensusSender()
 
 	gate := NewClientGateReceiverUsecase(validator, sender)
@@ -0,0 +1,44 @@
+package usecase
+
+import "time"
+
+// Clock は ConsensusStep が使う時刻とタイマーを提供する。Simulator では仮想時刻に置き換える
+type Clock interface {
+	// Now は UnixNano を返す
+	Now() int64
+	// NewTimer は d 経過後に発火する Timer を返す
+	NewTimer(d time.Duration) Timer
+}
+
+type Timer interface {
+	// C は発火を待つ channel を返す。ConsensusStep は select で待つ直前に毎回呼ぶ
+	C() <-chan time.Time
+	Stop() bool
+}
+
+type realClock struct{}
+
+// NewRealClock は time パッケージを使う Clock を返す
+func NewRealClock() Clock {
+	return &realClock{}
+}
+
+func (realClock) Now() int64 {
+	return Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return &realTimer{time.NewTimer(d)}
+}
+
+type realTimer struct {
+	timer *time.Timer
+}
+
+func (t *realTimer) C() <-chan time.Time {
+	return t.timer.C
+}
+
+func (t *realTimer) Stop() bool {
+	return t.timer.Stop()
+}
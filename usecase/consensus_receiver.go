@@ -7,6 +7,7 @@ import (
 	"github.com/satellitex/bbft/dba"
 	"github.com/satellitex/bbft/model"
 	"go.uber.org/multierr"
+	"log"
 )
 
 var (
@@ -14,6 +15,7 @@ var (
 	ErrVoteNotInPeerService      = errors.New("Failed vote's pubkey doesn't exist in peerService")
 	ErrPreCommitNotInPeerService = errors.New("Failed preCommit's pubkey doesn't exist in peerService")
 	ErrVerifyOnlyLeader          = errors.New("Failed not verified leader")
+	ErrFutureHeightProposal      = errors.New("Failed proposal's height is ahead of own blockchain")
 )
 
 type ReceiveChannel struct {
@@ -30,33 +32,76 @@ func NewReceiveChannel(conf *config.BBFTConfig) *ReceiveChannel {
 	}
 }
 
+// DrainUntil は done が閉じるまで、届いたメッセージを捨て続ける
+// Consensus の loop が止まった後も Receiver が Channel に送って止まらないようにする
+func (c *ReceiveChannel) DrainUntil(done <-chan struct{}) {
+	for {
+		select {
+		case <-c.Propose:
+		case <-c.Vote:
+		case <-c.PreCommit:
+		case <-done:
+			c.Drain()
+			return
+		}
+	}
+}
+
+// Drain は Channel に残っている受け取り済みのメッセージを全て捨てる
+func (c *ReceiveChannel) Drain() {
+	for {
+		select {
+		case <-c.Propose:
+		case <-c.Vote:
+		case <-c.PreCommit:
+		default:
+			return
+		}
+	}
+}
+
 type ConsensusReceiver interface {
 	Propagate(tx model.Transaction) error
 	Propose(proposal model.Proposal) error
 	Vote(vote model.VoteMessage) error
 	PreCommit(preCommit model.VoteMessage) error
+	Evidence(evidence model.Evidence) error
 }
 
 type ConsensusReceieverUsecase struct {
+	conf        *config.BBFTConfig
 	queue       dba.ProposalTxQueue
 	ps          dba.PeerService
+	selector    LeaderSelector
 	lock        dba.Lock
 	pool        dba.ReceiverPool
+	evidences   dba.EvidencePool
 	bc          dba.BlockChain
 	slv         model.StatelessValidator
+	ev          model.EvidenceValidator
 	sender      model.ConsensusSender
+	syncer      BlockSync
+	detector    *EvidenceDetector
+	bus         EventBus
 	ReceiveChan *ReceiveChannel
 }
 
-func NewConsensusReceiverUsecase(queue dba.ProposalTxQueue, ps dba.PeerService, lock dba.Lock, pool dba.ReceiverPool, bc dba.BlockChain, slv model.StatelessValidator, sender model.ConsensusSender, channel *ReceiveChannel) ConsensusReceiver {
+func NewConsensusReceiverUsecase(conf *config.BBFTConfig, queue dba.ProposalTxQueue, ps dba.PeerService, selector LeaderSelector, lock dba.Lock, pool dba.ReceiverPool, evidences dba.EvidencePool, bc dba.BlockChain, slv model.StatelessValidator, ev model.EvidenceValidator, sender model.ConsensusSender, syncer BlockSync, detector *EvidenceDetector, bus EventBus, channel *ReceiveChannel) ConsensusReceiver {
 	return &ConsensusReceieverUsecase{
+		conf:        conf,
 		queue:       queue,
 		ps:          ps,
+		selector:    selector,
 		lock:        lock,
 		pool:        pool,
+		evidences:   evidences,
 		bc:          bc,
 		slv:         slv,
+		ev:          ev,
 		sender:      sender,
+		syncer:      syncer,
+		detector:    detector,
+		bus:         bus,
 		ReceiveChan: channel,
 	}
 }
@@ -98,16 +143,65 @@ func (c *ConsensusReceieverUsecase) Propagate(tx model.Transaction) error {
 	return result
 }
 
+func (c *ConsensusReceieverUsecase) topHeight() int64 {
+	top, ok := c.bc.Top()
+	if !ok {
+		return -1
+	}
+	return top.GetHeader().GetHeight()
+}
+
 func (c *ConsensusReceieverUsecase) verifyOnlyLeader(proposal model.Proposal) error {
-	height := proposal.GetBlock().GetHeader().GetHeight()
-	round := proposal.GetRound()
-	peers := c.ps.GetPermutationPeers(height)
-	if 0 <= round && round < int32(len(peers)) {
-		if bytes.Equal(peers[round].GetPubkey(), proposal.GetBlock().GetSignature().GetPubkey()) {
-			return nil
+	header := proposal.GetBlock().GetHeader()
+	if !bytes.Equal(header.GetProposer(), proposal.GetBlock().GetSignature().GetPubkey()) {
+		return errors.New("proposer is not signer")
+	}
+	if header.GetRound() > proposal.GetRound() {
+		return errors.Errorf("block round: %d, is after proposal round: %d", header.GetRound(), proposal.GetRound())
+	}
+	// Lock した Block は後の Round で作ったリーダーの署名のまま提案し直されるので、proposal の round のリーダーが Proposal に署名し、
+	// Block が Lock された Round を polRound で示す。署名が無いと、どの Peer でも古い Block を後の Round の Proposal として送れてしまう
+	if header.GetRound() < proposal.GetRound() {
+		if polRound := proposal.GetPOLRound(); polRound < header.GetRound() || polRound >= proposal.GetRound() {
+			return errors.Errorf("polRound: %d, is not in [%d, %d)", polRound, header.GetRound(), proposal.GetRound())
+		}
+		if err := proposal.Verify(); err != nil {
+			return errors.Wrapf(model.ErrProposalVerify, err.Error())
+		}
+		if !c.isLeader(header.GetHeight(), proposal.GetRound(), proposal.GetSignature().GetPubkey()) {
+			return errors.New("re-proposal is not signed by leader of proposal round")
 		}
 	}
-	return errors.New("not leader peer's signed")
+	if !c.isLeader(header.GetHeight(), header.GetRound(), header.GetProposer()) {
+		return errors.New("not leader peer's signed")
+	}
+	return nil
+}
+
+func (c *ConsensusReceieverUsecase) isLeader(height int64, round int32, pubkey []byte) bool {
+	leader, ok := c.selector.GetLeader(height, round)
+	return ok && bytes.Equal(leader.GetPubkey(), pubkey)
+}
+
+// VoteMessage が自分の Chain の voteType の投票であることを確かめる
+func (c *ConsensusReceieverUsecase) verifyVoteMessage(vote model.VoteMessage, voteType model.VoteType) error {
+	if vote.GetType() != voteType {
+		return errors.Errorf("type: %d, expected %d", vote.GetType(), voteType)
+	}
+	if vote.GetChainId() != c.conf.ChainId {
+		return errors.Errorf("chainId: %s, expected %s", vote.GetChainId(), c.conf.ChainId)
+	}
+	if vote.IsReject() && voteType != model.PreVote {
+		return errors.Errorf("reject is allowed only PreVote")
+	}
+	if !vote.IsReject() && len(vote.GetBlockHash()) == 0 {
+		return errors.Errorf("blockHash is empty, but not reject")
+	}
+	// rejectMessage を変えると同じ投票を別のものとして何度も送れるので、Reject でない投票は rejectMessage を持たない
+	if !vote.IsReject() && vote.GetRejectMessage() != "" {
+		return errors.Errorf("rejectMessage is not empty, but not reject")
+	}
+	return nil
 }
 
 func (c *ConsensusReceieverUsecase) Propose(proposal model.Proposal) error {
@@ -117,12 +211,26 @@ func (c *ConsensusReceieverUsecase) Propose(proposal model.Proposal) error {
 	if err := c.slv.BlockValidate(proposal.GetBlock()); err != nil { // InvalidArgument (code = 3)
 		return errors.Wrapf(model.ErrStatelessBlockValidate, err.Error())
 	}
+	// 先の Height の Proposal はリーダーを決める Block をまだ持っていないので、検証せずに BlockSync へ渡す
+	// Proposal の lastCommit は height-1 の Block が Commit された根拠になる
+	if height, top := proposal.GetBlock().GetHeader().GetHeight(), c.topHeight(); height > top+1 { // FailedPrecondition (code = 9)
+		c.syncer.Observe(height, proposal.GetBlock().GetSignature().GetPubkey())
+		c.syncer.ObserveCertificate(proposal.GetBlock().GetLastCommit())
+		return errors.Wrapf(ErrFutureHeightProposal, "height: %d, top: %d", height, top)
+	}
 	if err := c.verifyOnlyLeader(proposal); err != nil { // InvalidArgument (code = 3)
 		return errors.Wrapf(ErrVerifyOnlyLeader, err.Error())
 	}
 	if c.pool.IsExistPropose(proposal) { // AlreadyExist (code = 6)
 		return errors.Wrapf(ErrAlradyReceivedSameObject, "proposal: %#v", proposal)
 	}
+	if evidence, err := c.detector.CheckProposal(proposal); err != nil {
+		return err
+	} else if evidence != nil { // InvalidArgument (code = 3)
+		c.reportEvidence(evidence)
+		return errors.Wrapf(ErrDetectEquivocation, "leader signed two proposals, height: %d, round: %d",
+			evidence.GetHeight(), evidence.GetRound())
+	}
 
 	// After parallel
 	errs := make(chan error)
@@ -150,6 +258,10 @@ func (c *ConsensusReceieverUsecase) Propose(proposal model.Proposal) error {
 	result = multierr.Append(result, <-errs)
 	result = multierr.Append(result, <-errs)
 	result = multierr.Append(result, <-errs)
+	if result == nil {
+		c.bus.Publish(&Event{Type: EventProposal, Height: proposal.GetBlock().GetHeader().GetHeight(), Round: proposal.GetRound(),
+			Hash: model.MustGetHash(proposal.GetBlock()), Block: proposal.GetBlock()})
+	}
 	c.ReceiveChan.Propose <- proposal
 	return result
 }
@@ -158,12 +270,16 @@ func (c *ConsensusReceieverUsecase) Vote(vote model.VoteMessage) error {
 	if vote == nil { // InvalidArgument (code = 3)
 		return errors.Wrapf(model.ErrInvalidVoteMessage, "vote is nil")
 	}
+	if err := c.verifyVoteMessage(vote, model.PreVote); err != nil { // InvalidArgument (code = 3)
+		return errors.Wrapf(model.ErrInvalidVoteMessage, err.Error())
+	}
 	if err := vote.Verify(); err != nil { // InvalidArgument (code = 3)
 		return errors.Wrapf(model.ErrVoteMessageVerify, err.Error())
 	}
-	if _, ok := c.ps.GetPeer(vote.GetSignature().GetPubkey()); !ok { // InvalidArgument (code = 3)
+	if _, ok := c.ps.AtHeight(vote.GetHeight()).GetPeer(vote.GetSignature().GetPubkey()); !ok { // InvalidArgument (code = 3)
 		return errors.Wrapf(ErrVoteNotInPeerService, "pubkey: %x", vote.GetSignature().GetPubkey())
 	}
+	c.syncer.Observe(vote.GetHeight(), vote.GetSignature().GetPubkey())
 	if c.pool.IsExistVote(vote) { // AlreadyExist (code = 6)
 		return errors.Wrapf(ErrAlradyReceivedSameObject, "vote: %#v", vote)
 	}
@@ -202,15 +318,26 @@ func (c *ConsensusReceieverUsecase) PreCommit(preCommit model.VoteMessage) error
 	if preCommit == nil { // InvalidArgument (code = 3)
 		return errors.Wrapf(model.ErrInvalidVoteMessage, "preCommit is nil")
 	}
+	if err := c.verifyVoteMessage(preCommit, model.PreCommit); err != nil { // InvalidArgument (code = 3)
+		return errors.Wrapf(model.ErrInvalidVoteMessage, err.Error())
+	}
 	if err := preCommit.Verify(); err != nil { // InvalidArgument (code = 3)
 		return errors.Wrapf(model.ErrVoteMessageVerify, err.Error())
 	}
-	if _, ok := c.ps.GetPeer(preCommit.GetSignature().GetPubkey()); !ok { // InvalidArgument (code = 3)
+	if _, ok := c.ps.AtHeight(preCommit.GetHeight()).GetPeer(preCommit.GetSignature().GetPubkey()); !ok { // InvalidArgument (code = 3)
 		return errors.Wrapf(ErrPreCommitNotInPeerService, "pubkey: %x", preCommit.GetSignature().GetPubkey())
 	}
+	c.syncer.Observe(preCommit.GetHeight(), preCommit.GetSignature().GetPubkey())
 	if c.pool.IsExistPreCommit(preCommit) { // AlreadyExist (code = 6)
 		return errors.Wrapf(ErrAlradyReceivedSameObject, "preCommit: %#v", preCommit)
 	}
+	if evidence, err := c.detector.CheckPreCommit(preCommit); err != nil {
+		return err
+	} else if evidence != nil { // InvalidArgument (code = 3)
+		c.reportEvidence(evidence)
+		return errors.Wrapf(ErrDetectEquivocation, "peer signed two preCommits, height: %d, round: %d",
+			evidence.GetHeight(), evidence.GetRound())
+	}
 
 	// after parallel
 	errs := make(chan error)
@@ -234,3 +361,49 @@ func (c *ConsensusReceieverUsecase) PreCommit(preCommit model.VoteMessage) error
 	return result
 
 }
+
+func (c *ConsensusReceieverUsecase) Evidence(evidence model.Evidence) error {
+	if evidence == nil { // InvalidArgument (code = 3)
+		return errors.Wrapf(model.ErrInvalidEvidence, "evidence is nil")
+	}
+	if err := c.ev.Validate(evidence); err != nil { // InvalidArgument (code = 3)
+		return errors.Wrapf(model.ErrEvidenceValidate, err.Error())
+	}
+	if c.evidences.IsExist(evidence) { // AlreadyExist (code = 6)
+		return errors.Wrapf(ErrAlradyReceivedSameObject, "evidence: %#v", evidence)
+	}
+	if height, ok := c.bc.FindEvidenceHeight(evidence); ok { // AlreadyExist (code = 6)
+		return errors.Wrapf(ErrAlradyReceivedSameObject, "evidence is already committed in %d-th Block", height)
+	}
+
+	// after parallel
+	errs := make(chan error)
+	go func() {
+		if err := c.evidences.Add(evidence); err != nil {
+			errs <- errors.Wrapf(dba.ErrEvidencePoolAdd, err.Error())
+		} else {
+			errs <- nil
+		}
+	}()
+	go func() {
+		if err := c.sender.Evidence(evidence); err != nil {
+			//log.Println(model.ErrConsensusSenderEvidence, err)
+		}
+		errs <- nil
+	}()
+	var result error
+	result = multierr.Append(result, <-errs)
+	result = multierr.Append(result, <-errs)
+	return result
+}
+
+// reportEvidence は自分で見つけた evidence を EvidencePool に追加し、自分以外の Peer に送信する
+func (c *ConsensusReceieverUsecase) reportEvidence(evidence model.Evidence) {
+	if err := c.evidences.Add(evidence); err != nil {
+		log.Println(dba.ErrEvidencePoolAdd, err)
+		return
+	}
+	if err := c.sender.Evidence(evidence); err != nil {
+		//log.Println(model.ErrConsensusSenderEvidence, err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/satellitex/bbft/convertor"
 	"github.com/satellitex/bbft/dba"
 	"github.com/satellitex/bbft/model"
+	"github.com/satellitex/bbft/proto"
 	. "github.com/satellitex/bbft/test_utils"
 	. "github.com/satellitex/bbft/usecase"
 	"github.com/stretchr/testify/assert"
@@ -13,21 +14,27 @@ import (
 	"testing"
 )
 
-func NewTestConsensusReceiverUsecase() (dba.ProposalTxQueue, dba.PeerService, dba.Lock, dba.BlockChain, model.ConsensusSender, *ReceiveChannel, ConsensusReceiver) {
+func NewTestConsensusReceiverUsecase() (dba.ProposalTxQueue, dba.PeerService, dba.Lock, dba.EvidencePool, dba.BlockChain, model.ConsensusSender, *ReceiveChannel, EventBus, ConsensusReceiver) {
 	testConfig := GetTestConfig()
 	queue := dba.NewProposalTxQueueOnMemory(testConfig)
 	ps := dba.NewPeerServiceOnMemory()
 	lock := dba.NewLockOnMemory(ps, testConfig)
 	pool := dba.NewReceiverPoolOnMemory(testConfig)
 	bc := dba.NewBlockChainOnMemory()
-	slv := convertor.NewStatelessValidator()
+	slv := convertor.NewStatelessValidator(testConfig)
 	sender := convertor.NewMockConsensusSender()
+	syncer := NewBlockSyncUsecase(testConfig, bc, ps, slv, convertor.NewStatefulValidator(testConfig, bc, ps, NewLeaderSelector(testConfig, ps, bc)), convertor.NewCommitCertificateValidator(testConfig, ps), convertor.NewMockBlockSyncSender(bc))
 	receivChan := NewReceiveChannel(testConfig)
-	return queue, ps, lock, bc, sender, receivChan, NewConsensusReceiverUsecase(queue, ps, lock, pool, bc, slv, sender, receivChan)
+	evidences := dba.NewEvidencePoolOnMemory(testConfig)
+	ev := convertor.NewEvidenceValidator(testConfig, ps)
+	detector := NewEvidenceDetector(testConfig, convertor.NewModelFactory())
+	bus := NewEventBusOnMemory(testConfig)
+	return queue, ps, lock, evidences, bc, sender, receivChan, bus,
+		NewConsensusReceiverUsecase(testConfig, queue, ps, NewLeaderSelector(testConfig, ps, bc), lock, pool, evidences, bc, slv, ev, sender, syncer, detector, bus, receivChan)
 }
 
 func TestConsensusReceieverUsecase_Propagate(t *testing.T) {
-	queue, _, _, _, sender, _, receiver := NewTestConsensusReceiverUsecase()
+	queue, _, _, _, _, sender, _, _, receiver := NewTestConsensusReceiverUsecase()
 	t.Run("success case", func(t *testing.T) {
 		tx := RandomValidTx(t)
 		err := receiver.Propagate(tx)
@@ -85,17 +92,24 @@ func TestConsensusReceieverUsecase_Propagate(t *testing.T) {
 }
 
 func TestConsensusReceieverUsecase_Propose(t *testing.T) {
-	_, ps, _, _, sender, channel, receiver := NewTestConsensusReceiverUsecase()
+	_, ps, _, evidences, _, sender, channel, bus, receiver := NewTestConsensusReceiverUsecase()
 
 	peer := RandomPeerWithPriv()
 	ps.AddPeer(peer)
 
 	t.Run("success case", func(t *testing.T) {
+		sub, err := bus.Subscribe(&EventFilter{Types: []EventType{EventProposal}})
+		require.NoError(t, err)
+		defer bus.Unsubscribe(sub)
+
 		proposal := RandomProposalWithPeer(t, 0, 0, peer)
-		err := receiver.Propose(proposal)
+		err = receiver.Propose(proposal)
 		require.NoError(t, err)
 		assert.Equal(t, proposal, sender.(*convertor.MockConsensusSender).Proposal)
 		assert.Equal(t, proposal, <-channel.Propose)
+
+		event := <-sub.Events()
+		assert.Equal(t, &Event{Type: EventProposal, Height: 0, Round: 0, Hash: GetHash(t, proposal.GetBlock()), Block: proposal.GetBlock()}, event)
 	})
 
 	t.Run("failed case input nil", func(t *testing.T) {
@@ -111,31 +125,118 @@ func TestConsensusReceieverUsecase_Propose(t *testing.T) {
 		assert.EqualError(t, errors.Cause(err), model.ErrStatelessBlockValidate.Error())
 	})
 
+	t.Run("success case, round over number of peers", func(t *testing.T) {
+		proposal := RandomProposalWithPeer(t, 0, int32(ps.Size())*3+1, peer)
+		err := receiver.Propose(proposal)
+		require.NoError(t, err)
+		assert.Equal(t, proposal, <-channel.Propose)
+	})
+
 	t.Run("failed case not leader signed", func(t *testing.T) {
 		proposal := RandomProposalWithHeightRound(t, 0, 0)
 		err := receiver.Propose(proposal)
 		assert.EqualError(t, errors.Cause(err), ErrVerifyOnlyLeader.Error())
 	})
 
+	t.Run("success case, block of earlier round is re-proposed", func(t *testing.T) {
+		block := RandomProposalWithPeer(t, 0, 1000, peer).GetBlock()
+		proposal, err := convertor.NewModelFactory().NewReProposal(block, 1001, 1000)
+		require.NoError(t, err)
+		require.NoError(t, proposal.Sign(peer.GetPubkey(), peer.(*PeerWithPriv).PrivKey))
+		require.NoError(t, receiver.Propose(proposal))
+		assert.Equal(t, proposal, <-channel.Propose)
+	})
+
+	t.Run("failed case block of earlier round is relabeled without leader's signature", func(t *testing.T) {
+		block := RandomProposalWithPeer(t, 0, 1010, peer).GetBlock()
+		proposal, err := convertor.NewModelFactory().NewProposal(block, 1011)
+		require.NoError(t, err)
+		err = receiver.Propose(proposal)
+		assert.EqualError(t, errors.Cause(err), ErrVerifyOnlyLeader.Error())
+	})
+
+	t.Run("failed case re-proposal signed by not leader", func(t *testing.T) {
+		other := RandomPeerWithPriv()
+		block := RandomProposalWithPeer(t, 0, 1012, peer).GetBlock()
+		proposal, err := convertor.NewModelFactory().NewReProposal(block, 1013, 1012)
+		require.NoError(t, err)
+		require.NoError(t, proposal.Sign(other.GetPubkey(), other.(*PeerWithPriv).PrivKey))
+		err = receiver.Propose(proposal)
+		assert.EqualError(t, errors.Cause(err), ErrVerifyOnlyLeader.Error())
+	})
+
+	t.Run("failed case re-proposal polRound is not in block round and proposal round", func(t *testing.T) {
+		block := RandomProposalWithPeer(t, 0, 1014, peer).GetBlock()
+		for _, polRound := range []int32{1013, 1016} {
+			proposal, err := convertor.NewModelFactory().NewReProposal(block, 1016, polRound)
+			require.NoError(t, err)
+			require.NoError(t, proposal.Sign(peer.GetPubkey(), peer.(*PeerWithPriv).PrivKey))
+			err = receiver.Propose(proposal)
+			assert.EqualError(t, errors.Cause(err), ErrVerifyOnlyLeader.Error())
+		}
+	})
+
+	t.Run("failed case block round is after proposal round", func(t *testing.T) {
+		block := RandomProposalWithPeer(t, 0, 1003, peer).GetBlock()
+		proposal, err := convertor.NewModelFactory().NewProposal(block, 1002)
+		require.NoError(t, err)
+		err = receiver.Propose(proposal)
+		assert.EqualError(t, errors.Cause(err), ErrVerifyOnlyLeader.Error())
+	})
+
+	t.Run("failed case proposer is not signer", func(t *testing.T) {
+		proposal := RandomProposalWithPeer(t, 0, 1004, RandomPeerWithPriv())
+		require.NoError(t, proposal.GetBlock().Sign(peer.GetPubkey(), peer.(*PeerWithPriv).PrivKey))
+		err := receiver.Propose(proposal)
+		assert.EqualError(t, errors.Cause(err), ErrVerifyOnlyLeader.Error())
+	})
+
+	t.Run("failed case future height, leader is unknown", func(t *testing.T) {
+		proposal := RandomProposalWithPeer(t, 5, 0, peer)
+		err := receiver.Propose(proposal)
+		assert.EqualError(t, errors.Cause(err), ErrFutureHeightProposal.Error())
+		assert.Empty(t, channel.Propose)
+	})
+
+	t.Run("failed case negative round", func(t *testing.T) {
+		proposal := RandomProposalWithPeer(t, 0, -1, peer)
+		err := receiver.Propose(proposal)
+		assert.EqualError(t, errors.Cause(err), ErrVerifyOnlyLeader.Error())
+	})
+
 	t.Run("failed case already exist", func(t *testing.T) {
-		proposal := RandomProposalWithPeer(t, 1, 0, peer)
+		proposal := RandomProposalWithPeer(t, 0, 2, peer)
 		err := receiver.Propose(proposal)
 		require.NoError(t, err)
 		require.Equal(t, proposal, <-channel.Propose)
 
 		err = receiver.Propose(proposal)
 		assert.EqualError(t, errors.Cause(err), ErrAlradyReceivedSameObject.Error())
+
+		t.Run("failed case equivocation, leader proposes other block in same round", func(t *testing.T) {
+			other := RandomProposalWithPeer(t, 0, 2, peer)
+			err := receiver.Propose(other)
+			assert.EqualError(t, errors.Cause(err), ErrDetectEquivocation.Error())
+
+			evidence := sender.(*convertor.MockConsensusSender).EvidenceMessage
+			require.NotNil(t, evidence)
+			assert.Equal(t, model.DuplicateProposal, evidence.GetType())
+			assert.Equal(t, []model.Proposal{proposal, other}, evidence.GetProposals())
+			assert.Equal(t, peer.GetPubkey(), evidence.GetOffender())
+			assert.NoError(t, evidence.Verify())
+			assert.True(t, evidences.IsExist(evidence))
+		})
 	})
 
 	t.Run("DoS safety test", func(t *testing.T) {
 		waiter := &sync.WaitGroup{}
 		for i := 0; i < GetTestConfig().ReceiveProposeProposalPoolLimits*2; i++ {
 			waiter.Add(1)
-			go func(i int64) {
-				err := receiver.Propose(RandomProposalWithPeer(t, i, 0, peer))
+			go func(i int32) {
+				err := receiver.Propose(RandomProposalWithPeer(t, 0, i, peer))
 				assert.NoError(t, err)
 				waiter.Done()
-			}(int64(i + 2))
+			}(int32(i + 5))
 			go func() {
 				<-channel.Propose
 			}()
@@ -145,7 +246,7 @@ func TestConsensusReceieverUsecase_Propose(t *testing.T) {
 }
 
 func TestConsensusReceieverUsecase_Vote(t *testing.T) {
-	_, ps, _, _, sender, channel, receiver := NewTestConsensusReceiverUsecase()
+	_, ps, _, _, _, sender, channel, _, receiver := NewTestConsensusReceiverUsecase()
 	peers := []model.Peer{
 		RandomPeerWithPriv(),
 		RandomPeerWithPriv(),
@@ -182,6 +283,33 @@ func TestConsensusReceieverUsecase_Vote(t *testing.T) {
 		assert.EqualError(t, errors.Cause(err), ErrVoteNotInPeerService.Error())
 	})
 
+	t.Run("failed not PreVote type", func(t *testing.T) {
+		err := receiver.Vote(RandomPreCommitFromPeer(t, peers[0]))
+		assert.EqualError(t, errors.Cause(err), model.ErrInvalidVoteMessage.Error())
+	})
+
+	t.Run("success reject vote", func(t *testing.T) {
+		vote := convertor.NewModelFactory().NewRejectVoteMessage(GetTestConfig().ChainId, 0, 0, nil, "Not Found Proposal")
+		require.NoError(t, vote.Sign(peers[0].GetPubkey(), peers[0].(*PeerWithPriv).PrivKey))
+		err := receiver.Vote(vote)
+		assert.NoError(t, err)
+		assert.Equal(t, vote, <-channel.Vote)
+	})
+
+	t.Run("failed empty blockHash, but not reject", func(t *testing.T) {
+		vote := NewTestVoteMessage(model.PreVote, 0, 0, nil)
+		require.NoError(t, vote.Sign(peers[0].GetPubkey(), peers[0].(*PeerWithPriv).PrivKey))
+		err := receiver.Vote(vote)
+		assert.EqualError(t, errors.Cause(err), model.ErrInvalidVoteMessage.Error())
+	})
+
+	t.Run("failed other chainId", func(t *testing.T) {
+		vote := convertor.NewModelFactory().NewVoteMessage("other", 0, 0, model.PreVote, RandomByte())
+		require.NoError(t, vote.Sign(peers[0].GetPubkey(), peers[0].(*PeerWithPriv).PrivKey))
+		err := receiver.Vote(vote)
+		assert.EqualError(t, errors.Cause(err), model.ErrInvalidVoteMessage.Error())
+	})
+
 	t.Run("fialed case already exist vote", func(t *testing.T) {
 		vote := RandomVoteMessageFromPeer(t, peers[0])
 		err := receiver.Vote(vote)
@@ -210,7 +338,7 @@ func TestConsensusReceieverUsecase_Vote(t *testing.T) {
 }
 
 func TestConsensusReceieverUsecase_PreCommit(t *testing.T) {
-	_, ps, _, _, sender, channel, receiver := NewTestConsensusReceiverUsecase()
+	_, ps, _, evidences, _, sender, channel, _, receiver := NewTestConsensusReceiverUsecase()
 	peers := []model.Peer{
 		RandomPeerWithPriv(),
 		RandomPeerWithPriv(),
@@ -222,7 +350,7 @@ func TestConsensusReceieverUsecase_PreCommit(t *testing.T) {
 	}
 
 	t.Run("success case", func(t *testing.T) {
-		preCommit := RandomVoteMessageFromPeer(t, peers[0])
+		preCommit := RandomPreCommitFromPeer(t, peers[0])
 		err := receiver.PreCommit(preCommit)
 		assert.NoError(t, err)
 		assert.Equal(t, preCommit, sender.(*convertor.MockConsensusSender).PreCommitMessage)
@@ -235,20 +363,48 @@ func TestConsensusReceieverUsecase_PreCommit(t *testing.T) {
 	})
 
 	t.Run("failed case input unverified preCommit", func(t *testing.T) {
-		preCommit := RandomVoteMessage(t)
+		preCommit := RandomPreCommit(t)
 		preCommit.(*convertor.VoteMessage).Signature = nil
 		err := receiver.PreCommit(preCommit)
 		assert.EqualError(t, errors.Cause(err), model.ErrVoteMessageVerify.Error())
 	})
 
 	t.Run("failed case input not peers preCommit", func(t *testing.T) {
-		preCommit := RandomVoteMessage(t)
+		preCommit := RandomPreCommit(t)
 		err := receiver.PreCommit(preCommit)
 		assert.EqualError(t, errors.Cause(err), ErrPreCommitNotInPeerService.Error())
 	})
 
+	t.Run("failed not PreCommit type", func(t *testing.T) {
+		err := receiver.PreCommit(RandomVoteMessageFromPeer(t, peers[0]))
+		assert.EqualError(t, errors.Cause(err), model.ErrInvalidVoteMessage.Error())
+	})
+
+	t.Run("failed reject preCommit", func(t *testing.T) {
+		preCommit := convertor.NewModelFactory().NewRejectVoteMessage(GetTestConfig().ChainId, 0, 0, RandomByte(), "reject")
+		preCommit.(*convertor.VoteMessage).Type = bbft.VoteType_PRECOMMIT
+		require.NoError(t, preCommit.Sign(peers[0].GetPubkey(), peers[0].(*PeerWithPriv).PrivKey))
+		err := receiver.PreCommit(preCommit)
+		assert.EqualError(t, errors.Cause(err), model.ErrInvalidVoteMessage.Error())
+	})
+
+	t.Run("failed rejectMessage, but not reject", func(t *testing.T) {
+		preCommit := NewTestVoteMessage(model.PreCommit, 0, 0, RandomByte())
+		preCommit.(*convertor.VoteMessage).RejectMessage = "tag"
+		require.NoError(t, preCommit.Sign(peers[0].GetPubkey(), peers[0].(*PeerWithPriv).PrivKey))
+		err := receiver.PreCommit(preCommit)
+		assert.EqualError(t, errors.Cause(err), model.ErrInvalidVoteMessage.Error())
+	})
+
+	t.Run("failed other chainId", func(t *testing.T) {
+		preCommit := convertor.NewModelFactory().NewVoteMessage("other", 0, 0, model.PreCommit, RandomByte())
+		require.NoError(t, preCommit.Sign(peers[0].GetPubkey(), peers[0].(*PeerWithPriv).PrivKey))
+		err := receiver.PreCommit(preCommit)
+		assert.EqualError(t, errors.Cause(err), model.ErrInvalidVoteMessage.Error())
+	})
+
 	t.Run("fialed case already exist preCommit", func(t *testing.T) {
-		preCommit := RandomVoteMessageFromPeer(t, peers[0])
+		preCommit := RandomPreCommitFromPeer(t, peers[2])
 		err := receiver.PreCommit(preCommit)
 		require.NoError(t, err)
 		require.Equal(t, preCommit, <-channel.PreCommit)
@@ -257,15 +413,35 @@ func TestConsensusReceieverUsecase_PreCommit(t *testing.T) {
 		assert.EqualError(t, errors.Cause(err), ErrAlradyReceivedSameObject.Error())
 	})
 
+	t.Run("failed case equivocation, same peer preCommits other blockHash in same round", func(t *testing.T) {
+		preCommit := RandomPreCommitFromPeer(t, peers[3])
+		require.NoError(t, receiver.PreCommit(preCommit))
+		require.Equal(t, preCommit, <-channel.PreCommit)
+
+		other := RandomPreCommitFromPeer(t, peers[3])
+		err := receiver.PreCommit(other)
+		assert.EqualError(t, errors.Cause(err), ErrDetectEquivocation.Error())
+
+		evidence := sender.(*convertor.MockConsensusSender).EvidenceMessage
+		require.NotNil(t, evidence)
+		assert.Equal(t, model.DuplicatePreCommit, evidence.GetType())
+		assert.Equal(t, []model.VoteMessage{preCommit, other}, evidence.GetVotes())
+		assert.Equal(t, peers[3].GetPubkey(), evidence.GetOffender())
+		assert.NoError(t, evidence.Verify())
+		assert.True(t, evidences.IsExist(evidence))
+	})
+
 	t.Run("DoS safety test", func(t *testing.T) {
 		waiter := &sync.WaitGroup{}
 		for i := 0; i < GetTestConfig().ReceivePreCommitVoteMessagePoolLimits*2; i++ {
 			waiter.Add(1)
-			go func() {
-				err := receiver.PreCommit(RandomVoteMessageFromPeer(t, peers[1]))
+			go func(round int32) {
+				preCommit := NewTestVoteMessage(model.PreCommit, 1, round, RandomByte())
+				require.NoError(t, preCommit.Sign(peers[1].GetPubkey(), peers[1].(*PeerWithPriv).PrivKey))
+				err := receiver.PreCommit(preCommit)
 				assert.NoError(t, err)
 				waiter.Done()
-			}()
+			}(int32(i))
 			go func() {
 				<-channel.PreCommit
 			}()
@@ -273,3 +449,70 @@ func TestConsensusReceieverUsecase_PreCommit(t *testing.T) {
 		waiter.Wait()
 	})
 }
+
+func TestConsensusReceieverUsecase_Evidence(t *testing.T) {
+	_, ps, _, evidences, bc, sender, _, _, receiver := NewTestConsensusReceiverUsecase()
+	peers := []model.Peer{
+		RandomPeerWithPriv(),
+		RandomPeerWithPriv(),
+		RandomPeerWithPriv(),
+		RandomPeerWithPriv(),
+	}
+	for _, p := range peers {
+		ps.AddPeer(p)
+	}
+
+	t.Run("success case", func(t *testing.T) {
+		for _, evidence := range []model.Evidence{
+			DuplicateProposalEvidence(t, peers[0], peers[1], 1, 0),
+			DuplicatePreCommitEvidence(t, peers[2], peers[3], 1, 0),
+		} {
+			err := receiver.Evidence(evidence)
+			assert.NoError(t, err)
+			assert.Equal(t, evidence, sender.(*convertor.MockConsensusSender).EvidenceMessage)
+			assert.True(t, evidences.IsExist(evidence))
+		}
+	})
+
+	t.Run("failed case input nil", func(t *testing.T) {
+		err := receiver.Evidence(nil)
+		assert.EqualError(t, errors.Cause(err), model.ErrInvalidEvidence.Error())
+	})
+
+	t.Run("failed case invalid evidence", func(t *testing.T) {
+		err := receiver.Evidence(RandomEvidence(t))
+		assert.EqualError(t, errors.Cause(err), model.ErrEvidenceValidate.Error())
+	})
+
+	t.Run("failed case already exist same offence", func(t *testing.T) {
+		err := receiver.Evidence(DuplicatePreCommitEvidence(t, peers[2], peers[0], 1, 0))
+		assert.EqualError(t, errors.Cause(err), ErrAlradyReceivedSameObject.Error())
+	})
+
+	t.Run("failed case already committed by synced block", func(t *testing.T) {
+		evidence := DuplicatePreCommitEvidence(t, peers[3], peers[0], 1, 0)
+		block := RandomCommitableBlock(t, bc)
+		block.(*convertor.Block).Evidences = append(block.(*convertor.Block).Evidences, evidence.(*convertor.Evidence).Evidence)
+		bc.Commit(block, nil)
+
+		err := receiver.Evidence(evidence)
+		assert.EqualError(t, errors.Cause(err), ErrAlradyReceivedSameObject.Error())
+		assert.False(t, evidences.IsExist(evidence))
+	})
+}
+
+func TestReceiveChannel_Drain(t *testing.T) {
+	channel := NewReceiveChannel(GetTestConfig())
+	channel.Propose <- RandomProposal(t)
+	channel.Vote <- RandomVoteMessage(t)
+	channel.Vote <- RandomVoteMessage(t)
+	channel.PreCommit <- RandomPreCommit(t)
+
+	channel.Drain()
+	assert.Empty(t, channel.Propose)
+	assert.Empty(t, channel.Vote)
+	assert.Empty(t, channel.PreCommit)
+
+	// empty channel does not block
+	channel.Drain()
+}
@@ -2,24 +2,59 @@ package usecase
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"github.com/pkg/errors"
 	"github.com/satellitex/bbft/config"
 	"github.com/satellitex/bbft/dba"
 	"github.com/satellitex/bbft/model"
+	"go.uber.org/multierr"
 	"log"
-	"math"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
 type ConsensusStep interface {
-	Run()
+	Run(ctx context.Context) error
 	Propose(height int64, round int32) error
 	Vote(height int64, round int32) error
 	PreCommit(height int64, round int32) error
 	Commit(height int64, round int32) error
 }
 
+const (
+	PhaseSync      = "sync"
+	PhasePropose   = "propose"
+	PhaseVote      = "vote"
+	PhasePreCommit = "precommit"
+	PhaseCommit    = "commit"
+)
+
+// ConsensusState は合意の今の状態。合意が止まったときに、どの Phase で何を待っているかを調べるために使う
+type ConsensusState struct {
+	Height           int64
+	Round            int32
+	Phase            string
+	ProposeTimeOut   time.Duration
+	VoteTimeOut      time.Duration
+	PreCommitTimeOut time.Duration
+	RoundCommitTime  time.Duration
+	// この Round の Leader。決まらないときは nil
+	Leader model.Peer
+	// この Height で Lock した Proposal。Lock していないときは nil
+	LockedProposal model.Proposal
+	// この Round で受け取った PreVote (Reject Vote を含む) と PreCommit
+	Votes      []model.VoteMessage
+	PreCommits []model.VoteMessage
+}
+
+// ConsensusStateReader は他の goroutine から合意の今の状態を読む
+type ConsensusStateReader interface {
+	GetConsensusState() *ConsensusState
+}
+
 // [Height][Round] = Proposal を管理する
 type ProposalFinder struct {
 	field map[int64]map[int32]model.Proposal
@@ -62,58 +97,186 @@ func (f *ProposalFinder) Clear(height int64) {
 
 // PreCommit を管理する
 //
-// PreCommit が 2/3 以上集まった時、collectedHash を上書きする。
-// Get() 時に collectedHash が存在した場合、PreCommit が 2/3以上集まっているので Commit Phase に遷移する。
-// その後、取得した collectedHash は再度 nil にする。
+// PreCommit は Height, Round, BlockHash の組ごとに、その Height の Peer の voting power の和で数える。
+// 同じ Peer の PreCommit は最初の 1 つだけを数え、集まった PreCommit にも 1 つだけ入れる。
+// ある Height, Round の PreCommit が voting power の 2/3 以上集まった時、collected[height][round] を上書きする。
+// Get(height, round) 時に collected[height][round] が存在した場合、PreCommit が 2/3以上集まっているので Commit Phase に遷移する。
+// 集まった PreCommit は CommitCertificate の作成に使う。
+// その後、取得した collected[height][round] は削除する。
+// 受け取った PreCommit は診断のため集まった後も残す。Height, Round, BlockHash の組は limit 個までで、古いものから忘れる。
+// Block を Commit したら Clean でその Height 以下の PreCommit を捨てる。
 type PreCommitFinder struct {
-	collectedHash []byte
-	field         map[string]int
-	queue         []string
-	limit         int
-	ps            dba.PeerService
+	collected map[int64]map[int32]*collectedPreCommits
+	entries   map[string]*preCommitEntry
+	queue     []string
+	limit     int
+	ps        dba.PeerService
+	mutex     *sync.Mutex
+}
+
+type collectedPreCommits struct {
+	hash       []byte
+	preCommits []model.VoteMessage
+}
+
+// preCommitEntry は Height, Round, BlockHash の組ごとに受け取った PreCommit
+type preCommitEntry struct {
+	height     int64
+	round      int32
+	power      int64
+	collected  bool
+	signers    map[string]struct{}
+	preCommits []model.VoteMessage
 }
 
 func NewPreCommitFinder(ps dba.PeerService, conf *config.BBFTConfig) *PreCommitFinder {
 	return &PreCommitFinder{
-		nil,
-		make(map[string]int),
+		make(map[int64]map[int32]*collectedPreCommits),
+		make(map[string]*preCommitEntry),
 		make([]string, 0, conf.PreCommitFinderLimits),
 		conf.PreCommitFinderLimits,
 		ps,
+		new(sync.Mutex),
 	}
 }
 
-func (f *PreCommitFinder) Get() ([]byte, bool) {
-	if f.collectedHash == nil {
-		return nil, false
+// Get は height, round で 2/3 以上集まった PreCommit の BlockHash と PreCommit の集合を返す
+// height 未満の集まった PreCommit は捨てる
+func (f *PreCommitFinder) Get(height int64, round int32) ([]byte, []model.VoteMessage, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for h := range f.collected {
+		if h < height {
+			delete(f.collected, h)
+		}
+	}
+	ret, ok := f.collected[height][round]
+	if !ok {
+		return nil, nil, false
 	}
-	ret := f.collectedHash
-	f.collectedHash = nil
-	return ret, true
+	delete(f.collected[height], round)
+	return ret.hash, ret.preCommits, true
 }
 
 func (f *PreCommitFinder) Set(vote model.VoteMessage) error {
 	if vote == nil {
 		return errors.Wrapf(model.ErrInvalidVoteMessage, "vote is nil")
 	}
+	if vote.GetType() != model.PreCommit {
+		return errors.Wrapf(model.ErrInvalidVoteMessage, "vote type is not PreCommit: %d", vote.GetType())
+	}
 
-	hashStr := string(vote.GetBlockHash())
-	if _, ok := f.field[hashStr]; !ok {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	height, round := vote.GetHeight(), vote.GetRound()
+	hashStr := strconv.FormatInt(height, 16) + "::" + strconv.FormatInt(int64(round), 16) + "::" + string(vote.GetBlockHash())
+	entry, ok := f.entries[hashStr]
+	if !ok {
 		if len(f.queue) >= f.limit {
-			delete(f.field, f.queue[0])
+			delete(f.entries, f.queue[0])
 			f.queue = f.queue[1:]
 		}
+		entry = &preCommitEntry{height: height, round: round, signers: make(map[string]struct{})}
+		f.entries[hashStr] = entry
 		f.queue = append(f.queue, hashStr)
 	}
-	f.field[hashStr]++
+	pubkey := vote.GetSignature().GetPubkey()
+	if _, ok := entry.signers[string(pubkey)]; ok {
+		// 同じ Peer の PreCommit は数え直さない
+		return nil
+	}
+	entry.signers[string(pubkey)] = struct{}{}
+	entry.preCommits = append(entry.preCommits, vote)
+	if entry.collected {
+		return nil
+	}
+	entry.power += f.ps.AtHeight(height).GetPower([][]byte{pubkey})
 
-	if f.ps.GetNumberOfRequiredAcceptPeers() <= f.field[hashStr] {
-		f.collectedHash = vote.GetBlockHash()
-		f.field[hashStr] = math.MinInt32
+	if f.ps.AtHeight(height).GetRequiredAcceptPower() <= entry.power {
+		if _, ok := f.collected[height]; !ok {
+			f.collected[height] = make(map[int32]*collectedPreCommits)
+		}
+		preCommits := make([]model.VoteMessage, len(entry.preCommits))
+		copy(preCommits, entry.preCommits)
+		f.collected[height][round] = &collectedPreCommits{vote.GetBlockHash(), preCommits}
+		entry.collected = true
 	}
 	return nil
 }
 
+// GetPreCommits は height, round で受け取った PreCommit を返す。誰がどの Block に PreCommit したかの診断に使う
+func (f *PreCommitFinder) GetPreCommits(height int64, round int32) []model.VoteMessage {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	ret := make([]model.VoteMessage, 0)
+	for _, key := range f.queue {
+		if entry := f.entries[key]; entry.height == height && entry.round == round {
+			ret = append(ret, entry.preCommits...)
+		}
+	}
+	return ret
+}
+
+// Clean は height 未満の PreCommit をすべて捨てる
+func (f *PreCommitFinder) Clean(height int64) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for h := range f.collected {
+		if h < height {
+			delete(f.collected, h)
+		}
+	}
+	queue := make([]string, 0, f.limit)
+	for _, key := range f.queue {
+		if f.entries[key].height < height {
+			delete(f.entries, key)
+		} else {
+			queue = append(queue, key)
+		}
+	}
+	f.queue = queue
+}
+
+const (
+	RoundBackoffNone        = "none"
+	RoundBackoffLinear      = "linear"
+	RoundBackoffExponential = "exponential"
+)
+
+// Backoff は round に応じて base を伸ばした時間を返す。伸ばした時間は max(base, RoundBackoffMaxTime) を超えない。
+//
+//	none        : base
+//	linear      : base * (round + 1)
+//	exponential : base * 2^round
+//
+// 長く合意が取れない場合でも、各 Phase の時間が伸びることでいずれ合意が取れるようになる。
+func Backoff(conf *config.BBFTConfig, base time.Duration, round int32) time.Duration {
+	max := conf.RoundBackoffMaxTime
+	if base <= 0 || round <= 0 || max <= base {
+		return base
+	}
+	switch conf.RoundBackoff {
+	case RoundBackoffLinear:
+		if int64(round)+1 > int64(max/base) {
+			return max
+		}
+		return base * time.Duration(round+1)
+	case RoundBackoffExponential:
+		ret := base
+		for i := int32(0); i < round; i++ {
+			if ret > max/2 {
+				return max
+			}
+			ret *= 2
+		}
+		return ret
+	}
+	return base
+}
+
+// emptyBlockPollInterval は Leader が Transaction の到着を確かめる間隔
+const emptyBlockPollInterval = 100 * time.Millisecond
+
 func UnixTime(t time.Time) int64 {
 	return t.UnixNano()
 }
@@ -123,43 +286,77 @@ func Now() int64 {
 }
 
 type ConsensusStepUsecase struct {
-	conf    *config.BBFTConfig
-	bc      dba.BlockChain
-	ps      dba.PeerService
-	lock    dba.Lock
-	queue   dba.ProposalTxQueue
-	sender  model.ConsensusSender
-	slv     model.StatelessValidator
-	sfv     model.StatefulValidator
-	factory model.ModelFactory
-	channel *ReceiveChannel
+	conf      *config.BBFTConfig
+	bc        dba.BlockChain
+	ps        dba.PeerService
+	selector  LeaderSelector
+	lock      dba.Lock
+	queue     dba.ProposalTxQueue
+	evidences dba.EvidencePool
+	sender    model.ConsensusSender
+	slv       model.StatelessValidator
+	sfv       model.StatefulValidator
+	ev        model.EvidenceValidator
+	factory   model.ModelFactory
+	syncer    BlockSync
+	wal       model.WAL
+	bus       EventBus
+	monitor   LatencyMonitor
+	clock     Clock
+	channel   *ReceiveChannel
+	// done は Run の ctx が終わると閉じられ、各 Phase の待ち受けを終わらせる
+	done <-chan struct{}
+	// publishedHeight まで EventNewBlock を publish した。lockedHeight, lockedRound は最後に publish した EventLock
+	publishedHeight int64
+	lockedHeight    int64
+	lockedRound     int32
+	// state は GetConsensusState で返す Height, Round, Phase と TimeOut。Run の goroutine だけが書き換える
+	state      ConsensusState
+	stateMutex *sync.Mutex
 
 	proposalFinder    *ProposalFinder
 	preCommitFinder   *PreCommitFinder
 	ThisRoundProposal model.Proposal
-	RoundStartTime    time.Duration
-	RoundCommitTime   time.Duration
-	ProposeTimeOut    time.Duration
-	VoteTimeOut       time.Duration
-	PreCommitTimeOut  time.Duration
+	// ThisRoundCertificate は PreCommit Phase で 2/3 以上集まった PreCommit から作られ、Block と一緒に Commit される
+	ThisRoundCertificate model.CommitCertificate
+	RoundStartTime       time.Duration
+	// timeouts は今の Height の合意で使う通信の時間と各 Phase の計算時間。Height を始めるときに Commit された Block から決める
+	timeouts PhaseTimeouts
+	// IdleTimeOut は Leader が Transaction を待って提案を遅らせてよい最後の時刻。待たない Round では RoundStartTime と同じ
+	IdleTimeOut      time.Duration
+	RoundCommitTime  time.Duration
+	ProposeTimeOut   time.Duration
+	VoteTimeOut      time.Duration
+	PreCommitTimeOut time.Duration
 }
 
-func NewConsensusStepUsecase(conf *config.BBFTConfig, bc dba.BlockChain, ps dba.PeerService, lock dba.Lock,
-	queue dba.ProposalTxQueue, sender model.ConsensusSender, slv model.StatelessValidator, sfv model.StatefulValidator,
-	factory model.ModelFactory, channel *ReceiveChannel) ConsensusStep {
+func NewConsensusStepUsecase(conf *config.BBFTConfig, bc dba.BlockChain, ps dba.PeerService, selector LeaderSelector, lock dba.Lock,
+	queue dba.ProposalTxQueue, evidences dba.EvidencePool, sender model.ConsensusSender, slv model.StatelessValidator, sfv model.StatefulValidator,
+	ev model.EvidenceValidator, factory model.ModelFactory, syncer BlockSync, wal model.WAL, bus EventBus, monitor LatencyMonitor, clock Clock, channel *ReceiveChannel) ConsensusStep {
 	return &ConsensusStepUsecase{
 		conf:            conf,
 		bc:              bc,
 		ps:              ps,
+		selector:        selector,
 		lock:            lock,
 		queue:           queue,
+		evidences:       evidences,
 		sender:          sender,
 		slv:             slv,
 		sfv:             sfv,
+		ev:              ev,
 		factory:         factory,
+		syncer:          syncer,
+		wal:             wal,
+		bus:             bus,
+		monitor:         monitor,
+		clock:           clock,
 		channel:         channel,
 		proposalFinder:  NewProposalFinder(),
 		preCommitFinder: NewPreCommitFinder(ps, conf),
+		timeouts:        NewPhaseTimeouts(conf, bc, 0),
+		state:           ConsensusState{Round: -1},
+		stateMutex:      new(sync.Mutex),
 	}
 }
 
@@ -168,39 +365,80 @@ var (
 	ErrConsensusVote      = errors.Errorf("Failed This peer Vote")
 	ErrConsensusPreCommit = errors.Errorf("Failed This peer PreCommit")
 	ErrConsensusCommit    = errors.Errorf("Failed This peer ConsensusCommit")
+	ErrConsensusRejected  = errors.Errorf("Failed This Round is Rejected")
+	ErrConsensusLocked    = errors.Errorf("Failed Proposal is not the Locked Block")
+	ErrConsensusReplay    = errors.Errorf("Failed Replay Consensus WAL")
 )
 
-// Runnning Consensus Endless...
-func (c *ConsensusStepUsecase) Run() {
+// Runnning Consensus until ctx is done.
+// ctx が終わると待ち受け中の Phase はタイムアウトしたものとして終わり、次の Phase に進む前に止まる
+// 始める前に WAL を読み直し、前に動いていた Height まで BlockSync してから、記録した Round の次の Round から再開する
+func (c *ConsensusStepUsecase) Run(ctx context.Context) error {
 	log.Println("============== Running Consensus!! ==============")
+	c.done = ctx.Done()
+	// resumeHeight の resumeRound までは既に署名しているかもしれないので、その Height では次の Round から始める
+	resumeHeight, resumeRound, err := c.Replay()
+	if err != nil {
+		log.Println("Consensus WAL Replay Error!!", err)
+	}
+	if resumeHeight > 0 {
+		c.syncer.Resume(resumeHeight)
+	}
+	if top, ok := c.bc.Top(); ok {
+		c.publishedHeight = top.GetHeader().GetHeight()
+	}
 	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if c.syncer.IsBehind() {
+			c.setPhase(c.state.Height, c.state.Round, PhaseSync)
+			c.sync()
+		}
 		top, ok := c.bc.Top()
 		if !ok {
 			panic("Unexpected Error No BlockChain Top")
 		}
 		height, round := top.GetHeader().GetHeight()+1, int32(-1)
+		c.timeouts = NewPhaseTimeouts(c.conf, c.bc, height)
 		if height == 1 {
-			c.RoundStartTime = time.Duration(Now())
+			c.RoundStartTime = time.Duration(c.clock.Now())
 		} else {
-			c.RoundStartTime = time.Duration(top.GetHeader().GetCreatedTime())
+			c.RoundStartTime = time.Duration(top.GetHeader().GetCreatedTime()) + c.targetBlockWait()
+		}
+		if height == resumeHeight {
+			log.Println("============== Resume Consensus!! ============== height:", height, "round:", resumeRound+1)
+			round = resumeRound
+			c.RoundStartTime = time.Duration(c.clock.Now())
+			resumeHeight = 0
 		}
 		log.Println("============== Running Consensus!! ============== height:", height)
+		committed := false
 		for {
+			rejected := false
 
-			timer := time.NewTimer(c.RoundStartTime - time.Duration(Now()))
-			<-timer.C
+			// Round の開始までに届いたメッセージは Finder に保存しておく
+			c.receive(c.RoundStartTime, receiveHandler{})
+			if err := ctx.Err(); err != nil {
+				return err
+			}
 
 			round++
 			log.Println("============== Running Consensus!! ============== round:", round)
+			if err := c.wal.WriteRound(height, round); err != nil {
+				log.Println("Consensus WAL Error!!", err)
+			}
+			c.bus.Publish(&Event{Type: EventNewRound, Height: height, Round: round})
 
 			// each Phase TimeOut Calc
-			c.ProposeTimeOut = c.RoundStartTime + c.conf.ProposeMaxCalcTime + c.conf.AllowedConnectDelayTime
-			c.VoteTimeOut = c.ProposeTimeOut + c.conf.VoteMaxCalcTime + c.conf.AllowedConnectDelayTime
-			c.PreCommitTimeOut = c.VoteTimeOut + c.conf.PreCommitMaxCalcTime + c.conf.AllowedConnectDelayTime
-			c.RoundCommitTime = c.PreCommitTimeOut + c.conf.CommitMaxCalcTime
+			// Leader が Transaction を待つ Round では、最も遅く提案が始まったときの TimeOut まで待つ
+			c.IdleTimeOut = c.RoundStartTime + c.idleTime(round)
+			c.schedule(c.IdleTimeOut, round)
 			c.ThisRoundProposal = nil
+			c.ThisRoundCertificate = nil
 
 			log.Println("=============== ProposePhase ===============")
+			c.setPhase(height, round, PhasePropose)
 			if err := c.Propose(height, round); err != nil {
 				log.Println("Consensus ProposePhase Error!!",
 					"height:", height,
@@ -208,7 +446,12 @@ func (c *ConsensusStepUsecase) Run() {
 					err)
 			}
 
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
 			log.Println("=============== VotePhase ===============")
+			c.setPhase(height, round, PhaseVote)
 			if err := c.Vote(height, round); err != nil {
 				log.Println("Consensus VotePhase Error!!",
 					"height:", height,
@@ -216,46 +459,250 @@ func (c *ConsensusStepUsecase) Run() {
 					err)
 			}
 
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
 			log.Println("=============== PreCommitPhase ===============")
+			c.setPhase(height, round, PhasePreCommit)
 			if err := c.PreCommit(height, round); err != nil {
 				log.Println("Consensus PreCommitPhase Error!!",
 					"height:", height,
 					"round:", round,
 					err)
+				rejected = errors.Cause(err) == ErrConsensusRejected
 			} else {
+				committed = true
 				break
 			}
-			c.RoundStartTime = c.PreCommitTimeOut
+			// Other peers are already ahead, so catch up before next round
+			// BlockSync で height が Commit できなかったときは Round を戻さずに続ける
+			if c.syncer.IsBehind() && c.catchUp(height, round) {
+				break
+			}
+			if rejected { // 2/3+ peers rejected this round, so start next round now
+				c.RoundStartTime = time.Duration(c.clock.Now())
+			} else {
+				c.RoundStartTime = c.PreCommitTimeOut
+			}
+		}
+		if committed {
+			log.Println("============== Commit!! ==============")
+			c.setPhase(height, round, PhaseCommit)
+			if err := c.Commit(height, round); err != nil {
+				// 2/3 以上が PreCommit した Block を持っていないので、他の Peer から取得する
+				// 取得できなくても、同じ Height の前の Round に戻って署名し直さないように次の Round から続ける
+				log.Println("Consensus Commit Error!!", "height:", height, "round:", round, err)
+				c.syncer.ObserveCertificate(c.ThisRoundCertificate)
+				c.sync()
+				resumeHeight, resumeRound = height, round
+			} else {
+				c.checkCommitTime()
+			}
+			c.publishBlocks()
+		}
+	}
+}
+
+// catchUp は BlockSync して、height の Block が Commit されたかを返す
+func (c *ConsensusStepUsecase) catchUp(height int64, round int32) bool {
+	c.setPhase(height, round, PhaseSync)
+	c.sync()
+	top, ok := c.bc.Top()
+	return ok && top.GetHeader().GetHeight() >= height
+}
+
+// sync は BlockSync して、BlockSync で Commit された Block にも Commit と同じ後始末をする
+func (c *ConsensusStepUsecase) sync() {
+	before, ok := c.bc.Top()
+	if !ok {
+		panic("Unexpected Error No BlockChain Top")
+	}
+	if err := c.syncer.Sync(); err != nil {
+		log.Println("Consensus BlockSync Error!!", err)
+	}
+	top, _ := c.bc.Top()
+	for height := before.GetHeader().GetHeight() + 1; height <= top.GetHeader().GetHeight(); height++ {
+		if block, ok := c.bc.GetBlock(height); ok {
+			c.evidences.Commit(block.GetEvidences())
+		}
+	}
+	if top.GetHeader().GetHeight() > before.GetHeader().GetHeight() {
+		c.clean(top.GetHeader().GetHeight())
+	}
+	c.publishBlocks()
+}
+
+// setPhase は Height, Round, Phase と今の各 Phase の TimeOut を GetConsensusState で読めるようにする
+func (c *ConsensusStepUsecase) setPhase(height int64, round int32, phase string) {
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+	c.state = ConsensusState{
+		Height:           height,
+		Round:            round,
+		Phase:            phase,
+		ProposeTimeOut:   c.ProposeTimeOut,
+		VoteTimeOut:      c.VoteTimeOut,
+		PreCommitTimeOut: c.PreCommitTimeOut,
+		RoundCommitTime:  c.RoundCommitTime,
+	}
+}
+
+// GetConsensusState は今の Height, Round, Phase に、Leader, Lock と集まった投票を加えて返す
+// Lock と PreCommitFinder は自身で排他するので、Run と別の goroutine から呼んでよい
+func (c *ConsensusStepUsecase) GetConsensusState() *ConsensusState {
+	c.stateMutex.Lock()
+	state := c.state
+	c.stateMutex.Unlock()
+
+	if leader, ok := c.selector.GetLeader(state.Height, state.Round); ok {
+		state.Leader = leader
+	}
+	if locked, ok := c.lock.GetLockedProposal(state.Height); ok {
+		state.LockedProposal = locked
+	}
+	state.Votes = c.lock.GetVotes(state.Height, state.Round)
+	state.PreCommits = c.preCommitFinder.GetPreCommits(state.Height, state.Round)
+	return &state
+}
+
+// roundLength は Round を始めてから RoundCommitTime までの時間を返す
+func (c *ConsensusStepUsecase) roundLength(round int32) time.Duration {
+	return Backoff(c.conf, c.timeouts.Propose, round) +
+		Backoff(c.conf, c.timeouts.Vote, round) +
+		Backoff(c.conf, c.timeouts.PreCommit, round) +
+		3*c.timeouts.ConnectDelay + c.conf.CommitMaxCalcTime
+}
+
+// schedule は start に提案が始まったものとして各 Phase の TimeOut と RoundCommitTime を計算する
+func (c *ConsensusStepUsecase) schedule(start time.Duration, round int32) {
+	c.ProposeTimeOut = start + Backoff(c.conf, c.timeouts.Propose, round) + c.timeouts.ConnectDelay
+	c.VoteTimeOut = c.ProposeTimeOut + Backoff(c.conf, c.timeouts.Vote, round) + c.timeouts.ConnectDelay
+	c.PreCommitTimeOut = c.VoteTimeOut + Backoff(c.conf, c.timeouts.PreCommit, round) + c.timeouts.ConnectDelay
+	c.RoundCommitTime = c.PreCommitTimeOut + c.conf.CommitMaxCalcTime
+}
+
+// targetBlockWait は前の Block の CreatedTime から TargetBlockTime 空けるために、round 0 の開始を遅らせる時間を返す
+func (c *ConsensusStepUsecase) targetBlockWait() time.Duration {
+	if wait := c.conf.TargetBlockTime - c.roundLength(0); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// idleTime は Leader が Transaction を待って提案を遅らせてよい時間を返す
+// 待つのは round 0 だけで、合意が取れずに進んだ Round では空でもすぐに提案する
+func (c *ConsensusStepUsecase) idleTime(round int32) time.Duration {
+	if round == 0 && !c.conf.CreateEmptyBlocks {
+		return c.conf.EmptyBlockInterval
+	}
+	return 0
+}
+
+// realign は提案が start に始まったものとして TimeOut を計算し直す
+// start は RoundStartTime から IdleTimeOut の間に収める。Transaction を待たない Round では何もしない
+func (c *ConsensusStepUsecase) realign(start time.Duration, round int32) {
+	if c.IdleTimeOut <= c.RoundStartTime {
+		return
+	}
+	if start < c.RoundStartTime {
+		start = c.RoundStartTime
+	}
+	if start > c.IdleTimeOut {
+		start = c.IdleTimeOut
+	}
+	c.schedule(start, round)
+	c.setPhase(c.state.Height, c.state.Round, c.state.Phase)
+}
+
+// waitTxs は ProposalTxQueue に Transaction が届くか IdleTimeOut になるまで待つ
+// 待っている間に届いたメッセージは Finder に保存しておく
+func (c *ConsensusStepUsecase) waitTxs() {
+	for c.queue.Len() == 0 {
+		now := time.Duration(c.clock.Now())
+		if now >= c.IdleTimeOut {
+			return
+		}
+		next := now + emptyBlockPollInterval
+		if next > c.IdleTimeOut {
+			next = c.IdleTimeOut
+		}
+		c.receive(next, receiveHandler{})
+		select {
+		case <-c.done:
+			return
+		default:
 		}
-		log.Println("============== Commit!! ==============")
-		c.Commit(height, round)
 	}
 }
 
+// repropose は Lock した Block をそのまま round の Proposal として提案し直す
+// Block の署名と header の proposer, round は Lock した Block を作ったリーダーのまま変えず、
+// Lock した Round を polRound に入れて、round のリーダーとして Proposal に署名する
+func (c *ConsensusStepUsecase) repropose(locked model.Proposal, round int32) (model.Proposal, error) {
+	proposal, err := c.factory.NewReProposal(locked.GetBlock(), round, locked.GetRound())
+	if err != nil {
+		return nil, err
+	}
+	if err := proposal.Sign(c.conf.PublicKey, c.conf.SecretKey); err != nil {
+		return nil, err
+	}
+	return proposal, nil
+}
+
+// isLockedThisRound は この Round 以降で Lock を取っていれば true を返す。この Round の Vote はもう要らない
+func (c *ConsensusStepUsecase) isLockedThisRound(height int64, round int32) bool {
+	lockedRound, ok := c.lock.GetLockedRound(height)
+	return ok && lockedRound >= round
+}
+
+// Propose は Leader なら Proposal を作って送り、そうでなければ Leader の Proposal を待つ
+// Lock を取っている Leader は新しい Block を作らず、Lock した Block をこの Round で提案し直す
 func (c *ConsensusStepUsecase) Propose(height int64, round int32) error {
-	if _, ok := c.lock.GetLockedProposal(height); !ok {
-		if bytes.Equal(c.ps.GetPermutationPeers(height)[round].GetPubkey(), c.conf.PublicKey) {
+	if !c.isLockedThisRound(height, round) {
+		if leader, ok := c.selector.GetLeader(height, round); ok && bytes.Equal(leader.GetPubkey(), c.conf.PublicKey) {
 			// Leader is me
-			log.Println("ProposePhase : Leader is Me")
-			txs := make([]model.Transaction, 0, c.conf.NumberOfBlockHasTransactions)
-			for len(txs) < c.conf.NumberOfBlockHasTransactions {
-				tx, ok := c.queue.Pop()
-				if !ok { // ProposalTx is empty
-					break
+			if locked, ok := c.lock.GetLockedProposal(height); ok {
+				log.Println("ProposePhase : Leader is Me, re-propose locked block of round:", locked.GetRound())
+				proposal, err := c.repropose(locked, round)
+				if err != nil {
+					return err
 				}
-				if err := c.slv.TxValidate(tx); err != nil {
-					continue
-				}
-				if _, ok := c.bc.FindTx(model.MustGetHash(tx)); ok {
-					continue // Already Exist Transaction
-				}
-				txs = append(txs, tx)
+				c.ThisRoundProposal = proposal
+				return c.sendProposal(proposal)
+			}
+			log.Println("ProposePhase : Leader is Me")
+			if c.IdleTimeOut > c.RoundStartTime {
+				c.waitTxs()
+				c.realign(time.Duration(c.clock.Now()), round)
 			}
 			top, ok := c.bc.Top()
 			if !ok {
 				return errors.New("Unexpected Error No BlockChain Top")
 			}
-			block, err := c.factory.NewBlock(height, model.MustGetHash(top), int64(c.RoundCommitTime), txs)
+			evidences := c.pendingEvidences()
+			// genesis block の次の Block は lastCommit を持たない
+			lastCommit, _ := c.bc.GetCommitCertificate(top.GetHeader().GetHeight())
+			state := c.factory.NewChainState(c.conf.PublicKey, round, top.GetHeader().GetNextStateRoot(),
+				c.ps.AtHeight(height).GetPeers(), lastCommit)
+			var timing model.PhaseTiming
+			if c.conf.AdaptiveTimeout {
+				timing = c.monitor.Report()
+			}
+			// Transaction 以外の大きさを引いた残りに Transaction を詰める
+			base, err := c.factory.NewTimedBlock(height, model.MustGetHash(top), int64(c.RoundCommitTime), nil, evidences, state, timing)
+			if err != nil {
+				return err
+			}
+			base.Sign(c.conf.PublicKey, c.conf.SecretKey)
+			txs := collectTxs(c.conf, c.queue, base, func(tx model.Transaction) bool {
+				if err := c.slv.TxValidate(tx); err != nil {
+					return true
+				}
+				_, ok := c.bc.FindTx(model.MustGetHash(tx)) // Already Exist Transaction
+				return ok
+			})
+			block, err := c.factory.NewTimedBlock(height, model.MustGetHash(top), int64(c.RoundCommitTime), txs, evidences, state, timing)
 			if err != nil {
 				return err
 			}
@@ -266,96 +713,272 @@ func (c *ConsensusStepUsecase) Propose(height int64, round int32) error {
 			}
 
 			c.ThisRoundProposal = proposal
-			if err = c.sender.Propose(proposal); err != nil {
-				//log.Println(err)
+			if err = c.sendProposal(proposal); err != nil {
+				return err
 			}
 		} else {
 			// Leader is not me
-			timer := time.NewTimer(c.ProposeTimeOut - time.Duration(Now()))
-			for {
-				select {
-				case <-timer.C:
-					goto proposeEnd
-				case proposal := <-c.channel.Propose:
-					c.proposalFinder.Set(proposal)
-					if c.ThisRoundProposal, ok = c.proposalFinder.Find(height, round); ok {
-						goto proposeEnd
-					}
-				case <-c.channel.Vote:
-					if _, ok := c.lock.GetLockedProposal(height); ok {
-						goto proposeEnd
-					}
-				case preCommit := <-c.channel.PreCommit:
-					c.preCommitFinder.Set(preCommit)
+			if c.ThisRoundProposal, ok = c.proposalFinder.Find(height, round); !ok {
+				start := time.Duration(c.clock.Now())
+				c.receive(c.ProposeTimeOut, receiveHandler{
+					propose: func() bool {
+						c.ThisRoundProposal, ok = c.proposalFinder.Find(height, round)
+						return ok
+					},
+					vote: func() bool {
+						return c.isLockedThisRound(height, round)
+					},
+				})
+				// Leader が Transaction を待つ Round では待った時間が入るので測らない
+				if c.ThisRoundProposal != nil && c.IdleTimeOut <= c.RoundStartTime {
+					c.observe(PhasePropose, start)
 				}
 			}
-		proposeEnd:
+			// Leader が Transaction を待っていた分だけ、Proposal の CreatedTime から TimeOut をずらす
+			if c.ThisRoundProposal != nil {
+				c.realign(time.Duration(c.ThisRoundProposal.GetBlock().GetHeader().GetCreatedTime())-c.roundLength(round), round)
+			}
 		}
 	}
 	return nil
 }
 
+// Vote は ThisRoundProposal を検証して PreVote を送る
+// Lock を取っているときは Lock した Block の Proposal にだけ PreVote を送る。Lock した Block は前の Round で作られたので CreatedTime は確かめない
 func (c *ConsensusStepUsecase) Vote(height int64, round int32) error {
-	if _, ok := c.lock.GetLockedProposal(height); !ok {
+	if !c.isLockedThisRound(height, round) {
+		locked, isLocked := c.lock.GetLockedProposal(height)
 		if c.ThisRoundProposal != nil {
 			log.Println("ThisRoundPropsoal: ", fmt.Sprintf("%x", model.MustGetHash(c.ThisRoundProposal.GetBlock())))
-			if err := c.slv.BlockValidate(c.ThisRoundProposal.GetBlock()); err != nil {
+			hash := model.MustGetHash(c.ThisRoundProposal.GetBlock())
+			relocked := isLocked && bytes.Equal(hash, model.MustGetHash(locked.GetBlock()))
+			if isLocked && !relocked {
+				log.Printf("Height: %d, Round: %d, proposal is not locked block of round: %d\n", height, round, locked.GetRound())
+				c.sendVote(c.factory.NewRejectVoteMessage(c.conf.ChainId, height, round, hash,
+					errors.Wrapf(ErrConsensusLocked, "locked round: %d, locked blockHash: %x", locked.GetRound(), model.MustGetHash(locked.GetBlock())).Error()))
+			} else if err := c.slv.BlockValidate(c.ThisRoundProposal.GetBlock()); err != nil {
 				log.Printf("Height: %d, Round: %d, proposal StatelessInvalid: %s\n", height, round, err.Error())
+				c.sendVote(c.factory.NewRejectVoteMessage(c.conf.ChainId, height, round, hash,
+					errors.Wrapf(model.ErrStatelessBlockValidate, err.Error()).Error()))
 			} else if err := c.sfv.Validate(c.ThisRoundProposal.GetBlock()); err != nil {
 				log.Printf("Height: %d, Round: %d, proposal StatefulInvalid: %s\n", height, round, err.Error())
+				c.sendVote(c.factory.NewRejectVoteMessage(c.conf.ChainId, height, round, hash,
+					errors.Wrapf(model.ErrStatefulValidate, err.Error()).Error()))
+			} else if err := c.validateEvidences(c.ThisRoundProposal.GetBlock()); err != nil {
+				log.Printf("Height: %d, Round: %d, proposal has invalid evidence: %s\n", height, round, err.Error())
+				c.sendVote(c.factory.NewRejectVoteMessage(c.conf.ChainId, height, round, hash,
+					errors.Wrapf(model.ErrEvidenceValidate, err.Error()).Error()))
+			} else if relocked {
+				c.sendVote(c.factory.NewVoteMessage(c.conf.ChainId, height, round, model.PreVote, hash))
+			} else if err := c.validateCreatedTime(c.ThisRoundProposal.GetBlock()); err != nil {
+				log.Printf("Height: %d, Round: %d, Clock Skew Warning, proposal rejected: %s\n", height, round, err.Error())
+				c.sendVote(c.factory.NewRejectVoteMessage(c.conf.ChainId, height, round, hash, err.Error()))
 			} else {
-				vote := c.factory.NewVoteMessage(model.MustGetHash(c.ThisRoundProposal.GetBlock()))
-				vote.Sign(c.conf.PublicKey, c.conf.SecretKey)
-				if err := c.sender.Vote(vote); err != nil {
-					//log.Println(err)
-				}
+				c.sendVote(c.factory.NewVoteMessage(c.conf.ChainId, height, round, model.PreVote, hash))
 			}
+		} else {
+			log.Printf("Height: %d, Round: %d, proposal Not Found\n", height, round)
+			c.sendVote(c.factory.NewRejectVoteMessage(c.conf.ChainId, height, round, nil, "Not Found Proposal"))
 		}
-		timer := time.NewTimer(c.VoteTimeOut - time.Duration(Now()))
-		for {
-			select {
-			case <-timer.C:
-				goto voteEnd
-			case proposal := <-c.channel.Propose:
-				c.proposalFinder.Set(proposal)
-			case <-c.channel.Vote:
-				if proposal, ok := c.lock.GetLockedProposal(height); ok {
-					if proposal.GetRound() == round {
-						goto voteEnd
-					}
+		start := time.Duration(c.clock.Now())
+		collected := false
+		c.receive(c.VoteTimeOut, receiveHandler{
+			vote: func() bool {
+				if proposal, ok := c.lock.GetLockedProposal(height); ok && proposal.GetRound() == round {
+					collected = true
+					return true
 				}
-			case preCommit := <-c.channel.PreCommit:
-				c.preCommitFinder.Set(preCommit)
-			}
+				return c.lock.IsRejected(height, round)
+			},
+		})
+		if collected {
+			c.observe(PhaseVote, start)
+		}
+	}
+	return nil
+}
+
+// pendingEvidences は EvidencePool の Evidence から、BlockSync で取得した Block などで既に Commit されたものを除いて返す
+// 除いた Evidence は EvidencePool でも Commit されたものとする
+func (c *ConsensusStepUsecase) pendingEvidences() []model.Evidence {
+	pendings := c.evidences.GetPendings(c.conf.NumberOfBlockHasEvidences)
+	ret := make([]model.Evidence, 0, len(pendings))
+	committed := make([]model.Evidence, 0)
+	for _, evidence := range pendings {
+		if _, ok := c.bc.FindEvidenceHeight(evidence); ok {
+			committed = append(committed, evidence)
+		} else {
+			ret = append(ret, evidence)
 		}
-	voteEnd:
+	}
+	c.evidences.Commit(committed)
+	return ret
+}
+
+func (c *ConsensusStepUsecase) validateEvidences(block model.Block) error {
+	var result error
+	for _, evidence := range block.GetEvidences() {
+		result = multierr.Append(result, c.ev.Validate(evidence))
+	}
+	return result
+}
+
+// validateCreatedTime は Block の CreatedTime が自分の RoundCommitTime から MaxClockSkew 以上ずれていないか確認する
+// Leader は自分の RoundCommitTime を CreatedTime にするので、このずれは Leader と自分の時計のずれである
+func (c *ConsensusStepUsecase) validateCreatedTime(block model.Block) error {
+	skew := time.Duration(block.GetHeader().GetCreatedTime()) - c.RoundCommitTime
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > c.conf.MaxClockSkew {
+		return errors.Wrapf(model.ErrBlockCreatedTimeSkew, "createdTime: %d, local commitTime: %d, skew: %v, max: %v",
+			block.GetHeader().GetCreatedTime(), c.RoundCommitTime, skew, c.conf.MaxClockSkew)
+	}
+	if skew > c.conf.MaxClockSkew/2 {
+		log.Printf("Clock Skew Warning: proposal createdTime is %v away from local commitTime (max: %v)\n", skew, c.conf.MaxClockSkew)
+	}
+	return nil
+}
+
+// checkCommitTime は Commit した Block の CreatedTime を MaxClockSkew 以上過ぎていたら警告を出す
+// CreatedTime は Round の終わりの時刻なので、過ぎているときは自分の時計が進んでいるか、処理が遅れている
+func (c *ConsensusStepUsecase) checkCommitTime() {
+	top, ok := c.bc.Top()
+	if !ok {
+		return
+	}
+	if late := time.Duration(c.clock.Now() - top.GetHeader().GetCreatedTime()); late > c.conf.MaxClockSkew {
+		log.Printf("Clock Skew Warning: committed %v after block createdTime (max: %v), local clock may be ahead\n", late, c.conf.MaxClockSkew)
+	}
+}
+
+// sendProposal は Proposal を WAL に記録してから送る。記録できなかったときは送らない
+func (c *ConsensusStepUsecase) sendProposal(proposal model.Proposal) error {
+	if err := c.wal.WriteProposal(proposal, true); err != nil {
+		return errors.Wrapf(ErrConsensusProposal, err.Error())
+	}
+	if err := c.sender.Propose(proposal); err != nil {
+		//log.Println(err)
 	}
 	return nil
 }
 
+func (c *ConsensusStepUsecase) sendVote(vote model.VoteMessage) {
+	vote.Sign(c.conf.PublicKey, c.conf.SecretKey)
+	if err := c.wal.WriteVote(vote, true); err != nil {
+		log.Println("Consensus WAL Error!! vote is not sent", err)
+		return
+	}
+	if err := c.sender.Vote(vote); err != nil {
+		//log.Println(err)
+	}
+}
+
+// 2/3 以上の Peer が Reject した Round は PreCommit を待たずに終了する
+func (c *ConsensusStepUsecase) rejectedError(height int64, round int32) error {
+	reasons := make([]string, 0)
+	for _, vote := range c.lock.GetRejectVotes(height, round) {
+		reasons = append(reasons, fmt.Sprintf("%x: %s", vote.GetSignature().GetPubkey(), vote.GetRejectMessage()))
+	}
+	return errors.Wrapf(ErrConsensusRejected, "height: %d, round: %d, reasons: [%s]", height, round, strings.Join(reasons, ", "))
+}
+
+// PreCommit は この Round の 2/3 以上の Vote で Lock を取ったときだけ、Lock した Block に PreCommit を送る
+// 前の Round の Lock のまま PreCommit すると、その後に古い Round の Vote で Lock が移ったときに別の Block が Commit されうる
 func (c *ConsensusStepUsecase) PreCommit(height int64, round int32) error {
-	if proposal, ok := c.lock.GetLockedProposal(height); ok {
+	if c.lock.IsRejected(height, round) {
+		return c.rejectedError(height, round)
+	}
+	if proposal, ok := c.lock.GetLockedProposal(height); ok && proposal.GetRound() == round {
 		log.Println("ThisRoundPropsoal: ", fmt.Sprintf("%x", model.MustGetHash(proposal.GetBlock())))
-		vote := c.factory.NewVoteMessage(model.MustGetHash(proposal.GetBlock()))
+		vote := c.factory.NewVoteMessage(c.conf.ChainId, height, round, model.PreCommit, model.MustGetHash(proposal.GetBlock()))
 		vote.Sign(c.conf.PublicKey, c.conf.SecretKey)
+		if err := c.wal.WriteVote(vote, true); err != nil {
+			return errors.Wrapf(ErrConsensusPreCommit, err.Error())
+		}
+		c.lock.SetPreCommitted(height, round)
 		if err := c.sender.PreCommit(vote); err != nil {
 			//log.Println(err)
 		}
 	}
-	timer := time.NewTimer(c.PreCommitTimeOut - time.Duration(Now()))
+	start := time.Duration(c.clock.Now())
+	var result error
+	collected := func() bool {
+		hash, preCommits, ok := c.preCommitFinder.Get(height, round)
+		if !ok {
+			return false
+		}
+		cert, err := c.factory.NewCommitCertificate(height, round, hash, preCommits)
+		if err != nil {
+			result = errors.Wrapf(ErrConsensusPreCommit, err.Error())
+			return true
+		}
+		c.ThisRoundCertificate = cert
+		return true
+	}
+	if collected() { // Already received
+		c.observe(PhasePreCommit, start)
+		return result
+	}
+	rejected := false
+	ok := c.receive(c.PreCommitTimeOut, receiveHandler{
+		vote: func() bool {
+			rejected = c.lock.IsRejected(height, round)
+			return rejected
+		},
+		preCommit: collected,
+	})
+	if !ok {
+		return errors.Wrapf(ErrConsensusPreCommit, "This Round Can't collect 2/3+ preCommits, so try to next Round: %d -> %d", round, round+1)
+	}
+	if rejected {
+		return c.rejectedError(height, round)
+	}
+	c.observe(PhasePreCommit, start)
+	return result
+}
+
+// observe は start に始まった phase が今終わったことを LatencyMonitor に記録する
+func (c *ConsensusStepUsecase) observe(phase string, start time.Duration) {
+	c.monitor.ObservePhase(phase, time.Duration(c.clock.Now())-start)
+}
+
+// receiveHandler は受け取ったメッセージの種類ごとに、待ち受けを終わるかどうかを返す。nil のときは終わらない
+type receiveHandler struct {
+	propose   func() bool
+	vote      func() bool
+	preCommit func() bool
+}
+
+// receive は deadline まで ReceiveChannel からメッセージを受け取る。
+// 受け取ったメッセージは WAL に記録し、Proposal と PreCommit は Finder に保存してから handler を呼ぶ。handler が true を返すとその時点で true を返す。
+// deadline に達したときと Run の ctx が終わったときは false を返す
+func (c *ConsensusStepUsecase) receive(deadline time.Duration, handler receiveHandler) bool {
+	timer := c.clock.NewTimer(deadline - time.Duration(c.clock.Now()))
+	defer timer.Stop()
 	for {
+		var handle func() bool
 		select {
-		case <-timer.C:
-			return errors.Wrapf(ErrConsensusPreCommit, "This Round Can't collect 2/3+ preCommits, so try to next Round: %d -> %d", round, round+1)
-		case propose := <-c.channel.Propose:
-			c.proposalFinder.Set(propose)
-		case <-c.channel.Vote:
-			continue
+		case <-timer.C():
+			return false
+		case <-c.done:
+			return false
+		case proposal := <-c.channel.Propose:
+			c.writeWAL(c.wal.WriteProposal(proposal, false))
+			c.proposalFinder.Set(proposal)
+			c.publishLock(proposal.GetBlock().GetHeader().GetHeight())
+			handle = handler.propose
+		case vote := <-c.channel.Vote:
+			c.writeWAL(c.wal.WriteVote(vote, false))
+			c.publishLock(vote.GetHeight())
+			handle = handler.vote
 		case preCommit := <-c.channel.PreCommit:
+			c.writeWAL(c.wal.WriteVote(preCommit, false))
 			c.preCommitFinder.Set(preCommit)
-			if _, ok := c.preCommitFinder.Get(); ok {
-				return nil
-			}
+			handle = handler.preCommit
+		}
+		if handle != nil && handle() {
+			return true
 		}
 	}
 }
@@ -367,10 +990,103 @@ func (c *ConsensusStepUsecase) Commit(height int64, round int32) error {
 			"Not Found Locked Proposal")
 	}
 	block := proposal.GetBlock()
+	if c.ThisRoundCertificate == nil {
+		return errors.Wrapf(ErrConsensusCommit,
+			"Not Found CommitCertificate")
+	}
+	if hash := model.MustGetHash(block); !bytes.Equal(c.ThisRoundCertificate.GetBlockHash(), hash) {
+		return errors.Wrapf(ErrConsensusCommit,
+			"CommitCertificate blockHash: %x, Locked Proposal blockHash: %x", c.ThisRoundCertificate.GetBlockHash(), hash)
+	}
 	if err := c.sfv.Validate(block); err != nil {
 		return errors.Wrapf(ErrConsensusCommit, err.Error())
 	}
-	c.bc.Commit(block)
+	c.bc.Commit(block, c.ThisRoundCertificate)
+	if err := commitValidatorUpdates(c.conf, c.ps, block); err != nil {
+		log.Println(err)
+	}
+	c.evidences.Commit(block.GetEvidences())
+	c.clean(height)
 	log.Println("Commited Block: ", fmt.Sprintf("%x", model.MustGetHash(block)), ", txSize:", len(block.GetTransactions()))
 	return nil
 }
+
+// clean は height まで Commit されたので、height 以下の Lock, PreCommit, WAL の記録を消す
+func (c *ConsensusStepUsecase) clean(height int64) {
+	c.lock.Clean(height + 1)
+	c.preCommitFinder.Clean(height + 1)
+	if err := c.wal.Clean(height + 1); err != nil {
+		log.Println("Consensus WAL Error!!", err)
+	}
+}
+
+// publishBlocks は publishedHeight より後に Commit された Block と、その Transaction の Event を publish する
+func (c *ConsensusStepUsecase) publishBlocks() {
+	top, ok := c.bc.Top()
+	if !ok {
+		return
+	}
+	for height := c.publishedHeight + 1; height <= top.GetHeader().GetHeight(); height++ {
+		block, ok := c.bc.GetBlock(height)
+		if !ok {
+			return
+		}
+		round := int32(0)
+		if cert, ok := c.bc.GetCommitCertificate(height); ok && cert != nil {
+			round = cert.GetRound()
+		}
+		c.bus.Publish(&Event{Type: EventNewBlock, Height: height, Round: round, Hash: model.MustGetHash(block), Block: block})
+		for _, tx := range block.GetTransactions() {
+			c.bus.Publish(&Event{Type: EventTx, Height: height, Round: round, Hash: model.MustGetHash(tx), Tx: tx})
+		}
+		c.publishedHeight = height
+	}
+}
+
+// publishLock は height で Lock を取った Proposal が最後に publish したものから変わっていれば EventLock を publish する
+func (c *ConsensusStepUsecase) publishLock(height int64) {
+	locked, ok := c.lock.GetLockedProposal(height)
+	if !ok || (c.lockedHeight == height && c.lockedRound == locked.GetRound()) {
+		return
+	}
+	c.lockedHeight, c.lockedRound = height, locked.GetRound()
+	c.bus.Publish(&Event{Type: EventLock, Height: height, Round: locked.GetRound(), Hash: model.MustGetHash(locked.GetBlock()), Block: locked.GetBlock()})
+}
+
+func (c *ConsensusStepUsecase) writeWAL(err error) {
+	if err != nil {
+		log.Println("Consensus WAL Error!!", err)
+	}
+}
+
+// Replay は WAL の記録を読み直し、Proposal と Vote を Lock に、受け取った Proposal と PreCommit を Finder に戻す。
+// 記録した最後の Round の Height と Round を返す。Round の記録が無いときは 0, -1 を返す。
+// 自分が送った PreCommit は自分にも届いて記録されているので、二重に数えないよう Finder には戻さない
+func (c *ConsensusStepUsecase) Replay() (int64, int32, error) {
+	records, err := c.wal.ReadAll()
+	if err != nil {
+		return 0, -1, errors.Wrapf(ErrConsensusReplay, err.Error())
+	}
+	height, round := int64(0), int32(-1)
+	for _, record := range records {
+		switch record.GetType() {
+		case model.WALRound:
+			if record.GetHeight() > height || (record.GetHeight() == height && record.GetRound() > round) {
+				height, round = record.GetHeight(), record.GetRound()
+			}
+		case model.WALProposal:
+			c.proposalFinder.Set(record.GetProposal())
+			c.lock.RegisterProposal(record.GetProposal())
+		case model.WALVote:
+			c.lock.AddVoteMessage(record.GetVote())
+		case model.WALPreCommit:
+			if record.IsSent() {
+				c.lock.SetPreCommitted(record.GetHeight(), record.GetRound())
+			} else {
+				c.preCommitFinder.Set(record.GetVote())
+			}
+		}
+	}
+	log.Println("Replayed Consensus WAL, records:", len(records), "height:", height, "round:", round)
+	return height, round, nil
+}
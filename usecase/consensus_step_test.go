@@ -5,6 +5,8 @@ import (
 	"testing"
 
 	"bytes"
+	"context"
+	"fmt"
 	"github.com/pkg/errors"
 	"github.com/satellitex/bbft/config"
 	"github.com/satellitex/bbft/convertor"
@@ -13,6 +15,8 @@ import (
 	. "github.com/satellitex/bbft/usecase"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"math"
+	"strings"
 	"sync"
 	"time"
 )
@@ -77,50 +81,159 @@ func TestPreCommitFinder(t *testing.T) {
 	for i := 0; i < 4; i++ {
 		ps.AddPeer(RandomPeerWithPriv())
 	}
+	peers := ps.GetPeers()
 
 	finder := NewPreCommitFinder(ps, conf)
 	t.Run("success", func(t *testing.T) {
-		err := finder.Set(RandomVoteMessage(t))
+		err := finder.Set(RandomPreCommit(t))
 		assert.NoError(t, err)
-		_, ok := finder.Get()
+		_, _, ok := finder.Get(0, 0)
 		assert.False(t, ok)
 	})
 
 	t.Run("failed nil vote", func(t *testing.T) {
 		err := finder.Set(nil)
 		assert.EqualError(t, errors.Cause(err), model.ErrInvalidVoteMessage.Error())
-		_, ok := finder.Get()
+		_, _, ok := finder.Get(0, 0)
 		assert.False(t, ok)
 	})
 
 	t.Run("many set", func(t *testing.T) {
 		for i := 0; i < conf.PreCommitFinderLimits*2; i++ {
-			err := finder.Set(RandomVoteMessage(t))
+			err := finder.Set(RandomPreCommit(t))
 			assert.NoError(t, err)
 		}
 	})
 
+	t.Run("received preCommits are bounded by limit", func(t *testing.T) {
+		finder := NewPreCommitFinder(ps, conf)
+		for i := 0; i < conf.PreCommitFinderLimits*2; i++ {
+			vote := NewTestVoteMessage(model.PreCommit, int64(i), int32(i), RandomByte())
+			require.NoError(t, vote.Sign(peers[0].GetPubkey(), peers[0].(*PeerWithPriv).PrivKey))
+			require.NoError(t, finder.Set(vote))
+		}
+		for i := 0; i < conf.PreCommitFinderLimits; i++ {
+			assert.Empty(t, finder.GetPreCommits(int64(i), int32(i)))
+		}
+		for i := conf.PreCommitFinderLimits; i < conf.PreCommitFinderLimits*2; i++ {
+			assert.Len(t, finder.GetPreCommits(int64(i), int32(i)), 1)
+		}
+	})
+
 	t.Run("collec Get", func(t *testing.T) {
-		vote := RandomVoteMessage(t)
+		hash := RandomByte()
+		votes := make([]model.VoteMessage, 0, 3)
+		for i := 0; i < 3; i++ {
+			vote := NewTestVoteMessage(model.PreCommit, 0, 0, hash)
+			require.NoError(t, vote.Sign(peers[i].GetPubkey(), peers[i].(*PeerWithPriv).PrivKey))
+			votes = append(votes, vote)
+		}
 		for i := 0; i < 2; i++ {
-			assert.NoError(t, finder.Set(vote))
-			_, ok := finder.Get()
+			assert.NoError(t, finder.Set(votes[i]))
+			_, _, ok := finder.Get(0, 0)
 			assert.False(t, ok)
 		}
-		assert.NoError(t, finder.Set(vote))
-		hash, ok := finder.Get()
+		assert.NoError(t, finder.Set(votes[2]))
+		actual, preCommits, ok := finder.Get(0, 0)
 		assert.True(t, ok)
 
-		assert.Equal(t, vote.GetBlockHash(), hash)
+		assert.Equal(t, hash, actual)
+		assert.Equal(t, votes, preCommits)
+
+		_, _, ok = finder.Get(0, 0)
+		assert.False(t, ok)
+	})
 
-		_, ok = finder.Get()
+	t.Run("same peer's preCommits are counted once", func(t *testing.T) {
+		hash := RandomByte()
+		for i := 0; i < 10; i++ {
+			vote := NewTestVoteMessage(model.PreCommit, 3, 0, hash)
+			vote.(*convertor.VoteMessage).RejectMessage = fmt.Sprintf("tag%d", i)
+			require.NoError(t, vote.Sign(peers[0].GetPubkey(), peers[0].(*PeerWithPriv).PrivKey))
+			assert.NoError(t, finder.Set(vote))
+		}
+		_, _, ok := finder.Get(3, 0)
 		assert.False(t, ok)
+
+		for i := 1; i < 3; i++ {
+			vote := NewTestVoteMessage(model.PreCommit, 3, 0, hash)
+			require.NoError(t, vote.Sign(peers[i].GetPubkey(), peers[i].(*PeerWithPriv).PrivKey))
+			assert.NoError(t, finder.Set(vote))
+		}
+		_, preCommits, ok := finder.Get(3, 0)
+		assert.True(t, ok)
+		assert.Len(t, preCommits, 3)
+	})
+
+	t.Run("failed not PreCommit type", func(t *testing.T) {
+		err := finder.Set(RandomVoteMessage(t))
+		assert.EqualError(t, errors.Cause(err), model.ErrInvalidVoteMessage.Error())
+	})
+
+	t.Run("collect Get only matching height and round", func(t *testing.T) {
+		hash := RandomByte()
+		for round := int32(0); round < 3; round++ {
+			vote := NewTestVoteMessage(model.PreCommit, 1, round, hash)
+			require.NoError(t, vote.Sign(peers[0].GetPubkey(), peers[0].(*PeerWithPriv).PrivKey))
+			assert.NoError(t, finder.Set(vote))
+		}
+		_, _, ok := finder.Get(1, 0)
+		assert.False(t, ok)
+
+		for i := 1; i < 3; i++ {
+			vote := NewTestVoteMessage(model.PreCommit, 1, 2, hash)
+			require.NoError(t, vote.Sign(peers[i].GetPubkey(), peers[i].(*PeerWithPriv).PrivKey))
+			assert.NoError(t, finder.Set(vote))
+		}
+		_, _, ok = finder.Get(1, 1)
+		assert.False(t, ok)
+		actual, preCommits, ok := finder.Get(1, 2)
+		assert.True(t, ok)
+		assert.Equal(t, hash, actual)
+		assert.Equal(t, 3, len(preCommits))
+
+		// 集まった後も受け取った PreCommit は残り、Clean すると消える
+		assert.Equal(t, preCommits, finder.GetPreCommits(1, 2))
+		assert.Len(t, finder.GetPreCommits(1, 0), 1)
+		finder.Clean(2)
+		assert.Empty(t, finder.GetPreCommits(1, 2))
+		assert.Empty(t, finder.GetPreCommits(1, 0))
+	})
+
+	t.Run("collect Get by voting power", func(t *testing.T) {
+		ps := dba.NewPeerServiceOnMemory()
+		heavy := RandomPeerWithPower(5)
+		ps.AddPeer(heavy)
+		for i := 0; i < 3; i++ {
+			ps.AddPeer(RandomPeerWithPriv())
+		}
+		// total 8, required 5
+		finder := NewPreCommitFinder(ps, conf)
+		hash := RandomByte()
+		for _, peer := range ps.GetPeers() {
+			if peer == heavy {
+				continue
+			}
+			vote := NewTestVoteMessage(model.PreCommit, 1, 0, hash)
+			require.NoError(t, vote.Sign(peer.GetPubkey(), peer.(*PeerWithPriv).PrivKey))
+			require.NoError(t, finder.Set(vote))
+		}
+		_, _, ok := finder.Get(1, 0)
+		assert.False(t, ok)
+
+		vote := NewTestVoteMessage(model.PreCommit, 2, 0, hash)
+		require.NoError(t, vote.Sign(heavy.GetPubkey(), heavy.(*PeerWithPriv).PrivKey))
+		require.NoError(t, finder.Set(vote))
+		actual, preCommits, ok := finder.Get(2, 0)
+		assert.True(t, ok)
+		assert.Equal(t, hash, actual)
+		assert.Equal(t, []model.VoteMessage{vote}, preCommits)
 	})
 
 }
 
 func NewTestConsensusStepUsecase(t *testing.T) (*config.BBFTConfig, dba.BlockChain, dba.PeerService, dba.Lock,
-	dba.ProposalTxQueue, model.ConsensusSender, *ReceiveChannel, ConsensusStep) {
+	dba.ProposalTxQueue, dba.EvidencePool, model.ConsensusSender, *ReceiveChannel, ConsensusStep) {
 
 	conf := GetTestConfig()
 	bc := dba.NewBlockChainOnMemory()
@@ -128,37 +241,82 @@ func NewTestConsensusStepUsecase(t *testing.T) (*config.BBFTConfig, dba.BlockCha
 	lock := dba.NewLockOnMemory(ps, conf)
 	queue := dba.NewProposalTxQueueOnMemory(conf)
 	sender := convertor.NewMockConsensusSender()
-	slv := convertor.NewStatelessValidator()
-	sfv := convertor.NewStatefulValidator(bc)
+	slv := convertor.NewStatelessValidator(conf)
+	sfv := convertor.NewStatefulValidator(conf, bc, ps, NewLeaderSelector(conf, ps, bc))
 	factory := convertor.NewModelFactory()
+	syncer := NewBlockSyncUsecase(conf, bc, ps, slv, sfv, convertor.NewCommitCertificateValidator(conf, ps), convertor.NewMockBlockSyncSender(bc))
 	channel := NewReceiveChannel(conf)
+	evidences := dba.NewEvidencePoolOnMemory(conf)
 
 	//First Commit
-	bc.Commit(RandomCommitableBlock(t, bc))
+	bc.Commit(RandomCommitableBlock(t, bc), nil)
 
 	ps.AddPeer(&PeerWithPriv{
-		&convertor.Peer{"myself", conf.PublicKey},
+		&convertor.Peer{"myself", conf.PublicKey, 1},
 		conf.SecretKey,
 	})
 	ps.AddPeer(RandomPeerWithPriv())
 	ps.AddPeer(RandomPeerWithPriv())
 	ps.AddPeer(RandomPeerWithPriv())
 
-	consensusStep := NewConsensusStepUsecase(conf, bc, ps, lock, queue, sender, slv, sfv, factory, channel)
-	return conf, bc, ps, lock, queue, sender, channel, consensusStep
+	consensusStep := NewConsensusStepUsecase(conf, bc, ps, NewLeaderSelector(conf, ps, bc), lock, queue, evidences, sender, slv, sfv,
+		convertor.NewEvidenceValidator(conf, ps), factory, syncer, dba.NewWALOnMemory(), NewEventBusOnMemory(conf),
+		NewLatencyMonitorUsecase(conf, ps, sender, factory), NewRealClock(), channel)
+	return conf, bc, ps, lock, queue, evidences, sender, channel, consensusStep
 }
 
-func mySelfId(conf *config.BBFTConfig, ps dba.PeerService, height int64) int32 {
-	for id, p := range ps.GetPermutationPeers(height) {
-		if bytes.Equal(p.GetPubkey(), conf.PublicKey) {
-			return int32(id)
+func mySelfId(conf *config.BBFTConfig, bc dba.BlockChain, ps dba.PeerService, height int64) int32 {
+	selector := NewLeaderSelector(conf, ps, bc)
+	for round := int32(0); round < int32(ps.Size()); round++ {
+		if p, ok := selector.GetLeader(height, round); ok && bytes.Equal(p.GetPubkey(), conf.PublicKey) {
+			return round
 		}
 	}
 	return 0
 }
 
+func mySelf(conf *config.BBFTConfig, ps dba.PeerService) model.Peer {
+	peer, _ := ps.GetPeer(conf.PublicKey)
+	return peer
+}
+
+func TestBackoff(t *testing.T) {
+	conf := GetTestConfig()
+	conf.RoundBackoffMaxTime = 10 * time.Second
+	base := time.Second
+
+	for _, c := range []struct {
+		backoff  string
+		base     time.Duration
+		round    int32
+		expected time.Duration
+	}{
+		{RoundBackoffNone, base, 0, base},
+		{RoundBackoffNone, base, 100, base},
+		{RoundBackoffLinear, base, 0, base},
+		{RoundBackoffLinear, base, 1, 2 * base},
+		{RoundBackoffLinear, base, 4, 5 * base},
+		{RoundBackoffLinear, base, 9, 10 * base},
+		{RoundBackoffLinear, base, 10, 10 * base},
+		{RoundBackoffLinear, base, math.MaxInt32, 10 * base},
+		{RoundBackoffExponential, base, 0, base},
+		{RoundBackoffExponential, base, 1, 2 * base},
+		{RoundBackoffExponential, base, 3, 8 * base},
+		{RoundBackoffExponential, base, 4, 10 * base},
+		{RoundBackoffExponential, base, math.MaxInt32, 10 * base},
+		{RoundBackoffExponential, 20 * time.Second, 3, 20 * time.Second},
+		{RoundBackoffExponential, 0, 3, 0},
+		{"unknown", base, 3, base},
+	} {
+		t.Run(fmt.Sprintf("%s base %v round %d", c.backoff, c.base, c.round), func(t *testing.T) {
+			conf.RoundBackoff = c.backoff
+			assert.Equal(t, c.expected, Backoff(conf, c.base, c.round))
+		})
+	}
+}
+
 func TestConsensusStepUsecase_Propose(t *testing.T) {
-	conf, bc, ps, lock, queue, sender, channel, c := NewTestConsensusStepUsecase(t)
+	conf, bc, ps, lock, queue, evidences, sender, channel, c := NewTestConsensusStepUsecase(t)
 	factory := convertor.NewModelFactory()
 
 	top, ok := bc.Top()
@@ -167,7 +325,7 @@ func TestConsensusStepUsecase_Propose(t *testing.T) {
 	var height int64 = 1
 
 	// myselfId is Round when myself is Leader
-	myselfId := mySelfId(conf, ps, height)
+	myselfId := mySelfId(conf, bc, ps, height)
 
 	t.Run("leader case", func(t *testing.T) {
 		validTx := RandomValidTx(t)
@@ -182,7 +340,7 @@ func TestConsensusStepUsecase_Propose(t *testing.T) {
 		assert.NoError(t, err)
 
 		tmp, err := factory.NewBlock(height, GetHash(t, top),
-			int64(c.(*ConsensusStepUsecase).RoundCommitTime), []model.Transaction{validTx})
+			int64(c.(*ConsensusStepUsecase).RoundCommitTime), []model.Transaction{validTx}, nil, CommitableChainState(t, bc, ps, conf.PublicKey))
 		tmp.Sign(conf.PublicKey, conf.SecretKey)
 		require.NoError(t, err)
 		expectedProposal, err := factory.NewProposal(tmp, myselfId)
@@ -192,6 +350,44 @@ func TestConsensusStepUsecase_Propose(t *testing.T) {
 		assert.Equal(t, expectedProposal, c.(*ConsensusStepUsecase).ThisRoundProposal)
 	})
 
+	t.Run("leader case, block has pending evidences", func(t *testing.T) {
+		expected := []model.Evidence{RandomEvidence(t), RandomEvidence(t)}
+		for _, evidence := range expected {
+			require.NoError(t, evidences.Add(evidence))
+		}
+		c.(*ConsensusStepUsecase).ThisRoundProposal = nil
+		err := c.Propose(height, myselfId+int32(ps.Size()))
+		assert.NoError(t, err)
+
+		proposal := c.(*ConsensusStepUsecase).ThisRoundProposal
+		require.NotNil(t, proposal)
+		assert.Equal(t, expected, proposal.GetBlock().GetEvidences())
+		assert.NoError(t, proposal.GetBlock().Verify())
+		evidences.Commit(expected)
+	})
+
+	t.Run("leader case, round over number of peers", func(t *testing.T) {
+		round := myselfId + int32(ps.Size())*2
+		c.(*ConsensusStepUsecase).ThisRoundProposal = nil
+		err := c.Propose(height, round)
+		assert.NoError(t, err)
+
+		proposal := c.(*ConsensusStepUsecase).ThisRoundProposal
+		require.NotNil(t, proposal)
+		assert.Equal(t, round, proposal.GetRound())
+		assert.Equal(t, proposal, sender.(*convertor.MockConsensusSender).Proposal)
+	})
+
+	t.Run("timeOut case not leader, round over number of peers", func(t *testing.T) {
+		startTime := Now()
+		c.(*ConsensusStepUsecase).ProposeTimeOut = time.Duration(Now()) + TimeParseDuration(t, "50ms")
+		err := c.Propose(height, (myselfId+1)%4+int32(ps.Size())*5)
+		endTime := Now()
+
+		assert.NoError(t, err)
+		assert.True(t, TimeParseDuration(t, "50ms") < time.Duration(endTime-startTime))
+	})
+
 	t.Run("timeOut case not leader ", func(t *testing.T) {
 		startTime := Now()
 		c.(*ConsensusStepUsecase).ProposeTimeOut = time.Duration(Now()) + TimeParseDuration(t, "200ms")
@@ -208,21 +404,28 @@ func TestConsensusStepUsecase_Propose(t *testing.T) {
 		c.(*ConsensusStepUsecase).ProposeTimeOut = time.Duration(Now()) + conf.ProposeMaxCalcTime + conf.AllowedConnectDelayTime
 
 		expectedProposal := RandomProposalWithHeightRound(t, height, int32((myselfId+2)%4))
+		waiter := &sync.WaitGroup{}
+		waiter.Add(1)
 		go func() {
+			defer waiter.Done()
 			err := c.Propose(height, int32((myselfId+2)%4))
 			assert.NoError(t, err)
 			assert.Equal(t, expectedProposal, c.(*ConsensusStepUsecase).ThisRoundProposal)
 		}()
 		channel.Propose <- expectedProposal
+		waiter.Wait()
 	})
 
 	t.Run("not leader get vote locked case", func(t *testing.T) {
 		c.(*ConsensusStepUsecase).ProposeTimeOut = time.Duration(Now()) + conf.ProposeMaxCalcTime + conf.AllowedConnectDelayTime
 
-		expectedProposal := RandomProposalWithHeightRound(t, height, int32((myselfId+2)%4))
+		expectedProposal := RandomProposalWithHeightRound(t, height, int32((myselfId+3)%4))
 		lock.RegisterProposal(expectedProposal)
 
+		waiter := &sync.WaitGroup{}
+		waiter.Add(1)
 		go func() {
+			defer waiter.Done()
 			err := c.Propose(height, int32((myselfId+3)%4))
 			assert.NoError(t, err)
 			actual, ok := lock.GetLockedProposal(height)
@@ -230,18 +433,304 @@ func TestConsensusStepUsecase_Propose(t *testing.T) {
 			assert.Equal(t, expectedProposal, actual)
 		}()
 		for _, p := range ps.GetPeers()[1:] {
-			vote := RandomVoteMessageFromPeerWithBlock(t, p, expectedProposal.GetBlock())
+			vote := VoteMessageFromPeerWithBlockRound(t, model.PreVote, p, expectedProposal.GetBlock(), expectedProposal.GetRound())
 			require.NoError(t, lock.AddVoteMessage(vote))
 			channel.Vote <- vote
 		}
 		actualProposal, ok := lock.GetLockedProposal(height)
 		require.True(t, ok)
 		require.Equal(t, expectedProposal, actualProposal)
+		waiter.Wait()
+	})
+
+	t.Run("leader case, locked leader re-proposes locked block", func(t *testing.T) {
+		locked := RandomProposalWithHeightRound(t, height, int32(ps.Size()))
+		require.NoError(t, lock.RegisterProposal(locked))
+		for _, p := range ps.GetPeers()[1:] {
+			require.NoError(t, lock.AddVoteMessage(VoteMessageFromPeerWithBlockRound(t, model.PreVote, p, locked.GetBlock(), locked.GetRound())))
+		}
+		lockedRound, ok := lock.GetLockedRound(height)
+		require.True(t, ok)
+		require.Equal(t, locked.GetRound(), lockedRound)
+		round := myselfId + int32(ps.Size())*3
+
+		c.(*ConsensusStepUsecase).ThisRoundProposal = nil
+		require.NoError(t, c.Propose(height, round))
+
+		proposal := c.(*ConsensusStepUsecase).ThisRoundProposal
+		require.NotNil(t, proposal)
+		assert.Equal(t, round, proposal.GetRound())
+		assert.Equal(t, GetHash(t, locked.GetBlock()), GetHash(t, proposal.GetBlock()))
+		assert.Equal(t, locked.GetBlock().GetSignature(), proposal.GetBlock().GetSignature())
+		assert.NoError(t, proposal.GetBlock().Verify())
+		assert.Equal(t, locked.GetRound(), proposal.GetPOLRound())
+		assert.Equal(t, conf.PublicKey, proposal.GetSignature().GetPubkey())
+		assert.NoError(t, proposal.Verify())
+		assert.Equal(t, proposal, sender.(*convertor.MockConsensusSender).Proposal)
+	})
+}
+
+func TestConsensusStepUsecase_ProposeEmptyBlock(t *testing.T) {
+	conf, bc, ps, _, queue, _, sender, channel, c := NewTestConsensusStepUsecase(t)
+	step := c.(*ConsensusStepUsecase)
+	conf.CreateEmptyBlocks = false
+
+	top, ok := bc.Top()
+	require.True(t, ok)
+	var height int64 = 1
+	myselfId := mySelfId(conf, bc, ps, height)
+	// roundLength は提案の開始から RoundCommitTime までの時間
+	roundLength := func(round int32) time.Duration {
+		return Backoff(conf, conf.ProposeMaxCalcTime, round) + Backoff(conf, conf.VoteMaxCalcTime, round) +
+			Backoff(conf, conf.PreCommitMaxCalcTime, round) + 3*conf.AllowedConnectDelayTime + conf.CommitMaxCalcTime
+	}
+
+	// idle は start に始まり、Leader が最大 d だけ Transaction を待つ Round を設定する
+	idle := func(start, d time.Duration) time.Duration {
+		step.RoundStartTime, step.IdleTimeOut = start, start+d
+		step.ProposeTimeOut = time.Duration(Now()) + d + conf.ProposeMaxCalcTime + conf.AllowedConnectDelayTime
+		step.ThisRoundProposal = nil
+		return start
+	}
+
+	t.Run("leader waits for interval, then proposes empty block", func(t *testing.T) {
+		start := idle(time.Duration(Now()), 200*time.Millisecond)
+		require.NoError(t, c.Propose(height, myselfId))
+
+		assert.True(t, time.Duration(Now())-start >= 200*time.Millisecond)
+		proposal := step.ThisRoundProposal
+		require.NotNil(t, proposal)
+		assert.Empty(t, proposal.GetBlock().GetTransactions())
+		assert.Equal(t, int64(step.IdleTimeOut+roundLength(myselfId)), proposal.GetBlock().GetHeader().GetCreatedTime())
+	})
+
+	t.Run("leader proposes as soon as transaction arrives", func(t *testing.T) {
+		start := idle(time.Duration(Now()), 5*time.Second)
+		tx := RandomValidTx(t)
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			queue.Push(tx)
+		}()
+		require.NoError(t, c.Propose(height, myselfId))
+
+		assert.True(t, time.Duration(Now())-start < time.Second)
+		proposal := step.ThisRoundProposal
+		require.NotNil(t, proposal)
+		assert.Equal(t, []model.Transaction{tx}, proposal.GetBlock().GetTransactions())
+		assert.True(t, time.Duration(proposal.GetBlock().GetHeader().GetCreatedTime()) < step.IdleTimeOut+roundLength(myselfId))
+		assert.Equal(t, time.Duration(proposal.GetBlock().GetHeader().GetCreatedTime()), step.RoundCommitTime)
+	})
+
+	t.Run("leader does not wait without idle time", func(t *testing.T) {
+		start := idle(time.Duration(Now()), 0)
+		require.NoError(t, c.Propose(height, myselfId+int32(ps.Size())))
+		assert.True(t, time.Duration(Now())-start < 100*time.Millisecond)
+		require.NotNil(t, step.ThisRoundProposal)
+	})
+
+	for i, cc := range []struct {
+		name   string
+		delay  time.Duration
+		reject bool
+	}{
+		{"follower accepts proposal delayed by leader", 300 * time.Millisecond, false},
+		{"follower rejects proposal delayed over interval", time.Second + conf.MaxClockSkew + time.Millisecond, true},
+	} {
+		round := (myselfId+1)%int32(ps.Size()) + int32(i*ps.Size())
+		t.Run(cc.name, func(t *testing.T) {
+			start := idle(time.Duration(top.GetHeader().GetCreatedTime()), time.Second)
+			block, err := convertor.NewModelFactory().NewBlock(height, GetHash(t, top), int64(start+cc.delay+roundLength(round)), RandomValidTxs(t), nil,
+				CommitableChainState(t, bc, ps, ps.GetPeers()[0].GetPubkey()))
+			require.NoError(t, err)
+			require.NoError(t, block.Sign(ps.GetPeers()[0].GetPubkey(), ps.GetPeers()[0].(*PeerWithPriv).PrivKey))
+			proposal, err := convertor.NewModelFactory().NewProposal(block, round)
+			require.NoError(t, err)
+
+			channel.Propose <- proposal
+			require.NoError(t, c.Propose(height, round))
+			require.Equal(t, proposal, step.ThisRoundProposal)
+
+			step.VoteTimeOut = time.Duration(Now())
+			require.NoError(t, c.Vote(height, round))
+			vote := sender.(*convertor.MockConsensusSender).VoteMessage
+			require.NotNil(t, vote)
+			assert.Equal(t, cc.reject, vote.IsReject())
+			if !cc.reject {
+				assert.Equal(t, time.Duration(block.GetHeader().GetCreatedTime()), step.RoundCommitTime)
+			}
+		})
+	}
+}
+
+func TestConsensusStepUsecase_ProposeCommittedEvidence(t *testing.T) {
+	conf, bc, ps, _, _, evidences, _, _, c := NewTestConsensusStepUsecase(t)
+	step := c.(*ConsensusStepUsecase)
+
+	// BlockSync で取得した Block に含まれる Evidence は EvidencePool では Commit されていない
+	committed, pending := RandomEvidence(t), RandomEvidence(t)
+	require.NoError(t, evidences.Add(committed))
+	require.NoError(t, evidences.Add(pending))
+	block := RandomCommitableBlockFromPeer(t, bc, ps, ps.GetPeers()[0])
+	block.(*convertor.Block).Evidences = append(block.(*convertor.Block).Evidences, committed.(*convertor.Evidence).Evidence)
+	bc.Commit(block, RandomCommitCertificate(t, block, ps.GetPeers()))
+
+	var height int64 = 2
+	step.RoundCommitTime = time.Duration(Now())
+	require.NoError(t, c.Propose(height, mySelfId(conf, bc, ps, height)))
+
+	proposal := step.ThisRoundProposal
+	require.NotNil(t, proposal)
+	assert.Equal(t, []model.Evidence{pending}, proposal.GetBlock().GetEvidences())
+	assert.Equal(t, []model.Evidence{pending}, evidences.GetPendings(conf.NumberOfBlockHasEvidences))
+	assert.True(t, evidences.IsExist(committed))
+}
+
+func TestConsensusStepUsecase_ProposeBlockLimits(t *testing.T) {
+	conf, bc, ps, _, queue, _, _, _, c := NewTestConsensusStepUsecase(t)
+	step := c.(*ConsensusStepUsecase)
+	factory := convertor.NewModelFactory()
+
+	top, ok := bc.Top()
+	require.True(t, ok)
+	var height int64 = 1
+	myselfId := mySelfId(conf, bc, ps, height)
+	step.RoundCommitTime = time.Duration(Now())
+
+	// round は自分が Leader の i 番目の Round を返す
+	round := func(i int) int32 {
+		return myselfId + int32(i*ps.Size())
+	}
+	// propose は自分が Leader の i 番目の Round で提案した Block の Transaction を返す
+	propose := func(t *testing.T, i int) []model.Transaction {
+		step.ThisRoundProposal = nil
+		require.NoError(t, c.Propose(height, round(i)))
+		require.NotNil(t, step.ThisRoundProposal)
+		return step.ThisRoundProposal.GetBlock().GetTransactions()
+	}
+	// blockSize は i 番目の Round で txs を入れた Block の大きさを返す
+	blockSize := func(t *testing.T, i int, txs ...model.Transaction) int {
+		state := CommitableChainState(t, bc, ps, conf.PublicKey)
+		state.(*convertor.ChainState).Round = round(i)
+		block, err := factory.NewBlock(height, GetHash(t, top), int64(step.RoundCommitTime), txs, nil, state)
+		require.NoError(t, err)
+		require.NoError(t, block.Sign(conf.PublicKey, conf.SecretKey))
+		return block.GetSize()
+	}
+	drain := func() {
+		for _, ok := queue.Pop(); ok; _, ok = queue.Pop() {
+		}
+	}
+	defer func(maxBytes int, maxCost int64) {
+		conf.MaxBlockBytes, conf.MaxBlockCost = maxBytes, maxCost
+	}(conf.MaxBlockBytes, conf.MaxBlockCost)
+
+	t.Run("txs over max block bytes are left in queue", func(t *testing.T) {
+		defer drain()
+		txs := []model.Transaction{RandomValidTx(t), RandomValidTx(t), RandomValidTx(t)}
+		for _, tx := range txs {
+			require.NoError(t, queue.Push(tx))
+		}
+		conf.MaxBlockBytes = blockSize(t, 0, txs[:2]...)
+
+		assert.Equal(t, txs[:2], propose(t, 0))
+		left, ok := queue.Peek()
+		require.True(t, ok)
+		assert.Equal(t, txs[2], left)
+		assert.Equal(t, 1, queue.Len())
+	})
+
+	t.Run("tx never fits in block is dropped", func(t *testing.T) {
+		defer drain()
+		validPub, validPriv := convertor.NewKeyPair()
+		large, err := convertor.NewTxModelBuilder().Message(strings.Repeat("a", 512)).Sign(validPub, validPriv).Build()
+		require.NoError(t, err)
+		small := RandomValidTx(t)
+		require.NoError(t, queue.Push(large))
+		require.NoError(t, queue.Push(small))
+		conf.MaxBlockBytes = blockSize(t, 1, small)
+
+		assert.Equal(t, []model.Transaction{small}, propose(t, 1))
+		assert.Equal(t, 0, queue.Len())
+	})
+
+	t.Run("txs over max block cost are left in queue", func(t *testing.T) {
+		defer drain()
+		txs := []model.Transaction{RandomValidTxWithCost(t, 2), RandomValidTxWithCost(t, 3), RandomValidTxWithCost(t, 4)}
+		for _, tx := range txs {
+			require.NoError(t, queue.Push(tx))
+		}
+		conf.MaxBlockBytes = blockSize(t, 2, txs...)
+		conf.MaxBlockCost = 6
+
+		assert.Equal(t, txs[:2], propose(t, 2))
+		assert.Equal(t, 1, queue.Len())
+		assert.NoError(t, convertor.NewStatelessValidator(conf).BlockValidate(step.ThisRoundProposal.GetBlock()))
+	})
+}
+
+func TestConsensusStepUsecase_ProposeTiming(t *testing.T) {
+	conf, bc, ps, lock, _, _, sender, channel, c := NewTestConsensusStepUsecase(t)
+	step := c.(*ConsensusStepUsecase)
+	conf.AdaptiveTimeout = true
+
+	top, ok := bc.Top()
+	require.True(t, ok)
+	var height int64 = 1
+	myselfId := mySelfId(conf, bc, ps, height)
+
+	t.Run("follower observes propose phase", func(t *testing.T) {
+		round := (myselfId + 1) % int32(ps.Size())
+		now := time.Duration(Now())
+		step.RoundStartTime, step.IdleTimeOut, step.ProposeTimeOut = now, now, now+time.Second
+		block, err := convertor.NewModelFactory().NewBlock(height, GetHash(t, top), int64(now), RandomValidTxs(t), nil, CommitableChainState(t, bc, ps, ps.GetPeers()[0].GetPubkey()))
+		require.NoError(t, err)
+		require.NoError(t, block.Sign(ps.GetPeers()[0].GetPubkey(), ps.GetPeers()[0].(*PeerWithPriv).PrivKey))
+		proposal, err := convertor.NewModelFactory().NewProposal(block, round)
+		require.NoError(t, err)
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			channel.Propose <- proposal
+		}()
+		require.NoError(t, c.Propose(height, round))
+		require.Equal(t, proposal, step.ThisRoundProposal)
+	})
+
+	t.Run("leader records observed timing in block", func(t *testing.T) {
+		step.RoundCommitTime = time.Duration(Now())
+		require.NoError(t, c.Propose(height, myselfId))
+
+		block := sender.(*convertor.MockConsensusSender).Proposal.GetBlock()
+		timing := block.GetHeader().GetTiming()
+		assert.True(t, timing.GetPropose() >= int64(50*time.Millisecond))
+		assert.Equal(t, int64(0), timing.GetConnectDelay())
+		assert.NoError(t, convertor.NewStatelessValidator(conf).BlockValidate(block))
+	})
+
+	t.Run("locked leader keeps timing when re-proposing", func(t *testing.T) {
+		factory := convertor.NewModelFactory()
+		block, err := factory.NewTimedBlock(height, GetHash(t, top), Now(), RandomValidTxs(t), nil,
+			CommitableChainState(t, bc, ps, ps.GetPeers()[0].GetPubkey()), factory.NewPhaseTiming(1, 2, 3, 4))
+		require.NoError(t, err)
+		require.NoError(t, block.Sign(ps.GetPeers()[0].GetPubkey(), ps.GetPeers()[0].(*PeerWithPriv).PrivKey))
+		locked, err := factory.NewProposal(block, int32(ps.Size()))
+		require.NoError(t, err)
+		require.NoError(t, lock.RegisterProposal(locked))
+		for _, p := range ps.GetPeers()[1:] {
+			require.NoError(t, lock.AddVoteMessage(VoteMessageFromPeerWithBlockRound(t, model.PreVote, p, block, locked.GetRound())))
+		}
+
+		round := myselfId + int32(ps.Size())*2
+		require.NoError(t, c.Propose(height, round))
+		proposal := step.ThisRoundProposal
+		require.NotNil(t, proposal)
+		assert.Equal(t, round, proposal.GetRound())
+		assert.Equal(t, GetHash(t, block), GetHash(t, proposal.GetBlock()))
 	})
 }
 
 func TestConsensusStepUsecase_Vote(t *testing.T) {
-	conf, bc, ps, lock, _, sender, channel, c := NewTestConsensusStepUsecase(t)
+	conf, bc, ps, lock, _, _, sender, channel, c := NewTestConsensusStepUsecase(t)
 	factory := convertor.NewModelFactory()
 
 	_, ok := bc.Top()
@@ -249,9 +738,6 @@ func TestConsensusStepUsecase_Vote(t *testing.T) {
 
 	var height int64 = 1
 
-	// myselfId is Round when myself is Leader
-	myselfId := mySelfId(conf, ps, height)
-
 	t.Run("normal case, voteTimeOut", func(t *testing.T) {
 		c.(*ConsensusStepUsecase).VoteTimeOut = time.Duration(Now()) + TimeParseDuration(t, "200ms")
 
@@ -264,10 +750,11 @@ func TestConsensusStepUsecase_Vote(t *testing.T) {
 	})
 
 	t.Run("normal case, validate proposal and sendVote", func(t *testing.T) {
-		validProposal, err := factory.NewProposal(RandomCommitableBlock(t, bc), 0)
+		validProposal, err := factory.NewProposal(RandomCommitableBlockFromPeer(t, bc, ps, ps.GetPeers()[0]), 0)
 		require.NoError(t, err)
 
 		c.(*ConsensusStepUsecase).ThisRoundProposal = validProposal
+		c.(*ConsensusStepUsecase).RoundCommitTime = time.Duration(validProposal.GetBlock().GetHeader().GetCreatedTime())
 		c.(*ConsensusStepUsecase).VoteTimeOut = time.Duration(Now()) + conf.VoteMaxCalcTime + conf.AllowedConnectDelayTime
 		lock.RegisterProposal(validProposal)
 
@@ -282,7 +769,7 @@ func TestConsensusStepUsecase_Vote(t *testing.T) {
 			assert.Equal(t, validProposal, actual)
 
 			actualVote := sender.(*convertor.MockConsensusSender).VoteMessage
-			expectedVote := RandomVoteMessageFromPeerWithBlock(t, ps.GetPermutationPeers(height)[myselfId], validProposal.GetBlock())
+			expectedVote := RandomVoteMessageFromPeerWithBlock(t, mySelf(conf, ps), validProposal.GetBlock())
 			assert.Equal(t, expectedVote, actualVote)
 			waiter.Done()
 		}()
@@ -308,29 +795,142 @@ func TestConsensusStepUsecase_Vote(t *testing.T) {
 		assert.True(t, TimeParseDuration(t, "190ms") > time.Duration(endTime-startTime), "%v", time.Duration(endTime-startTime))
 	})
 
+	t.Run("locked case, vote re-proposed locked block", func(t *testing.T) {
+		locked, ok := lock.GetLockedProposal(height)
+		require.True(t, ok)
+		proposal, err := factory.NewProposal(locked.GetBlock(), locked.GetRound()+1)
+		require.NoError(t, err)
+
+		c.(*ConsensusStepUsecase).ThisRoundProposal = proposal
+		c.(*ConsensusStepUsecase).RoundCommitTime = time.Duration(Now())
+		c.(*ConsensusStepUsecase).VoteTimeOut = time.Duration(Now())
+		require.NoError(t, c.Vote(height, proposal.GetRound()))
+
+		vote := sender.(*convertor.MockConsensusSender).VoteMessage
+		require.NotNil(t, vote)
+		assert.False(t, vote.IsReject())
+		assert.Equal(t, proposal.GetRound(), vote.GetRound())
+		assert.Equal(t, GetHash(t, locked.GetBlock()), vote.GetBlockHash())
+	})
+
+	t.Run("locked case, reject other block", func(t *testing.T) {
+		locked, ok := lock.GetLockedProposal(height)
+		require.True(t, ok)
+		proposal, err := factory.NewProposal(RandomCommitableBlockFromPeer(t, bc, ps, ps.GetPeers()[0]), locked.GetRound()+2)
+		require.NoError(t, err)
+
+		c.(*ConsensusStepUsecase).ThisRoundProposal = proposal
+		c.(*ConsensusStepUsecase).RoundCommitTime = time.Duration(proposal.GetBlock().GetHeader().GetCreatedTime())
+		c.(*ConsensusStepUsecase).VoteTimeOut = time.Duration(Now())
+		require.NoError(t, c.Vote(height, proposal.GetRound()))
+
+		vote := sender.(*convertor.MockConsensusSender).VoteMessage
+		require.NotNil(t, vote)
+		assert.True(t, vote.IsReject())
+		assert.Equal(t, GetHash(t, proposal.GetBlock()), vote.GetBlockHash())
+		assert.Contains(t, vote.GetRejectMessage(), ErrConsensusLocked.Error())
+	})
+
 	t.Run("normal case, invalid received proposal", func(t *testing.T) {
 		c.(*ConsensusStepUsecase).VoteTimeOut = time.Duration(Now()) + TimeParseDuration(t, "200ms")
 		sender.(*convertor.MockConsensusSender).VoteMessage = nil
 		require.Nil(t, sender.(*convertor.MockConsensusSender).VoteMessage)
 
-		c.(*ConsensusStepUsecase).ThisRoundProposal = RandomProposalWithHeightRound(t, height+1, 1)
+		proposal := RandomProposalWithHeightRound(t, height+1, 1)
+		c.(*ConsensusStepUsecase).ThisRoundProposal = proposal
 		err := c.Vote(height+1, 1)
 		assert.NoError(t, err)
-		assert.Nil(t, sender.(*convertor.MockConsensusSender).VoteMessage)
+
+		vote := sender.(*convertor.MockConsensusSender).VoteMessage
+		require.NotNil(t, vote)
+		assert.True(t, vote.IsReject())
+		assert.Equal(t, GetHash(t, proposal.GetBlock()), vote.GetBlockHash())
+		assert.Contains(t, vote.GetRejectMessage(), model.ErrStatefulValidate.Error())
+		assert.NoError(t, vote.Verify())
+	})
+
+	t.Run("normal case, not found proposal, send nil reject vote", func(t *testing.T) {
+		c.(*ConsensusStepUsecase).VoteTimeOut = time.Duration(Now()) + TimeParseDuration(t, "200ms")
+		c.(*ConsensusStepUsecase).ThisRoundProposal = nil
+		err := c.Vote(height+1, 2)
+		assert.NoError(t, err)
+
+		vote := sender.(*convertor.MockConsensusSender).VoteMessage
+		require.NotNil(t, vote)
+		assert.True(t, vote.IsReject())
+		assert.Nil(t, vote.GetBlockHash())
+		assert.Equal(t, int32(2), vote.GetRound())
+	})
+
+	t.Run("normal case, collected 2/3+ reject votes, no wait voteTimeOut", func(t *testing.T) {
+		c.(*ConsensusStepUsecase).VoteTimeOut = time.Duration(Now()) + TimeParseDuration(t, "200ms")
+		c.(*ConsensusStepUsecase).ThisRoundProposal = nil
+
+		for _, p := range ps.GetPeers()[1:] {
+			vote := factory.NewRejectVoteMessage(conf.ChainId, height+1, 3, nil, "Not Found Proposal")
+			require.NoError(t, vote.Sign(p.GetPubkey(), p.(*PeerWithPriv).PrivKey))
+			require.NoError(t, lock.AddVoteMessage(vote))
+			channel.Vote <- vote
+		}
+
+		startTime := Now()
+		err := c.Vote(height+1, 3)
+		endTime := Now()
+		assert.NoError(t, err)
+		assert.True(t, TimeParseDuration(t, "190ms") > time.Duration(endTime-startTime), "%v", time.Duration(endTime-startTime))
 	})
 
 }
 
+func TestConsensusStepUsecase_VoteClockSkew(t *testing.T) {
+	conf, bc, ps, _, _, _, sender, _, c := NewTestConsensusStepUsecase(t)
+	factory := convertor.NewModelFactory()
+
+	top, ok := bc.Top()
+	require.True(t, ok)
+
+	for _, cc := range []struct {
+		name   string
+		skew   time.Duration
+		reject bool
+	}{
+		{"same commitTime, vote", 0, false},
+		{"skew over half of max, vote with warning", conf.MaxClockSkew/2 + time.Millisecond, false},
+		{"leader clock is ahead, reject", conf.MaxClockSkew + time.Millisecond, true},
+		{"leader clock is behind, reject", -conf.MaxClockSkew - time.Millisecond, true},
+	} {
+		t.Run(cc.name, func(t *testing.T) {
+			createdTime := top.GetHeader().GetCreatedTime() + 10
+			block, err := factory.NewBlock(1, GetHash(t, top), createdTime, RandomValidTxs(t), nil, CommitableChainState(t, bc, ps, ps.GetPeers()[0].GetPubkey()))
+			require.NoError(t, err)
+			require.NoError(t, block.Sign(ps.GetPeers()[0].GetPubkey(), ps.GetPeers()[0].(*PeerWithPriv).PrivKey))
+			proposal, err := factory.NewProposal(block, 0)
+			require.NoError(t, err)
+
+			c.(*ConsensusStepUsecase).ThisRoundProposal = proposal
+			c.(*ConsensusStepUsecase).RoundCommitTime = time.Duration(createdTime) - cc.skew
+			c.(*ConsensusStepUsecase).VoteTimeOut = time.Duration(Now())
+			require.NoError(t, c.Vote(1, 0))
+
+			vote := sender.(*convertor.MockConsensusSender).VoteMessage
+			require.NotNil(t, vote)
+			assert.Equal(t, GetHash(t, block), vote.GetBlockHash())
+			assert.Equal(t, cc.reject, vote.IsReject())
+			if cc.reject {
+				assert.Contains(t, vote.GetRejectMessage(), model.ErrBlockCreatedTimeSkew.Error())
+			}
+		})
+	}
+}
+
 func TestConsensusStepUsecase_PreCommit(t *testing.T) {
-	conf, bc, ps, lock, _, sender, channel, c := NewTestConsensusStepUsecase(t)
+	conf, bc, ps, lock, _, _, sender, channel, c := NewTestConsensusStepUsecase(t)
 	factory := convertor.NewModelFactory()
 
 	_, ok := bc.Top()
 	require.True(t, ok)
 
 	var height int64 = 1
-	// myselfId is Round when myself is Leader
-	myselfId := mySelfId(conf, ps, height)
 
 	t.Run("invalid case, preCommitTimeOut", func(t *testing.T) {
 		c.(*ConsensusStepUsecase).PreCommitTimeOut = time.Duration(Now()) + TimeParseDuration(t, "200ms")
@@ -343,8 +943,25 @@ func TestConsensusStepUsecase_PreCommit(t *testing.T) {
 		assert.True(t, TimeParseDuration(t, "210ms") > time.Duration(endTime-startTime), "%v", time.Duration(endTime-startTime))
 	})
 
+	t.Run("invalid case, rejected round, no wait preCommitTimeOut", func(t *testing.T) {
+		c.(*ConsensusStepUsecase).PreCommitTimeOut = time.Duration(Now()) + TimeParseDuration(t, "200ms")
+
+		for _, p := range ps.GetPeers()[1:] {
+			vote := factory.NewRejectVoteMessage(conf.ChainId, height, 1, nil, "Not Found Proposal")
+			require.NoError(t, vote.Sign(p.GetPubkey(), p.(*PeerWithPriv).PrivKey))
+			require.NoError(t, lock.AddVoteMessage(vote))
+		}
+
+		startTime := Now()
+		err := c.PreCommit(height, 1)
+		endTime := Now()
+		assert.EqualError(t, errors.Cause(err), ErrConsensusRejected.Error())
+		assert.Contains(t, err.Error(), "Not Found Proposal")
+		assert.True(t, TimeParseDuration(t, "190ms") > time.Duration(endTime-startTime), "%v", time.Duration(endTime-startTime))
+	})
+
 	t.Run("normal case, sendPreCommit and collected preCommit", func(t *testing.T) {
-		proposal, err := factory.NewProposal(RandomCommitableBlock(t, bc), 0)
+		proposal, err := factory.NewProposal(RandomCommitableBlockFromPeer(t, bc, ps, ps.GetPeers()[0]), 0)
 		require.NoError(t, err)
 		lock.RegisterProposal(proposal)
 
@@ -362,19 +979,112 @@ func TestConsensusStepUsecase_PreCommit(t *testing.T) {
 			err := c.PreCommit(height, 0)
 			assert.NoError(t, err)
 
-			expectedPreCommit := RandomVoteMessageFromPeerWithBlock(t, ps.GetPermutationPeers(height)[myselfId], proposal.GetBlock())
+			expectedPreCommit := RandomPreCommitFromPeerWithBlock(t, mySelf(conf, ps), proposal.GetBlock())
 			actualPreCommit := sender.(*convertor.MockConsensusSender).PreCommitMessage
 			assert.Equal(t, expectedPreCommit, actualPreCommit)
+
+			cert := c.(*ConsensusStepUsecase).ThisRoundCertificate
+			require.NotNil(t, cert)
+			assert.Equal(t, height, cert.GetHeight())
+			assert.Equal(t, GetHash(t, proposal.GetBlock()), cert.GetBlockHash())
+			assert.Equal(t, len(ps.GetPeers()[1:]), len(cert.GetPreCommits()))
 		}()
 		for _, p := range ps.GetPeers()[1:] {
-			vote := RandomVoteMessageFromPeerWithBlock(t, p, proposal.GetBlock())
+			vote := RandomPreCommitFromPeerWithBlock(t, p, proposal.GetBlock())
 			channel.PreCommit <- vote
 		}
 	})
 }
 
+func TestConsensusStepUsecase_PreCommitOnlyThisRoundLock(t *testing.T) {
+	_, bc, ps, lock, _, _, sender, channel, c := NewTestConsensusStepUsecase(t)
+	factory := convertor.NewModelFactory()
+	// A は自分, D は Byzantine
+	peers := ps.GetPeers()
+	a, b, cc, d := peers[0], peers[1], peers[2], peers[3]
+	mock := sender.(*convertor.MockConsensusSender)
+
+	var height int64 = 1
+	x, err := factory.NewProposal(RandomCommitableBlockFromPeer(t, bc, ps, a), 1)
+	require.NoError(t, err)
+	y, err := factory.NewProposal(RandomCommitableBlockFromPeer(t, bc, ps, b), 2)
+	require.NoError(t, err)
+	preCommit := func(t *testing.T, round int32) {
+		c.(*ConsensusStepUsecase).PreCommitTimeOut = time.Duration(Now()) + TimeParseDuration(t, "100ms")
+		assert.EqualError(t, errors.Cause(c.PreCommit(height, round)), ErrConsensusPreCommit.Error())
+	}
+
+	t.Run("round 1, A sees the polka of X and preCommits X", func(t *testing.T) {
+		require.NoError(t, lock.RegisterProposal(x))
+		for _, p := range peers {
+			require.NoError(t, lock.AddVoteMessage(VoteMessageFromPeerWithBlockRound(t, model.PreVote, p, x.GetBlock(), 1)))
+		}
+		preCommit(t, 1)
+		require.NotNil(t, mock.PreCommitMessage)
+		assert.Equal(t, int32(1), mock.PreCommitMessage.GetRound())
+		assert.Equal(t, GetHash(t, x.GetBlock()), mock.PreCommitMessage.GetBlockHash())
+	})
+
+	t.Run("round 2, polka of Y is delivered late", func(t *testing.T) {
+		require.NoError(t, lock.RegisterProposal(y))
+		mock.PreCommitMessage = nil
+		preCommit(t, 2)
+		assert.Nil(t, mock.PreCommitMessage)
+	})
+
+	t.Run("round 3, A does not preCommit the lock of round 1", func(t *testing.T) {
+		// B は round 1 の polka を遅れて見て X に Lock し、B と D は X に PreCommit する
+		channel.PreCommit <- VoteMessageFromPeerWithBlockRound(t, model.PreCommit, b, x.GetBlock(), 3)
+		channel.PreCommit <- VoteMessageFromPeerWithBlockRound(t, model.PreCommit, d, x.GetBlock(), 3)
+		preCommit(t, 3)
+		assert.Nil(t, mock.PreCommitMessage)
+		assert.Nil(t, c.(*ConsensusStepUsecase).ThisRoundCertificate)
+	})
+
+	t.Run("later, polka of round 2 moves the lock to Y, X was not committed", func(t *testing.T) {
+		for _, p := range []model.Peer{b, cc, d} {
+			require.NoError(t, lock.AddVoteMessage(VoteMessageFromPeerWithBlockRound(t, model.PreVote, p, y.GetBlock(), 2)))
+		}
+		locked, ok := lock.GetLockedProposal(height)
+		require.True(t, ok)
+		assert.Equal(t, y, locked)
+	})
+
+	t.Run("polka not newer than the preCommitted round does not move the lock", func(t *testing.T) {
+		z, err := factory.NewProposal(RandomCommitableBlockFromPeer(t, bc, ps, cc), 4)
+		require.NoError(t, err)
+		require.NoError(t, lock.RegisterProposal(z))
+		for _, p := range peers {
+			require.NoError(t, lock.AddVoteMessage(VoteMessageFromPeerWithBlockRound(t, model.PreVote, p, z.GetBlock(), 4)))
+		}
+		preCommit(t, 4)
+		require.NotNil(t, mock.PreCommitMessage)
+		assert.Equal(t, int32(4), mock.PreCommitMessage.GetRound())
+
+		w, err := factory.NewProposal(RandomCommitableBlockFromPeer(t, bc, ps, d), 3)
+		require.NoError(t, err)
+		require.NoError(t, lock.RegisterProposal(w))
+		for _, p := range peers[1:] {
+			require.NoError(t, lock.AddVoteMessage(VoteMessageFromPeerWithBlockRound(t, model.PreVote, p, w.GetBlock(), 3)))
+		}
+		locked, ok := lock.GetLockedProposal(height)
+		require.True(t, ok)
+		assert.Equal(t, z, locked)
+	})
+}
+
+func TestConsensusStepUsecase_Run(t *testing.T) {
+	_, _, _, _, _, _, _, _, c := NewTestConsensusStepUsecase(t)
+
+	t.Run("stop by canceled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.Equal(t, context.Canceled, c.Run(ctx))
+	})
+}
+
 func TestConsensusStepUsecase_Commit(t *testing.T) {
-	_, bc, ps, lock, _, _, _, c := NewTestConsensusStepUsecase(t)
+	_, bc, ps, lock, _, evidences, _, _, c := NewTestConsensusStepUsecase(t)
 	factory := convertor.NewModelFactory()
 
 	_, ok := bc.Top()
@@ -383,7 +1093,15 @@ func TestConsensusStepUsecase_Commit(t *testing.T) {
 	var height int64 = 1
 
 	t.Run("success, commit!", func(t *testing.T) {
-		proposal, err := factory.NewProposal(RandomCommitableBlock(t, bc), 0)
+		evidence := RandomEvidence(t)
+		require.NoError(t, evidences.Add(evidence))
+		top, ok := bc.Top()
+		require.True(t, ok)
+		block, err := factory.NewBlock(height, GetHash(t, top), top.GetHeader().GetCreatedTime()+10, RandomValidTxs(t), []model.Evidence{evidence},
+			CommitableChainState(t, bc, ps, ps.GetPeers()[0].GetPubkey()))
+		require.NoError(t, err)
+		require.NoError(t, block.Sign(ps.GetPeers()[0].GetPubkey(), ps.GetPeers()[0].(*PeerWithPriv).PrivKey))
+		proposal, err := factory.NewProposal(block, 0)
 		require.NoError(t, err)
 
 		lock.RegisterProposal(proposal)
@@ -392,13 +1110,415 @@ func TestConsensusStepUsecase_Commit(t *testing.T) {
 			require.NoError(t, lock.AddVoteMessage(vote))
 		}
 
+		cert := RandomCommitCertificate(t, proposal.GetBlock(), ps.GetPeers()[1:])
+		c.(*ConsensusStepUsecase).ThisRoundCertificate = cert
+
 		assert.NoError(t, c.Commit(height, 0))
 		newBlock, ok := bc.Top()
 		require.True(t, ok)
 		assert.Equal(t, proposal.GetBlock(), newBlock)
+
+		actualCert, ok := bc.GetCommitCertificate(height)
+		require.True(t, ok)
+		assert.Equal(t, cert, actualCert)
+
+		// committed evidence is removed from pool
+		assert.Empty(t, evidences.GetPendings(1))
+		assert.True(t, evidences.IsExist(evidence))
 	})
 
 	t.Run("invalid commit case", func(t *testing.T) {
 		assert.Error(t, errors.Cause(c.Commit(height, 0)), ErrConsensusCommit.Error())
 	})
 }
+
+func TestConsensusStepUsecase_Replay(t *testing.T) {
+	conf, bc, ps, _, _, _, sender, _, _ := NewTestConsensusStepUsecase(t)
+	factory := convertor.NewModelFactory()
+	slv := convertor.NewStatelessValidator(conf)
+	sfv := convertor.NewStatefulValidator(conf, bc, ps, NewLeaderSelector(conf, ps, bc))
+	syncer := NewBlockSyncUsecase(conf, bc, ps, slv, sfv, convertor.NewCommitCertificateValidator(conf, ps), convertor.NewMockBlockSyncSender(bc))
+	newStep := func(lock dba.Lock, wal model.WAL) *ConsensusStepUsecase {
+		return NewConsensusStepUsecase(conf, bc, ps, NewLeaderSelector(conf, ps, bc), lock, dba.NewProposalTxQueueOnMemory(conf),
+			dba.NewEvidencePoolOnMemory(conf), sender, slv, sfv, convertor.NewEvidenceValidator(conf, ps), factory, syncer,
+			wal, NewEventBusOnMemory(conf), NewLatencyMonitorUsecase(conf, ps, sender, factory), NewRealClock(), NewReceiveChannel(conf)).(*ConsensusStepUsecase)
+	}
+
+	var height int64 = 1
+	proposal, err := factory.NewProposal(RandomCommitableBlockFromPeer(t, bc, ps, ps.GetPeers()[0]), 1)
+	require.NoError(t, err)
+
+	t.Run("success, record sent vote before sending", func(t *testing.T) {
+		wal := dba.NewWALOnMemory()
+		c := newStep(dba.NewLockOnMemory(ps, conf), wal)
+		c.VoteTimeOut = time.Duration(Now())
+		require.NoError(t, c.Vote(height, 0))
+
+		records, err := wal.ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		assert.Equal(t, model.WALVote, records[0].GetType())
+		assert.True(t, records[0].IsSent())
+		assert.Equal(t, sender.(*convertor.MockConsensusSender).VoteMessage, records[0].GetVote())
+	})
+
+	t.Run("success, restore round, lock and preCommits", func(t *testing.T) {
+		wal := dba.NewWALOnMemory()
+		require.NoError(t, wal.WriteRound(height, 0))
+		require.NoError(t, wal.WriteRound(height, 1))
+		require.NoError(t, wal.WriteProposal(proposal, false))
+		for _, p := range ps.GetPeers()[1:] {
+			require.NoError(t, wal.WriteVote(VoteMessageFromPeerWithBlockRound(t, model.PreVote, p, proposal.GetBlock(), 1), false))
+			require.NoError(t, wal.WriteVote(VoteMessageFromPeerWithBlockRound(t, model.PreCommit, p, proposal.GetBlock(), 1), false))
+		}
+
+		lock := dba.NewLockOnMemory(ps, conf)
+		c := newStep(lock, wal)
+		walHeight, walRound, err := c.Replay()
+		require.NoError(t, err)
+		assert.Equal(t, height, walHeight)
+		assert.Equal(t, int32(1), walRound)
+
+		locked, ok := lock.GetLockedProposal(height)
+		require.True(t, ok)
+		assert.Equal(t, GetHash(t, proposal.GetBlock()), GetHash(t, locked.GetBlock()))
+
+		// 2/3 以上の PreCommit は既に集まっているので待たない
+		c.PreCommitTimeOut = time.Duration(Now()) + TimeParseDuration(t, "1s")
+		require.NoError(t, c.PreCommit(height, 1))
+		require.NotNil(t, c.ThisRoundCertificate)
+		assert.Equal(t, GetHash(t, proposal.GetBlock()), c.ThisRoundCertificate.GetBlockHash())
+	})
+
+	t.Run("success, sent preCommit is not counted twice", func(t *testing.T) {
+		wal := dba.NewWALOnMemory()
+		require.NoError(t, wal.WriteVote(VoteMessageFromPeerWithBlockRound(t, model.PreCommit, mySelf(conf, ps), proposal.GetBlock(), 1), true))
+		for _, p := range ps.GetPeers()[1:3] {
+			require.NoError(t, wal.WriteVote(VoteMessageFromPeerWithBlockRound(t, model.PreCommit, p, proposal.GetBlock(), 1), false))
+		}
+
+		c := newStep(dba.NewLockOnMemory(ps, conf), wal)
+		_, walRound, err := c.Replay()
+		require.NoError(t, err)
+		assert.Equal(t, int32(-1), walRound)
+
+		c.PreCommitTimeOut = time.Duration(Now()) + TimeParseDuration(t, "100ms")
+		assert.EqualError(t, errors.Cause(c.PreCommit(height, 1)), ErrConsensusPreCommit.Error())
+	})
+
+	t.Run("success, resume from next round of recorded round", func(t *testing.T) {
+		wal := dba.NewWALOnMemory()
+		require.NoError(t, wal.WriteRound(height, 0))
+		require.NoError(t, wal.WriteRound(height, 1))
+
+		c := newStep(dba.NewLockOnMemory(ps, conf), wal)
+		ctx, cancel := context.WithTimeout(context.Background(), TimeParseDuration(t, "100ms"))
+		defer cancel()
+		assert.Equal(t, context.DeadlineExceeded, c.Run(ctx))
+
+		records, err := wal.ReadAll()
+		require.NoError(t, err)
+		require.True(t, len(records) > 2)
+		assert.Equal(t, model.WALRound, records[2].GetType())
+		assert.Equal(t, height, records[2].GetHeight())
+		assert.Equal(t, int32(2), records[2].GetRound())
+	})
+}
+
+func TestConsensusStepUsecase_CommitBySync(t *testing.T) {
+	conf, bc, ps, _, _, _, sender, channel, _ := NewTestConsensusStepUsecase(t)
+	factory := convertor.NewModelFactory()
+	peers := ps.GetPeers()
+
+	// 他の Peer は Height 1 の Block を Commit している
+	src := dba.NewBlockChainOnMemory()
+	genesis, ok := bc.GetBlock(0)
+	require.True(t, ok)
+	src.Commit(genesis, nil)
+	evidence := RandomEvidence(t)
+	block, err := factory.NewBlock(1, GetHash(t, genesis), genesis.GetHeader().GetCreatedTime()+10, RandomValidTxs(t), []model.Evidence{evidence},
+		CommitableChainState(t, src, ps, peers[0].GetPubkey()))
+	require.NoError(t, err)
+	require.NoError(t, block.Sign(peers[0].GetPubkey(), peers[0].(*PeerWithPriv).PrivKey))
+	src.Commit(block, RandomCommitCertificate(t, block, peers[1:]))
+
+	// 自分は Height 1 の別の Proposal に Lock している
+	lock := dba.NewLockOnMemory(ps, conf)
+	proposal, err := factory.NewProposal(RandomCommitableBlockFromPeer(t, bc, ps, peers[0]), 0)
+	require.NoError(t, err)
+	require.NoError(t, lock.RegisterProposal(proposal))
+	for _, p := range peers[1:] {
+		require.NoError(t, lock.AddVoteMessage(RandomVoteMessageFromPeerWithBlock(t, p, proposal.GetBlock())))
+	}
+	evidences := dba.NewEvidencePoolOnMemory(conf)
+	require.NoError(t, evidences.Add(evidence))
+	wal := dba.NewWALOnMemory()
+	require.NoError(t, wal.WriteRound(1, 0))
+
+	slv := convertor.NewStatelessValidator(conf)
+	sfv := convertor.NewStatefulValidator(conf, bc, ps, NewLeaderSelector(conf, ps, bc))
+	syncer := NewBlockSyncUsecase(conf, bc, ps, slv, sfv, convertor.NewCommitCertificateValidator(conf, ps), convertor.NewMockBlockSyncSender(src))
+	syncer.Resume(2)
+	c := NewConsensusStepUsecase(conf, bc, ps, NewLeaderSelector(conf, ps, bc), lock, dba.NewProposalTxQueueOnMemory(conf), evidences, sender, slv, sfv,
+		convertor.NewEvidenceValidator(conf, ps), factory, syncer, wal, NewEventBusOnMemory(conf), NewLatencyMonitorUsecase(conf, ps, sender, factory), NewRealClock(), channel)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		c.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	t.Run("cleanup as same as commit", func(t *testing.T) {
+		for i := 0; i < 100 && c.(ConsensusStateReader).GetConsensusState().Height < 2; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		top, ok := bc.Top()
+		require.True(t, ok)
+		require.Equal(t, GetHash(t, block), GetHash(t, top))
+
+		_, ok = lock.GetLockedProposal(1)
+		assert.False(t, ok)
+		assert.Empty(t, evidences.GetPendings(1))
+		assert.True(t, evidences.IsExist(evidence))
+		records, err := wal.ReadAll()
+		require.NoError(t, err)
+		for _, record := range records {
+			assert.True(t, record.GetHeight() >= 2)
+		}
+	})
+}
+
+func TestConsensusStepUsecase_CommitNotLockedBlock(t *testing.T) {
+	// 自分が Lock していない Block に 2/3 以上の PreCommit が集まる
+	run := func(t *testing.T, hasBlock bool) (dba.BlockChain, model.Block, model.WAL, ConsensusStateReader, func()) {
+		conf, bc, ps, lock, _, evidences, sender, channel, _ := NewTestConsensusStepUsecase(t)
+		conf.AllowedConnectDelayTime = TimeParseDuration(t, "10ms")
+		conf.ProposeMaxCalcTime = TimeParseDuration(t, "50ms")
+		conf.VoteMaxCalcTime = TimeParseDuration(t, "50ms")
+		conf.PreCommitMaxCalcTime = TimeParseDuration(t, "50ms")
+		factory := convertor.NewModelFactory()
+		peers := ps.GetPeers()
+
+		src := dba.NewBlockChainOnMemory()
+		genesis, ok := bc.GetBlock(0)
+		require.True(t, ok)
+		src.Commit(genesis, nil)
+		block := RandomCommitableBlockFromPeer(t, src, ps, peers[1])
+		if hasBlock {
+			src.Commit(block, RandomCommitCertificate(t, block, peers[1:]))
+		}
+		for _, p := range peers[1:] {
+			channel.PreCommit <- RandomPreCommitFromPeerWithBlock(t, p, block)
+		}
+
+		slv := convertor.NewStatelessValidator(conf)
+		sfv := convertor.NewStatefulValidator(conf, bc, ps, NewLeaderSelector(conf, ps, bc))
+		syncer := NewBlockSyncUsecase(conf, bc, ps, slv, sfv, convertor.NewCommitCertificateValidator(conf, ps), convertor.NewMockBlockSyncSender(src))
+		wal := dba.NewWALOnMemory()
+		c := NewConsensusStepUsecase(conf, bc, ps, NewLeaderSelector(conf, ps, bc), lock, dba.NewProposalTxQueueOnMemory(conf), evidences, sender, slv, sfv,
+			convertor.NewEvidenceValidator(conf, ps), factory, syncer, wal, NewEventBusOnMemory(conf), NewLatencyMonitorUsecase(conf, ps, sender, factory), NewRealClock(), channel)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			c.Run(ctx)
+			close(done)
+		}()
+		stop := func() {
+			cancel()
+			<-done
+		}
+		return bc, block, wal, c.(ConsensusStateReader), stop
+	}
+	waitState := func(t *testing.T, reader ConsensusStateReader, cond func(state *ConsensusState) bool) {
+		for i := 0; i < 500; i++ {
+			if cond(reader.GetConsensusState()) {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		require.FailNow(t, "consensus state is not changed")
+	}
+
+	t.Run("success, fetch and commit the certified block", func(t *testing.T) {
+		bc, block, _, reader, stop := run(t, true)
+		defer stop()
+		waitState(t, reader, func(state *ConsensusState) bool { return state.Height >= 2 })
+		top, ok := bc.Top()
+		require.True(t, ok)
+		assert.Equal(t, GetHash(t, block), GetHash(t, top))
+	})
+
+	t.Run("not go back to round 0, when the certified block is not found", func(t *testing.T) {
+		_, _, wal, reader, stop := run(t, false)
+		defer stop()
+		waitState(t, reader, func(state *ConsensusState) bool { return state.Height == 1 && state.Round >= 2 })
+
+		records, err := wal.ReadAll()
+		require.NoError(t, err)
+		started := make(map[int32]int)
+		for _, record := range records {
+			if record.GetType() == model.WALRound && record.GetHeight() == 1 {
+				started[record.GetRound()]++
+			}
+		}
+		assert.Equal(t, 1, started[0])
+		assert.Equal(t, 1, started[1])
+	})
+}
+
+func TestConsensusStepUsecase_GetConsensusState(t *testing.T) {
+	conf, bc, ps, lock, _, _, _, channel, c := NewTestConsensusStepUsecase(t)
+	reader := c.(ConsensusStateReader)
+	// Round 0 の間に状態を読むため、各 Phase を長くする
+	conf.ProposeMaxCalcTime = TimeParseDuration(t, "10s")
+	conf.VoteMaxCalcTime = TimeParseDuration(t, "10s")
+
+	t.Run("before running", func(t *testing.T) {
+		state := reader.GetConsensusState()
+		assert.Equal(t, "", state.Phase)
+		assert.Nil(t, state.Leader)
+		assert.Nil(t, state.LockedProposal)
+		assert.Empty(t, state.Votes)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		c.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	var height int64 = 1
+	waitState := func(t *testing.T, cond func(state *ConsensusState) bool) *ConsensusState {
+		for i := 0; i < 100; i++ {
+			if state := reader.GetConsensusState(); cond(state) {
+				return state
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		require.FailNow(t, "consensus state is not changed")
+		return nil
+	}
+
+	t.Run("running round 0", func(t *testing.T) {
+		state := waitState(t, func(state *ConsensusState) bool { return state.Phase != "" })
+		assert.Equal(t, height, state.Height)
+		assert.Equal(t, int32(0), state.Round)
+		assert.Contains(t, []string{PhasePropose, PhaseVote}, state.Phase)
+		assert.True(t, state.ProposeTimeOut < state.VoteTimeOut)
+		assert.True(t, state.VoteTimeOut < state.PreCommitTimeOut)
+		assert.True(t, state.PreCommitTimeOut < state.RoundCommitTime)
+
+		expected, ok := NewLeaderSelector(conf, ps, bc).GetLeader(height, 0)
+		require.True(t, ok)
+		assert.Equal(t, expected, state.Leader)
+	})
+
+	t.Run("locked proposal, votes and preCommits", func(t *testing.T) {
+		proposal, err := convertor.NewModelFactory().NewProposal(RandomCommitableBlock(t, bc), 0)
+		require.NoError(t, err)
+		require.NoError(t, lock.RegisterProposal(proposal))
+		for _, p := range ps.GetPeers()[1:] {
+			require.NoError(t, lock.AddVoteMessage(RandomVoteMessageFromPeerWithBlock(t, p, proposal.GetBlock())))
+		}
+		preCommit := RandomPreCommitFromPeerWithBlock(t, ps.GetPeers()[1], proposal.GetBlock())
+		channel.PreCommit <- preCommit
+
+		state := waitState(t, func(state *ConsensusState) bool { return len(state.PreCommits) > 0 })
+		require.NotNil(t, state.LockedProposal)
+		assert.Equal(t, GetHash(t, proposal.GetBlock()), GetHash(t, state.LockedProposal.GetBlock()))
+		assert.True(t, len(state.Votes) >= len(ps.GetPeers()[1:]))
+		assert.Equal(t, []model.VoteMessage{preCommit}, state.PreCommits)
+	})
+}
+
+func TestConsensusStepUsecase_Events(t *testing.T) {
+	conf, bc, ps, lock, queue, evidences, sender, channel, _ := NewTestConsensusStepUsecase(t)
+	factory := convertor.NewModelFactory()
+	slv := convertor.NewStatelessValidator(conf)
+	sfv := convertor.NewStatefulValidator(conf, bc, ps, NewLeaderSelector(conf, ps, bc))
+	syncer := NewBlockSyncUsecase(conf, bc, ps, slv, sfv, convertor.NewCommitCertificateValidator(conf, ps), convertor.NewMockBlockSyncSender(bc))
+	bus := NewEventBusOnMemory(conf)
+	c := NewConsensusStepUsecase(conf, bc, ps, NewLeaderSelector(conf, ps, bc), lock, queue, evidences, sender, slv, sfv,
+		convertor.NewEvidenceValidator(conf, ps), factory, syncer, dba.NewWALOnMemory(), bus, NewLatencyMonitorUsecase(conf, ps, sender, factory), NewRealClock(), channel)
+
+	var height int64 = 1
+	// Round 0 で Lock を取っているので、Propose と Vote を飛ばして PreCommit を待つ
+	proposal, err := factory.NewProposal(RandomCommitableBlockFromPeer(t, bc, ps, ps.GetPeers()[0]), 0)
+	require.NoError(t, err)
+	require.NoError(t, lock.RegisterProposal(proposal))
+	for _, p := range ps.GetPeers()[1:] {
+		require.NoError(t, lock.AddVoteMessage(RandomVoteMessageFromPeerWithBlock(t, p, proposal.GetBlock())))
+	}
+	hash := GetHash(t, proposal.GetBlock())
+
+	all, err := bus.Subscribe(nil)
+	require.NoError(t, err)
+	txs, err := bus.Subscribe(&EventFilter{
+		Types:      []EventType{EventTx},
+		TxHash:     GetHash(t, proposal.GetBlock().GetTransactions()[0]),
+		FromHeight: height,
+		ToHeight:   height,
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		c.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	next := func(t *testing.T, sub *Subscription) *Event {
+		select {
+		case event, ok := <-sub.Events():
+			require.True(t, ok, "subscription is closed: %v", sub.Err())
+			return event
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "no event")
+		}
+		return nil
+	}
+
+	t.Run("new round", func(t *testing.T) {
+		assert.Equal(t, &Event{Type: EventNewRound, Height: height, Round: 0}, next(t, all))
+	})
+
+	t.Run("lock", func(t *testing.T) {
+		channel.Vote <- RandomVoteMessageFromPeerWithBlock(t, ps.GetPeers()[1], proposal.GetBlock())
+		assert.Equal(t, &Event{Type: EventLock, Height: height, Round: 0, Hash: hash, Block: proposal.GetBlock()}, next(t, all))
+	})
+
+	t.Run("new block and txs", func(t *testing.T) {
+		for _, p := range ps.GetPeers()[1:] {
+			channel.PreCommit <- RandomPreCommitFromPeerWithBlock(t, p, proposal.GetBlock())
+		}
+		assert.Equal(t, &Event{Type: EventNewBlock, Height: height, Round: 0, Hash: hash, Block: proposal.GetBlock()}, next(t, all))
+		for _, tx := range proposal.GetBlock().GetTransactions() {
+			assert.Equal(t, &Event{Type: EventTx, Height: height, Round: 0, Hash: GetHash(t, tx), Tx: tx}, next(t, all))
+		}
+
+		// Transaction の Hash で絞り込んだ購読者には1つだけ届く
+		event := next(t, txs)
+		assert.Equal(t, proposal.GetBlock().GetTransactions()[0], event.Tx)
+		select {
+		case event := <-txs.Events():
+			assert.Fail(t, "unexpected event", "%#v", event)
+		default:
+		}
+	})
+}
@@ -0,0 +1,180 @@
+package usecase
+
+import (
+	"bytes"
+	"github.com/pkg/errors"
+	"github.com/satellitex/bbft/config"
+	"github.com/satellitex/bbft/model"
+	"sync"
+)
+
+var (
+	ErrEventBusSlowSubscriber = errors.Errorf("Failed EventBus subscriber can not keep up with events")
+	ErrEventBusInvalidFilter  = errors.Errorf("Failed EventBus invalid filter")
+)
+
+type EventType int32
+
+const (
+	UnknownEvent EventType = iota
+	// Block が Commit された。BlockSync で Commit された Block も含む
+	EventNewBlock
+	// Round を始めた
+	EventNewRound
+	// 検証済みの Proposal を受け取った
+	EventProposal
+	// Proposal に Lock を取った
+	EventLock
+	// Transaction が Block に入って Commit された
+	EventTx
+)
+
+// Event は EventBus に publish される合意の出来事
+type Event struct {
+	Type   EventType
+	Height int64
+	Round  int32
+	// EventNewBlock, EventProposal, EventLock では Block の Hash, EventTx では Transaction の Hash
+	Hash []byte
+	// EventNewBlock, EventProposal, EventLock の Block
+	Block model.Block
+	// EventTx の Transaction
+	Tx model.Transaction
+}
+
+// EventFilter は購読する Event の条件。値の無い条件では絞り込まない
+type EventFilter struct {
+	Types []EventType
+	// EventTx を Transaction の Hash で絞り込む。他の Event には使わない
+	TxHash []byte
+	// Height が [FromHeight, ToHeight] の Event だけを購読する。ToHeight = 0 のときは上限なし
+	FromHeight int64
+	ToHeight   int64
+}
+
+func (f *EventFilter) Validate() error {
+	if f.FromHeight < 0 || f.ToHeight < 0 {
+		return errors.Wrapf(ErrEventBusInvalidFilter, "height must not be negative, from: %d, to: %d", f.FromHeight, f.ToHeight)
+	}
+	if f.ToHeight != 0 && f.FromHeight > f.ToHeight {
+		return errors.Wrapf(ErrEventBusInvalidFilter, "fromHeight: %d > toHeight: %d", f.FromHeight, f.ToHeight)
+	}
+	return nil
+}
+
+func (f *EventFilter) Match(event *Event) bool {
+	if len(f.Types) > 0 {
+		found := false
+		for _, t := range f.Types {
+			if t == event.Type {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(f.TxHash) > 0 && event.Type == EventTx && !bytes.Equal(f.TxHash, event.Hash) {
+		return false
+	}
+	if event.Height < f.FromHeight {
+		return false
+	}
+	if f.ToHeight != 0 && event.Height > f.ToHeight {
+		return false
+	}
+	return true
+}
+
+// Subscription は EventBus の1つの購読
+// Events は購読が終わると閉じられ、Err が終わった理由を返す。Unsubscribe で終えたときの Err は nil
+type Subscription struct {
+	filter *EventFilter
+	events chan *Event
+	err    error
+	mutex  *sync.Mutex
+}
+
+func (s *Subscription) Events() <-chan *Event {
+	return s.events
+}
+
+func (s *Subscription) Err() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.err
+}
+
+// EventBus は合意の Usecase が publish した Event を購読者に配る
+type EventBus interface {
+	// Publish は event を条件に合う全ての購読者に配る。購読者を待たない
+	Publish(event *Event)
+	Subscribe(filter *EventFilter) (*Subscription, error)
+	Unsubscribe(subscription *Subscription)
+}
+
+// EventBusOnMemory は購読者ごとに EventSubscriptionBufferSize の Buffer を持つ EventBus
+// Buffer が一杯の購読者は Event を取り逃がすので、ErrEventBusSlowSubscriber で購読を終わらせる。合意は購読者を待たない
+type EventBusOnMemory struct {
+	subscriptions map[*Subscription]struct{}
+	bufferSize    int
+	mutex         *sync.Mutex
+}
+
+func NewEventBusOnMemory(conf *config.BBFTConfig) EventBus {
+	return &EventBusOnMemory{
+		make(map[*Subscription]struct{}),
+		conf.EventSubscriptionBufferSize,
+		new(sync.Mutex),
+	}
+}
+
+func (b *EventBusOnMemory) Publish(event *Event) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for s := range b.subscriptions {
+		if !s.filter.Match(event) {
+			continue
+		}
+		select {
+		case s.events <- event:
+		default:
+			b.close(s, errors.Wrapf(ErrEventBusSlowSubscriber, "buffer size: %d", b.bufferSize))
+		}
+	}
+}
+
+func (b *EventBusOnMemory) Subscribe(filter *EventFilter) (*Subscription, error) {
+	if filter == nil {
+		filter = &EventFilter{}
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	s := &Subscription{
+		filter: filter,
+		events: make(chan *Event, b.bufferSize),
+		mutex:  new(sync.Mutex),
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.subscriptions[s] = struct{}{}
+	return s, nil
+}
+
+func (b *EventBusOnMemory) Unsubscribe(subscription *Subscription) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if _, ok := b.subscriptions[subscription]; ok {
+		b.close(subscription, nil)
+	}
+}
+
+func (b *EventBusOnMemory) close(s *Subscription, err error) {
+	delete(b.subscriptions, s)
+	s.mutex.Lock()
+	s.err = err
+	s.mutex.Unlock()
+	close(s.events)
+}
@@ -0,0 +1,93 @@
+package usecase_test
+
+import (
+	"github.com/pkg/errors"
+	. "github.com/satellitex/bbft/test_utils"
+	. "github.com/satellitex/bbft/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestEventFilter(t *testing.T) {
+	txHash := RandomByte()
+	for _, c := range []struct {
+		name     string
+		filter   *EventFilter
+		event    *Event
+		expected bool
+	}{
+		{"empty filter matches all", &EventFilter{}, &Event{Type: EventNewRound, Height: 10}, true},
+		{"type matched", &EventFilter{Types: []EventType{EventNewBlock, EventTx}}, &Event{Type: EventTx}, true},
+		{"type not matched", &EventFilter{Types: []EventType{EventNewBlock}}, &Event{Type: EventLock}, false},
+		{"tx hash matched", &EventFilter{TxHash: txHash}, &Event{Type: EventTx, Hash: txHash}, true},
+		{"tx hash not matched", &EventFilter{TxHash: txHash}, &Event{Type: EventTx, Hash: RandomByte()}, false},
+		{"tx hash is not used for other events", &EventFilter{TxHash: txHash}, &Event{Type: EventNewBlock, Hash: RandomByte()}, true},
+		{"in height range", &EventFilter{FromHeight: 2, ToHeight: 4}, &Event{Height: 4}, true},
+		{"below height range", &EventFilter{FromHeight: 2, ToHeight: 4}, &Event{Height: 1}, false},
+		{"above height range", &EventFilter{FromHeight: 2, ToHeight: 4}, &Event{Height: 5}, false},
+		{"no upper height", &EventFilter{FromHeight: 2}, &Event{Height: 100}, true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.expected, c.filter.Match(c.event))
+		})
+	}
+
+	t.Run("invalid filter", func(t *testing.T) {
+		assert.NoError(t, (&EventFilter{FromHeight: 3, ToHeight: 3}).Validate())
+		assert.EqualError(t, errors.Cause((&EventFilter{FromHeight: 3, ToHeight: 2}).Validate()), ErrEventBusInvalidFilter.Error())
+		assert.EqualError(t, errors.Cause((&EventFilter{FromHeight: -1}).Validate()), ErrEventBusInvalidFilter.Error())
+	})
+}
+
+func TestEventBusOnMemory(t *testing.T) {
+	conf := GetTestConfig()
+	conf.EventSubscriptionBufferSize = 2
+	bus := NewEventBusOnMemory(conf)
+
+	t.Run("failed subscribe invalid filter", func(t *testing.T) {
+		_, err := bus.Subscribe(&EventFilter{FromHeight: 2, ToHeight: 1})
+		assert.EqualError(t, errors.Cause(err), ErrEventBusInvalidFilter.Error())
+	})
+
+	t.Run("success publish to matched subscribers and unsubscribe", func(t *testing.T) {
+		blocks, err := bus.Subscribe(&EventFilter{Types: []EventType{EventNewBlock}})
+		require.NoError(t, err)
+		rounds, err := bus.Subscribe(&EventFilter{Types: []EventType{EventNewRound}})
+		require.NoError(t, err)
+
+		event := &Event{Type: EventNewBlock, Height: 1}
+		bus.Publish(event)
+		bus.Publish(&Event{Type: EventLock, Height: 1})
+		assert.Equal(t, event, <-blocks.Events())
+		assert.Len(t, rounds.Events(), 0)
+
+		bus.Unsubscribe(blocks)
+		bus.Unsubscribe(rounds)
+		_, ok := <-blocks.Events()
+		assert.False(t, ok)
+		assert.NoError(t, blocks.Err())
+		// 2回目の Unsubscribe は何もしない
+		bus.Unsubscribe(blocks)
+	})
+
+	t.Run("success slow subscriber is closed without blocking others", func(t *testing.T) {
+		slow, err := bus.Subscribe(nil)
+		require.NoError(t, err)
+		fast, err := bus.Subscribe(nil)
+		require.NoError(t, err)
+		defer bus.Unsubscribe(fast)
+
+		for i := int64(0); i < 4; i++ {
+			bus.Publish(&Event{Type: EventNewRound, Height: i})
+			assert.Equal(t, i, (<-fast.Events()).Height)
+		}
+
+		received := 0
+		for range slow.Events() {
+			received++
+		}
+		assert.Equal(t, conf.EventSubscriptionBufferSize, received)
+		assert.EqualError(t, errors.Cause(slow.Err()), ErrEventBusSlowSubscriber.Error())
+	})
+}
@@ -0,0 +1,108 @@
+package usecase
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/satellitex/bbft/config"
+	"github.com/satellitex/bbft/model"
+	"strconv"
+	"sync"
+)
+
+var ErrDetectEquivocation = errors.New("Failed Detect Equivocation")
+
+// EvidenceDetector は Peer ごとに height, round で最初に受け取った Proposal, PreCommit を覚えておき、
+// 異なるものを受け取ったとき、その2つから自分の署名をつけた Evidence を作る
+type EvidenceDetector struct {
+	conf       *config.BBFTConfig
+	factory    model.ModelFactory
+	proposals  map[string]model.Proposal
+	preCommits map[string]model.VoteMessage
+	q          []string
+	mutex      *sync.Mutex
+}
+
+func NewEvidenceDetector(conf *config.BBFTConfig, factory model.ModelFactory) *EvidenceDetector {
+	return &EvidenceDetector{
+		conf:       conf,
+		factory:    factory,
+		proposals:  make(map[string]model.Proposal),
+		preCommits: make(map[string]model.VoteMessage),
+		q:          make([]string, 0, conf.EvidenceDetectorLimits),
+		mutex:      new(sync.Mutex),
+	}
+}
+
+func detectorKey(kind string, height int64, round int32, pubkey []byte) string {
+	return kind + "::" + strconv.FormatInt(height, 16) + "::" + strconv.FormatInt(int64(round), 16) + "::" + fmt.Sprintf("%x", pubkey)
+}
+
+// 古いものから忘れる
+func (d *EvidenceDetector) remember(key string) {
+	d.q = append(d.q, key)
+	if len(d.q) > d.conf.EvidenceDetectorLimits {
+		delete(d.proposals, d.q[0])
+		delete(d.preCommits, d.q[0])
+		d.q = d.q[1:]
+	}
+}
+
+// CheckProposal は proposal が同じリーダーの同じ height, round の異なる Block の Proposal であるとき Evidence を返す
+// 提案し直された Proposal も round のリーダーが提案したものなので、Proposal の round と model.GetProposer で比べる
+// 不正が無いときは nil を返す。proposal は署名が検証済みである必要がある
+func (d *EvidenceDetector) CheckProposal(proposal model.Proposal) (model.Evidence, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	block := proposal.GetBlock()
+	key := detectorKey("proposal", block.GetHeader().GetHeight(), proposal.GetRound(), model.GetProposer(proposal))
+	first, ok := d.proposals[key]
+	if !ok {
+		d.proposals[key] = proposal
+		d.remember(key)
+		return nil, nil
+	}
+	firstHash, err := first.GetBlock().GetHash()
+	if err != nil {
+		return nil, errors.Wrapf(model.ErrBlockGetHash, err.Error())
+	}
+	hash, err := block.GetHash()
+	if err != nil {
+		return nil, errors.Wrapf(model.ErrBlockGetHash, err.Error())
+	}
+	if bytes.Equal(firstHash, hash) {
+		return nil, nil
+	}
+	return d.newEvidence(model.DuplicateProposal, []model.Proposal{first, proposal}, nil)
+}
+
+// CheckPreCommit は preCommit が同じ Peer の同じ height, round の異なる blockHash の PreCommit であるとき Evidence を返す
+// 不正が無いときは nil を返す。preCommit は署名が検証済みである必要がある
+func (d *EvidenceDetector) CheckPreCommit(preCommit model.VoteMessage) (model.Evidence, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	key := detectorKey("preCommit", preCommit.GetHeight(), preCommit.GetRound(), preCommit.GetSignature().GetPubkey())
+	first, ok := d.preCommits[key]
+	if !ok {
+		d.preCommits[key] = preCommit
+		d.remember(key)
+		return nil, nil
+	}
+	if bytes.Equal(first.GetBlockHash(), preCommit.GetBlockHash()) {
+		return nil, nil
+	}
+	return d.newEvidence(model.DuplicatePreCommit, nil, []model.VoteMessage{first, preCommit})
+}
+
+func (d *EvidenceDetector) newEvidence(evidenceType model.EvidenceType, proposals []model.Proposal, votes []model.VoteMessage) (model.Evidence, error) {
+	evidence, err := d.factory.NewEvidence(evidenceType, proposals, votes)
+	if err != nil {
+		return nil, errors.Wrapf(model.ErrNewEvidence, err.Error())
+	}
+	if err := evidence.Sign(d.conf.PublicKey, d.conf.SecretKey); err != nil {
+		return nil, errors.Wrapf(model.ErrEvidenceSign, err.Error())
+	}
+	return evidence, nil
+}
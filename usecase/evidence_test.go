@@ -0,0 +1,161 @@
+package usecase_test
+
+import (
+	"github.com/satellitex/bbft/convertor"
+	"github.com/satellitex/bbft/model"
+	. "github.com/satellitex/bbft/test_utils"
+	. "github.com/satellitex/bbft/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestEvidenceDetector_CheckProposal(t *testing.T) {
+	conf := GetTestConfig()
+	detector := NewEvidenceDetector(conf, convertor.NewModelFactory())
+	offender := RandomPeerWithPriv()
+
+	first := RandomProposalWithPeer(t, 1, 0, offender)
+	evidence, err := detector.CheckProposal(first)
+	require.NoError(t, err)
+	assert.Nil(t, evidence)
+
+	t.Run("same proposal, no evidence", func(t *testing.T) {
+		evidence, err := detector.CheckProposal(first)
+		require.NoError(t, err)
+		assert.Nil(t, evidence)
+	})
+
+	t.Run("other round, no evidence", func(t *testing.T) {
+		evidence, err := detector.CheckProposal(RandomProposalWithPeer(t, 1, 1, offender))
+		require.NoError(t, err)
+		assert.Nil(t, evidence)
+	})
+
+	t.Run("same block re-proposed in later round, no evidence", func(t *testing.T) {
+		reproposal, err := convertor.NewModelFactory().NewReProposal(first.GetBlock(), 5, 0)
+		require.NoError(t, err)
+		require.NoError(t, reproposal.Sign(offender.GetPubkey(), offender.(*PeerWithPriv).PrivKey))
+		evidence, err := detector.CheckProposal(reproposal)
+		require.NoError(t, err)
+		assert.Nil(t, evidence)
+	})
+
+	t.Run("other leader, no evidence", func(t *testing.T) {
+		evidence, err := detector.CheckProposal(RandomProposalWithPeer(t, 1, 0, RandomPeerWithPriv()))
+		require.NoError(t, err)
+		assert.Nil(t, evidence)
+	})
+
+	t.Run("different proposal, detect equivocation", func(t *testing.T) {
+		second := RandomProposalWithPeer(t, 1, 0, offender)
+		evidence, err := detector.CheckProposal(second)
+		require.NoError(t, err)
+		require.NotNil(t, evidence)
+
+		assert.Equal(t, model.DuplicateProposal, evidence.GetType())
+		assert.Equal(t, []model.Proposal{first, second}, evidence.GetProposals())
+		assert.Equal(t, offender.GetPubkey(), evidence.GetOffender())
+		assert.Equal(t, conf.PublicKey, evidence.GetSignature().GetPubkey())
+		assert.NoError(t, evidence.Verify())
+	})
+
+	// 提案し直した Proposal は Proposal に署名したリーダーが Proposal の round で提案したものとする
+	leader := RandomPeerWithPriv()
+	reproposal := func(t *testing.T, round int32) model.Proposal {
+		proposal, err := convertor.NewModelFactory().NewReProposal(RandomProposalWithPeer(t, 1, 0, offender).GetBlock(), round, 0)
+		require.NoError(t, err)
+		require.NoError(t, proposal.Sign(leader.GetPubkey(), leader.(*PeerWithPriv).PrivKey))
+		return proposal
+	}
+
+	t.Run("re-proposal and new block in same round, detect equivocation", func(t *testing.T) {
+		first := reproposal(t, 2)
+		evidence, err := detector.CheckProposal(first)
+		require.NoError(t, err)
+		require.Nil(t, evidence)
+
+		evidence, err = detector.CheckProposal(RandomProposalWithPeer(t, 1, 2, leader))
+		require.NoError(t, err)
+		require.NotNil(t, evidence)
+		assert.Equal(t, leader.GetPubkey(), evidence.GetOffender())
+		assert.Equal(t, int32(2), evidence.GetRound())
+	})
+
+	t.Run("two re-proposals in same round, detect equivocation", func(t *testing.T) {
+		evidence, err := detector.CheckProposal(reproposal(t, 3))
+		require.NoError(t, err)
+		require.Nil(t, evidence)
+
+		evidence, err = detector.CheckProposal(reproposal(t, 3))
+		require.NoError(t, err)
+		require.NotNil(t, evidence)
+		assert.Equal(t, leader.GetPubkey(), evidence.GetOffender())
+		assert.Equal(t, int32(3), evidence.GetRound())
+	})
+}
+
+func TestEvidenceDetector_CheckPreCommit(t *testing.T) {
+	conf := GetTestConfig()
+	detector := NewEvidenceDetector(conf, convertor.NewModelFactory())
+	offender := RandomPeerWithPriv()
+
+	newPreCommit := func(round int32, hash []byte) model.VoteMessage {
+		vote := NewTestVoteMessage(model.PreCommit, 1, round, hash)
+		require.NoError(t, vote.Sign(offender.GetPubkey(), offender.(*PeerWithPriv).PrivKey))
+		return vote
+	}
+
+	hash := RandomByte()
+	first := newPreCommit(0, hash)
+	evidence, err := detector.CheckPreCommit(first)
+	require.NoError(t, err)
+	assert.Nil(t, evidence)
+
+	t.Run("same blockHash, no evidence", func(t *testing.T) {
+		evidence, err := detector.CheckPreCommit(newPreCommit(0, hash))
+		require.NoError(t, err)
+		assert.Nil(t, evidence)
+	})
+
+	t.Run("other round, no evidence", func(t *testing.T) {
+		evidence, err := detector.CheckPreCommit(newPreCommit(1, RandomByte()))
+		require.NoError(t, err)
+		assert.Nil(t, evidence)
+	})
+
+	t.Run("different blockHash, detect equivocation", func(t *testing.T) {
+		second := newPreCommit(0, RandomByte())
+		evidence, err := detector.CheckPreCommit(second)
+		require.NoError(t, err)
+		require.NotNil(t, evidence)
+
+		assert.Equal(t, model.DuplicatePreCommit, evidence.GetType())
+		assert.Equal(t, []model.VoteMessage{first, second}, evidence.GetVotes())
+		assert.Equal(t, offender.GetPubkey(), evidence.GetOffender())
+		assert.NoError(t, evidence.Verify())
+	})
+}
+
+func TestEvidenceDetector_Limits(t *testing.T) {
+	conf := GetTestConfig()
+	conf.EvidenceDetectorLimits = 2
+	detector := NewEvidenceDetector(conf, convertor.NewModelFactory())
+	offender := RandomPeerWithPriv()
+
+	for round := int32(0); round < 3; round++ {
+		evidence, err := detector.CheckProposal(RandomProposalWithPeer(t, 1, round, offender))
+		require.NoError(t, err)
+		assert.Nil(t, evidence)
+	}
+
+	// round 0 is forgotten
+	evidence, err := detector.CheckProposal(RandomProposalWithPeer(t, 1, 0, offender))
+	require.NoError(t, err)
+	assert.Nil(t, evidence)
+
+	// round 2 is remembered
+	evidence, err = detector.CheckProposal(RandomProposalWithPeer(t, 1, 2, offender))
+	require.NoError(t, err)
+	assert.NotNil(t, evidence)
+}
@@ -0,0 +1,676 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/satellitex/bbft/config"
+	"github.com/satellitex/bbft/dba"
+	"github.com/satellitex/bbft/model"
+	"log"
+	"math"
+	"time"
+)
+
+const (
+	ConsensusEnginePBFT     = "pbft"
+	ConsensusEngineHotStuff = "hotstuff"
+)
+
+var (
+	ErrHotStuffInvalidProposal = errors.Errorf("Failed HotStuff Invalid Proposal")
+	ErrHotStuffUnknownParent   = errors.Errorf("Failed HotStuff Unknown Parent Block")
+	ErrHotStuffUnsafeProposal  = errors.Errorf("Failed HotStuff Proposal violates voting rule")
+	ErrHotStuffNotSupported    = errors.Errorf("Failed HotStuff does not support")
+	ErrHotStuffViewOverflow    = errors.Errorf("Failed HotStuff view reached the max")
+)
+
+// HotStuffUsecase は chained HotStuff で合意を取る ConsensusStep
+//
+// view は height によらず単調に増え、Proposal の round に入る。
+// view v のリーダーは highQC が指す Block の子を提案し、各 Peer は投票 (PreCommit) を view v+1 のリーダーだけに送る。
+// リーダーは提案する Block の height と view で決める。投票先は投票した Block の子の height と view v+1 のリーダーである。
+// view v+1 のリーダーは投票を集めて QC (CommitCertificate) を作り、次の Proposal の justify に入れる。
+// b0 <- b1 <- b2 がそれぞれ親の QC を持ち、view が連続していて、b2 の QC が集まると b0 までの Block を Commit する。
+//
+// 投票するのは view が最後に投票した view より大きく、justify の view が preferred (2-chain の先頭の view) 以上の Proposal だけである。
+// view が進むのは検証した QC を受け取ったときと、自分の view がタイムアウトしたときだけである。Proposal の view には移らない。
+// 同じ view で二度投票しないよう、送った投票を WAL に記録し、再起動したときに最後に投票した view を復元する。
+// ValidatorUpdate を有効にする height が合意の途中の Block と重なるので、ValidatorUpdate と Evidence を含む Block は提案も投票もしない。
+type HotStuffUsecase struct {
+	conf     *config.BBFTConfig
+	bc       dba.BlockChain
+	ps       dba.PeerService
+	selector LeaderSelector
+	queue    dba.ProposalTxQueue
+	sender   model.ConsensusSender
+	slv      model.StatelessValidator
+	sfv      model.StatefulValidator
+	cv       model.CommitCertificateValidator
+	factory  model.ModelFactory
+	syncer   BlockSync
+	wal      model.WAL
+	clock    Clock
+	channel  *ReceiveChannel
+	// done は Run の ctx が終わると閉じられ、各 Phase の待ち受けを終わらせる
+	done <-chan struct{}
+
+	// blocks は Commit されていない Proposal を Block の Hash ごとに持つ
+	blocks map[string]model.Proposal
+	// views は view ごとに最初に受け取った Proposal の Block の Hash を持つ
+	views map[int32][]byte
+	// qcs は Block の Hash ごとにその Block に対する QC を持つ
+	qcs             map[string]model.CommitCertificate
+	preCommitFinder *PreCommitFinder
+
+	View int32
+	// LastVoted は最後に投票した view, Preferred は 2-chain の先頭の Block の view
+	LastVoted int32
+	Preferred int32
+	// HighQC は受け取った中で view が最も大きい QC。genesis の子を提案するまでは nil
+	HighQC           model.CommitCertificate
+	ProposeTimeOut   time.Duration
+	VoteTimeOut      time.Duration
+	PreCommitTimeOut time.Duration
+}
+
+func NewHotStuffUsecase(conf *config.BBFTConfig, bc dba.BlockChain, ps dba.PeerService, selector LeaderSelector,
+	queue dba.ProposalTxQueue, sender model.ConsensusSender, slv model.StatelessValidator, sfv model.StatefulValidator,
+	cv model.CommitCertificateValidator, factory model.ModelFactory, syncer BlockSync, wal model.WAL, clock Clock, channel *ReceiveChannel) ConsensusStep {
+	return &HotStuffUsecase{
+		conf:            conf,
+		bc:              bc,
+		ps:              ps,
+		selector:        selector,
+		queue:           queue,
+		sender:          sender,
+		slv:             slv,
+		sfv:             sfv,
+		cv:              cv,
+		factory:         factory,
+		syncer:          syncer,
+		wal:             wal,
+		clock:           clock,
+		channel:         channel,
+		blocks:          make(map[string]model.Proposal),
+		views:           make(map[int32][]byte),
+		qcs:             make(map[string]model.CommitCertificate),
+		preCommitFinder: NewPreCommitFinder(ps, conf),
+		LastVoted:       -1,
+		Preferred:       -1,
+	}
+}
+
+// Runnning Consensus until ctx is done.
+// 1つの view で Propose, Vote, PreCommit, Commit を行い、view が進まないまま PreCommit Phase が終わると次の view に進む
+// 始める前に WAL を読み直し、最後に投票した view より後の view から始める
+func (c *HotStuffUsecase) Run(ctx context.Context) error {
+	log.Println("============== Running HotStuff Consensus!! ==============")
+	c.done = ctx.Done()
+	if err := c.Replay(); err != nil {
+		log.Println("HotStuff WAL Replay Error!!", err)
+	}
+	c.resume()
+	failures := int32(0)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// view+1 のリーダーに投票するので、最後の view には進まない
+		if c.View == math.MaxInt32 {
+			return errors.Wrapf(ErrHotStuffViewOverflow, "view: %d", c.View)
+		}
+		if c.syncer.IsBehind() {
+			if err := c.syncer.Sync(); err != nil {
+				log.Println("Consensus BlockSync Error!!", err)
+			}
+			c.resume()
+			if top, ok := c.bc.Top(); ok {
+				c.cleanWAL(top.GetHeader().GetHeight() + 1)
+			}
+		}
+		view, height := c.View, c.nextHeight()
+		log.Println("============== Running HotStuff Consensus!! ============== height:", height, "view:", view)
+
+		// 続けて view が進まなかった回数だけ各 Phase の時間を伸ばす
+		start := time.Duration(c.clock.Now())
+		c.ProposeTimeOut = start + Backoff(c.conf, c.conf.ProposeMaxCalcTime, failures) + c.conf.AllowedConnectDelayTime
+		c.VoteTimeOut = c.ProposeTimeOut + Backoff(c.conf, c.conf.VoteMaxCalcTime, failures) + c.conf.AllowedConnectDelayTime
+		c.PreCommitTimeOut = c.VoteTimeOut + Backoff(c.conf, c.conf.PreCommitMaxCalcTime, failures) + c.conf.AllowedConnectDelayTime
+
+		if err := c.Propose(height, view); err != nil {
+			log.Println("HotStuff ProposePhase Error!!", "height:", height, "view:", view, err)
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := c.Vote(height, view); err != nil {
+			log.Println("HotStuff VotePhase Error!!", "height:", height, "view:", view, err)
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := c.PreCommit(height, view); err != nil {
+			log.Println("HotStuff PreCommitPhase Error!!", "height:", height, "view:", view, err)
+		}
+		if err := c.Commit(height, view); err != nil {
+			log.Println("HotStuff CommitPhase Error!!", "height:", height, "view:", view, err)
+		}
+
+		if c.View > view {
+			failures = 0
+		} else {
+			c.View = view + 1
+			failures++
+			c.rebroadcast()
+		}
+	}
+}
+
+// Replay は WAL に記録した自分の投票から、最後に投票した view を復元する
+func (c *HotStuffUsecase) Replay() error {
+	records, err := c.wal.ReadAll()
+	if err != nil {
+		return errors.Wrapf(ErrConsensusReplay, err.Error())
+	}
+	for _, record := range records {
+		// WALRound は cleanWAL が消した投票の代わりに残した、最後に投票した view
+		voted := record.GetType() == model.WALRound || (record.GetType() == model.WALPreCommit && record.IsSent())
+		if voted && record.GetRound() > c.LastVoted {
+			c.LastVoted = record.GetRound()
+		}
+	}
+	if c.View <= c.LastVoted {
+		c.View = c.LastVoted + 1
+	}
+	log.Println("Replayed HotStuff WAL, records:", len(records), "last voted view:", c.LastVoted)
+	return nil
+}
+
+// resume は Commit 済みの Block の QC から highQC と view を復元し、Commit 済みの height の Proposal を捨てる
+func (c *HotStuffUsecase) resume() {
+	top, ok := c.bc.Top()
+	if !ok {
+		panic("Unexpected Error No BlockChain Top")
+	}
+	if qc, ok := c.bc.GetCommitCertificate(top.GetHeader().GetHeight()); ok && qc != nil {
+		c.updateHighQC(qc)
+	}
+	c.prune(top.GetHeader().GetHeight())
+}
+
+func qcRound(qc model.CommitCertificate) int32 {
+	if qc == nil {
+		return -1
+	}
+	return qc.GetRound()
+}
+
+func (c *HotStuffUsecase) updateHighQC(qc model.CommitCertificate) {
+	if qcRound(qc) > qcRound(c.HighQC) {
+		c.HighQC = qc
+	}
+	if c.View <= qc.GetRound() && qc.GetRound() < math.MaxInt32 {
+		c.View = qc.GetRound() + 1
+	}
+}
+
+// nextHeight は highQC の Block の子の height を返す
+func (c *HotStuffUsecase) nextHeight() int64 {
+	if c.HighQC == nil {
+		return 1
+	}
+	return c.HighQC.GetHeight() + 1
+}
+
+// prune は height 以下の Proposal と QC, 投票と、今の view より古い view を捨てる
+func (c *HotStuffUsecase) prune(height int64) {
+	c.preCommitFinder.Clean(height + 1)
+	for hash, proposal := range c.blocks {
+		if proposal.GetBlock().GetHeader().GetHeight() <= height {
+			delete(c.blocks, hash)
+			delete(c.qcs, hash)
+		}
+	}
+	for view := range c.views {
+		if view < c.View {
+			delete(c.views, view)
+		}
+	}
+}
+
+// findBlock は justify が指す Block を Commit されていない Block と Commit 済みの Block から探す
+// justify が nil のときは genesis block を返す
+func (c *HotStuffUsecase) findBlock(justify model.CommitCertificate) (model.Block, bool) {
+	if justify == nil {
+		return c.bc.GetBlock(0)
+	}
+	if proposal, ok := c.blocks[string(justify.GetBlockHash())]; ok {
+		return proposal.GetBlock(), true
+	}
+	if block, ok := c.bc.GetBlock(justify.GetHeight()); ok && bytes.Equal(model.MustGetHash(block), justify.GetBlockHash()) {
+		return block, true
+	}
+	return nil, false
+}
+
+// uncommitted は hash の Block から Commit 済みの先頭の Block の子までを新しい順に返す
+// 途中の Block を持っていないか、Commit 済みの Block から分岐している場合は false を返す
+func (c *HotStuffUsecase) uncommitted(hash []byte) ([]model.Block, bool) {
+	top, ok := c.bc.Top()
+	if !ok {
+		return nil, false
+	}
+	topHash := model.MustGetHash(top)
+	ret := make([]model.Block, 0)
+	for !bytes.Equal(hash, topHash) {
+		proposal, ok := c.blocks[string(hash)]
+		if !ok {
+			return nil, false
+		}
+		block := proposal.GetBlock()
+		if block.GetHeader().GetHeight() <= top.GetHeader().GetHeight() {
+			return nil, false
+		}
+		ret = append(ret, block)
+		hash = block.GetHeader().GetPreBlockHash()
+	}
+	return ret, true
+}
+
+// rebroadcast は highQC の Block から親を辿れるだけ辿った Proposal を全ての Peer に送り直す
+// view が進まない原因が Proposal を受け取れなかった Peer の投票できないことであれば、次の view で投票できるようになる
+func (c *HotStuffUsecase) rebroadcast() {
+	if c.HighQC == nil {
+		return
+	}
+	hash := c.HighQC.GetBlockHash()
+	for {
+		proposal, ok := c.blocks[string(hash)]
+		if !ok {
+			return
+		}
+		if err := c.sender.Propose(proposal); err != nil {
+			//log.Println(err)
+		}
+		hash = proposal.GetBlock().GetHeader().GetPreBlockHash()
+	}
+}
+
+// qcFor は block に対する QC を返す。子の Proposal を親より先に受け取っていた場合は、子の justify を検証して使う
+func (c *HotStuffUsecase) qcFor(block model.Block) (model.CommitCertificate, bool) {
+	hash := model.MustGetHash(block)
+	if qc, ok := c.qcs[string(hash)]; ok {
+		return qc, true
+	}
+	for _, proposal := range c.blocks {
+		justify := proposal.GetJustify()
+		if justify == nil || !bytes.Equal(justify.GetBlockHash(), hash) {
+			continue
+		}
+		if err := c.cv.Validate(block, justify); err == nil {
+			c.qcs[string(hash)] = justify
+			return justify, true
+		}
+	}
+	return nil, false
+}
+
+// Propose は自分が view のリーダーのとき、highQC の Block の子を提案する
+// 自分の Proposal も他の Peer と同じく受け取ってから投票する
+func (c *HotStuffUsecase) Propose(height int64, view int32) error {
+	if leader, ok := c.selector.GetLeader(height, view); !ok || !bytes.Equal(leader.GetPubkey(), c.conf.PublicKey) {
+		// Leader is not me
+		c.receive(c.ProposeTimeOut, func() bool {
+			_, ok := c.views[view]
+			return ok || c.View > view
+		})
+		return nil
+	}
+	log.Println("ProposePhase : Leader is Me")
+	parent, ok := c.findBlock(c.HighQC)
+	if !ok {
+		if c.HighQC == nil {
+			return errors.Wrapf(ErrConsensusProposal, "not found genesis block")
+		}
+		return errors.Wrapf(ErrConsensusProposal, "not found highQC block: %x", c.HighQC.GetBlockHash())
+	}
+	if h := parent.GetHeader().GetHeight() + 1; h != height {
+		return errors.Wrapf(ErrConsensusProposal, "height: %d, expected %d", height, h)
+	}
+	pending, ok := c.uncommitted(model.MustGetHash(parent))
+	if !ok {
+		return errors.Wrapf(ErrConsensusProposal, "highQC block is not connected to committed blocks: %x", model.MustGetHash(parent))
+	}
+	included := pendingTxs(pending)
+
+	// CreatedTime は親の CreatedTime より後でなければ Commit できない
+	createdTime := c.clock.Now()
+	if min := parent.GetHeader().GetCreatedTime() + 1; createdTime < min {
+		createdTime = min
+	}
+	// highQC は親の Block の QC なので lastCommit にする。genesis block の子のときは nil
+	state := c.factory.NewChainState(c.conf.PublicKey, view, parent.GetHeader().GetNextStateRoot(),
+		c.ps.AtHeight(height).GetPeers(), c.HighQC)
+	// Transaction 以外の大きさを引いた残りに Transaction を詰める
+	base, err := c.factory.NewBlock(height, model.MustGetHash(parent), createdTime, nil, nil, state)
+	if err != nil {
+		return errors.Wrapf(ErrConsensusProposal, err.Error())
+	}
+	if err := base.Sign(c.conf.PublicKey, c.conf.SecretKey); err != nil {
+		return errors.Wrapf(ErrConsensusProposal, err.Error())
+	}
+	txs := collectTxs(c.conf, c.queue, base, func(tx model.Transaction) bool {
+		if err := c.slv.TxValidate(tx); err != nil {
+			return true
+		}
+		if _, ok := tx.GetPayload().GetValidatorUpdate(); ok {
+			// Receiver で拒否するので、ここに来るのは hotstuff に切り替える前に Queue に入っていたものだけ
+			log.Printf("ProposePhase : drop validator update transaction, hotstuff does not support: %x\n", model.MustGetHash(tx))
+			return true
+		}
+		hash := model.MustGetHash(tx)
+		if _, ok := c.bc.FindTx(hash); ok {
+			return true // Already Exist Transaction
+		}
+		_, ok := included[string(hash)] // Already Proposed Transaction
+		return ok
+	})
+
+	block, err := c.factory.NewBlock(height, model.MustGetHash(parent), createdTime, txs, nil, state)
+	if err != nil {
+		return errors.Wrapf(ErrConsensusProposal, err.Error())
+	}
+	if err := block.Sign(c.conf.PublicKey, c.conf.SecretKey); err != nil {
+		return errors.Wrapf(ErrConsensusProposal, err.Error())
+	}
+	proposal, err := c.factory.NewJustifiedProposal(block, view, c.HighQC)
+	if err != nil {
+		return errors.Wrapf(ErrConsensusProposal, err.Error())
+	}
+	if err := c.sender.Propose(proposal); err != nil {
+		//log.Println(err)
+	}
+	c.receive(c.ProposeTimeOut, func() bool {
+		_, ok := c.views[view]
+		return ok || c.View > view
+	})
+	return nil
+}
+
+// pendingTxs は Commit されていない Block に含まれる Transaction の Hash の集合を返す
+func pendingTxs(pending []model.Block) map[string]struct{} {
+	ret := make(map[string]struct{})
+	for _, block := range pending {
+		for _, tx := range block.GetTransactions() {
+			ret[string(model.MustGetHash(tx))] = struct{}{}
+		}
+	}
+	return ret
+}
+
+// Vote は view の Proposal が投票の規則を満たし、正しい Block であれば、view+1 のリーダーに投票する
+func (c *HotStuffUsecase) Vote(height int64, view int32) error {
+	if c.View != view || c.LastVoted >= view || view == math.MaxInt32 {
+		return nil
+	}
+	hash, ok := c.views[view]
+	if !ok {
+		return errors.Wrapf(ErrConsensusVote, "proposal Not Found, view: %d", view)
+	}
+	proposal := c.blocks[string(hash)]
+	if err := c.validateProposal(proposal); err != nil {
+		return errors.Wrapf(ErrConsensusVote, err.Error())
+	}
+	block := proposal.GetBlock()
+	leader, ok := c.selector.GetLeader(block.GetHeader().GetHeight()+1, view+1)
+	if !ok {
+		return errors.Wrapf(ErrConsensusVote, "next leader Not Found, view: %d", view+1)
+	}
+	c.LastVoted = view
+	log.Println("ThisViewPropsoal: ", fmt.Sprintf("%x", hash))
+	vote := c.factory.NewVoteMessage(c.conf.ChainId, block.GetHeader().GetHeight(), view, model.PreCommit, hash)
+	if err := vote.Sign(c.conf.PublicKey, c.conf.SecretKey); err != nil {
+		return errors.Wrapf(ErrConsensusVote, err.Error())
+	}
+	// 記録できなかった投票は送らない
+	if err := c.wal.WriteVote(vote, true); err != nil {
+		return errors.Wrapf(ErrConsensusVote, err.Error())
+	}
+	if err := c.sender.PreCommitTo(leader, vote); err != nil {
+		//log.Println(err)
+	}
+	return nil
+}
+
+// validateProposal は proposal に投票してよいかを確かめる
+// justify は onProposal で検証済みなので、投票の規則と Block の中身だけを確かめる
+func (c *HotStuffUsecase) validateProposal(proposal model.Proposal) error {
+	if proposal.GetRound() <= c.LastVoted {
+		return errors.Wrapf(ErrHotStuffUnsafeProposal, "view: %d, last voted: %d", proposal.GetRound(), c.LastVoted)
+	}
+	if r := qcRound(proposal.GetJustify()); r < c.Preferred {
+		return errors.Wrapf(ErrHotStuffUnsafeProposal, "justify view: %d, preferred: %d", r, c.Preferred)
+	}
+	block := proposal.GetBlock()
+	parent, ok := c.findBlock(proposal.GetJustify())
+	if !ok {
+		return errors.Wrapf(ErrHotStuffUnknownParent, "preBlockHash: %x", block.GetHeader().GetPreBlockHash())
+	}
+	if h := parent.GetHeader().GetHeight() + 1; block.GetHeader().GetHeight() != h {
+		return errors.Wrapf(ErrHotStuffInvalidProposal, "height: %d, expected %d", block.GetHeader().GetHeight(), h)
+	}
+	if hash := model.MustGetHash(parent); !bytes.Equal(block.GetHeader().GetPreBlockHash(), hash) {
+		return errors.Wrapf(ErrHotStuffInvalidProposal, "preBlockHash: %x, expected %x", block.GetHeader().GetPreBlockHash(), hash)
+	}
+	if block.GetHeader().GetCreatedTime() <= parent.GetHeader().GetCreatedTime() {
+		return errors.Wrapf(ErrHotStuffInvalidProposal, "createdTime: %d, parent createdTime: %d",
+			block.GetHeader().GetCreatedTime(), parent.GetHeader().GetCreatedTime())
+	}
+	skew := time.Duration(block.GetHeader().GetCreatedTime() - c.clock.Now())
+	if skew < 0 {
+		skew = -skew
+	}
+	if max := c.conf.MaxClockSkew + c.conf.AllowedConnectDelayTime; skew > max {
+		return errors.Wrapf(model.ErrBlockCreatedTimeSkew, "createdTime: %d, skew: %v, max: %v", block.GetHeader().GetCreatedTime(), skew, max)
+	}
+	if len(block.GetEvidences()) > 0 {
+		return errors.Wrapf(ErrHotStuffInvalidProposal, "evidences are not supported")
+	}
+	pending, ok := c.uncommitted(model.MustGetHash(parent))
+	if !ok {
+		return errors.Wrapf(ErrHotStuffUnknownParent, "parent is not connected to committed blocks: %x", model.MustGetHash(parent))
+	}
+	included := pendingTxs(pending)
+	for _, tx := range block.GetTransactions() {
+		if _, ok := tx.GetPayload().GetValidatorUpdate(); ok {
+			return errors.Wrapf(ErrHotStuffInvalidProposal, "validator updates are not supported")
+		}
+		hash := model.MustGetHash(tx)
+		if _, ok := c.bc.FindTx(hash); ok {
+			return errors.Wrapf(ErrHotStuffInvalidProposal, "already committed transaction: %x", hash)
+		}
+		if _, ok := included[string(hash)]; ok {
+			return errors.Wrapf(ErrHotStuffInvalidProposal, "already proposed transaction: %x", hash)
+		}
+		included[string(hash)] = struct{}{}
+	}
+	return nil
+}
+
+// PreCommit は自分が height+1, view+1 のリーダーのとき、view の height の Block への投票を集めて QC を作る
+// リーダーでないときは view+1 の Proposal を待つ
+func (c *HotStuffUsecase) PreCommit(height int64, view int32) error {
+	leader, ok := c.selector.GetLeader(height+1, view+1)
+	if !ok || !bytes.Equal(leader.GetPubkey(), c.conf.PublicKey) {
+		// Leader is not me
+		c.receive(c.PreCommitTimeOut, func() bool {
+			return c.View > view
+		})
+		return nil
+	}
+	if ok := c.receive(c.PreCommitTimeOut, func() bool {
+		return qcRound(c.HighQC) >= view
+	}); !ok && qcRound(c.HighQC) < view {
+		return errors.Wrapf(ErrConsensusPreCommit, "This View Can't collect 2/3+ votes, so try to next View: %d -> %d", view, view+1)
+	}
+	return nil
+}
+
+// Commit は highQC から 3-chain を辿り、view が連続していれば b0 までの Block を順に Commit する
+func (c *HotStuffUsecase) Commit(height int64, view int32) error {
+	if c.HighQC == nil {
+		return nil
+	}
+	b2, ok := c.blocks[string(c.HighQC.GetBlockHash())]
+	if !ok || b2.GetJustify() == nil {
+		return nil
+	}
+	b1, ok := c.blocks[string(b2.GetJustify().GetBlockHash())]
+	if !ok || b1.GetJustify() == nil {
+		return nil
+	}
+	if b2.GetRound() != b1.GetRound()+1 || b1.GetRound() != b1.GetJustify().GetRound()+1 {
+		return nil
+	}
+	hash := b1.GetJustify().GetBlockHash()
+	if top, ok := c.bc.Top(); ok && top.GetHeader().GetHeight() >= b1.GetJustify().GetHeight() {
+		return nil // Already Committed
+	}
+	pending, ok := c.uncommitted(hash)
+	if !ok {
+		// 途中の Block を持っていないので他の Peer から取得する
+		c.syncer.ObserveCertificate(b1.GetJustify())
+		return errors.Wrapf(ErrConsensusCommit, "not found uncommitted blocks to %x", hash)
+	}
+	for i := len(pending) - 1; i >= 0; i-- {
+		block := pending[i]
+		qc, ok := c.qcFor(block)
+		if !ok {
+			return errors.Wrapf(ErrConsensusCommit, "Not Found QC: %x", model.MustGetHash(block))
+		}
+		if err := c.sfv.Validate(block); err != nil {
+			return errors.Wrapf(ErrConsensusCommit, err.Error())
+		}
+		c.bc.Commit(block, qc)
+		if err := commitValidatorUpdates(c.conf, c.ps, block); err != nil {
+			log.Println(err)
+		}
+		log.Println("Commited Block: ", fmt.Sprintf("%x", model.MustGetHash(block)), ", txSize:", len(block.GetTransactions()))
+	}
+	c.prune(b1.GetJustify().GetHeight())
+	c.cleanWAL(b1.GetJustify().GetHeight() + 1)
+	return nil
+}
+
+// cleanWAL は height 未満の記録を消す。消した投票の view より前に投票しないよう、先に最後に投票した view を height の記録として残す
+func (c *HotStuffUsecase) cleanWAL(height int64) {
+	if err := c.wal.WriteRound(height, c.LastVoted); err != nil {
+		log.Println("HotStuff WAL Error!!", err)
+		return
+	}
+	if err := c.wal.Clean(height); err != nil {
+		log.Println("HotStuff WAL Error!!", err)
+	}
+}
+
+// onProposal は受け取った Proposal を保存し、justify が正しければ QC として使う
+// 今の view 以降の Proposal は view ごとに最初のものを覚えておくが、Proposal の view には移らない。
+// リーダーの署名だけで view を進めると、先の view のリーダーが間のリーダーを飛ばせてしまう
+// リーダーはどの Height, view の Proposal にも署名できるので、HotStuffWindow の範囲の外の Proposal は覚えない
+func (c *HotStuffUsecase) onProposal(proposal model.Proposal) {
+	block := proposal.GetBlock()
+	if top, ok := c.bc.Top(); !ok || block.GetHeader().GetHeight() <= top.GetHeader().GetHeight() {
+		return
+	}
+	window := int64(c.conf.HotStuffWindow)
+	if view := int64(proposal.GetRound()); view < int64(c.View) || view >= int64(c.View)+window ||
+		block.GetHeader().GetHeight() > c.nextHeight()+window {
+		log.Printf("View: %d, Height: %d, proposal is out of window\n", proposal.GetRound(), block.GetHeader().GetHeight())
+		return
+	}
+	hash := model.MustGetHash(block)
+	if _, ok := c.blocks[string(hash)]; !ok {
+		c.blocks[string(hash)] = proposal
+	}
+	justify := proposal.GetJustify()
+	if qcRound(justify) >= proposal.GetRound() {
+		log.Printf("View: %d, justify view: %d is not older than proposal\n", proposal.GetRound(), qcRound(justify))
+		return
+	}
+	parent, ok := c.findBlock(justify)
+	if !ok {
+		// 親を持っていないので、Commit 済みの Block を他の Peer から取得する
+		c.syncer.ObserveCertificate(justify)
+		return
+	}
+	if justify != nil {
+		if err := c.cv.Validate(parent, justify); err != nil {
+			log.Printf("View: %d, invalid justify: %s\n", proposal.GetRound(), err.Error())
+			return
+		}
+		c.onQC(justify)
+	}
+	if _, ok := c.views[proposal.GetRound()]; !ok && proposal.GetRound() >= c.View {
+		c.views[proposal.GetRound()] = hash
+	}
+}
+
+// onPreCommit は受け取った投票を集め、2/3 以上集まると QC を作る
+func (c *HotStuffUsecase) onPreCommit(vote model.VoteMessage) {
+	if err := c.preCommitFinder.Set(vote); err != nil {
+		return
+	}
+	hash, preCommits, ok := c.preCommitFinder.Get(vote.GetHeight(), vote.GetRound())
+	if !ok {
+		return
+	}
+	qc, err := c.factory.NewCommitCertificate(vote.GetHeight(), vote.GetRound(), hash, preCommits)
+	if err != nil {
+		log.Println(errors.Wrapf(ErrConsensusPreCommit, err.Error()))
+		return
+	}
+	c.onQC(qc)
+}
+
+// onQC は検証済みの qc を保存し、qc の Block の justify から preferred を更新する
+func (c *HotStuffUsecase) onQC(qc model.CommitCertificate) {
+	hash := string(qc.GetBlockHash())
+	if _, ok := c.qcs[hash]; !ok {
+		c.qcs[hash] = qc
+	}
+	if proposal, ok := c.blocks[hash]; ok {
+		if r := qcRound(proposal.GetJustify()); r > c.Preferred {
+			c.Preferred = r
+		}
+	}
+	c.updateHighQC(qc)
+}
+
+// receive は deadline まで ReceiveChannel からメッセージを受け取る。
+// 受け取ったメッセージを処理するたびに until を呼び、true を返すとその時点で true を返す。
+// deadline に達したときと Run の ctx が終わったときは false を返す
+func (c *HotStuffUsecase) receive(deadline time.Duration, until func() bool) bool {
+	if until() {
+		return true
+	}
+	timer := c.clock.NewTimer(deadline - time.Duration(c.clock.Now()))
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C():
+			return false
+		case <-c.done:
+			return false
+		case proposal := <-c.channel.Propose:
+			c.onProposal(proposal)
+		case <-c.channel.Vote:
+		case preCommit := <-c.channel.PreCommit:
+			c.onPreCommit(preCommit)
+		}
+		if until() {
+			return true
+		}
+	}
+}
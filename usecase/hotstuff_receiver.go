@@ -0,0 +1,132 @@
+package usecase
+
+import (
+	"bytes"
+	"github.com/pkg/errors"
+	"github.com/satellitex/bbft/config"
+	"github.com/satellitex/bbft/dba"
+	"github.com/satellitex/bbft/model"
+)
+
+// HotStuffReceiverUsecase は hotstuff の ConsensusReceiver
+// Propagate は pbft と同じで、Proposal と投票は他の Peer に送り直さない。
+// hotstuff は Vote Phase の投票を使わないので、Vote は受け取らない
+// hotstuff は Peer の集合の変更と不正の処罰をしないので、ValidatorUpdate を含む Transaction と Evidence は ErrHotStuffNotSupported で拒否する
+type HotStuffReceiverUsecase struct {
+	*ConsensusReceieverUsecase
+}
+
+func NewHotStuffReceiverUsecase(conf *config.BBFTConfig, queue dba.ProposalTxQueue, ps dba.PeerService, selector LeaderSelector, pool dba.ReceiverPool, evidences dba.EvidencePool, bc dba.BlockChain, slv model.StatelessValidator, ev model.EvidenceValidator, sender model.ConsensusSender, channel *ReceiveChannel) ConsensusReceiver {
+	return &HotStuffReceiverUsecase{
+		&ConsensusReceieverUsecase{
+			conf:        conf,
+			queue:       queue,
+			ps:          ps,
+			selector:    selector,
+			pool:        pool,
+			evidences:   evidences,
+			bc:          bc,
+			slv:         slv,
+			ev:          ev,
+			sender:      sender,
+			ReceiveChan: channel,
+		},
+	}
+}
+
+func (c *HotStuffReceiverUsecase) Propose(proposal model.Proposal) error {
+	if proposal == nil { // InvalidArgument (code = 3)
+		return errors.Wrapf(model.ErrInvalidProposal, "proposal is nil")
+	}
+	if err := c.slv.BlockValidate(proposal.GetBlock()); err != nil { // InvalidArgument (code = 3)
+		return errors.Wrapf(model.ErrStatelessBlockValidate, err.Error())
+	}
+	// hotstuff では提案し直さないので、Block は proposal の view のリーダーが作って署名したものでなければならない
+	header := proposal.GetBlock().GetHeader()
+	if header.GetRound() != proposal.GetRound() ||
+		!bytes.Equal(header.GetProposer(), proposal.GetBlock().GetSignature().GetPubkey()) { // InvalidArgument (code = 3)
+		return errors.Wrapf(ErrVerifyOnlyLeader, "block is not made by signer in view %d", proposal.GetRound())
+	}
+	if leader, ok := c.selector.GetLeader(header.GetHeight(), proposal.GetRound()); !ok ||
+		!bytes.Equal(leader.GetPubkey(), header.GetProposer()) { // InvalidArgument (code = 3)
+		return errors.Wrapf(ErrVerifyOnlyLeader, "not leader peer's signed")
+	}
+	if c.pool.IsExistPropose(proposal) { // AlreadyExist (code = 6)
+		return errors.Wrapf(ErrAlradyReceivedSameObject, "proposal: %#v", proposal)
+	}
+	if err := c.pool.SetPropose(proposal); err != nil {
+		return errors.Wrapf(dba.ErrReceiverPoolSet, err.Error())
+	}
+	c.ReceiveChan.Propose <- proposal
+	return nil
+}
+
+func (c *HotStuffReceiverUsecase) Propagate(tx model.Transaction) error {
+	if err := rejectValidatorUpdate(tx); err != nil { // Unimplemented (code = 12)
+		return err
+	}
+	return c.ConsensusReceieverUsecase.Propagate(tx)
+}
+
+func (c *HotStuffReceiverUsecase) Evidence(evidence model.Evidence) error {
+	return errors.Wrapf(ErrHotStuffNotSupported, "evidence is not included in block")
+}
+
+func (c *HotStuffReceiverUsecase) Vote(vote model.VoteMessage) error {
+	return errors.Wrapf(model.ErrInvalidVoteMessage, "hotstuff does not use Vote")
+}
+
+func (c *HotStuffReceiverUsecase) PreCommit(preCommit model.VoteMessage) error {
+	if preCommit == nil { // InvalidArgument (code = 3)
+		return errors.Wrapf(model.ErrInvalidVoteMessage, "preCommit is nil")
+	}
+	if err := c.verifyVoteMessage(preCommit, model.PreCommit); err != nil { // InvalidArgument (code = 3)
+		return errors.Wrapf(model.ErrInvalidVoteMessage, err.Error())
+	}
+	if err := preCommit.Verify(); err != nil { // InvalidArgument (code = 3)
+		return errors.Wrapf(model.ErrVoteMessageVerify, err.Error())
+	}
+	if _, ok := c.ps.AtHeight(preCommit.GetHeight()).GetPeer(preCommit.GetSignature().GetPubkey()); !ok { // InvalidArgument (code = 3)
+		return errors.Wrapf(ErrPreCommitNotInPeerService, "pubkey: %x", preCommit.GetSignature().GetPubkey())
+	}
+	if c.pool.IsExistPreCommit(preCommit) { // AlreadyExist (code = 6)
+		return errors.Wrapf(ErrAlradyReceivedSameObject, "preCommit: %#v", preCommit)
+	}
+	if err := c.pool.SetPreCommit(preCommit); err != nil {
+		return errors.Wrap(dba.ErrReceiverPoolSet, err.Error())
+	}
+	c.ReceiveChan.PreCommit <- preCommit
+	return nil
+}
+
+// HotStuffClientGateReceiverUsecase は hotstuff の ClientGateReceiver
+// Client に知らせるために、ValidatorUpdate を含む Transaction は他の Peer に送る前に拒否する
+type HotStuffClientGateReceiverUsecase struct {
+	*ClientGateReceiverUsecase
+}
+
+func NewHotStuffClientGateReceiverUsecase(validator model.StatelessValidator, sender model.ConsensusSender) ClientGateReceiver {
+	return &HotStuffClientGateReceiverUsecase{
+		&ClientGateReceiverUsecase{
+			slv:    validator,
+			sender: sender,
+		},
+	}
+}
+
+func (c *HotStuffClientGateReceiverUsecase) Gate(tx model.Transaction) error {
+	if err := rejectValidatorUpdate(tx); err != nil { // Unimplemented (code = 12)
+		return err
+	}
+	return c.ClientGateReceiverUsecase.Gate(tx)
+}
+
+func rejectValidatorUpdate(tx model.Transaction) error {
+	if tx == nil {
+		return nil
+	}
+	if _, ok := tx.GetPayload().GetValidatorUpdate(); ok {
+		return errors.Wrapf(ErrHotStuffNotSupported, "validator update transaction")
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package usecase_test
+
+import (
+	"github.com/pkg/errors"
+	"github.com/satellitex/bbft/convertor"
+	"github.com/satellitex/bbft/dba"
+	"github.com/satellitex/bbft/model"
+	. "github.com/satellitex/bbft/test_utils"
+	. "github.com/satellitex/bbft/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func NewTestHotStuffReceiverUsecase(t *testing.T) (dba.BlockChain, dba.PeerService, LeaderSelector, *convertor.MockConsensusSender, *ReceiveChannel, ConsensusReceiver) {
+	conf := GetTestConfig()
+	queue := dba.NewProposalTxQueueOnMemory(conf)
+	ps := RandomPeerService(t, 4)
+	pool := dba.NewReceiverPoolOnMemory(conf)
+	bc := dba.NewBlockChainOnMemory()
+	sender := convertor.NewMockConsensusSender()
+	channel := NewReceiveChannel(conf)
+	selector := NewRoundRobinLeaderSelector(ps)
+
+	genesis, err := convertor.NewModelFactory().NewBlock(0, nil, 0, nil, nil, nil)
+	require.NoError(t, err)
+	bc.Commit(genesis, nil)
+
+	receiver := NewHotStuffReceiverUsecase(conf, queue, ps, selector, pool, dba.NewEvidencePoolOnMemory(conf), bc,
+		convertor.NewStatelessValidator(conf), convertor.NewEvidenceValidator(conf, ps), sender, channel)
+	return bc, ps, selector, sender.(*convertor.MockConsensusSender), channel, receiver
+}
+
+func TestHotStuffReceiverUsecase_Propose(t *testing.T) {
+	bc, ps, selector, sender, channel, receiver := NewTestHotStuffReceiverUsecase(t)
+	genesis, ok := bc.Top()
+	require.True(t, ok)
+
+	proposal := HotStuffProposal(t, ps, genesis, 5, nil, leaderOf(t, selector, 1, 5))
+	t.Run("success, not send again", func(t *testing.T) {
+		require.NoError(t, receiver.Propose(proposal))
+		assert.Equal(t, proposal, <-channel.Propose)
+		assert.Nil(t, sender.Proposal)
+	})
+
+	t.Run("failed already received", func(t *testing.T) {
+		err := receiver.Propose(proposal)
+		assert.EqualError(t, errors.Cause(err), ErrAlradyReceivedSameObject.Error())
+	})
+
+	t.Run("failed not leader of view", func(t *testing.T) {
+		err := receiver.Propose(HotStuffProposal(t, ps, genesis, 5, nil, leaderOf(t, selector, 1, 6)))
+		assert.EqualError(t, errors.Cause(err), ErrVerifyOnlyLeader.Error())
+	})
+
+	t.Run("success with justify", func(t *testing.T) {
+		justify := CommitCertificateWithRound(t, genesis, 5, ps.GetPeers())
+		p := HotStuffProposal(t, ps, genesis, 7, justify, leaderOf(t, selector, 1, 7))
+		require.NoError(t, receiver.Propose(p))
+		assert.Equal(t, justify, (<-channel.Propose).GetJustify())
+	})
+}
+
+func TestHotStuffReceiverUsecase_PreCommit(t *testing.T) {
+	bc, ps, _, sender, channel, receiver := NewTestHotStuffReceiverUsecase(t)
+	genesis, ok := bc.Top()
+	require.True(t, ok)
+
+	vote := VoteMessageFromPeerWithBlockRound(t, model.PreCommit, ps.GetPeers()[0], genesis, 3)
+	t.Run("success, not send again", func(t *testing.T) {
+		require.NoError(t, receiver.PreCommit(vote))
+		assert.Equal(t, vote, <-channel.PreCommit)
+		assert.Nil(t, sender.PreCommitMessage)
+	})
+
+	t.Run("failed already received", func(t *testing.T) {
+		err := receiver.PreCommit(vote)
+		assert.EqualError(t, errors.Cause(err), ErrAlradyReceivedSameObject.Error())
+	})
+
+	t.Run("failed not peer", func(t *testing.T) {
+		err := receiver.PreCommit(VoteMessageFromPeerWithBlockRound(t, model.PreCommit, RandomPeerWithPriv(), genesis, 3))
+		assert.EqualError(t, errors.Cause(err), ErrPreCommitNotInPeerService.Error())
+	})
+
+	t.Run("failed vote is not used", func(t *testing.T) {
+		err := receiver.Vote(VoteMessageFromPeerWithBlockRound(t, model.PreVote, ps.GetPeers()[0], genesis, 3))
+		assert.EqualError(t, errors.Cause(err), model.ErrInvalidVoteMessage.Error())
+	})
+}
+
+func TestHotStuffReceiverUsecase_Propagate(t *testing.T) {
+	_, ps, _, sender, _, receiver := NewTestHotStuffReceiverUsecase(t)
+
+	t.Run("success, same as pbft", func(t *testing.T) {
+		tx := RandomValidTx(t)
+		require.NoError(t, receiver.Propagate(tx))
+		assert.Equal(t, tx, sender.Tx)
+	})
+
+	t.Run("failed validator update is not supported", func(t *testing.T) {
+		sender.Tx = nil
+		added := RandomPeerWithPriv()
+		update := convertor.NewModelFactory().NewValidatorUpdate(model.AddValidator, added.GetAddress(), added.GetPubkey(), nil, 1)
+		err := receiver.Propagate(ValidatorUpdateTx(t, update, ps.GetPeers()))
+		assert.EqualError(t, errors.Cause(err), ErrHotStuffNotSupported.Error())
+		assert.Nil(t, sender.Tx)
+	})
+}
+
+func TestHotStuffReceiverUsecase_Evidence(t *testing.T) {
+	_, ps, _, sender, _, receiver := NewTestHotStuffReceiverUsecase(t)
+
+	t.Run("failed evidence is not supported", func(t *testing.T) {
+		peers := ps.GetPeers()
+		err := receiver.Evidence(DuplicatePreCommitEvidence(t, peers[0], peers[1], 1, 0))
+		assert.EqualError(t, errors.Cause(err), ErrHotStuffNotSupported.Error())
+		assert.Nil(t, sender.EvidenceMessage)
+	})
+}
+
+func TestHotStuffClientGateReceiverUsecase_Gate(t *testing.T) {
+	sender := convertor.NewMockConsensusSender()
+	gate := NewHotStuffClientGateReceiverUsecase(convertor.NewStatelessValidator(GetTestConfig()), sender)
+
+	t.Run("success case", func(t *testing.T) {
+		tx := RandomValidTx(t)
+		require.NoError(t, gate.Gate(tx))
+		assert.Equal(t, tx, sender.(*convertor.MockConsensusSender).Tx)
+	})
+
+	t.Run("failed validator update is not supported", func(t *testing.T) {
+		sender.(*convertor.MockConsensusSender).Tx = nil
+		added := RandomPeerWithPriv()
+		update := convertor.NewModelFactory().NewValidatorUpdate(model.AddValidator, added.GetAddress(), added.GetPubkey(), nil, 1)
+		err := gate.Gate(ValidatorUpdateTx(t, update, []model.Peer{RandomPeerWithPriv()}))
+		assert.EqualError(t, errors.Cause(err), ErrHotStuffNotSupported.Error())
+		assert.Nil(t, sender.(*convertor.MockConsensusSender).Tx)
+	})
+}
@@ -0,0 +1,354 @@
+package usecase_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/pkg/errors"
+	"github.com/satellitex/bbft/config"
+	"github.com/satellitex/bbft/convertor"
+	"github.com/satellitex/bbft/dba"
+	"github.com/satellitex/bbft/model"
+	. "github.com/satellitex/bbft/test_utils"
+	. "github.com/satellitex/bbft/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"testing"
+	"time"
+)
+
+func NewTestHotStuffUsecase(t *testing.T) (*config.BBFTConfig, dba.BlockChain, dba.PeerService, LeaderSelector,
+	*convertor.MockConsensusSender, *ReceiveChannel, *HotStuffUsecase) {
+	return NewTestHotStuffUsecaseWithWAL(t, dba.NewWALOnMemory())
+}
+
+func NewTestHotStuffUsecaseWithWAL(t *testing.T, wal model.WAL) (*config.BBFTConfig, dba.BlockChain, dba.PeerService, LeaderSelector,
+	*convertor.MockConsensusSender, *ReceiveChannel, *HotStuffUsecase) {
+
+	conf := GetTestConfig()
+	bc := dba.NewBlockChainOnMemory()
+	ps := dba.NewPeerServiceOnMemory()
+	queue := dba.NewProposalTxQueueOnMemory(conf)
+	sender := convertor.NewMockConsensusSender()
+	slv := convertor.NewStatelessValidator(conf)
+	selector := NewRoundRobinLeaderSelector(ps)
+	sfv := convertor.NewStatefulValidator(conf, bc, ps, selector)
+	cv := convertor.NewCommitCertificateValidator(conf, ps)
+	syncer := NewBlockSyncUsecase(conf, bc, ps, slv, sfv, cv, convertor.NewMockBlockSyncSender(bc))
+	channel := NewReceiveChannel(conf)
+
+	genesis, err := convertor.NewModelFactory().NewBlock(0, nil, 0, nil, nil, nil)
+	require.NoError(t, err)
+	bc.Commit(genesis, nil)
+
+	ps.AddPeer(RandomPeerFromConf(conf))
+	ps.AddPeer(RandomPeerWithPriv())
+	ps.AddPeer(RandomPeerWithPriv())
+	ps.AddPeer(RandomPeerWithPriv())
+
+	step := NewHotStuffUsecase(conf, bc, ps, selector, queue, sender, slv, sfv, cv, convertor.NewModelFactory(), syncer, wal, NewRealClock(), channel)
+	return conf, bc, ps, selector, sender.(*convertor.MockConsensusSender), channel, step.(*HotStuffUsecase)
+}
+
+func leaderOf(t *testing.T, selector LeaderSelector, height int64, view int32) model.Peer {
+	leader, ok := selector.GetLeader(height, view)
+	require.True(t, ok)
+	return leader
+}
+
+func myView(conf *config.BBFTConfig, selector LeaderSelector, height int64) int32 {
+	for view := int32(0); ; view++ {
+		if leader, ok := selector.GetLeader(height, view); ok && bytes.Equal(leader.GetPubkey(), conf.PublicKey) {
+			return view
+		}
+	}
+}
+
+func otherView(conf *config.BBFTConfig, selector LeaderSelector, height int64) int32 {
+	for view := int32(0); ; view++ {
+		if leader, ok := selector.GetLeader(height, view); ok && !bytes.Equal(leader.GetPubkey(), conf.PublicKey) {
+			return view
+		}
+	}
+}
+
+// setTimeOut は各 Phase の待ち受けを今から d 後に終わらせる
+func setTimeOut(c *HotStuffUsecase, d time.Duration) {
+	deadline := time.Duration(Now()) + d
+	c.ProposeTimeOut, c.VoteTimeOut, c.PreCommitTimeOut = deadline, deadline, deadline
+}
+
+func TestHotStuffUsecase_Propose(t *testing.T) {
+	conf, bc, ps, selector, sender, channel, c := NewTestHotStuffUsecase(t)
+	genesis, ok := bc.Top()
+	require.True(t, ok)
+
+	t.Run("leader proposes child of genesis without justify", func(t *testing.T) {
+		view := myView(conf, selector, 1)
+		c.View = view
+		setTimeOut(c, 0)
+		require.NoError(t, c.Propose(1, view))
+
+		require.NotNil(t, sender.Proposal)
+		assert.Equal(t, view, sender.Proposal.GetRound())
+		assert.Nil(t, sender.Proposal.GetJustify())
+		block := sender.Proposal.GetBlock()
+		assert.Equal(t, int64(1), block.GetHeader().GetHeight())
+		assert.Equal(t, GetHash(t, genesis), block.GetHeader().GetPreBlockHash())
+		assert.Equal(t, conf.PublicKey, block.GetSignature().GetPubkey())
+	})
+
+	t.Run("not leader does not propose", func(t *testing.T) {
+		sender.Proposal = nil
+		view := otherView(conf, selector, 1)
+		c.View = view
+		setTimeOut(c, 0)
+		require.NoError(t, c.Propose(1, view))
+		assert.Nil(t, sender.Proposal)
+	})
+
+	t.Run("not move to view of proposal without QC", func(t *testing.T) {
+		view := otherView(conf, selector, 1)
+		far := view + int32(ps.Size())*10
+		channel.Propose <- HotStuffProposal(t, ps, genesis, far, nil, leaderOf(t, selector, 1, far))
+		c.View = view
+		setTimeOut(c, 100*time.Millisecond)
+		require.NoError(t, c.Propose(1, view))
+		assert.Equal(t, view, c.View)
+	})
+
+	t.Run("failed no genesis block without highQC", func(t *testing.T) {
+		ps := dba.NewPeerServiceOnMemory()
+		ps.AddPeer(RandomPeerFromConf(conf))
+		empty := dba.NewBlockChainOnMemory()
+		slv := convertor.NewStatelessValidator(conf)
+		sfv := convertor.NewStatefulValidator(conf, empty, ps, NewRoundRobinLeaderSelector(ps))
+		cv := convertor.NewCommitCertificateValidator(conf, ps)
+		c := NewHotStuffUsecase(conf, empty, ps, NewRoundRobinLeaderSelector(ps), dba.NewProposalTxQueueOnMemory(conf),
+			sender, slv, sfv, cv, convertor.NewModelFactory(),
+			NewBlockSyncUsecase(conf, empty, ps, slv, sfv, cv, convertor.NewMockBlockSyncSender(empty)), dba.NewWALOnMemory(), NewRealClock(), NewReceiveChannel(conf))
+		err := c.Propose(1, 0)
+		assert.EqualError(t, errors.Cause(err), ErrConsensusProposal.Error())
+	})
+}
+
+func TestHotStuffUsecase_ProposalWindow(t *testing.T) {
+	conf, bc, ps, selector, _, channel, c := NewTestHotStuffUsecase(t)
+	genesis, ok := bc.Top()
+	require.True(t, ok)
+
+	t.Run("not keep proposal of view out of window", func(t *testing.T) {
+		view := otherView(conf, selector, 1)
+		far := view + int32(ps.Size()*conf.HotStuffWindow)
+		proposal := HotStuffProposal(t, ps, genesis, far, nil, leaderOf(t, selector, 1, far))
+		channel.Propose <- proposal
+		c.View = view
+		setTimeOut(c, 100*time.Millisecond)
+		require.NoError(t, c.Propose(1, view))
+
+		// far の view に進んでも、覚えていない Block への QC は使えない
+		child := HotStuffProposal(t, ps, proposal.GetBlock(), far+1,
+			CommitCertificateWithRound(t, proposal.GetBlock(), far, ps.GetPeers()), leaderOf(t, selector, 2, far+1))
+		channel.Propose <- child
+		c.View = far
+		setTimeOut(c, 100*time.Millisecond)
+		require.NoError(t, c.Propose(1, view))
+		assert.Nil(t, c.HighQC)
+		assert.Equal(t, far, c.View)
+	})
+}
+
+func TestHotStuffUsecase_Vote(t *testing.T) {
+	conf, bc, ps, selector, sender, channel, c := NewTestHotStuffUsecase(t)
+	genesis, ok := bc.Top()
+	require.True(t, ok)
+
+	view := otherView(conf, selector, 1)
+	proposal := HotStuffProposal(t, ps, genesis, view, nil, leaderOf(t, selector, 1, view))
+
+	t.Run("vote to next leader", func(t *testing.T) {
+		channel.Propose <- proposal
+		c.View = view
+		setTimeOut(c, time.Second)
+		require.NoError(t, c.Propose(1, view))
+		require.NoError(t, c.Vote(1, view))
+
+		assert.Equal(t, leaderOf(t, selector, 2, view+1), sender.PreCommitPeer)
+		vote := sender.PreCommitMessage
+		require.NotNil(t, vote)
+		assert.Equal(t, model.PreCommit, vote.GetType())
+		assert.Equal(t, int64(1), vote.GetHeight())
+		assert.Equal(t, view, vote.GetRound())
+		assert.Equal(t, GetHash(t, proposal.GetBlock()), vote.GetBlockHash())
+		assert.NoError(t, vote.Verify())
+		assert.Equal(t, view, c.LastVoted)
+	})
+
+	t.Run("not vote twice in same view", func(t *testing.T) {
+		sender.PreCommitMessage = nil
+		require.NoError(t, c.Vote(1, view))
+		assert.Nil(t, sender.PreCommitMessage)
+	})
+
+	t.Run("not vote twice in same view after restart", func(t *testing.T) {
+		wal := dba.NewWALOnMemory()
+		_, _, _, _, sender, channel, c := NewTestHotStuffUsecaseWithWAL(t, wal)
+		channel.Propose <- proposal
+		c.View = view
+		setTimeOut(c, time.Second)
+		require.NoError(t, c.Propose(1, view))
+		require.NoError(t, c.Vote(1, view))
+		require.NotNil(t, sender.PreCommitMessage)
+
+		_, _, _, _, sender, _, restarted := NewTestHotStuffUsecaseWithWAL(t, wal)
+		require.NoError(t, restarted.Replay())
+		assert.Equal(t, view, restarted.LastVoted)
+		assert.Equal(t, view+1, restarted.View)
+
+		restarted.View = view
+		require.NoError(t, restarted.Vote(1, view))
+		assert.Nil(t, sender.PreCommitMessage)
+	})
+
+	t.Run("failed justify is older than preferred", func(t *testing.T) {
+		next := view + 1
+		channel.Propose <- HotStuffProposal(t, ps, genesis, next, nil, leaderOf(t, selector, 1, next))
+		c.View = next
+		c.Preferred = 0
+		setTimeOut(c, time.Second)
+		require.NoError(t, c.Propose(1, next))
+		err := c.Vote(1, next)
+		assert.EqualError(t, errors.Cause(err), ErrConsensusVote.Error())
+		assert.Contains(t, err.Error(), ErrHotStuffUnsafeProposal.Error())
+	})
+
+	t.Run("failed proposal has validator update", func(t *testing.T) {
+		next := view + 2
+		update := convertor.NewModelFactory().NewValidatorUpdate(model.RemoveValidator, "", ps.GetPeers()[1].GetPubkey(), nil, 0)
+		block, err := convertor.NewModelFactory().NewBlock(1, GetHash(t, genesis), time.Now().UnixNano(),
+			[]model.Transaction{ValidatorUpdateTx(t, update, ps.GetPeers())}, nil, nil)
+		require.NoError(t, err)
+		ValidSign(t, block)
+		p, err := convertor.NewModelFactory().NewJustifiedProposal(block, next, nil)
+		require.NoError(t, err)
+
+		channel.Propose <- p
+		c.View = next
+		c.Preferred = -1
+		setTimeOut(c, time.Second)
+		require.NoError(t, c.Propose(1, next))
+		err = c.Vote(1, next)
+		assert.EqualError(t, errors.Cause(err), ErrConsensusVote.Error())
+		assert.Contains(t, err.Error(), ErrHotStuffInvalidProposal.Error())
+	})
+}
+
+func TestHotStuffUsecase_Commit(t *testing.T) {
+	_, bc, ps, selector, _, channel, c := NewTestHotStuffUsecase(t)
+	genesis, ok := bc.Top()
+	require.True(t, ok)
+	peers := ps.GetPeers()
+
+	// chain は parent から views の順に Proposal を繋げ、最後の Proposal まで受け取る
+	chain := func(parent model.Block, justify model.CommitCertificate, views ...int32) []model.Proposal {
+		ret := make([]model.Proposal, 0, len(views))
+		for _, view := range views {
+			proposal := HotStuffProposal(t, ps, parent, view, justify, leaderOf(t, selector, parent.GetHeader().GetHeight()+1, view))
+			parent, justify = proposal.GetBlock(), CommitCertificateWithRound(t, proposal.GetBlock(), view, peers)
+			ret = append(ret, proposal)
+			channel.Propose <- proposal
+		}
+		// 最後の Proposal を受け取るまで待つ
+		setTimeOut(c, time.Second)
+		require.NoError(t, c.PreCommit(0, views[len(views)-1]-1))
+		return ret
+	}
+
+	var proposals []model.Proposal
+	t.Run("not commit without consecutive views", func(t *testing.T) {
+		proposals = chain(genesis, nil, 0, 2, 3, 4)
+		assert.Equal(t, int32(3), c.HighQC.GetRound())
+		assert.Equal(t, int32(2), c.Preferred)
+
+		require.NoError(t, c.Commit(0, 4))
+		top, ok := bc.Top()
+		require.True(t, ok)
+		assert.Equal(t, genesis, top)
+	})
+
+	t.Run("commit 3-chain with consecutive views", func(t *testing.T) {
+		last := proposals[len(proposals)-1]
+		chain(last.GetBlock(), CommitCertificateWithRound(t, last.GetBlock(), 4, peers), 5)
+		assert.Equal(t, int32(4), c.HighQC.GetRound())
+
+		// b(2) <- b(3) <- b(4) に QC が集まったので b(2) までを Commit する
+		require.NoError(t, c.Commit(0, 5))
+		top, ok := bc.Top()
+		require.True(t, ok)
+		assert.Equal(t, int64(2), top.GetHeader().GetHeight())
+		assert.Equal(t, proposals[1].GetBlock(), top)
+
+		for i, proposal := range proposals[:2] {
+			block, ok := bc.GetBlock(int64(i + 1))
+			require.True(t, ok)
+			assert.Equal(t, proposal.GetBlock(), block)
+			cert, ok := bc.GetCommitCertificate(int64(i + 1))
+			require.True(t, ok)
+			assert.Equal(t, proposal.GetRound(), cert.GetRound())
+			assert.Equal(t, GetHash(t, block), cert.GetBlockHash())
+		}
+	})
+}
+
+func TestHotStuffUsecase_PreCommit(t *testing.T) {
+	conf, bc, ps, selector, _, channel, c := NewTestHotStuffUsecase(t)
+	genesis, ok := bc.Top()
+	require.True(t, ok)
+
+	t.Run("next leader makes QC from 2/3+ votes", func(t *testing.T) {
+		view := myView(conf, selector, 2) - 1
+		if view < 0 {
+			view += int32(ps.Size())
+		}
+		proposal := HotStuffProposal(t, ps, genesis, view, nil, leaderOf(t, selector, 1, view))
+		channel.Propose <- proposal
+		for _, peer := range ps.GetPeers()[:3] {
+			channel.PreCommit <- VoteMessageFromPeerWithBlockRound(t, model.PreCommit, peer, proposal.GetBlock(), view)
+		}
+		c.View = view
+		setTimeOut(c, time.Second)
+		require.NoError(t, c.PreCommit(1, view))
+		require.NotNil(t, c.HighQC)
+		assert.Equal(t, view, c.HighQC.GetRound())
+		assert.Equal(t, GetHash(t, proposal.GetBlock()), c.HighQC.GetBlockHash())
+		assert.Equal(t, view+1, c.View)
+	})
+
+	t.Run("failed not enough votes", func(t *testing.T) {
+		// 前の subtest の view より後にする
+		view := myView(conf, selector, 2) + int32(ps.Size())*2 - 1
+		proposal := HotStuffProposal(t, ps, genesis, view, nil, leaderOf(t, selector, 1, view))
+		channel.Propose <- proposal
+		channel.PreCommit <- VoteMessageFromPeerWithBlockRound(t, model.PreCommit, ps.GetPeers()[0], proposal.GetBlock(), view)
+		c.View = view
+		setTimeOut(c, 0)
+		err := c.PreCommit(1, view)
+		assert.EqualError(t, errors.Cause(err), ErrConsensusPreCommit.Error())
+	})
+}
+
+func TestHotStuffUsecase_Run(t *testing.T) {
+	_, _, _, _, _, _, c := NewTestHotStuffUsecase(t)
+
+	t.Run("stop by canceled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.Equal(t, context.Canceled, c.Run(ctx))
+	})
+
+	t.Run("failed view reached the max", func(t *testing.T) {
+		c.View = math.MaxInt32
+		err := c.Run(context.Background())
+		assert.EqualError(t, errors.Cause(err), ErrHotStuffViewOverflow.Error())
+	})
+}
@@ -0,0 +1,196 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"github.com/satellitex/bbft/config"
+	"github.com/satellitex/bbft/dba"
+	"github.com/satellitex/bbft/model"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencyWeight は観測した時間を平均に混ぜる重み (1/latencyWeight)
+const latencyWeight = 8
+
+// LatencyMonitor は Peer との RTT と各 Phase にかかった時間を測り、Leader が Block に記録する PhaseTiming を作る
+type LatencyMonitor interface {
+	// Run は ctx が終わるまで PingInterval ごとに全ての Peer に Ping を送って RTT を測る
+	Run(ctx context.Context) error
+	// ObserveRoundTrip は peer との RTT を記録する
+	ObserveRoundTrip(peer model.Peer, rtt time.Duration)
+	// ObservePhase は phase が始まってから終わるまでにかかった時間を記録する
+	ObservePhase(phase string, d time.Duration)
+	// Report は今までに観測した時間から PhaseTiming を作る
+	Report() model.PhaseTiming
+}
+
+type LatencyMonitorUsecase struct {
+	conf    *config.BBFTConfig
+	ps      dba.PeerService
+	sender  model.ConsensusSender
+	factory model.ModelFactory
+	// rtts は hex encode した Pubkey ごとの RTT の移動平均
+	rtts map[string]time.Duration
+	// phases は Phase ごとにかかった時間の移動平均
+	phases map[string]time.Duration
+	mutex  *sync.Mutex
+}
+
+func NewLatencyMonitorUsecase(conf *config.BBFTConfig, ps dba.PeerService, sender model.ConsensusSender, factory model.ModelFactory) LatencyMonitor {
+	return &LatencyMonitorUsecase{
+		conf:    conf,
+		ps:      ps,
+		sender:  sender,
+		factory: factory,
+		rtts:    make(map[string]time.Duration),
+		phases:  make(map[string]time.Duration),
+		mutex:   new(sync.Mutex),
+	}
+}
+
+func (m *LatencyMonitorUsecase) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.conf.PingInterval)
+	defer ticker.Stop()
+	for {
+		m.pingAll()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *LatencyMonitorUsecase) pingAll() {
+	for _, peer := range m.ps.GetPeers() {
+		if bytes.Equal(peer.GetPubkey(), m.conf.PublicKey) {
+			continue
+		}
+		rtt, err := m.sender.Ping(peer)
+		if err != nil {
+			log.Println("LatencyMonitor Ping Error!!", peer.GetAddress(), err)
+			continue
+		}
+		m.ObserveRoundTrip(peer, rtt)
+	}
+}
+
+func (m *LatencyMonitorUsecase) ObserveRoundTrip(peer model.Peer, rtt time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	key := hex.EncodeToString(peer.GetPubkey())
+	m.rtts[key] = average(m.rtts[key], rtt)
+}
+
+func (m *LatencyMonitorUsecase) ObservePhase(phase string, d time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.phases[phase] = average(m.phases[phase], d)
+}
+
+// Report は 2/3 の Peer に届くまでの片道の時間を connectDelay とし、各 Phase の時間から connectDelay を除いたものを返す
+// PeerService から外れた Peer の RTT は捨てる
+func (m *LatencyMonitorUsecase) Report() model.PhaseTiming {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	peers := make(map[string]struct{})
+	for _, peer := range m.ps.GetPeers() {
+		peers[hex.EncodeToString(peer.GetPubkey())] = struct{}{}
+	}
+	rtts := make([]time.Duration, 0, len(m.rtts))
+	for key, rtt := range m.rtts {
+		if _, ok := peers[key]; !ok {
+			delete(m.rtts, key)
+			continue
+		}
+		rtts = append(rtts, rtt)
+	}
+	var delay time.Duration
+	if len(rtts) > 0 {
+		sort.Slice(rtts, func(i, j int) bool { return rtts[i] < rtts[j] })
+		delay = rtts[len(rtts)*2/3] / 2
+	}
+	calc := func(phase string) int64 {
+		if d, ok := m.phases[phase]; ok && d > delay {
+			return int64(d - delay)
+		}
+		return 0
+	}
+	return m.factory.NewPhaseTiming(int64(delay), calc(PhasePropose), calc(PhaseVote), calc(PhasePreCommit))
+}
+
+// average は移動平均 avg に d を混ぜる。avg が 0 のときは d を返す
+func average(avg time.Duration, d time.Duration) time.Duration {
+	if avg == 0 {
+		return d
+	}
+	return avg + (d-avg)/latencyWeight
+}
+
+// PhaseTimeouts は 1つの Height の合意で使う通信の時間と各 Phase の計算時間
+type PhaseTimeouts struct {
+	ConnectDelay time.Duration
+	Propose      time.Duration
+	Vote         time.Duration
+	PreCommit    time.Duration
+}
+
+// NewPhaseTimeouts は height の合意で使う PhaseTimeouts を返す
+// AdaptiveTimeout のときは height 未満の直近 AdaptiveTimeoutWindow 個の Block の timing から決める
+// Commit された Block だけから決めるので、同じ Chain を持つ Peer は同じ値を使う
+func NewPhaseTimeouts(conf *config.BBFTConfig, bc dba.BlockChain, height int64) PhaseTimeouts {
+	ret := PhaseTimeouts{
+		ConnectDelay: conf.AllowedConnectDelayTime,
+		Propose:      conf.ProposeMaxCalcTime,
+		Vote:         conf.VoteMaxCalcTime,
+		PreCommit:    conf.PreCommitMaxCalcTime,
+	}
+	if !conf.AdaptiveTimeout {
+		return ret
+	}
+	var delays, proposes, votes, preCommits []int64
+	for h := height - 1; h > 0 && h >= height-int64(conf.AdaptiveTimeoutWindow); h-- {
+		block, ok := bc.GetBlock(h)
+		if !ok {
+			break
+		}
+		timing := block.GetHeader().GetTiming()
+		delays = appendObserved(delays, timing.GetConnectDelay())
+		proposes = appendObserved(proposes, timing.GetPropose())
+		votes = appendObserved(votes, timing.GetVote())
+		preCommits = appendObserved(preCommits, timing.GetPreCommit())
+	}
+	ret.ConnectDelay = adapt(conf, delays, ret.ConnectDelay, conf.AdaptiveMinConnectDelayTime, conf.AdaptiveMaxConnectDelayTime)
+	ret.Propose = adapt(conf, proposes, ret.Propose, conf.AdaptiveMinCalcTime, conf.AdaptiveMaxCalcTime)
+	ret.Vote = adapt(conf, votes, ret.Vote, conf.AdaptiveMinCalcTime, conf.AdaptiveMaxCalcTime)
+	ret.PreCommit = adapt(conf, preCommits, ret.PreCommit, conf.AdaptiveMinCalcTime, conf.AdaptiveMaxCalcTime)
+	return ret
+}
+
+func appendObserved(values []int64, v int64) []int64 {
+	if v > 0 {
+		return append(values, v)
+	}
+	return values
+}
+
+// adapt は values の中央値を AdaptiveTimeoutMarginPercent % に伸ばして [min, max] に収める。values が空のときは static を返す
+func adapt(conf *config.BBFTConfig, values []int64, static time.Duration, min time.Duration, max time.Duration) time.Duration {
+	if len(values) == 0 {
+		return static
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	d := time.Duration(values[len(values)/2]) * time.Duration(conf.AdaptiveTimeoutMarginPercent) / 100
+	if d < min {
+		return min
+	}
+	if d > max {
+		return max
+	}
+	return d
+}
@@ -0,0 +1,159 @@
+package usecase_test
+
+import (
+	"context"
+	"github.com/satellitex/bbft/config"
+	"github.com/satellitex/bbft/convertor"
+	"github.com/satellitex/bbft/dba"
+	"github.com/satellitex/bbft/model"
+	. "github.com/satellitex/bbft/test_utils"
+	. "github.com/satellitex/bbft/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestLatencyMonitorUsecase(t *testing.T) {
+	conf := GetTestConfig()
+	factory := convertor.NewModelFactory()
+
+	newMonitor := func(peers ...model.Peer) (dba.PeerService, model.ConsensusSender, LatencyMonitor) {
+		ps := dba.NewPeerServiceOnMemory()
+		ps.AddPeer(RandomPeerFromConf(conf))
+		for _, peer := range peers {
+			ps.AddPeer(peer)
+		}
+		sender := convertor.NewMockConsensusSender()
+		return ps, sender, NewLatencyMonitorUsecase(conf, ps, sender, factory)
+	}
+
+	t.Run("no observation reports zero", func(t *testing.T) {
+		_, _, m := newMonitor(RandomPeerWithPriv())
+		assert.Equal(t, factory.NewPhaseTiming(0, 0, 0, 0), m.Report())
+	})
+
+	t.Run("connect delay is half of rtt to reach 2/3 peers", func(t *testing.T) {
+		peers := []model.Peer{RandomPeerWithPriv(), RandomPeerWithPriv(), RandomPeerWithPriv()}
+		_, _, m := newMonitor(peers...)
+		m.ObserveRoundTrip(peers[0], 10*time.Millisecond)
+		m.ObserveRoundTrip(peers[1], 40*time.Millisecond)
+		m.ObserveRoundTrip(peers[2], 20*time.Millisecond)
+		assert.Equal(t, int64(20*time.Millisecond), m.Report().GetConnectDelay())
+	})
+
+	t.Run("phase time excludes connect delay", func(t *testing.T) {
+		peer := RandomPeerWithPriv()
+		_, _, m := newMonitor(peer)
+		m.ObserveRoundTrip(peer, 20*time.Millisecond)
+		m.ObservePhase(PhasePropose, 100*time.Millisecond)
+		m.ObservePhase(PhaseVote, 5*time.Millisecond)
+		m.ObservePhase(PhasePreCommit, 30*time.Millisecond)
+		assert.Equal(t, factory.NewPhaseTiming(int64(10*time.Millisecond), int64(90*time.Millisecond), 0, int64(20*time.Millisecond)), m.Report())
+	})
+
+	t.Run("observations are averaged", func(t *testing.T) {
+		_, _, m := newMonitor()
+		m.ObservePhase(PhaseVote, 100*time.Millisecond)
+		m.ObservePhase(PhaseVote, 180*time.Millisecond)
+		assert.Equal(t, int64(110*time.Millisecond), m.Report().GetVote())
+	})
+
+	t.Run("rtt of removed peer is discarded", func(t *testing.T) {
+		peer := RandomPeerWithPriv()
+		ps, _, m := newMonitor(peer)
+		m.ObserveRoundTrip(peer, 20*time.Millisecond)
+		ps.RemovePeer(peer.GetPubkey())
+		assert.Equal(t, int64(0), m.Report().GetConnectDelay())
+	})
+
+	t.Run("run pings other peers until ctx is done", func(t *testing.T) {
+		peer := RandomPeerWithPriv()
+		_, sender, m := newMonitor(peer)
+		sender.(*convertor.MockConsensusSender).RoundTripTime = 30 * time.Millisecond
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.Equal(t, context.Canceled, m.Run(ctx))
+		assert.Equal(t, int64(15*time.Millisecond), m.Report().GetConnectDelay())
+	})
+}
+
+func TestNewPhaseTimeouts(t *testing.T) {
+	factory := convertor.NewModelFactory()
+
+	newConfig := func() *config.BBFTConfig {
+		conf := GetTestConfig()
+		conf.AllowedConnectDelayTime = 500 * time.Millisecond
+		conf.ProposeMaxCalcTime = 500 * time.Millisecond
+		conf.VoteMaxCalcTime = time.Second
+		conf.PreCommitMaxCalcTime = 200 * time.Millisecond
+		conf.AdaptiveTimeout = true
+		conf.AdaptiveTimeoutWindow = 4
+		conf.AdaptiveTimeoutMarginPercent = 200
+		conf.AdaptiveMinConnectDelayTime = 10 * time.Millisecond
+		conf.AdaptiveMaxConnectDelayTime = time.Second
+		conf.AdaptiveMinCalcTime = 50 * time.Millisecond
+		conf.AdaptiveMaxCalcTime = 300 * time.Millisecond
+		return conf
+	}
+	static := PhaseTimeouts{
+		ConnectDelay: 500 * time.Millisecond,
+		Propose:      500 * time.Millisecond,
+		Vote:         time.Second,
+		PreCommit:    200 * time.Millisecond,
+	}
+
+	// commit は timing を記録した Block を順に Commit する
+	commit := func(t *testing.T, bc dba.BlockChain, timings ...model.PhaseTiming) {
+		for _, timing := range timings {
+			top, ok := bc.Top()
+			require.True(t, ok)
+			block, err := factory.NewTimedBlock(top.GetHeader().GetHeight()+1, GetHash(t, top),
+				top.GetHeader().GetCreatedTime()+10, nil, nil, nil, timing)
+			require.NoError(t, err)
+			bc.Commit(block, nil)
+		}
+	}
+	newBlockChain := func(t *testing.T) dba.BlockChain {
+		bc := dba.NewBlockChainOnMemory()
+		genesis, err := factory.NewBlock(0, nil, 0, nil, nil, nil)
+		require.NoError(t, err)
+		bc.Commit(genesis, nil)
+		return bc
+	}
+	ms := func(n int64) int64 {
+		return n * int64(time.Millisecond)
+	}
+
+	t.Run("disabled uses static config", func(t *testing.T) {
+		conf := newConfig()
+		conf.AdaptiveTimeout = false
+		bc := newBlockChain(t)
+		commit(t, bc, factory.NewPhaseTiming(ms(5), ms(5), ms(5), ms(5)))
+		assert.Equal(t, static, NewPhaseTimeouts(conf, bc, 2))
+	})
+
+	t.Run("no timing uses static config", func(t *testing.T) {
+		bc := newBlockChain(t)
+		commit(t, bc, nil, nil)
+		assert.Equal(t, static, NewPhaseTimeouts(newConfig(), bc, 3))
+	})
+
+	t.Run("median with margin within bounds", func(t *testing.T) {
+		bc := newBlockChain(t)
+		commit(t, bc,
+			factory.NewPhaseTiming(ms(1000), ms(1000), ms(1000), ms(1000)), // out of window
+			factory.NewPhaseTiming(ms(30), ms(10), ms(100), 0),
+			nil,
+			factory.NewPhaseTiming(ms(40), ms(20), ms(400), 0),
+			factory.NewPhaseTiming(ms(20), ms(90), ms(200), 0),
+		)
+		assert.Equal(t, PhaseTimeouts{
+			ConnectDelay: 60 * time.Millisecond,  // median 30ms * 2
+			Propose:      50 * time.Millisecond,  // median 20ms * 2, raised to min
+			Vote:         300 * time.Millisecond, // median 200ms * 2, lowered to max
+			PreCommit:    200 * time.Millisecond, // no timing
+		}, NewPhaseTimeouts(newConfig(), bc, 6))
+	})
+}
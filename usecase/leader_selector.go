@@ -0,0 +1,174 @@
+package usecase
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"github.com/satellitex/bbft/config"
+	"github.com/satellitex/bbft/dba"
+	"github.com/satellitex/bbft/model"
+	"sort"
+)
+
+const (
+	LeaderSelectorRoundRobin = "round_robin"
+	LeaderSelectorHash       = "hash"
+	LeaderSelectorStake      = "stake"
+)
+
+// LeaderSelector は height, round のリーダーを height で有効な Peer の中から決める。
+// 全ての Peer が同じ結果を得られるように、決定的でなければならない。
+type LeaderSelector interface {
+	model.LeaderSelector
+}
+
+// NewLeaderSelector は conf.LeaderSelector に従って LeaderSelector を返す。不明な値のときは hash を使う。
+// hotstuff は親の Block を Commit する前に子の height のリーダーを決めるので、前の Block を使わない roundrobin を使う。
+func NewLeaderSelector(conf *config.BBFTConfig, ps dba.PeerService, bc dba.BlockChain) LeaderSelector {
+	if conf.ConsensusEngine == ConsensusEngineHotStuff {
+		return NewRoundRobinLeaderSelector(ps)
+	}
+	switch conf.LeaderSelector {
+	case LeaderSelectorRoundRobin:
+		return NewRoundRobinLeaderSelector(ps)
+	case LeaderSelectorStake:
+		return NewStakeWeightedLeaderSelector(conf, ps, bc)
+	}
+	return NewHashLeaderSelector(ps, bc)
+}
+
+// RoundRobinLeaderSelector は Address 順に並べた Peer から (height + round) 番目をリーダーにする
+type RoundRobinLeaderSelector struct {
+	ps dba.PeerService
+}
+
+func NewRoundRobinLeaderSelector(ps dba.PeerService) LeaderSelector {
+	return &RoundRobinLeaderSelector{ps}
+}
+
+func (s *RoundRobinLeaderSelector) GetLeader(height int64, round int32) (model.Peer, bool) {
+	if height < 0 || round < 0 {
+		return nil, false
+	}
+	peers := s.ps.AtHeight(height).GetPeers()
+	if len(peers) == 0 {
+		return nil, false
+	}
+	n := uint64(len(peers))
+	return peers[(uint64(height)%n+uint64(round)%n)%n], true
+}
+
+// HashLeaderSelector は height-1 の Block の Hash を seed にした Peer の順列から round 番目をリーダーにする。
+// 順列は sha256(seed || height || pubkey) の順で決まるので、Go の version に依存しない。
+// height-1 の Block をまだ Commit していないときはリーダーを決められない。
+type HashLeaderSelector struct {
+	ps dba.PeerService
+	bc dba.BlockChain
+}
+
+func NewHashLeaderSelector(ps dba.PeerService, bc dba.BlockChain) LeaderSelector {
+	return &HashLeaderSelector{ps, bc}
+}
+
+func (s *HashLeaderSelector) GetLeader(height int64, round int32) (model.Peer, bool) {
+	if height < 0 || round < 0 {
+		return nil, false
+	}
+	peers, ok := s.GetPermutationPeers(height)
+	if !ok || len(peers) == 0 {
+		return nil, false
+	}
+	return peers[int(round)%len(peers)], true
+}
+
+func (s *HashLeaderSelector) GetPermutationPeers(height int64) ([]model.Peer, bool) {
+	seed, ok := leaderSeed(s.bc, height)
+	if !ok {
+		return nil, false
+	}
+	peers := s.ps.AtHeight(height).GetPeers()
+	keys := make(map[string][]byte, len(peers))
+	for _, peer := range peers {
+		keys[string(peer.GetPubkey())] = leaderHash(seed, peer.GetPubkey())
+	}
+	sort.SliceStable(peers, func(i, j int) bool {
+		return bytes.Compare(keys[string(peers[i].GetPubkey())], keys[string(peers[j].GetPubkey())]) < 0
+	})
+	return peers, true
+}
+
+// StakeWeightedLeaderSelector は conf.LeaderStakes または voting power の重みに比例した確率でリーダーを選ぶ。
+// 乱数は sha256(seed || height || round) から作るので、全ての Peer で同じ結果になる。
+// HashLeaderSelector と同じく height-1 の Block をまだ Commit していないときはリーダーを決められない。
+type StakeWeightedLeaderSelector struct {
+	conf *config.BBFTConfig
+	ps   dba.PeerService
+	bc   dba.BlockChain
+}
+
+func NewStakeWeightedLeaderSelector(conf *config.BBFTConfig, ps dba.PeerService, bc dba.BlockChain) LeaderSelector {
+	return &StakeWeightedLeaderSelector{conf, ps, bc}
+}
+
+// GetStake は peer の重みを返す。conf.LeaderStakes に無い Peer の重みは Peer の voting power である。
+func (s *StakeWeightedLeaderSelector) GetStake(peer model.Peer) uint64 {
+	stake, ok := s.conf.LeaderStakes[hex.EncodeToString(peer.GetPubkey())]
+	if !ok {
+		stake = peer.GetPower()
+	}
+	if stake < 0 {
+		return 0
+	}
+	return uint64(stake)
+}
+
+func (s *StakeWeightedLeaderSelector) GetLeader(height int64, round int32) (model.Peer, bool) {
+	if height < 0 || round < 0 {
+		return nil, false
+	}
+	seed, ok := leaderSeed(s.bc, height)
+	if !ok {
+		return nil, false
+	}
+	peers := s.ps.AtHeight(height).GetPeers()
+	var total uint64
+	for _, peer := range peers {
+		total += s.GetStake(peer)
+	}
+	if total == 0 {
+		return nil, false
+	}
+
+	roundBytes := make([]byte, 4)
+	binary.BigEndian.PutUint32(roundBytes, uint32(round))
+	target := binary.BigEndian.Uint64(leaderHash(seed, roundBytes)) % total
+	for _, peer := range peers {
+		stake := s.GetStake(peer)
+		if target < stake {
+			return peer, true
+		}
+		target -= stake
+	}
+	return nil, false
+}
+
+// leaderSeed は height-1 の Block の Hash と height を繋げたものを返す。height 0 は前の Block が無いので height だけを使う。
+// height-1 の Block をまだ持っていないときは、他の Peer と同じ seed を作れないので false を返す。
+func leaderSeed(bc dba.BlockChain, height int64) ([]byte, bool) {
+	seed := make([]byte, 8)
+	binary.BigEndian.PutUint64(seed, uint64(height))
+	if height == 0 {
+		return seed, true
+	}
+	prev, ok := bc.GetBlock(height - 1)
+	if !ok {
+		return nil, false
+	}
+	return append(model.MustGetHash(prev), seed...), true
+}
+
+func leaderHash(seed []byte, value []byte) []byte {
+	hash := sha256.Sum256(append(append([]byte{}, seed...), value...))
+	return hash[:]
+}
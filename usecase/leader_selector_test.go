@@ -0,0 +1,232 @@
+package usecase_test
+
+import (
+	"encoding/hex"
+	"github.com/satellitex/bbft/convertor"
+	"github.com/satellitex/bbft/dba"
+	"github.com/satellitex/bbft/model"
+	. "github.com/satellitex/bbft/test_utils"
+	. "github.com/satellitex/bbft/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// testLeaderSelector は [0, maxHeight] の height でリーダーが決まることを確かめる
+func testLeaderSelector(t *testing.T, ps dba.PeerService, selector LeaderSelector, maxHeight int64) {
+	size := int32(ps.Size())
+
+	t.Run("same height and round, same leader", func(t *testing.T) {
+		for i := 0; i < 20; i++ {
+			height := rand.Int63() % (maxHeight + 1)
+			round := rand.Int31()
+			leader, ok := selector.GetLeader(height, round)
+			require.True(t, ok)
+			_, ok = ps.GetPeer(leader.GetPubkey())
+			assert.True(t, ok)
+
+			leader2, ok := selector.GetLeader(height, round)
+			require.True(t, ok)
+			assert.Equal(t, leader, leader2)
+		}
+	})
+
+	t.Run("large round", func(t *testing.T) {
+		for _, round := range []int32{size - 1, size, size*3 + 2, math.MaxInt32} {
+			_, ok := selector.GetLeader(1, round)
+			assert.True(t, ok)
+		}
+		_, ok := selector.GetLeader(maxHeight, math.MaxInt32)
+		assert.True(t, ok)
+	})
+
+	t.Run("negative height or round", func(t *testing.T) {
+		_, ok := selector.GetLeader(1, -1)
+		assert.False(t, ok)
+		_, ok = selector.GetLeader(-1, 0)
+		assert.False(t, ok)
+	})
+}
+
+func TestRoundRobinLeaderSelector(t *testing.T) {
+	ps := RandomPeerService(t, 4)
+	selector := NewRoundRobinLeaderSelector(ps)
+	testLeaderSelector(t, ps, selector, math.MaxInt64-1)
+
+	t.Run("rotate in address order", func(t *testing.T) {
+		peers := ps.GetPeers()
+		for height := int64(0); height < 8; height++ {
+			for round := int32(0); round < 8; round++ {
+				leader, ok := selector.GetLeader(height, round)
+				require.True(t, ok)
+				assert.Equal(t, peers[(height+int64(round))%4], leader)
+			}
+		}
+	})
+
+	t.Run("empty peerService", func(t *testing.T) {
+		_, ok := NewRoundRobinLeaderSelector(dba.NewPeerServiceOnMemory()).GetLeader(0, 0)
+		assert.False(t, ok)
+	})
+
+	t.Run("switch validators at activated height", func(t *testing.T) {
+		ps := RandomPeerService(t, 4)
+		selector := NewRoundRobinLeaderSelector(ps)
+		before := ps.GetPeers()
+		added := RandomPeer()
+		update := convertor.NewModelFactory().NewValidatorUpdate(model.AddValidator, added.GetAddress(), added.GetPubkey(), nil, 1)
+		require.NoError(t, ps.Update(10, []model.ValidatorUpdate{update}))
+		after := ps.AtHeight(10).GetPeers()
+		require.Len(t, after, 5)
+
+		for height := int64(6); height < 14; height++ {
+			leader, ok := selector.GetLeader(height, 0)
+			require.True(t, ok)
+			if height < 10 {
+				assert.Equal(t, before[height%4], leader)
+			} else {
+				assert.Equal(t, after[height%5], leader)
+			}
+		}
+	})
+}
+
+func TestHashLeaderSelector(t *testing.T) {
+	ps := RandomPeerService(t, 4)
+	bc := dba.NewBlockChainOnMemory()
+	bc.Commit(RandomCommitableBlock(t, bc), nil)
+	selector := NewHashLeaderSelector(ps, bc)
+	testLeaderSelector(t, ps, selector, 1)
+
+	t.Run("every peer is leader once in a permutation", func(t *testing.T) {
+		permutation, ok := selector.(*HashLeaderSelector).GetPermutationPeers(1)
+		require.True(t, ok)
+		assert.ElementsMatch(t, ps.GetPeers(), permutation)
+		for round := int32(0); round < 12; round++ {
+			leader, ok := selector.GetLeader(1, round)
+			require.True(t, ok)
+			assert.Equal(t, permutation[round%4], leader)
+		}
+	})
+
+	t.Run("permutation depends on previous block", func(t *testing.T) {
+		differ := false
+		for i := 0; i < 20 && !differ; i++ {
+			otherBc := dba.NewBlockChainOnMemory()
+			otherBc.Commit(RandomCommitableBlock(t, otherBc), nil)
+			other := NewHashLeaderSelector(ps, otherBc)
+			expected, _ := selector.(*HashLeaderSelector).GetPermutationPeers(1)
+			actual, _ := other.(*HashLeaderSelector).GetPermutationPeers(1)
+			differ = !assert.ObjectsAreEqual(expected, actual)
+		}
+		assert.True(t, differ)
+	})
+
+	t.Run("empty peerService", func(t *testing.T) {
+		_, ok := NewHashLeaderSelector(dba.NewPeerServiceOnMemory(), bc).GetLeader(1, 0)
+		assert.False(t, ok)
+	})
+
+	testUnknownPreviousBlock(t, ps, func(bc dba.BlockChain) LeaderSelector {
+		return NewHashLeaderSelector(ps, bc)
+	})
+}
+
+// 前の Block を持っていない height のリーダーは決めない。genesis の height 0 は前の Block 無しで決まる。
+func testUnknownPreviousBlock(t *testing.T, ps dba.PeerService, newSelector func(bc dba.BlockChain) LeaderSelector) {
+	t.Run("unknown previous block", func(t *testing.T) {
+		bc := dba.NewBlockChainOnMemory()
+		selector := newSelector(bc)
+		_, ok := selector.GetLeader(0, 0)
+		assert.True(t, ok)
+		_, ok = selector.GetLeader(1, 0)
+		assert.False(t, ok)
+
+		bc.Commit(RandomCommitableBlock(t, bc), nil)
+		_, ok = selector.GetLeader(1, 0)
+		assert.True(t, ok)
+		_, ok = selector.GetLeader(2, 0)
+		assert.False(t, ok)
+	})
+}
+
+func TestStakeWeightedLeaderSelector(t *testing.T) {
+	conf := GetTestConfig()
+	ps := RandomPeerService(t, 4)
+	bc := dba.NewBlockChainOnMemory()
+	bc.Commit(RandomCommitableBlock(t, bc), nil)
+	selector := NewStakeWeightedLeaderSelector(conf, ps, bc)
+	testLeaderSelector(t, ps, selector, 1)
+
+	peers := ps.GetPeers()
+	conf.LeaderStakes = map[string]int64{
+		hex.EncodeToString(peers[0].GetPubkey()): 0,
+		hex.EncodeToString(peers[1].GetPubkey()): -5,
+		hex.EncodeToString(peers[2].GetPubkey()): 1000,
+	}
+
+	t.Run("leader is chosen by stake", func(t *testing.T) {
+		counts := make(map[string]int)
+		for round := int32(0); round < 1000; round++ {
+			leader, ok := selector.GetLeader(1, round)
+			require.True(t, ok)
+			counts[leader.GetAddress()]++
+		}
+		assert.Equal(t, 0, counts[peers[0].GetAddress()])
+		assert.Equal(t, 0, counts[peers[1].GetAddress()])
+		assert.True(t, counts[peers[2].GetAddress()] > counts[peers[3].GetAddress()])
+	})
+
+	t.Run("all stake is zero", func(t *testing.T) {
+		for _, peer := range peers {
+			conf.LeaderStakes[hex.EncodeToString(peer.GetPubkey())] = 0
+		}
+		_, ok := selector.GetLeader(1, 0)
+		assert.False(t, ok)
+	})
+
+	t.Run("stake is voting power if not configured", func(t *testing.T) {
+		ps := dba.NewPeerServiceOnMemory()
+		ps.AddPeer(RandomPeerWithPower(0))
+		ps.AddPeer(RandomPeerWithPower(3))
+		selector := NewStakeWeightedLeaderSelector(GetTestConfig(), ps, bc)
+		for round := int32(0); round < 100; round++ {
+			leader, ok := selector.GetLeader(1, round)
+			require.True(t, ok)
+			assert.Equal(t, int64(3), leader.GetPower())
+		}
+	})
+
+	testUnknownPreviousBlock(t, ps, func(bc dba.BlockChain) LeaderSelector {
+		return NewStakeWeightedLeaderSelector(GetTestConfig(), ps, bc)
+	})
+}
+
+func TestNewLeaderSelector(t *testing.T) {
+	conf := GetTestConfig()
+	ps := RandomPeerService(t, 4)
+	bc := dba.NewBlockChainOnMemory()
+
+	for _, c := range []struct {
+		name     string
+		expected LeaderSelector
+	}{
+		{LeaderSelectorRoundRobin, &RoundRobinLeaderSelector{}},
+		{LeaderSelectorHash, &HashLeaderSelector{}},
+		{LeaderSelectorStake, &StakeWeightedLeaderSelector{}},
+		{"unknown", &HashLeaderSelector{}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			conf.LeaderSelector = c.name
+			assert.IsType(t, c.expected, NewLeaderSelector(conf, ps, bc))
+		})
+	}
+
+	t.Run("hotstuff uses roundrobin", func(t *testing.T) {
+		conf.LeaderSelector = LeaderSelectorHash
+		conf.ConsensusEngine = ConsensusEngineHotStuff
+		assert.IsType(t, &RoundRobinLeaderSelector{}, NewLeaderSelector(conf, ps, bc))
+	})
+}
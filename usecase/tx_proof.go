@@ -0,0 +1,42 @@
+package usecase
+
+import (
+	"github.com/pkg/errors"
+	"github.com/satellitex/bbft/dba"
+	"github.com/satellitex/bbft/model"
+)
+
+var (
+	ErrTxProofNotFound = errors.New("Failed TxProof Transaction Not Found")
+)
+
+type TxProofReceiver interface {
+	// GetTxProof は Hash が txHash の Commit 済みの Transaction が Block に含まれることの TxProof を返す
+	GetTxProof(txHash []byte) (model.TxProof, error)
+}
+
+type TxProofReceiverUsecase struct {
+	bc      dba.BlockChain
+	factory model.ModelFactory
+}
+
+func NewTxProofReceiverUsecase(bc dba.BlockChain, factory model.ModelFactory) TxProofReceiver {
+	return &TxProofReceiverUsecase{
+		bc:      bc,
+		factory: factory,
+	}
+}
+
+func (r *TxProofReceiverUsecase) GetTxProof(txHash []byte) (model.TxProof, error) {
+	height, ok := r.bc.FindTxHeight(txHash)
+	if !ok { // NotFound (code = 5)
+		return nil, errors.Wrapf(ErrTxProofNotFound, "tx: %x", txHash)
+	}
+	block, ok := r.bc.GetBlock(height)
+	if !ok {
+		return nil, errors.Wrapf(ErrTxProofNotFound, "block of height: %d", height)
+	}
+	// genesis block has no CommitCertificate
+	cert, _ := r.bc.GetCommitCertificate(height)
+	return r.factory.NewTxProof(block, txHash, cert)
+}
@@ -0,0 +1,48 @@
+package usecase_test
+
+import (
+	"github.com/pkg/errors"
+	"github.com/satellitex/bbft/convertor"
+	"github.com/satellitex/bbft/dba"
+	. "github.com/satellitex/bbft/test_utils"
+	. "github.com/satellitex/bbft/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestTxProofReceiverUsecase_GetTxProof(t *testing.T) {
+	ps := RandomPeerService(t, 4)
+	cv := convertor.NewCommitCertificateValidator(GetTestConfig(), ps)
+	bc := dba.NewBlockChainOnMemory()
+	genesis := RandomCommitableBlock(t, bc)
+	bc.Commit(genesis, nil)
+	for i := 1; i < 3; i++ {
+		block := RandomCommitableBlock(t, bc)
+		bc.Commit(block, RandomCommitCertificate(t, block, ps.GetPeers()))
+	}
+	receiver := NewTxProofReceiverUsecase(bc, convertor.NewModelFactory())
+
+	t.Run("success case", func(t *testing.T) {
+		block, ok := bc.GetBlock(2)
+		require.True(t, ok)
+		for _, tx := range block.GetTransactions() {
+			proof, err := receiver.GetTxProof(GetHash(t, tx))
+			require.NoError(t, err)
+			assert.Equal(t, int64(2), proof.GetHeader().GetHeight())
+			assert.NoError(t, cv.ValidateTxProof(proof))
+		}
+	})
+
+	t.Run("success case, genesis block has no CommitCertificate", func(t *testing.T) {
+		proof, err := receiver.GetTxProof(GetHash(t, genesis.GetTransactions()[0]))
+		require.NoError(t, err)
+		assert.Nil(t, proof.GetCommitCertificate())
+		assert.NoError(t, proof.Verify())
+	})
+
+	t.Run("failed case, not committed tx", func(t *testing.T) {
+		_, err := receiver.GetTxProof(GetHash(t, RandomValidTx(t)))
+		assert.EqualError(t, errors.Cause(err), ErrTxProofNotFound.Error())
+	})
+}
@@ -0,0 +1,25 @@
+package usecase
+
+import (
+	"github.com/pkg/errors"
+	"github.com/satellitex/bbft/config"
+	"github.com/satellitex/bbft/dba"
+	"github.com/satellitex/bbft/model"
+)
+
+// commitValidatorUpdates は Commit した block の ValidatorUpdate を height + ValidatorUpdateDelay から有効にし、
+// PeerService の現在の height を次の height に進める
+// Consensus と BlockSync のどちらで Commit しても全ての Peer が同じ height で Peer の集合を切り替える
+func commitValidatorUpdates(conf *config.BBFTConfig, ps dba.PeerService, block model.Block) error {
+	height := block.GetHeader().GetHeight()
+	defer ps.SetHeight(height + 1)
+
+	updates := model.GetValidatorUpdates(block.GetTransactions())
+	if len(updates) == 0 {
+		return nil
+	}
+	if err := ps.Update(height+conf.ValidatorUpdateDelay, updates); err != nil {
+		return errors.Wrapf(dba.ErrPeerServiceUpdate, err.Error())
+	}
+	return nil
+}